@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/AndreasM009/eventstore/pkg/esctl"
+)
+
+const usage = `Usage:
+  esctl export -store <name> -out <file> [-endpoint <url>] [-token <token>] [-resume]
+  esctl import -store <name> -in <file> [-endpoint <url>] [-token <token>] [-dryrun] [-batch <lines>]
+
+The token defaults to the environment variable EVENTSTORE_API_TOKEN.
+`
+
+const tokenEnv = "EVENTSTORE_API_TOKEN"
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	var err error
+
+	switch os.Args[1] {
+	case "export":
+		err = export(os.Args[2:])
+	case "import":
+		err = importArchive(os.Args[2:])
+	default:
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	endpoint := flags.String("endpoint", "http://localhost:5000", "Endpoint of eventstored")
+	store := flags.String("store", "", "Name of the eventstore to export")
+	out := flags.String("out", "", "Path of the gzip compressed NDJSON archive")
+	token := flags.String("token", os.Getenv(tokenEnv), "API token of eventstored")
+	resume := flags.Bool("resume", false, "Continue an interrupted export")
+	flags.Parse(args) // nolint: errcheck
+
+	if *store == "" || *out == "" {
+		return fmt.Errorf("store and out are required")
+	}
+
+	lines, err := esctl.NewClient(*endpoint).WithToken(*token).Export(*store, *out, *resume)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("exported %d entity versions to %s\n", lines, *out)
+	return nil
+}
+
+func importArchive(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	endpoint := flags.String("endpoint", "http://localhost:5000", "Endpoint of eventstored")
+	store := flags.String("store", "", "Name of the eventstore to import into")
+	in := flags.String("in", "", "Path of the gzip compressed NDJSON archive")
+	token := flags.String("token", os.Getenv(tokenEnv), "API token of eventstored")
+	dryRun := flags.Bool("dryrun", false, "Validate the archive without importing it")
+	batch := flags.Int("batch", esctl.DefaultBatchSize, "Number of entity versions per request")
+	flags.Parse(args) // nolint: errcheck
+
+	if *store == "" || *in == "" {
+		return fmt.Errorf("store and in are required")
+	}
+
+	summary, err := esctl.NewClient(*endpoint).WithToken(*token).Import(*store, *in, *dryRun, *batch)
+	if err != nil {
+		return err
+	}
+
+	if summary.DryRun {
+		fmt.Printf("validated %d lines, %d entity versions would be imported, %d exist already\n", summary.Lines, summary.Imported, summary.Skipped)
+	} else {
+		fmt.Printf("imported %d entity versions, %d existed already\n", summary.Imported, summary.Skipped)
+	}
+
+	return nil
+}
@@ -22,7 +22,7 @@ func main() {
 	}
 
 	// Block, until SIGINT (Ctrl+C)
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 
 	// Block until we receive our signal.
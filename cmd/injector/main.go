@@ -17,7 +17,7 @@ func main() {
 	kubeClient := factories.CreateKubeClient()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	sigchannel := make(chan os.Signal)
+	sigchannel := make(chan os.Signal, 1)
 	signal.Notify(sigchannel, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 
 	i := injector.NewInjector()
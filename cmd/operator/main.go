@@ -3,27 +3,44 @@ package main
 import (
 	"context"
 	"flag"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	eventstorev1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
 	eventstoreclient "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned"
 	"github.com/AndreasM009/eventstore/pkg/factories"
 	"github.com/AndreasM009/eventstore/pkg/operator"
 	"github.com/AndreasM009/eventstore/pkg/operator/http"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 )
 
+// namespaceFile holds the namespace of the pod in the cluster
+const namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
 func main() {
 	apiPortFlags := flag.Int("port", 5000, "api server's port")
+	election := operator.NewLeaderElection(defaultNamespace())
+	flag.BoolVar(&election.Enabled, "leader-elect", true, "elect a leader among the replicas to run the informers and workers")
+	flag.StringVar(&election.Namespace, "leader-election-namespace", election.Namespace, "namespace of the leader election lease")
+	flag.StringVar(&election.Name, "leader-election-name", election.Name, "name of the leader election lease")
+	flag.DurationVar(&election.LeaseDuration, "leader-election-lease-duration", election.LeaseDuration, "time replicas wait before taking over the lease of a leader")
+	flag.DurationVar(&election.RenewDeadline, "leader-election-renew-deadline", election.RenewDeadline, "time the leader retries renewing its lease before it stops leading")
+	flag.DurationVar(&election.RetryPeriod, "leader-election-retry-period", election.RetryPeriod, "time between attempts to acquire or renew the lease")
+	conversionCAFlag := flag.String("conversion-ca-bundle", "", "file of the CA of the conversion webhook's certificate, v1beta1 Eventstores are served only with it")
+	conversionServiceFlag := flag.String("conversion-service", "eventstore-injector", "service of the conversion webhook")
+	conversionNamespaceFlag := flag.String("conversion-namespace", defaultNamespace(), "namespace of the service of the conversion webhook")
 	flag.Parse()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	sigchannel := make(chan os.Signal)
+	sigchannel := make(chan os.Signal, 1)
 	signal.Notify(sigchannel, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 
 	config := factories.CreateKubeConfig()
@@ -41,30 +58,71 @@ func main() {
 		return
 	}
 
-	operator := operator.NewOperator(eventStoreClient, kubeClient, extensionClient)
+	op := operator.NewOperator(eventStoreClient, kubeClient, extensionClient)
 
-	err = operator.InitCustomResourceDefinitions()
-	if err != nil {
-		log.Printf("Error creating CustomResoiurceDefinition: %s\n", err)
-		return
-	}
+	var conversion *apiextensionsv1.WebhookClientConfig
 
-	done, err := operator.Run(ctx)
-	if err != nil {
-		log.Println("Failed to start operator")
-		return
+	if *conversionCAFlag != "" {
+		caBundle, err := ioutil.ReadFile(*conversionCAFlag)
+		if err != nil {
+			log.Printf("Failed to read CA bundle of the conversion webhook: %s\n", err)
+			os.Exit(1)
+		}
+
+		conversion = eventstorev1alpha1.ConversionWebhookClientConfig(*conversionNamespaceFlag, *conversionServiceFlag, caBundle)
 	}
 
-	server := http.NewServer(*apiPortFlags, eventStoreClient)
+	// sidecars watch the Eventstores through the api server of every replica
+	informer := operator.NewEventstoreInformer(eventStoreClient)
+	watches := http.NewWatches(informer)
+
+	go informer.Run(ctx.Done())
+
+	// the api server has no authentication, so it serves Eventstores without secret values, they
+	// are pushed to the sidecars only
+	server := http.NewServer(*apiPortFlags, eventStoreClient, watches)
 
 	server.StartNonBlocking()
 
+	done := make(chan error, 1)
+
+	go func() {
+		// only the leader initializes the CustomResourceDefinitions and runs the informers and
+		// workers of the operator
+		done <- operator.RunLeaderElected(ctx, kubeClient, election, op, conversion)
+	}()
+
 	select {
 	case <-sigchannel:
-		cancel()
 		log.Println("Operator received stop signal")
-		<-time.After(2 * time.Second)
-	case <-done:
+		// the leader releases its lease, another replica takes over without waiting for it to expire
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(election.RenewDeadline):
+		}
+	case err := <-done:
 		cancel()
+
+		if err != nil {
+			log.Printf("Operator stopped: %s\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// defaultNamespace returns the namespace of the pod, if the operator runs in a cluster
+func defaultNamespace() string {
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
 	}
+
+	if data, err := ioutil.ReadFile(namespaceFile); err == nil {
+		if ns := strings.TrimSpace(string(data)); ns != "" {
+			return ns
+		}
+	}
+
+	return "default"
 }
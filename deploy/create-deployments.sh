@@ -54,10 +54,11 @@ openssl genrsa -out ${output}/eventstore-injector-tls.key 2048
 openssl req -new -key ${output}/${serviceInjector}-tls.key -subj "/CN=${serviceInjector}.${namespace}.svc" \
     | openssl x509 -req -CA ${output}/ca.crt -CAkey ${output}/ca.key -CAcreateserial -out ${output}/${serviceInjector}-tls.crt
 
-# create the secret with CA cert and server cert/key
+# create the secret with CA cert and server cert/key, the operator registers the CA for the conversion webhook
 kubectl create secret generic ${secretInjector} -n ${namespace} \
         --from-file=key.key=${output}/${serviceInjector}-tls.key \
         --from-file=cert.crt=${output}/${serviceInjector}-tls.crt \
+        --from-file=ca.crt=${output}/ca.crt \
         --dry-run -o yaml > ${resultInjectorSecret}
     #kubectl -n ${namespace} apply -f -
 
@@ -5,6 +5,9 @@ go 1.14
 require (
 	github.com/AdhityaRamadhanus/fasthttpcors v0.0.0-20170121111917-d4c07198763a
 	github.com/AndreasM009/eventstore-impl v0.0.0-20200618080406-827b7b46c386
+	github.com/Azure/azure-sdk-for-go v40.5.0+incompatible
+	github.com/Shopify/sarama v1.26.4
+	github.com/evanphx/json-patch v4.2.0+incompatible
 	github.com/go-ozzo/ozzo-routing v2.1.4+incompatible // indirect
 	github.com/golang/gddo v0.0.0-20200324184333-3c2cc9a6329d // indirect
 	github.com/json-iterator/go v1.1.9 // indirect
@@ -12,6 +15,7 @@ require (
 	github.com/qiangxue/fasthttp-routing v0.0.0-20160225050629-6ccdc2a18d87
 	github.com/stretchr/testify v1.5.1
 	github.com/valyala/fasthttp v1.9.0
+	go.starlark.net v0.0.0-20200821142938-949cc6f4b097
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d // indirect
 	golang.org/x/time v0.0.0-20191024005414-555d28b269f0 // indirect
 	gopkg.in/yaml.v2 v2.2.8
@@ -0,0 +1,413 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1beta1"
+)
+
+// Types of the backends and their metadata keys, as the registry of the sidecars reads them
+const (
+	inMemoryType          = "eventstore.inmemory"
+	azureTableStorageType = "eventstore.azure.tablestorage"
+	cosmosDBType          = "eventstore.azure.cosmosdb"
+	chaosType             = "eventstore.chaos"
+
+	storageAccountNameKey = "storageAccountName"
+	storageAccountKeyKey  = "storageAccountKey"
+	urlKey                = "url"
+	masterKeyKey          = "masterKey"
+	databaseKey           = "database"
+	containerKey          = "container"
+
+	chaosTargetKey        = "chaosTarget"
+	chaosLatencyKey       = "chaosLatency"
+	chaosErrorRateKey     = "chaosErrorRate"
+	chaosConflictRateKey  = "chaosConflictRate"
+	chaosStaleReadRateKey = "chaosStaleReadRate"
+)
+
+// Annotations of v1beta1 Eventstores that keep the types of backends v1beta1 has no provider for,
+// so they survive a round trip through v1beta1
+const (
+	TypeAnnotation          = "eventstore.io/v1alpha1-type"
+	MigrationTypeAnnotation = "eventstore.io/v1alpha1-migration-type"
+)
+
+// ConvertTo converts es to the v1beta1 version of the API. The metadata of the backend that v1beta1
+// has a field for is moved into its provider section, the rest is kept as options.
+func (es *Eventstore) ConvertTo(dst *v1beta1.Eventstore) error {
+	dst.TypeMeta = es.TypeMeta
+	dst.APIVersion = v1beta1.SchemeGroupVersion.String()
+	es.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+
+	dst.Status = v1beta1.EventstoreStatus{
+		ObservedGeneration: es.Status.ObservedGeneration,
+		Ready:              es.Status.Ready,
+		Sidecars:           es.Status.Sidecars,
+		Message:            es.Status.Message,
+	}
+
+	backend, options, ok := toBackend(es.Spec.Type, es.Spec.Metadata)
+	dst.Spec = v1beta1.EventstoreSpec{
+		Backend: backend,
+		Options: options,
+	}
+
+	if !ok {
+		setAnnotation(dst, TypeAnnotation, es.Spec.Type)
+	}
+
+	if es.Spec.Sink != nil {
+		dst.Spec.Sink = &v1beta1.SinkSpec{
+			Type:     es.Spec.Sink.Type,
+			Metadata: toMetadata(es.Spec.Sink.Metadata),
+		}
+	}
+
+	for _, p := range es.Spec.Projections {
+		dst.Spec.Projections = append(dst.Spec.Projections, v1beta1.ProjectionSpec{
+			Name:     p.Name,
+			Type:     p.Type,
+			Metadata: toMetadata(p.Metadata),
+		})
+	}
+
+	if es.Spec.Migration != nil {
+		backend, options, ok := toBackend(es.Spec.Migration.Type, es.Spec.Migration.Metadata)
+		dst.Spec.Migration = &v1beta1.MigrationSpec{
+			Backend: backend,
+			Options: options,
+			Stage:   es.Spec.Migration.Stage,
+		}
+
+		if !ok {
+			setAnnotation(dst, MigrationTypeAnnotation, es.Spec.Migration.Type)
+		}
+	}
+
+	return nil
+}
+
+// ConvertFrom converts src from the v1beta1 version of the API to es. The fields of the provider
+// section become metadata of the backend, followed by the options.
+func (es *Eventstore) ConvertFrom(src *v1beta1.Eventstore) error {
+	es.TypeMeta = src.TypeMeta
+	es.APIVersion = SchemeGroupVersion.String()
+	src.ObjectMeta.DeepCopyInto(&es.ObjectMeta)
+
+	es.Status = EventstoreStatus{
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Ready:              src.Status.Ready,
+		Sidecars:           src.Status.Sidecars,
+		Message:            src.Status.Message,
+	}
+
+	typ, metadata, err := fromBackend(src.Spec.Backend, popAnnotation(es, TypeAnnotation))
+	if err != nil {
+		return fmt.Errorf("spec: %s", err)
+	}
+
+	es.Spec = EventstoreSpec{
+		Type:     typ,
+		Metadata: append(metadata, fromMetadata(src.Spec.Options)...),
+	}
+
+	if src.Spec.Sink != nil {
+		es.Spec.Sink = &SinkSpec{
+			Type:     src.Spec.Sink.Type,
+			Metadata: fromMetadata(src.Spec.Sink.Metadata),
+		}
+	}
+
+	for _, p := range src.Spec.Projections {
+		es.Spec.Projections = append(es.Spec.Projections, ProjectionSpec{
+			Name:     p.Name,
+			Type:     p.Type,
+			Metadata: fromMetadata(p.Metadata),
+		})
+	}
+
+	migrationType := popAnnotation(es, MigrationTypeAnnotation)
+
+	if src.Spec.Migration != nil {
+		typ, metadata, err := fromBackend(src.Spec.Migration.Backend, migrationType)
+		if err != nil {
+			return fmt.Errorf("spec.migration: %s", err)
+		}
+
+		es.Spec.Migration = &MigrationSpec{
+			Type:     typ,
+			Metadata: append(metadata, fromMetadata(src.Spec.Migration.Options)...),
+			Stage:    src.Spec.Migration.Stage,
+		}
+	}
+
+	return nil
+}
+
+// metadataItems are the metadata of a backend not yet moved into a field of its provider
+type metadataItems []MetadataItem
+
+// take removes the first item named name and returns its value
+func (items *metadataItems) take(name string) (v1beta1.SecretValue, bool) {
+	for i, m := range *items {
+		if m.Name == name {
+			*items = append((*items)[:i:i], (*items)[i+1:]...)
+			return toSecretValue(m), true
+		}
+	}
+
+	return v1beta1.SecretValue{}, false
+}
+
+// value returns the value of the first item named name, ok is false if it's missing, empty or read
+// from a secret
+func (items metadataItems) value(name string) (string, bool) {
+	for _, m := range items {
+		if m.Name == name {
+			return m.Value, m.Value != "" && m.SecretKeyRef.Name == "" && m.SecretKeyRef.Key == ""
+		}
+	}
+
+	return "", false
+}
+
+// takeValue removes the first item named name, if it's set literally, and returns its value
+func (items *metadataItems) takeValue(name string) string {
+	value, ok := items.value(name)
+	if !ok {
+		return ""
+	}
+
+	items.take(name)
+	return value
+}
+
+// takeBackend moves the metadata of a backend of type typ into its provider section, ok is false if
+// v1beta1 has no provider for typ
+func (items *metadataItems) takeBackend(typ string) (backend v1beta1.Backend, ok bool) {
+	switch typ {
+	case "":
+	case inMemoryType:
+		backend.InMemory = &v1beta1.InMemoryBackend{}
+	case azureTableStorageType:
+		s := &v1beta1.AzureTableStorageBackend{}
+		s.AccountName, _ = items.take(storageAccountNameKey)
+		s.AccountKey, _ = items.take(storageAccountKeyKey)
+		backend.AzureTableStorage = s
+	case cosmosDBType:
+		s := &v1beta1.CosmosDBBackend{}
+		s.URL, _ = items.take(urlKey)
+		s.MasterKey, _ = items.take(masterKeyKey)
+		s.Database, _ = items.take(databaseKey)
+		s.Container, _ = items.take(containerKey)
+		backend.CosmosDB = s
+	case chaosType:
+		s := &v1beta1.ChaosBackend{
+			Latency:       items.takeValue(chaosLatencyKey),
+			ErrorRate:     items.takeValue(chaosErrorRateKey),
+			ConflictRate:  items.takeValue(chaosConflictRateKey),
+			StaleReadRate: items.takeValue(chaosStaleReadRateKey),
+		}
+
+		for _, m := range append(metadataItems{}, *items...) {
+			op := strings.TrimPrefix(m.Name, chaosErrorRateKey+".")
+			if _, seen := s.OperationErrorRates[op]; seen || op == m.Name || op == "" {
+				continue
+			}
+
+			if value := items.takeValue(m.Name); value != "" {
+				if s.OperationErrorRates == nil {
+					s.OperationErrorRates = map[string]string{}
+				}
+
+				s.OperationErrorRates[op] = value
+			}
+		}
+
+		// the target and its metadata stay in the options, if it has no provider
+		if target, ok := items.value(chaosTargetKey); ok && target != chaosType {
+			rest := append(metadataItems{}, *items...)
+			rest.take(chaosTargetKey)
+
+			if targetBackend, ok := rest.takeBackend(target); ok {
+				s.Target = targetBackend
+				*items = rest
+			}
+		}
+
+		backend.Chaos = s
+	default:
+		return backend, false
+	}
+
+	return backend, true
+}
+
+// toBackend returns the provider section of a backend of type typ and the metadata it has no
+// fields for
+func toBackend(typ string, metadata []MetadataItem) (v1beta1.Backend, []v1beta1.MetadataItem, bool) {
+	items := append(metadataItems{}, metadata...)
+	backend, ok := items.takeBackend(typ)
+
+	return backend, toMetadata(items), ok
+}
+
+// fromBackend returns the type and metadata of the provider set in backend. Without a provider the
+// type is fallback, the type of a v1alpha1 backend kept in an annotation.
+func fromBackend(backend v1beta1.Backend, fallback string) (string, []MetadataItem, error) {
+	providers := []string{}
+	typ := fallback
+	var metadata []MetadataItem
+
+	if backend.InMemory != nil {
+		providers = append(providers, "inMemory")
+		typ = inMemoryType
+	}
+
+	if s := backend.AzureTableStorage; s != nil {
+		providers = append(providers, "azureTableStorage")
+		typ = azureTableStorageType
+		metadata = appendSecretValue(metadata, storageAccountNameKey, s.AccountName)
+		metadata = appendSecretValue(metadata, storageAccountKeyKey, s.AccountKey)
+	}
+
+	if s := backend.CosmosDB; s != nil {
+		providers = append(providers, "cosmosDB")
+		typ = cosmosDBType
+		metadata = appendSecretValue(metadata, urlKey, s.URL)
+		metadata = appendSecretValue(metadata, masterKeyKey, s.MasterKey)
+		metadata = appendSecretValue(metadata, databaseKey, s.Database)
+		metadata = appendSecretValue(metadata, containerKey, s.Container)
+	}
+
+	if s := backend.Chaos; s != nil {
+		providers = append(providers, "chaos")
+		typ = chaosType
+
+		if s.Target != (v1beta1.Backend{}) {
+			if s.Target.Chaos != nil {
+				return "", nil, fmt.Errorf("the target of chaos can't be chaos")
+			}
+
+			target, targetMetadata, err := fromBackend(s.Target, "")
+			if err != nil {
+				return "", nil, fmt.Errorf("chaos.target: %s", err)
+			}
+
+			metadata = append(metadata, MetadataItem{Name: chaosTargetKey, Value: target})
+			metadata = append(metadata, targetMetadata...)
+		}
+
+		metadata = appendValue(metadata, chaosLatencyKey, s.Latency)
+		metadata = appendValue(metadata, chaosErrorRateKey, s.ErrorRate)
+
+		ops := make([]string, 0, len(s.OperationErrorRates))
+		for op := range s.OperationErrorRates {
+			ops = append(ops, op)
+		}
+
+		sort.Strings(ops)
+
+		for _, op := range ops {
+			metadata = appendValue(metadata, chaosErrorRateKey+"."+op, s.OperationErrorRates[op])
+		}
+
+		metadata = appendValue(metadata, chaosConflictRateKey, s.ConflictRate)
+		metadata = appendValue(metadata, chaosStaleReadRateKey, s.StaleReadRate)
+	}
+
+	if len(providers) > 1 {
+		return "", nil, fmt.Errorf("only one of %s can be set", strings.Join(providers, ", "))
+	}
+
+	return typ, metadata, nil
+}
+
+func setAnnotation(es *v1beta1.Eventstore, key, value string) {
+	if es.Annotations == nil {
+		es.Annotations = map[string]string{}
+	}
+
+	es.Annotations[key] = value
+}
+
+// popAnnotation removes the annotation key of es and returns its value
+func popAnnotation(es *Eventstore, key string) string {
+	value, ok := es.Annotations[key]
+	if !ok {
+		return ""
+	}
+
+	delete(es.Annotations, key)
+	if len(es.Annotations) == 0 {
+		es.Annotations = nil
+	}
+
+	return value
+}
+
+func toSecretValue(m MetadataItem) v1beta1.SecretValue {
+	value := v1beta1.SecretValue{Value: m.Value}
+
+	if m.SecretKeyRef.Name != "" || m.SecretKeyRef.Key != "" {
+		value.SecretKeyRef = &v1beta1.SecretKeyRef{Name: m.SecretKeyRef.Name, Key: m.SecretKeyRef.Key}
+	}
+
+	return value
+}
+
+func appendSecretValue(metadata []MetadataItem, name string, value v1beta1.SecretValue) []MetadataItem {
+	m := MetadataItem{Name: name, Value: value.Value}
+
+	if value.SecretKeyRef != nil {
+		m.SecretKeyRef = SecretKeyRef{Name: value.SecretKeyRef.Name, Key: value.SecretKeyRef.Key}
+	} else if value.Value == "" {
+		return metadata
+	}
+
+	return append(metadata, m)
+}
+
+func appendValue(metadata []MetadataItem, name, value string) []MetadataItem {
+	if value == "" {
+		return metadata
+	}
+
+	return append(metadata, MetadataItem{Name: name, Value: value})
+}
+
+func toMetadata(metadata []MetadataItem) []v1beta1.MetadataItem {
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	result := make([]v1beta1.MetadataItem, 0, len(metadata))
+	for _, m := range metadata {
+		value := toSecretValue(m)
+		result = append(result, v1beta1.MetadataItem{Name: m.Name, Value: value.Value, SecretKeyRef: value.SecretKeyRef})
+	}
+
+	return result
+}
+
+func fromMetadata(metadata []v1beta1.MetadataItem) []MetadataItem {
+	if metadata == nil {
+		return nil
+	}
+
+	result := make([]MetadataItem, 0, len(metadata))
+	for _, m := range metadata {
+		item := MetadataItem{Name: m.Name, Value: m.Value}
+		if m.SecretKeyRef != nil {
+			item.SecretKeyRef = SecretKeyRef{Name: m.SecretKeyRef.Name, Key: m.SecretKeyRef.Key}
+		}
+
+		result = append(result, item)
+	}
+
+	return result
+}
@@ -0,0 +1,197 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1beta1"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/chaos"
+	registry "github.com/AndreasM009/eventstore/pkg/eventstored/eventstore"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testEventstore(typ string, metadata ...MetadataItem) *Eventstore {
+	return &Eventstore{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "eventstore.io/v1alpha1", Kind: "Eventstore"},
+		ObjectMeta: metav1.ObjectMeta{Name: "teststore", Namespace: "default", Generation: 3},
+		Spec:       EventstoreSpec{Type: typ, Metadata: metadata},
+		Status:     EventstoreStatus{ObservedGeneration: 2, Ready: true, Sidecars: 4},
+	}
+}
+
+// roundTrip converts es to v1beta1 and back
+func roundTrip(t *testing.T, es *Eventstore) (*v1beta1.Eventstore, *Eventstore) {
+	beta := &v1beta1.Eventstore{}
+	assert.Nil(t, es.ConvertTo(beta))
+
+	alpha := &Eventstore{}
+	assert.Nil(t, alpha.ConvertFrom(beta))
+
+	return beta, alpha
+}
+
+func TestConvertTableStorage(t *testing.T) {
+	es := testEventstore("eventstore.azure.tablestorage",
+		MetadataItem{Name: "storageAccountName", Value: "account"},
+		MetadataItem{Name: "storageAccountKey", SecretKeyRef: SecretKeyRef{Name: "storage", Key: "key"}},
+		MetadataItem{Name: "journal", Value: "true"},
+	)
+
+	beta, alpha := roundTrip(t, es)
+
+	assert.Equal(t, "eventstore.io/v1beta1", beta.APIVersion)
+	assert.Equal(t, "teststore", beta.Name)
+	assert.Equal(t, int64(2), beta.Status.ObservedGeneration)
+	assert.Equal(t, &v1beta1.AzureTableStorageBackend{
+		AccountName: v1beta1.SecretValue{Value: "account"},
+		AccountKey:  v1beta1.SecretValue{SecretKeyRef: &v1beta1.SecretKeyRef{Name: "storage", Key: "key"}},
+	}, beta.Spec.AzureTableStorage)
+	assert.Nil(t, beta.Spec.InMemory)
+	assert.Equal(t, []v1beta1.MetadataItem{{Name: "journal", Value: "true"}}, beta.Spec.Options)
+	assert.Empty(t, beta.Annotations)
+
+	assert.Equal(t, es, alpha)
+}
+
+func TestConvertCosmosDB(t *testing.T) {
+	es := testEventstore("eventstore.azure.cosmosdb",
+		MetadataItem{Name: "url", Value: "https://account.documents.azure.com"},
+		MetadataItem{Name: "masterKey", SecretKeyRef: SecretKeyRef{Name: "cosmos", Key: "key"}},
+		MetadataItem{Name: "database", Value: "db"},
+		MetadataItem{Name: "container", Value: "entities"},
+	)
+
+	beta, alpha := roundTrip(t, es)
+
+	assert.Equal(t, "https://account.documents.azure.com", beta.Spec.CosmosDB.URL.Value)
+	assert.Equal(t, "cosmos", beta.Spec.CosmosDB.MasterKey.SecretKeyRef.Name)
+	assert.Equal(t, "db", beta.Spec.CosmosDB.Database.Value)
+	assert.Equal(t, "entities", beta.Spec.CosmosDB.Container.Value)
+	assert.Empty(t, beta.Spec.Options)
+
+	assert.Equal(t, es, alpha)
+}
+
+func TestConvertChaos(t *testing.T) {
+	es := testEventstore("eventstore.chaos",
+		MetadataItem{Name: "chaosTarget", Value: "eventstore.inmemory"},
+		MetadataItem{Name: "chaosLatency", Value: "100ms"},
+		MetadataItem{Name: "chaosErrorRate", Value: "0.1"},
+		MetadataItem{Name: "chaosErrorRate.append", Value: "0.5"},
+		MetadataItem{Name: "chaosConflictRate", Value: "0.2"},
+		MetadataItem{Name: "chaosStaleReadRate", Value: "0.3"},
+		MetadataItem{Name: "cacheSize", Value: "100"},
+	)
+
+	beta, alpha := roundTrip(t, es)
+
+	assert.Equal(t, &v1beta1.ChaosBackend{
+		Target:              v1beta1.Backend{InMemory: &v1beta1.InMemoryBackend{}},
+		Latency:             "100ms",
+		ErrorRate:           "0.1",
+		OperationErrorRates: map[string]string{"append": "0.5"},
+		ConflictRate:        "0.2",
+		StaleReadRate:       "0.3",
+	}, beta.Spec.Chaos)
+	assert.Equal(t, []v1beta1.MetadataItem{{Name: "cacheSize", Value: "100"}}, beta.Spec.Options)
+
+	assert.Equal(t, es, alpha)
+}
+
+func TestConvertChaosKeepsTargetWithoutProviderInOptions(t *testing.T) {
+	es := testEventstore("eventstore.chaos",
+		MetadataItem{Name: "chaosTarget", Value: "eventstore.chaos"},
+		MetadataItem{Name: "chaosLatency", SecretKeyRef: SecretKeyRef{Name: "chaos", Key: "latency"}},
+	)
+
+	beta, alpha := roundTrip(t, es)
+
+	assert.Equal(t, v1beta1.Backend{}, beta.Spec.Chaos.Target)
+	assert.Equal(t, "", beta.Spec.Chaos.Latency)
+	assert.Len(t, beta.Spec.Options, 2)
+
+	assert.Equal(t, es, alpha)
+}
+
+func TestConvertUnknownTypeKeepsTypeInAnnotation(t *testing.T) {
+	es := testEventstore("eventstore.azure.cosmos", MetadataItem{Name: "url", Value: "https://cosmos"})
+	es.Spec.Migration = &MigrationSpec{
+		Type:     "eventstore.azure.table",
+		Metadata: []MetadataItem{{Name: "storageAccountName", Value: "account"}},
+		Stage:    "dualwrite",
+	}
+
+	beta, alpha := roundTrip(t, es)
+
+	assert.Equal(t, v1beta1.Backend{}, beta.Spec.Backend)
+	assert.Equal(t, "eventstore.azure.cosmos", beta.Annotations[TypeAnnotation])
+	assert.Equal(t, "eventstore.azure.table", beta.Annotations[MigrationTypeAnnotation])
+	assert.Equal(t, []v1beta1.MetadataItem{{Name: "url", Value: "https://cosmos"}}, beta.Spec.Options)
+	assert.Equal(t, "dualwrite", beta.Spec.Migration.Stage)
+
+	assert.Equal(t, es, alpha)
+}
+
+func TestConvertSinkProjectionsAndMigration(t *testing.T) {
+	es := testEventstore("eventstore.inmemory")
+	es.Spec.Sink = &SinkSpec{Type: "http", Metadata: []MetadataItem{{Name: "url", Value: "http://sink"}}}
+	es.Spec.Projections = []ProjectionSpec{
+		{Name: "balance", Type: "starlark", Metadata: []MetadataItem{{Name: "script", SecretKeyRef: SecretKeyRef{Name: "scripts", Key: "balance"}}}},
+	}
+	es.Spec.Migration = &MigrationSpec{
+		Type: "eventstore.azure.tablestorage",
+		Metadata: []MetadataItem{
+			{Name: "storageAccountName", Value: "account"},
+			{Name: "storageAccountKey", Value: "key"},
+			{Name: "backendRetries", Value: "3"},
+		},
+		Stage: "backfill",
+	}
+
+	beta, alpha := roundTrip(t, es)
+
+	assert.NotNil(t, beta.Spec.InMemory)
+	assert.Equal(t, "http", beta.Spec.Sink.Type)
+	assert.Equal(t, "scripts", beta.Spec.Projections[0].Metadata[0].SecretKeyRef.Name)
+	assert.Equal(t, "account", beta.Spec.Migration.AzureTableStorage.AccountName.Value)
+	assert.Equal(t, []v1beta1.MetadataItem{{Name: "backendRetries", Value: "3"}}, beta.Spec.Migration.Options)
+
+	assert.Equal(t, es, alpha)
+}
+
+func TestConvertFromRejectsSeveralProviders(t *testing.T) {
+	beta := &v1beta1.Eventstore{
+		Spec: v1beta1.EventstoreSpec{
+			Backend: v1beta1.Backend{
+				InMemory: &v1beta1.InMemoryBackend{},
+				CosmosDB: &v1beta1.CosmosDBBackend{},
+			},
+		},
+	}
+
+	err := (&Eventstore{}).ConvertFrom(beta)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "inMemory, cosmosDB")
+
+	beta.Spec.Backend = v1beta1.Backend{Chaos: &v1beta1.ChaosBackend{
+		Target: v1beta1.Backend{Chaos: &v1beta1.ChaosBackend{}},
+	}}
+
+	assert.NotNil(t, (&Eventstore{}).ConvertFrom(beta))
+}
+
+func TestConversionKeysMatchTheSidecars(t *testing.T) {
+	assert.Equal(t, chaos.TargetKey, chaosTargetKey)
+	assert.Equal(t, chaos.LatencyKey, chaosLatencyKey)
+	assert.Equal(t, chaos.ErrorRateKey, chaosErrorRateKey)
+	assert.Equal(t, chaos.ConflictRateKey, chaosConflictRateKey)
+	assert.Equal(t, chaos.StaleReadRateKey, chaosStaleReadRateKey)
+
+	assert.Equal(t, []string{cosmosDBType, azureTableStorageType, chaosType, inMemoryType}, registry.Types())
+
+	keys, _ := registry.RequiredMetadata(azureTableStorageType)
+	assert.Equal(t, []string{storageAccountNameKey, storageAccountKeyKey}, keys)
+
+	keys, _ = registry.RequiredMetadata(cosmosDBType)
+	assert.Equal(t, []string{urlKey, masterKeyKey, databaseKey, containerKey}, keys)
+}
@@ -6,7 +6,8 @@ import (
 	"reflect"
 	"time"
 
-	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1beta1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -15,72 +16,510 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
-// CreateCustomResourceDefinition creates the CRD and add it into Kubernetes. If there is error,
-// it will do some clean up.
-func CreateCustomResourceDefinition(namespace string, clientSet apiextensionsclientset.Interface) error {
-	crd := &apiextensionsv1beta1.CustomResourceDefinition{
+const (
+	// ShortName of Eventstores, e.g. 'kubectl get es'
+	ShortName string = "es"
+	// SubscriptionShortName of EventstoreSubscriptions
+	SubscriptionShortName string = "esub"
+	// ConversionPath is the path the conversion webhook of Eventstores is served at
+	ConversionPath string = "/convert"
+)
+
+// CreateCustomResourceDefinition creates the CRD and add it into Kubernetes. An existing CRD, e.g.
+// one created with apiextensions v1beta1, is updated in place. If there is error, a created CRD
+// is cleaned up. The v1beta1 version is only served with a conversion webhook.
+func CreateCustomResourceDefinition(namespace string, conversion *apiextensionsv1.WebhookClientConfig, clientSet apiextensionsclientset.Interface) error {
+	return createCustomResourceDefinition(EventstoreCustomResourceDefinition(namespace, conversion), clientSet)
+}
+
+// ConversionWebhookClientConfig returns the config of the conversion webhook served by the service
+// name in namespace, caBundle is the PEM encoded CA of its certificate
+func ConversionWebhookClientConfig(namespace, name string, caBundle []byte) *apiextensionsv1.WebhookClientConfig {
+	path := ConversionPath
+	port := int32(443)
+
+	return &apiextensionsv1.WebhookClientConfig{
+		Service: &apiextensionsv1.ServiceReference{
+			Namespace: namespace,
+			Name:      name,
+			Path:      &path,
+			Port:      &port,
+		},
+		CABundle: caBundle,
+	}
+}
+
+// CreateSubscriptionCustomResourceDefinition creates the CRD of EventstoreSubscription and add it into Kubernetes.
+// An existing CRD is updated in place. If there is error, a created CRD is cleaned up.
+func CreateSubscriptionCustomResourceDefinition(namespace string, clientSet apiextensionsclientset.Interface) error {
+	return createCustomResourceDefinition(SubscriptionCustomResourceDefinition(namespace), clientSet)
+}
+
+// EventstoreCustomResourceDefinition returns the CRD of Eventstore with the schema of its spec and
+// the status subresource written by the operator. With a conversion webhook v1beta1 is served, too,
+// v1alpha1 stays the version Eventstores are stored in.
+func EventstoreCustomResourceDefinition(namespace string, conversion *apiextensionsv1.WebhookClientConfig) *apiextensionsv1.CustomResourceDefinition {
+	crd := &apiextensionsv1.CustomResourceDefinition{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      CRDName,
 			Namespace: namespace,
 		},
-		Spec: apiextensionsv1beta1.CustomResourceDefinitionSpec{
-			Group:   GroupName,
-			Version: SchemeGroupVersion.Version,
-			Scope:   apiextensionsv1beta1.NamespaceScoped,
-			Names: apiextensionsv1beta1.CustomResourceDefinitionNames{
-				Plural: Plural,
-				Kind:   reflect.TypeOf(Eventstore{}).Name(),
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: GroupName,
+			Scope: apiextensionsv1.NamespaceScoped,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Plural:     Plural,
+				Singular:   Singular,
+				Kind:       reflect.TypeOf(Eventstore{}).Name(),
+				ListKind:   reflect.TypeOf(EventstoreList{}).Name(),
+				ShortNames: []string{ShortName},
+			},
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{
+					Name:    SchemeGroupVersion.Version,
+					Served:  true,
+					Storage: true,
+					Schema: &apiextensionsv1.CustomResourceValidation{
+						OpenAPIV3Schema: objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+							"spec":   eventstoreSpecSchema(),
+							"status": eventstoreStatusSchema(),
+						}),
+					},
+					Subresources: &apiextensionsv1.CustomResourceSubresources{
+						Status: &apiextensionsv1.CustomResourceSubresourceStatus{},
+					},
+					AdditionalPrinterColumns: []apiextensionsv1.CustomResourceColumnDefinition{
+						{Name: "Type", Type: "string", JSONPath: ".spec.type"},
+						{Name: "Ready", Type: "boolean", JSONPath: ".status.ready"},
+						{Name: "Sidecars", Type: "integer", JSONPath: ".status.sidecars"},
+						{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+					},
+				},
 			},
+			Conversion: &apiextensionsv1.CustomResourceConversion{
+				Strategy: apiextensionsv1.NoneConverter,
+			},
+			PreserveUnknownFields: false,
+		},
+	}
+
+	if conversion == nil {
+		return crd
+	}
+
+	crd.Spec.Versions = append(crd.Spec.Versions, apiextensionsv1.CustomResourceDefinitionVersion{
+		Name:    v1beta1.SchemeGroupVersion.Version,
+		Served:  true,
+		Storage: false,
+		Schema: &apiextensionsv1.CustomResourceValidation{
+			OpenAPIV3Schema: objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+				"spec":   v1beta1EventstoreSpecSchema(),
+				"status": eventstoreStatusSchema(),
+			}),
+		},
+		Subresources: &apiextensionsv1.CustomResourceSubresources{
+			Status: &apiextensionsv1.CustomResourceSubresourceStatus{},
 		},
+		AdditionalPrinterColumns: []apiextensionsv1.CustomResourceColumnDefinition{
+			{Name: "Ready", Type: "boolean", JSONPath: ".status.ready"},
+			{Name: "Sidecars", Type: "integer", JSONPath: ".status.sidecars"},
+			{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+		},
+	})
+
+	crd.Spec.Conversion = &apiextensionsv1.CustomResourceConversion{
+		Strategy: apiextensionsv1.WebhookConverter,
+		Webhook: &apiextensionsv1.WebhookConversion{
+			ClientConfig:             conversion,
+			ConversionReviewVersions: []string{"v1", "v1beta1"},
+		},
+	}
+
+	return crd
+}
+
+// SubscriptionCustomResourceDefinition returns the CRD of EventstoreSubscription with the schema of
+// its spec and the status subresource written by the operator
+func SubscriptionCustomResourceDefinition(namespace string) *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SubscriptionCRDName,
+			Namespace: namespace,
+		},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: GroupName,
+			Scope: apiextensionsv1.NamespaceScoped,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Plural:     SubscriptionPlural,
+				Singular:   "eventstoresubscription",
+				Kind:       reflect.TypeOf(EventstoreSubscription{}).Name(),
+				ListKind:   reflect.TypeOf(EventstoreSubscriptionList{}).Name(),
+				ShortNames: []string{SubscriptionShortName},
+			},
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{
+					Name:    SchemeGroupVersion.Version,
+					Served:  true,
+					Storage: true,
+					Schema: &apiextensionsv1.CustomResourceValidation{
+						OpenAPIV3Schema: objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+							"spec":   subscriptionSpecSchema(),
+							"status": subscriptionStatusSchema(),
+						}),
+					},
+					Subresources: &apiextensionsv1.CustomResourceSubresources{
+						Status: &apiextensionsv1.CustomResourceSubresourceStatus{},
+					},
+					AdditionalPrinterColumns: []apiextensionsv1.CustomResourceColumnDefinition{
+						{Name: "Eventstore", Type: "string", JSONPath: ".spec.eventstore"},
+						{Name: "URL", Type: "string", JSONPath: ".spec.url"},
+						{Name: "Ready", Type: "boolean", JSONPath: ".status.ready"},
+						{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+					},
+				},
+			},
+			Conversion: &apiextensionsv1.CustomResourceConversion{
+				Strategy: apiextensionsv1.NoneConverter,
+			},
+			PreserveUnknownFields: false,
+		},
+	}
+}
+
+func objectSchema(properties map[string]apiextensionsv1.JSONSchemaProps, required ...string) *apiextensionsv1.JSONSchemaProps {
+	return &apiextensionsv1.JSONSchemaProps{
+		Type:       "object",
+		Properties: properties,
+		Required:   required,
+	}
+}
+
+func stringSchema() apiextensionsv1.JSONSchemaProps {
+	return apiextensionsv1.JSONSchemaProps{Type: "string"}
+}
+
+// metadataSchema is the schema of a list of MetadataItems
+func metadataSchema() apiextensionsv1.JSONSchemaProps {
+	return apiextensionsv1.JSONSchemaProps{
+		Type: "array",
+		Items: &apiextensionsv1.JSONSchemaPropsOrArray{
+			Schema: objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+				"name":         stringSchema(),
+				"value":        stringSchema(),
+				"secretKeyRef": *secretKeyRefSchema(),
+			}, "name"),
+		},
+	}
+}
+
+func secretKeyRefSchema() *apiextensionsv1.JSONSchemaProps {
+	return objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+		"name": stringSchema(),
+		"key":  stringSchema(),
+	})
+}
+
+// typedSchema is the schema of a spec with a type and metadata, like the ones of sinks
+func typedSchema(properties map[string]apiextensionsv1.JSONSchemaProps) apiextensionsv1.JSONSchemaProps {
+	properties["type"] = stringSchema()
+	properties["metadata"] = metadataSchema()
+
+	return *objectSchema(properties, "type")
+}
+
+func eventstoreSpecSchema() apiextensionsv1.JSONSchemaProps {
+	return typedSchema(map[string]apiextensionsv1.JSONSchemaProps{
+		"sink": typedSchema(map[string]apiextensionsv1.JSONSchemaProps{}),
+		"projections": {
+			Type: "array",
+			Items: &apiextensionsv1.JSONSchemaPropsOrArray{
+				Schema: objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+					"name":     stringSchema(),
+					"type":     stringSchema(),
+					"metadata": metadataSchema(),
+				}, "name", "type"),
+			},
+		},
+		"migration": typedSchema(map[string]apiextensionsv1.JSONSchemaProps{
+			"stage": stageSchema(),
+		}),
+	})
+}
+
+func stageSchema() apiextensionsv1.JSONSchemaProps {
+	return apiextensionsv1.JSONSchemaProps{
+		Type: "string",
+		Enum: []apiextensionsv1.JSON{
+			{Raw: []byte(`"dualwrite"`)},
+			{Raw: []byte(`"backfill"`)},
+			{Raw: []byte(`"verify"`)},
+			{Raw: []byte(`"cutover"`)},
+		},
+	}
+}
+
+// patternSchema is the schema of a string matching pattern
+func patternSchema(pattern string) apiextensionsv1.JSONSchemaProps {
+	return apiextensionsv1.JSONSchemaProps{Type: "string", Pattern: pattern}
+}
+
+// secretValueSchema is the schema of a v1beta1 SecretValue, a literal value has to match pattern
+func secretValueSchema(pattern string) apiextensionsv1.JSONSchemaProps {
+	ref := secretKeyRefSchema()
+	ref.Required = []string{"name", "key"}
+
+	value := stringSchema()
+	value.MinLength = &minLength
+	value.Pattern = pattern
+
+	schema := *objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+		"value":        value,
+		"secretKeyRef": *ref,
+	})
+	schema.OneOf = []apiextensionsv1.JSONSchemaProps{
+		{Required: []string{"value"}},
+		{Required: []string{"secretKeyRef"}},
+	}
+
+	return schema
+}
+
+// v1beta1MetadataSchema is the schema of a list of v1beta1 MetadataItems
+func v1beta1MetadataSchema() apiextensionsv1.JSONSchemaProps {
+	ref := secretKeyRefSchema()
+	ref.Required = []string{"name", "key"}
+
+	return apiextensionsv1.JSONSchemaProps{
+		Type: "array",
+		Items: &apiextensionsv1.JSONSchemaPropsOrArray{
+			Schema: objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+				"name":         stringSchema(),
+				"value":        stringSchema(),
+				"secretKeyRef": *ref,
+			}, "name"),
+		},
+	}
+}
+
+// Patterns of the literal values of the v1beta1 providers
+const (
+	storageAccountNamePattern = `^[a-z0-9]{3,24}$`
+	urlPattern                = `^https?://`
+	durationPattern           = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	ratePattern               = `^(0(\.[0-9]+)?|1(\.0+)?)$`
+)
+
+var minLength = int64(1)
+
+// backendSchema adds the provider sections of a v1beta1 Backend to properties. Exactly one of them
+// has to be set, chaos is left out of the targets of chaos.
+func backendSchema(properties map[string]apiextensionsv1.JSONSchemaProps, withChaos bool) apiextensionsv1.JSONSchemaProps {
+	properties["inMemory"] = *objectSchema(map[string]apiextensionsv1.JSONSchemaProps{})
+	properties["azureTableStorage"] = *objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+		"accountName": secretValueSchema(storageAccountNamePattern),
+		"accountKey":  secretValueSchema(""),
+	}, "accountName", "accountKey")
+	properties["cosmosDB"] = *objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+		"url":       secretValueSchema(urlPattern),
+		"masterKey": secretValueSchema(""),
+		"database":  secretValueSchema(""),
+		"container": secretValueSchema(""),
+	}, "url", "masterKey", "database", "container")
+
+	providers := []string{"inMemory", "azureTableStorage", "cosmosDB"}
+
+	if withChaos {
+		providers = append(providers, "chaos")
+
+		properties["chaos"] = *objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+			"target":    backendSchema(map[string]apiextensionsv1.JSONSchemaProps{}, false),
+			"latency":   patternSchema(durationPattern),
+			"errorRate": patternSchema(ratePattern),
+			"operationErrorRates": {
+				Type: "object",
+				AdditionalProperties: &apiextensionsv1.JSONSchemaPropsOrBool{
+					Allows: true,
+					Schema: &apiextensionsv1.JSONSchemaProps{Type: "string", Pattern: ratePattern},
+				},
+			},
+			"conflictRate":  patternSchema(ratePattern),
+			"staleReadRate": patternSchema(ratePattern),
+		}, "target")
+	}
+
+	schema := *objectSchema(properties)
+	for _, name := range providers {
+		schema.OneOf = append(schema.OneOf, apiextensionsv1.JSONSchemaProps{Required: []string{name}})
 	}
-	_, err := clientSet.ApiextensionsV1beta1().CustomResourceDefinitions().Create(context.TODO(), crd, metav1.CreateOptions{})
+
+	return schema
+}
+
+// v1beta1EventstoreSpecSchema is the schema of the spec of v1beta1 Eventstores
+func v1beta1EventstoreSpecSchema() apiextensionsv1.JSONSchemaProps {
+	return backendSchema(map[string]apiextensionsv1.JSONSchemaProps{
+		"options": v1beta1MetadataSchema(),
+		"sink": *objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+			"type":     stringSchema(),
+			"metadata": v1beta1MetadataSchema(),
+		}, "type"),
+		"projections": {
+			Type: "array",
+			Items: &apiextensionsv1.JSONSchemaPropsOrArray{
+				Schema: objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+					"name":     stringSchema(),
+					"type":     stringSchema(),
+					"metadata": v1beta1MetadataSchema(),
+				}, "name", "type"),
+			},
+		},
+		"migration": v1beta1MigrationSchema(),
+	}, true)
+}
+
+func v1beta1MigrationSchema() apiextensionsv1.JSONSchemaProps {
+	schema := backendSchema(map[string]apiextensionsv1.JSONSchemaProps{
+		"options": v1beta1MetadataSchema(),
+		"stage":   stageSchema(),
+	}, true)
+	schema.Required = []string{"stage"}
+
+	return schema
+}
+
+func eventstoreStatusSchema() apiextensionsv1.JSONSchemaProps {
+	return *objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+		"observedGeneration": {Type: "integer", Format: "int64"},
+		"ready":              {Type: "boolean"},
+		"sidecars":           {Type: "integer", Format: "int32"},
+		"message":            stringSchema(),
+	})
+}
+
+func subscriptionStatusSchema() apiextensionsv1.JSONSchemaProps {
+	return *objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+		"observedGeneration": {Type: "integer", Format: "int64"},
+		"ready":              {Type: "boolean"},
+		"message":            stringSchema(),
+	})
+}
+
+func subscriptionSpecSchema() apiextensionsv1.JSONSchemaProps {
+	return *objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+		"eventstore": stringSchema(),
+		"url":        stringSchema(),
+		"filter": *objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+			"idPrefix":  stringSchema(),
+			"eventType": stringSchema(),
+		}),
+		"signingSecret": *secretKeyRefSchema(),
+		"retryPolicy": *objectSchema(map[string]apiextensionsv1.JSONSchemaProps{
+			"maxAttempts": {Type: "integer"},
+			"minBackoff":  stringSchema(),
+			"maxBackoff":  stringSchema(),
+		}),
+	}, "eventstore", "url")
+}
+
+func createCustomResourceDefinition(crd *apiextensionsv1.CustomResourceDefinition, clientSet apiextensionsclientset.Interface) error {
+	name := crd.ObjectMeta.Name
+	kind := crd.Spec.Names.Kind
+
+	_, err := clientSet.ApiextensionsV1().CustomResourceDefinitions().Create(context.TODO(), crd, metav1.CreateOptions{})
 	if err == nil {
-		fmt.Println("CRD Eventstore was created")
+		fmt.Printf("CRD %s was created\n", kind)
 	} else if apierrors.IsAlreadyExists(err) {
-		fmt.Println("CRD Eventstore already exists")
-		return nil
+		return updateCustomResourceDefinition(crd, clientSet)
 	} else {
-		fmt.Printf("Failed to create CRD Eventstore: %+v\n", err)
+		fmt.Printf("Failed to create CRD %s: %+v\n", kind, err)
+
+		return err
+	}
+
+	err = waitForCustomResourceDefinition(name, kind, clientSet)
+
+	// If there is an error, delete the object to keep it clean.
+	if err != nil {
+		fmt.Println("Try to cleanup")
+		deleteErr := clientSet.ApiextensionsV1().CustomResourceDefinitions().Delete(context.TODO(), name, metav1.DeleteOptions{})
+		if deleteErr != nil {
+			fmt.Printf("Failed to delete CRD %s: %+v\n", kind, deleteErr)
+
+			return errors.NewAggregate([]error{err, deleteErr})
+		}
 
 		return err
 	}
 
-	// Wait for CRD creation.
-	err = wait.Poll(5*time.Second, 60*time.Second, func() (bool, error) {
-		crd, err = clientSet.ApiextensionsV1beta1().CustomResourceDefinitions().Get(context.TODO(), CRDName, metav1.GetOptions{})
+	return nil
+}
+
+// updateCustomResourceDefinition replaces the spec of an existing CRD with the one of crd. CRDs
+// created with apiextensions v1beta1 are read as v1, so they get the schema and subresources, too.
+// The existing resources are kept.
+func updateCustomResourceDefinition(crd *apiextensionsv1.CustomResourceDefinition, clientSet apiextensionsclientset.Interface) error {
+	name := crd.ObjectMeta.Name
+	kind := crd.Spec.Names.Kind
+	crds := clientSet.ApiextensionsV1().CustomResourceDefinitions()
+
+	err := wait.ExponentialBackoff(wait.Backoff{Duration: time.Second, Factor: 2, Steps: 5}, func() (bool, error) {
+		existing, err := crds.Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		if reflect.DeepEqual(existing.Spec, crd.Spec) {
+			fmt.Printf("CRD %s is up to date\n", kind)
+			return true, nil
+		}
+
+		updated := existing.DeepCopy()
+		updated.Spec = crd.Spec
+
+		_, err = crds.Update(context.TODO(), updated, metav1.UpdateOptions{})
+		if apierrors.IsConflict(err) {
+			// changed meanwhile, e.g. by another replica of the operator
+			return false, nil
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		fmt.Printf("CRD %s was updated\n", kind)
+		return true, nil
+	})
+
+	if err != nil {
+		fmt.Printf("Failed to update CRD %s: %+v\n", kind, err)
+		return err
+	}
+
+	return waitForCustomResourceDefinition(name, kind, clientSet)
+}
+
+// waitForCustomResourceDefinition waits until the CRD is established
+func waitForCustomResourceDefinition(name, kind string, clientSet apiextensionsclientset.Interface) error {
+	return wait.Poll(5*time.Second, 60*time.Second, func() (bool, error) {
+		crd, err := clientSet.ApiextensionsV1().CustomResourceDefinitions().Get(context.TODO(), name, metav1.GetOptions{})
 		if err != nil {
-			fmt.Printf("Failed to wait for CRD Eventstore creation: %+v\n", err)
+			fmt.Printf("Failed to wait for CRD %s creation: %+v\n", kind, err)
 
 			return false, err
 		}
 		for _, cond := range crd.Status.Conditions {
 			switch cond.Type {
-			case apiextensionsv1beta1.Established:
-				if cond.Status == apiextensionsv1beta1.ConditionTrue {
+			case apiextensionsv1.Established:
+				if cond.Status == apiextensionsv1.ConditionTrue {
 					return true, err
 				}
-			case apiextensionsv1beta1.NamesAccepted:
-				if cond.Status == apiextensionsv1beta1.ConditionFalse {
-					fmt.Printf("Name conflict while wait for CRD Eventstore creation: %s, %+v\n", cond.Reason, err)
+			case apiextensionsv1.NamesAccepted:
+				if cond.Status == apiextensionsv1.ConditionFalse {
+					fmt.Printf("Name conflict while wait for CRD %s creation: %s, %+v\n", kind, cond.Reason, err)
 				}
 			}
 		}
 
 		return false, err
 	})
-
-	// If there is an error, delete the object to keep it clean.
-	if err != nil {
-		fmt.Println("Try to cleanup")
-		deleteErr := clientSet.ApiextensionsV1beta1().CustomResourceDefinitions().Delete(context.TODO(), CRDName, metav1.DeleteOptions{})
-		if deleteErr != nil {
-			fmt.Printf("Failed to delete CRD Eventstore: %+v\n", deleteErr)
-
-			return errors.NewAggregate([]error{err, deleteErr})
-		}
-
-		return err
-	}
-
-	return nil
 }
@@ -20,6 +20,10 @@ const (
 	Singular string = "eventstore"
 	// CRDName of CRD eventstores.eventstore.io
 	CRDName string = Plural + "." + GroupName
+	// SubscriptionPlural eventstoresubscriptions
+	SubscriptionPlural string = "eventstoresubscriptions"
+	// SubscriptionCRDName of CRD eventstoresubscriptions.eventstore.io
+	SubscriptionCRDName string = SubscriptionPlural + "." + GroupName
 )
 
 var (
@@ -44,6 +48,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&Eventstore{},
 		&EventstoreList{},
+		&EventstoreSubscription{},
+		&EventstoreSubscriptionList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 
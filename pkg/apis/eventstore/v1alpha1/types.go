@@ -4,7 +4,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// MetadataItem is a name/value pair for a metadata
+// MetadataItem is a name/value pair for a metadata. The operator resolves SecretKeyRef into Value
+// when it pushes the Eventstore to the sidecars of its namespace.
 type MetadataItem struct {
 	Name         string       `json:"name"`
 	Value        string       `json:"value"`
@@ -18,22 +19,49 @@ type SecretKeyRef struct {
 	Key  string `json:"key"`
 }
 
-// EventstoreSpec defines the desired state of Eventstore
-type EventstoreSpec struct {
+// SinkSpec defines a message broker every committed entity version is published to.
+// Type is one of 'http', 'nats' or 'kafka'.
+type SinkSpec struct {
 	Type     string         `json:"type"`
 	Metadata []MetadataItem `json:"metadata"`
 }
 
-// EventstoreStatus defines the observed state of Eventstore
+// ProjectionSpec defines a projection that folds the versions of each entity into a read model.
+// Type is one of 'mergepatch' or 'starlark'.
+type ProjectionSpec struct {
+	Name     string         `json:"name"`
+	Type     string         `json:"type"`
+	Metadata []MetadataItem `json:"metadata"`
+}
+
+// MigrationSpec defines the backend an Eventstore is migrated to. Stage is one of 'dualwrite',
+// 'backfill', 'verify' or 'cutover', the stages are passed in this order.
+type MigrationSpec struct {
+	Type     string         `json:"type"`
+	Metadata []MetadataItem `json:"metadata"`
+	Stage    string         `json:"stage"`
+}
+
+// EventstoreSpec defines the desired state of Eventstore
+type EventstoreSpec struct {
+	Type        string           `json:"type"`
+	Metadata    []MetadataItem   `json:"metadata"`
+	Sink        *SinkSpec        `json:"sink,omitempty"`
+	Projections []ProjectionSpec `json:"projections,omitempty"`
+	Migration   *MigrationSpec   `json:"migration,omitempty"`
+}
+
+// EventstoreStatus defines the observed state of Eventstore. It's written by the operator when it
+// pushed the spec of ObservedGeneration to the sidecars, Ready is true if all of them received it.
 type EventstoreStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
-	// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	Ready              bool   `json:"ready"`
+	Sidecars           int32  `json:"sidecars"`
+	Message            string `json:"message,omitempty"`
 }
 
 // Eventstore is the Schema for the eventstores API
 // +genclient
-// +genclient:noStatus
 // +resource:path=eventstore
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type Eventstore struct {
@@ -52,3 +80,59 @@ type EventstoreList struct {
 	metav1.ListMeta `json:"metadata,omitempty"`
 	Items           []Eventstore `json:"items"`
 }
+
+// SubscriptionFilter selects the entity versions delivered to a subscription. IDPrefix matches
+// the beginning of the entity id, EventType matches the metadata of the entity version.
+type SubscriptionFilter struct {
+	IDPrefix  string `json:"idPrefix,omitempty"`
+	EventType string `json:"eventType,omitempty"`
+}
+
+// RetryPolicy defines how often and how long a failed delivery is retried, before it is recorded
+// as dead letter. Backoffs are durations like '500ms' or '1m', the delay doubles with each attempt.
+type RetryPolicy struct {
+	MaxAttempts int    `json:"maxAttempts,omitempty"`
+	MinBackoff  string `json:"minBackoff,omitempty"`
+	MaxBackoff  string `json:"maxBackoff,omitempty"`
+}
+
+// EventstoreSubscriptionSpec defines the desired state of EventstoreSubscription.
+// Eventstore is the name of an Eventstore in the same namespace, its journal must be enabled and
+// its backend must be shared by the sidecars, the in memory backend isn't supported.
+type EventstoreSubscriptionSpec struct {
+	Eventstore    string             `json:"eventstore"`
+	Filter        SubscriptionFilter `json:"filter,omitempty"`
+	URL           string             `json:"url"`
+	SigningSecret *SecretKeyRef      `json:"signingSecret,omitempty"`
+	RetryPolicy   RetryPolicy        `json:"retryPolicy,omitempty"`
+}
+
+// EventstoreSubscriptionStatus defines the observed state of EventstoreSubscription. It's written by
+// the operator when it processed the spec of ObservedGeneration, Ready is true if the delivery runs.
+// Otherwise Message tells why, e.g. the subscription is invalid or its Eventstore isn't supported.
+type EventstoreSubscriptionStatus struct {
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	Ready              bool   `json:"ready"`
+	Message            string `json:"message,omitempty"`
+}
+
+// EventstoreSubscription delivers the entity versions of an Eventstore to a webhook
+// +genclient
+// +resource:path=eventstoresubscription
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type EventstoreSubscription struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   EventstoreSubscriptionSpec   `json:"spec,omitempty"`
+	Status EventstoreSubscriptionStatus `json:"status,omitempty"`
+}
+
+// EventstoreSubscriptionList contains a list of EventstoreSubscription
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +resourcepath=eventstoresubscription
+type EventstoreSubscriptionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []EventstoreSubscription `json:"items"`
+}
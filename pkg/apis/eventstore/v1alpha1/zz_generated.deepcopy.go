@@ -93,6 +93,23 @@ func (in *EventstoreSpec) DeepCopyInto(out *EventstoreSpec) {
 		*out = make([]MetadataItem, len(*in))
 		copy(*out, *in)
 	}
+	if in.Sink != nil {
+		in, out := &in.Sink, &out.Sink
+		*out = new(SinkSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Projections != nil {
+		in, out := &in.Projections, &out.Projections
+		*out = make([]ProjectionSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Migration != nil {
+		in, out := &in.Migration, &out.Migration
+		*out = new(MigrationSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -122,6 +139,106 @@ func (in *EventstoreStatus) DeepCopy() *EventstoreStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventstoreSubscription) DeepCopyInto(out *EventstoreSubscription) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventstoreSubscription.
+func (in *EventstoreSubscription) DeepCopy() *EventstoreSubscription {
+	if in == nil {
+		return nil
+	}
+	out := new(EventstoreSubscription)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EventstoreSubscription) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventstoreSubscriptionList) DeepCopyInto(out *EventstoreSubscriptionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]EventstoreSubscription, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventstoreSubscriptionList.
+func (in *EventstoreSubscriptionList) DeepCopy() *EventstoreSubscriptionList {
+	if in == nil {
+		return nil
+	}
+	out := new(EventstoreSubscriptionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EventstoreSubscriptionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventstoreSubscriptionSpec) DeepCopyInto(out *EventstoreSubscriptionSpec) {
+	*out = *in
+	out.Filter = in.Filter
+	if in.SigningSecret != nil {
+		in, out := &in.SigningSecret, &out.SigningSecret
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	out.RetryPolicy = in.RetryPolicy
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventstoreSubscriptionSpec.
+func (in *EventstoreSubscriptionSpec) DeepCopy() *EventstoreSubscriptionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EventstoreSubscriptionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventstoreSubscriptionStatus) DeepCopyInto(out *EventstoreSubscriptionStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventstoreSubscriptionStatus.
+func (in *EventstoreSubscriptionStatus) DeepCopy() *EventstoreSubscriptionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EventstoreSubscriptionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetadataItem) DeepCopyInto(out *MetadataItem) {
 	*out = *in
@@ -139,6 +256,64 @@ func (in *MetadataItem) DeepCopy() *MetadataItem {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationSpec) DeepCopyInto(out *MigrationSpec) {
+	*out = *in
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = make([]MetadataItem, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationSpec.
+func (in *MigrationSpec) DeepCopy() *MigrationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProjectionSpec) DeepCopyInto(out *ProjectionSpec) {
+	*out = *in
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = make([]MetadataItem, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProjectionSpec.
+func (in *ProjectionSpec) DeepCopy() *ProjectionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProjectionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryPolicy) DeepCopyInto(out *RetryPolicy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryPolicy.
+func (in *RetryPolicy) DeepCopy() *RetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretKeyRef) DeepCopyInto(out *SecretKeyRef) {
 	*out = *in
@@ -154,3 +329,40 @@ func (in *SecretKeyRef) DeepCopy() *SecretKeyRef {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SinkSpec) DeepCopyInto(out *SinkSpec) {
+	*out = *in
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = make([]MetadataItem, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SinkSpec.
+func (in *SinkSpec) DeepCopy() *SinkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SinkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubscriptionFilter) DeepCopyInto(out *SubscriptionFilter) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubscriptionFilter.
+func (in *SubscriptionFilter) DeepCopy() *SubscriptionFilter {
+	if in == nil {
+		return nil
+	}
+	out := new(SubscriptionFilter)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,8 @@
+// +k8s:deepcopy-gen=package,register
+// +k8s:defaulter-gen=TypeMeta
+// +k8s:openapi-gen=true
+
+// Package v1beta1 is the v1beta1 version of the API. Backends of Eventstores are typed sections
+// instead of a type string with metadata.
+// +groupName=eventstore.io
+package v1beta1
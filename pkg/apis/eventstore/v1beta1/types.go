@@ -0,0 +1,128 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MetadataItem is a name/value pair for an option of the decorators of a backend, e.g. 'journal'
+// or 'cacheSize'. The value is either set literally or read from a secret.
+type MetadataItem struct {
+	Name         string        `json:"name"`
+	Value        string        `json:"value,omitempty"`
+	SecretKeyRef *SecretKeyRef `json:"secretKeyRef,omitempty"`
+}
+
+// SecretKeyRef is a reference to a secret holding a value.
+// Name is the secret name, and key is the field in the secret.
+type SecretKeyRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// SecretValue is a value that is either set literally or read from a secret, exactly one of both
+// is set
+type SecretValue struct {
+	Value        string        `json:"value,omitempty"`
+	SecretKeyRef *SecretKeyRef `json:"secretKeyRef,omitempty"`
+}
+
+// InMemoryBackend keeps the entities in the memory of each sidecar
+type InMemoryBackend struct {
+}
+
+// AzureTableStorageBackend keeps the entities in tables of an Azure storage account
+type AzureTableStorageBackend struct {
+	AccountName SecretValue `json:"accountName"`
+	AccountKey  SecretValue `json:"accountKey"`
+}
+
+// CosmosDBBackend keeps the entities in a container of an Azure Cosmos DB database
+type CosmosDBBackend struct {
+	URL       SecretValue `json:"url"`
+	MasterKey SecretValue `json:"masterKey"`
+	Database  SecretValue `json:"database"`
+	Container SecretValue `json:"container"`
+}
+
+// ChaosBackend injects faults into the calls of its target, which must not be a chaos backend
+// itself. Latency is a duration like '100ms', the rates are between 0 and 1. OperationErrorRates
+// overrides ErrorRate for single operations, e.g. 'append'.
+type ChaosBackend struct {
+	Target              Backend           `json:"target"`
+	Latency             string            `json:"latency,omitempty"`
+	ErrorRate           string            `json:"errorRate,omitempty"`
+	OperationErrorRates map[string]string `json:"operationErrorRates,omitempty"`
+	ConflictRate        string            `json:"conflictRate,omitempty"`
+	StaleReadRate       string            `json:"staleReadRate,omitempty"`
+}
+
+// Backend is the store of the entities, exactly one of its providers is set
+type Backend struct {
+	InMemory          *InMemoryBackend          `json:"inMemory,omitempty"`
+	AzureTableStorage *AzureTableStorageBackend `json:"azureTableStorage,omitempty"`
+	CosmosDB          *CosmosDBBackend          `json:"cosmosDB,omitempty"`
+	Chaos             *ChaosBackend             `json:"chaos,omitempty"`
+}
+
+// SinkSpec defines a message broker every committed entity version is published to.
+// Type is one of 'http', 'nats' or 'kafka'.
+type SinkSpec struct {
+	Type     string         `json:"type"`
+	Metadata []MetadataItem `json:"metadata,omitempty"`
+}
+
+// ProjectionSpec defines a projection that folds the versions of each entity into a read model.
+// Type is one of 'mergepatch' or 'starlark'.
+type ProjectionSpec struct {
+	Name     string         `json:"name"`
+	Type     string         `json:"type"`
+	Metadata []MetadataItem `json:"metadata,omitempty"`
+}
+
+// MigrationSpec defines the backend an Eventstore is migrated to. Stage is one of 'dualwrite',
+// 'backfill', 'verify' or 'cutover', the stages are passed in this order.
+type MigrationSpec struct {
+	Backend `json:",inline"`
+	Options []MetadataItem `json:"options,omitempty"`
+	Stage   string         `json:"stage"`
+}
+
+// EventstoreSpec defines the desired state of Eventstore. Options configure the decorators of the
+// backend, like the journal, the cache or the resilience of its calls.
+type EventstoreSpec struct {
+	Backend     `json:",inline"`
+	Options     []MetadataItem   `json:"options,omitempty"`
+	Sink        *SinkSpec        `json:"sink,omitempty"`
+	Projections []ProjectionSpec `json:"projections,omitempty"`
+	Migration   *MigrationSpec   `json:"migration,omitempty"`
+}
+
+// EventstoreStatus defines the observed state of Eventstore. It's written by the operator when it
+// pushed the spec of ObservedGeneration to the sidecars, Ready is true if all of them received it.
+type EventstoreStatus struct {
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	Ready              bool   `json:"ready"`
+	Sidecars           int32  `json:"sidecars"`
+	Message            string `json:"message,omitempty"`
+}
+
+// Eventstore is the Schema for the eventstores API
+// +genclient
+// +resource:path=eventstore
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type Eventstore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   EventstoreSpec   `json:"spec,omitempty"`
+	Status EventstoreStatus `json:"status,omitempty"`
+}
+
+// EventstoreList contains a list of Eventstore
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +resourcepath=eventstore
+type EventstoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Eventstore `json:"items"`
+}
@@ -0,0 +1,385 @@
+// +build !ignore_autogenerated
+
+/*
+Copyright AndreasM009.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by deepcopy-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureTableStorageBackend) DeepCopyInto(out *AzureTableStorageBackend) {
+	*out = *in
+	in.AccountName.DeepCopyInto(&out.AccountName)
+	in.AccountKey.DeepCopyInto(&out.AccountKey)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureTableStorageBackend.
+func (in *AzureTableStorageBackend) DeepCopy() *AzureTableStorageBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureTableStorageBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Backend) DeepCopyInto(out *Backend) {
+	*out = *in
+	if in.InMemory != nil {
+		in, out := &in.InMemory, &out.InMemory
+		*out = new(InMemoryBackend)
+		**out = **in
+	}
+	if in.AzureTableStorage != nil {
+		in, out := &in.AzureTableStorage, &out.AzureTableStorage
+		*out = new(AzureTableStorageBackend)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CosmosDB != nil {
+		in, out := &in.CosmosDB, &out.CosmosDB
+		*out = new(CosmosDBBackend)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Chaos != nil {
+		in, out := &in.Chaos, &out.Chaos
+		*out = new(ChaosBackend)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Backend.
+func (in *Backend) DeepCopy() *Backend {
+	if in == nil {
+		return nil
+	}
+	out := new(Backend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChaosBackend) DeepCopyInto(out *ChaosBackend) {
+	*out = *in
+	in.Target.DeepCopyInto(&out.Target)
+	if in.OperationErrorRates != nil {
+		in, out := &in.OperationErrorRates, &out.OperationErrorRates
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChaosBackend.
+func (in *ChaosBackend) DeepCopy() *ChaosBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(ChaosBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CosmosDBBackend) DeepCopyInto(out *CosmosDBBackend) {
+	*out = *in
+	in.URL.DeepCopyInto(&out.URL)
+	in.MasterKey.DeepCopyInto(&out.MasterKey)
+	in.Database.DeepCopyInto(&out.Database)
+	in.Container.DeepCopyInto(&out.Container)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CosmosDBBackend.
+func (in *CosmosDBBackend) DeepCopy() *CosmosDBBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(CosmosDBBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Eventstore) DeepCopyInto(out *Eventstore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Eventstore.
+func (in *Eventstore) DeepCopy() *Eventstore {
+	if in == nil {
+		return nil
+	}
+	out := new(Eventstore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Eventstore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventstoreList) DeepCopyInto(out *EventstoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Eventstore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventstoreList.
+func (in *EventstoreList) DeepCopy() *EventstoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(EventstoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EventstoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventstoreSpec) DeepCopyInto(out *EventstoreSpec) {
+	*out = *in
+	in.Backend.DeepCopyInto(&out.Backend)
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make([]MetadataItem, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Sink != nil {
+		in, out := &in.Sink, &out.Sink
+		*out = new(SinkSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Projections != nil {
+		in, out := &in.Projections, &out.Projections
+		*out = make([]ProjectionSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Migration != nil {
+		in, out := &in.Migration, &out.Migration
+		*out = new(MigrationSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventstoreSpec.
+func (in *EventstoreSpec) DeepCopy() *EventstoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EventstoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventstoreStatus) DeepCopyInto(out *EventstoreStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventstoreStatus.
+func (in *EventstoreStatus) DeepCopy() *EventstoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EventstoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InMemoryBackend) DeepCopyInto(out *InMemoryBackend) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InMemoryBackend.
+func (in *InMemoryBackend) DeepCopy() *InMemoryBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(InMemoryBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataItem) DeepCopyInto(out *MetadataItem) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataItem.
+func (in *MetadataItem) DeepCopy() *MetadataItem {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataItem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationSpec) DeepCopyInto(out *MigrationSpec) {
+	*out = *in
+	in.Backend.DeepCopyInto(&out.Backend)
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make([]MetadataItem, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationSpec.
+func (in *MigrationSpec) DeepCopy() *MigrationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProjectionSpec) DeepCopyInto(out *ProjectionSpec) {
+	*out = *in
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = make([]MetadataItem, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProjectionSpec.
+func (in *ProjectionSpec) DeepCopy() *ProjectionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProjectionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyRef) DeepCopyInto(out *SecretKeyRef) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyRef.
+func (in *SecretKeyRef) DeepCopy() *SecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretValue) DeepCopyInto(out *SecretValue) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretValue.
+func (in *SecretValue) DeepCopy() *SecretValue {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretValue)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SinkSpec) DeepCopyInto(out *SinkSpec) {
+	*out = *in
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = make([]MetadataItem, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SinkSpec.
+func (in *SinkSpec) DeepCopy() *SinkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SinkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 
 	eventstorev1alpha1 "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned/typed/eventstore/v1alpha1"
+	eventstorev1beta1 "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned/typed/eventstore/v1beta1"
 	discovery "k8s.io/client-go/discovery"
 	rest "k8s.io/client-go/rest"
 	flowcontrol "k8s.io/client-go/util/flowcontrol"
@@ -30,6 +31,7 @@ import (
 type Interface interface {
 	Discovery() discovery.DiscoveryInterface
 	EventstoreV1alpha1() eventstorev1alpha1.EventstoreV1alpha1Interface
+	EventstoreV1beta1() eventstorev1beta1.EventstoreV1beta1Interface
 }
 
 // Clientset contains the clients for groups. Each group has exactly one
@@ -37,6 +39,7 @@ type Interface interface {
 type Clientset struct {
 	*discovery.DiscoveryClient
 	eventstoreV1alpha1 *eventstorev1alpha1.EventstoreV1alpha1Client
+	eventstoreV1beta1  *eventstorev1beta1.EventstoreV1beta1Client
 }
 
 // EventstoreV1alpha1 retrieves the EventstoreV1alpha1Client
@@ -44,6 +47,11 @@ func (c *Clientset) EventstoreV1alpha1() eventstorev1alpha1.EventstoreV1alpha1In
 	return c.eventstoreV1alpha1
 }
 
+// EventstoreV1beta1 retrieves the EventstoreV1beta1Client
+func (c *Clientset) EventstoreV1beta1() eventstorev1beta1.EventstoreV1beta1Interface {
+	return c.eventstoreV1beta1
+}
+
 // Discovery retrieves the DiscoveryClient
 func (c *Clientset) Discovery() discovery.DiscoveryInterface {
 	if c == nil {
@@ -69,6 +77,10 @@ func NewForConfig(c *rest.Config) (*Clientset, error) {
 	if err != nil {
 		return nil, err
 	}
+	cs.eventstoreV1beta1, err = eventstorev1beta1.NewForConfig(&configShallowCopy)
+	if err != nil {
+		return nil, err
+	}
 
 	cs.DiscoveryClient, err = discovery.NewDiscoveryClientForConfig(&configShallowCopy)
 	if err != nil {
@@ -82,6 +94,7 @@ func NewForConfig(c *rest.Config) (*Clientset, error) {
 func NewForConfigOrDie(c *rest.Config) *Clientset {
 	var cs Clientset
 	cs.eventstoreV1alpha1 = eventstorev1alpha1.NewForConfigOrDie(c)
+	cs.eventstoreV1beta1 = eventstorev1beta1.NewForConfigOrDie(c)
 
 	cs.DiscoveryClient = discovery.NewDiscoveryClientForConfigOrDie(c)
 	return &cs
@@ -91,6 +104,7 @@ func NewForConfigOrDie(c *rest.Config) *Clientset {
 func New(c rest.Interface) *Clientset {
 	var cs Clientset
 	cs.eventstoreV1alpha1 = eventstorev1alpha1.New(c)
+	cs.eventstoreV1beta1 = eventstorev1beta1.New(c)
 
 	cs.DiscoveryClient = discovery.NewDiscoveryClient(c)
 	return &cs
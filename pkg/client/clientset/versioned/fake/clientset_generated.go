@@ -22,6 +22,8 @@ import (
 	clientset "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned"
 	eventstorev1alpha1 "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned/typed/eventstore/v1alpha1"
 	fakeeventstorev1alpha1 "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned/typed/eventstore/v1alpha1/fake"
+	eventstorev1beta1 "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned/typed/eventstore/v1beta1"
+	fakeeventstorev1beta1 "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned/typed/eventstore/v1beta1/fake"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/discovery"
@@ -80,3 +82,8 @@ var _ clientset.Interface = &Clientset{}
 func (c *Clientset) EventstoreV1alpha1() eventstorev1alpha1.EventstoreV1alpha1Interface {
 	return &fakeeventstorev1alpha1.FakeEventstoreV1alpha1{Fake: &c.Fake}
 }
+
+// EventstoreV1beta1 retrieves the EventstoreV1beta1Client
+func (c *Clientset) EventstoreV1beta1() eventstorev1beta1.EventstoreV1beta1Interface {
+	return &fakeeventstorev1beta1.FakeEventstoreV1beta1{Fake: &c.Fake}
+}
@@ -40,6 +40,7 @@ type EventstoresGetter interface {
 type EventstoreInterface interface {
 	Create(ctx context.Context, eventstore *v1alpha1.Eventstore, opts v1.CreateOptions) (*v1alpha1.Eventstore, error)
 	Update(ctx context.Context, eventstore *v1alpha1.Eventstore, opts v1.UpdateOptions) (*v1alpha1.Eventstore, error)
+	UpdateStatus(ctx context.Context, eventstore *v1alpha1.Eventstore, opts v1.UpdateOptions) (*v1alpha1.Eventstore, error)
 	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
 	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.Eventstore, error)
@@ -135,6 +136,22 @@ func (c *eventstores) Update(ctx context.Context, eventstore *v1alpha1.Eventstor
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *eventstores) UpdateStatus(ctx context.Context, eventstore *v1alpha1.Eventstore, opts v1.UpdateOptions) (result *v1alpha1.Eventstore, err error) {
+	result = &v1alpha1.Eventstore{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("eventstores").
+		Name(eventstore.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(eventstore).
+		Do(ctx).
+		Into(result)
+	return
+}
+
 // Delete takes name of the eventstore and deletes it. Returns an error if one occurs.
 func (c *eventstores) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	return c.client.Delete().
@@ -27,6 +27,7 @@ import (
 type EventstoreV1alpha1Interface interface {
 	RESTClient() rest.Interface
 	EventstoresGetter
+	EventstoreSubscriptionsGetter
 }
 
 // EventstoreV1alpha1Client is used to interact with features provided by the eventstore.io group.
@@ -38,6 +39,10 @@ func (c *EventstoreV1alpha1Client) Eventstores(namespace string) EventstoreInter
 	return newEventstores(c, namespace)
 }
 
+func (c *EventstoreV1alpha1Client) EventstoreSubscriptions(namespace string) EventstoreSubscriptionInterface {
+	return newEventstoreSubscriptions(c, namespace)
+}
+
 // NewForConfig creates a new EventstoreV1alpha1Client for the given config.
 func NewForConfig(c *rest.Config) (*EventstoreV1alpha1Client, error) {
 	config := *c
@@ -0,0 +1,195 @@
+/*
+Copyright AndreasM009.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	scheme "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// EventstoreSubscriptionsGetter has a method to return a EventstoreSubscriptionInterface.
+// A group's client should implement this interface.
+type EventstoreSubscriptionsGetter interface {
+	EventstoreSubscriptions(namespace string) EventstoreSubscriptionInterface
+}
+
+// EventstoreSubscriptionInterface has methods to work with EventstoreSubscription resources.
+type EventstoreSubscriptionInterface interface {
+	Create(ctx context.Context, eventstoreSubscription *v1alpha1.EventstoreSubscription, opts v1.CreateOptions) (*v1alpha1.EventstoreSubscription, error)
+	Update(ctx context.Context, eventstoreSubscription *v1alpha1.EventstoreSubscription, opts v1.UpdateOptions) (*v1alpha1.EventstoreSubscription, error)
+	UpdateStatus(ctx context.Context, eventstoreSubscription *v1alpha1.EventstoreSubscription, opts v1.UpdateOptions) (*v1alpha1.EventstoreSubscription, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.EventstoreSubscription, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1alpha1.EventstoreSubscriptionList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.EventstoreSubscription, err error)
+	EventstoreSubscriptionExpansion
+}
+
+// eventstoreSubscriptions implements EventstoreSubscriptionInterface
+type eventstoreSubscriptions struct {
+	client rest.Interface
+	ns     string
+}
+
+// newEventstoreSubscriptions returns a EventstoreSubscriptions
+func newEventstoreSubscriptions(c *EventstoreV1alpha1Client, namespace string) *eventstoreSubscriptions {
+	return &eventstoreSubscriptions{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the eventstoreSubscription, and returns the corresponding eventstoreSubscription object, and an error if there is any.
+func (c *eventstoreSubscriptions) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.EventstoreSubscription, err error) {
+	result = &v1alpha1.EventstoreSubscription{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("eventstoresubscriptions").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of EventstoreSubscriptions that match those selectors.
+func (c *eventstoreSubscriptions) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.EventstoreSubscriptionList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.EventstoreSubscriptionList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("eventstoresubscriptions").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested eventstoreSubscriptions.
+func (c *eventstoreSubscriptions) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("eventstoresubscriptions").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a eventstoreSubscription and creates it.  Returns the server's representation of the eventstoreSubscription, and an error, if there is any.
+func (c *eventstoreSubscriptions) Create(ctx context.Context, eventstoreSubscription *v1alpha1.EventstoreSubscription, opts v1.CreateOptions) (result *v1alpha1.EventstoreSubscription, err error) {
+	result = &v1alpha1.EventstoreSubscription{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("eventstoresubscriptions").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(eventstoreSubscription).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a eventstoreSubscription and updates it. Returns the server's representation of the eventstoreSubscription, and an error, if there is any.
+func (c *eventstoreSubscriptions) Update(ctx context.Context, eventstoreSubscription *v1alpha1.EventstoreSubscription, opts v1.UpdateOptions) (result *v1alpha1.EventstoreSubscription, err error) {
+	result = &v1alpha1.EventstoreSubscription{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("eventstoresubscriptions").
+		Name(eventstoreSubscription.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(eventstoreSubscription).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *eventstoreSubscriptions) UpdateStatus(ctx context.Context, eventstoreSubscription *v1alpha1.EventstoreSubscription, opts v1.UpdateOptions) (result *v1alpha1.EventstoreSubscription, err error) {
+	result = &v1alpha1.EventstoreSubscription{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("eventstoresubscriptions").
+		Name(eventstoreSubscription.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(eventstoreSubscription).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the eventstoreSubscription and deletes it. Returns an error if one occurs.
+func (c *eventstoreSubscriptions) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("eventstoresubscriptions").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *eventstoreSubscriptions) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("eventstoresubscriptions").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched eventstoreSubscription.
+func (c *eventstoreSubscriptions) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.EventstoreSubscription, err error) {
+	result = &v1alpha1.EventstoreSubscription{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("eventstoresubscriptions").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -102,6 +102,18 @@ func (c *FakeEventstores) Update(ctx context.Context, eventstore *v1alpha1.Event
 	return obj.(*v1alpha1.Eventstore), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeEventstores) UpdateStatus(ctx context.Context, eventstore *v1alpha1.Eventstore, opts v1.UpdateOptions) (*v1alpha1.Eventstore, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(eventstoresResource, "status", c.ns, eventstore), &v1alpha1.Eventstore{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.Eventstore), err
+}
+
 // Delete takes name of the eventstore and deletes it. Returns an error if one occurs.
 func (c *FakeEventstores) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	_, err := c.Fake.
@@ -32,6 +32,10 @@ func (c *FakeEventstoreV1alpha1) Eventstores(namespace string) v1alpha1.Eventsto
 	return &FakeEventstores{c, namespace}
 }
 
+func (c *FakeEventstoreV1alpha1) EventstoreSubscriptions(namespace string) v1alpha1.EventstoreSubscriptionInterface {
+	return &FakeEventstoreSubscriptions{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeEventstoreV1alpha1) RESTClient() rest.Interface {
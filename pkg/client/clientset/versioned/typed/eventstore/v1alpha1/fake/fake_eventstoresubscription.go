@@ -0,0 +1,142 @@
+/*
+Copyright AndreasM009.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeEventstoreSubscriptions implements EventstoreSubscriptionInterface
+type FakeEventstoreSubscriptions struct {
+	Fake *FakeEventstoreV1alpha1
+	ns   string
+}
+
+var eventstoresubscriptionsResource = schema.GroupVersionResource{Group: "eventstore.io", Version: "v1alpha1", Resource: "eventstoresubscriptions"}
+
+var eventstoresubscriptionsKind = schema.GroupVersionKind{Group: "eventstore.io", Version: "v1alpha1", Kind: "EventstoreSubscription"}
+
+// Get takes name of the eventstoreSubscription, and returns the corresponding eventstoreSubscription object, and an error if there is any.
+func (c *FakeEventstoreSubscriptions) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.EventstoreSubscription, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(eventstoresubscriptionsResource, c.ns, name), &v1alpha1.EventstoreSubscription{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.EventstoreSubscription), err
+}
+
+// List takes label and field selectors, and returns the list of EventstoreSubscriptions that match those selectors.
+func (c *FakeEventstoreSubscriptions) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.EventstoreSubscriptionList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(eventstoresubscriptionsResource, eventstoresubscriptionsKind, c.ns, opts), &v1alpha1.EventstoreSubscriptionList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.EventstoreSubscriptionList{ListMeta: obj.(*v1alpha1.EventstoreSubscriptionList).ListMeta}
+	for _, item := range obj.(*v1alpha1.EventstoreSubscriptionList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested eventstoreSubscriptions.
+func (c *FakeEventstoreSubscriptions) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(eventstoresubscriptionsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a eventstoreSubscription and creates it.  Returns the server's representation of the eventstoreSubscription, and an error, if there is any.
+func (c *FakeEventstoreSubscriptions) Create(ctx context.Context, eventstoreSubscription *v1alpha1.EventstoreSubscription, opts v1.CreateOptions) (result *v1alpha1.EventstoreSubscription, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(eventstoresubscriptionsResource, c.ns, eventstoreSubscription), &v1alpha1.EventstoreSubscription{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.EventstoreSubscription), err
+}
+
+// Update takes the representation of a eventstoreSubscription and updates it. Returns the server's representation of the eventstoreSubscription, and an error, if there is any.
+func (c *FakeEventstoreSubscriptions) Update(ctx context.Context, eventstoreSubscription *v1alpha1.EventstoreSubscription, opts v1.UpdateOptions) (result *v1alpha1.EventstoreSubscription, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(eventstoresubscriptionsResource, c.ns, eventstoreSubscription), &v1alpha1.EventstoreSubscription{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.EventstoreSubscription), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeEventstoreSubscriptions) UpdateStatus(ctx context.Context, eventstoreSubscription *v1alpha1.EventstoreSubscription, opts v1.UpdateOptions) (*v1alpha1.EventstoreSubscription, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(eventstoresubscriptionsResource, "status", c.ns, eventstoreSubscription), &v1alpha1.EventstoreSubscription{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.EventstoreSubscription), err
+}
+
+// Delete takes name of the eventstoreSubscription and deletes it. Returns an error if one occurs.
+func (c *FakeEventstoreSubscriptions) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(eventstoresubscriptionsResource, c.ns, name), &v1alpha1.EventstoreSubscription{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeEventstoreSubscriptions) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(eventstoresubscriptionsResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.EventstoreSubscriptionList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched eventstoreSubscription.
+func (c *FakeEventstoreSubscriptions) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.EventstoreSubscription, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(eventstoresubscriptionsResource, c.ns, name, pt, data, subresources...), &v1alpha1.EventstoreSubscription{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.EventstoreSubscription), err
+}
@@ -0,0 +1,90 @@
+/*
+Copyright AndreasM009.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	time "time"
+
+	eventstorev1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	versioned "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned"
+	internalinterfaces "github.com/AndreasM009/eventstore/pkg/client/informers/externalversions/internalinterfaces"
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/client/listers/eventstore/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// EventstoreSubscriptionInformer provides access to a shared informer and lister for
+// EventstoreSubscriptions.
+type EventstoreSubscriptionInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v1alpha1.EventstoreSubscriptionLister
+}
+
+type eventstoreSubscriptionInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewEventstoreSubscriptionInformer constructs a new informer for EventstoreSubscription type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewEventstoreSubscriptionInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredEventstoreSubscriptionInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredEventstoreSubscriptionInformer constructs a new informer for EventstoreSubscription type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredEventstoreSubscriptionInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.EventstoreV1alpha1().EventstoreSubscriptions(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.EventstoreV1alpha1().EventstoreSubscriptions(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&eventstorev1alpha1.EventstoreSubscription{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *eventstoreSubscriptionInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredEventstoreSubscriptionInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *eventstoreSubscriptionInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&eventstorev1alpha1.EventstoreSubscription{}, f.defaultInformer)
+}
+
+func (f *eventstoreSubscriptionInformer) Lister() v1alpha1.EventstoreSubscriptionLister {
+	return v1alpha1.NewEventstoreSubscriptionLister(f.Informer().GetIndexer())
+}
@@ -26,6 +26,8 @@ import (
 type Interface interface {
 	// Eventstores returns a EventstoreInformer.
 	Eventstores() EventstoreInformer
+	// EventstoreSubscriptions returns a EventstoreSubscriptionInformer.
+	EventstoreSubscriptions() EventstoreSubscriptionInformer
 }
 
 type version struct {
@@ -43,3 +45,8 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 func (v *version) Eventstores() EventstoreInformer {
 	return &eventstoreInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// EventstoreSubscriptions returns a EventstoreSubscriptionInformer.
+func (v *version) EventstoreSubscriptions() EventstoreSubscriptionInformer {
+	return &eventstoreSubscriptionInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
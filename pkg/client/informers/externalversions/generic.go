@@ -22,6 +22,7 @@ import (
 	"fmt"
 
 	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	v1beta1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1beta1"
 	schema "k8s.io/apimachinery/pkg/runtime/schema"
 	cache "k8s.io/client-go/tools/cache"
 )
@@ -55,6 +56,12 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	// Group=eventstore.io, Version=v1alpha1
 	case v1alpha1.SchemeGroupVersion.WithResource("eventstores"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Eventstore().V1alpha1().Eventstores().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("eventstoresubscriptions"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Eventstore().V1alpha1().EventstoreSubscriptions().Informer()}, nil
+
+		// Group=eventstore.io, Version=v1beta1
+	case v1beta1.SchemeGroupVersion.WithResource("eventstores"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Eventstore().V1beta1().Eventstores().Informer()}, nil
 
 	}
 
@@ -0,0 +1,94 @@
+/*
+Copyright AndreasM009.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// EventstoreSubscriptionLister helps list EventstoreSubscriptions.
+type EventstoreSubscriptionLister interface {
+	// List lists all EventstoreSubscriptions in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha1.EventstoreSubscription, err error)
+	// EventstoreSubscriptions returns an object that can list and get EventstoreSubscriptions.
+	EventstoreSubscriptions(namespace string) EventstoreSubscriptionNamespaceLister
+	EventstoreSubscriptionListerExpansion
+}
+
+// eventstoreSubscriptionLister implements the EventstoreSubscriptionLister interface.
+type eventstoreSubscriptionLister struct {
+	indexer cache.Indexer
+}
+
+// NewEventstoreSubscriptionLister returns a new EventstoreSubscriptionLister.
+func NewEventstoreSubscriptionLister(indexer cache.Indexer) EventstoreSubscriptionLister {
+	return &eventstoreSubscriptionLister{indexer: indexer}
+}
+
+// List lists all EventstoreSubscriptions in the indexer.
+func (s *eventstoreSubscriptionLister) List(selector labels.Selector) (ret []*v1alpha1.EventstoreSubscription, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.EventstoreSubscription))
+	})
+	return ret, err
+}
+
+// EventstoreSubscriptions returns an object that can list and get EventstoreSubscriptions.
+func (s *eventstoreSubscriptionLister) EventstoreSubscriptions(namespace string) EventstoreSubscriptionNamespaceLister {
+	return eventstoreSubscriptionNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// EventstoreSubscriptionNamespaceLister helps list and get EventstoreSubscriptions.
+type EventstoreSubscriptionNamespaceLister interface {
+	// List lists all EventstoreSubscriptions in the indexer for a given namespace.
+	List(selector labels.Selector) (ret []*v1alpha1.EventstoreSubscription, err error)
+	// Get retrieves the EventstoreSubscription from the indexer for a given namespace and name.
+	Get(name string) (*v1alpha1.EventstoreSubscription, error)
+	EventstoreSubscriptionNamespaceListerExpansion
+}
+
+// eventstoreSubscriptionNamespaceLister implements the EventstoreSubscriptionNamespaceLister
+// interface.
+type eventstoreSubscriptionNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all EventstoreSubscriptions in the indexer for a given namespace.
+func (s eventstoreSubscriptionNamespaceLister) List(selector labels.Selector) (ret []*v1alpha1.EventstoreSubscription, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.EventstoreSubscription))
+	})
+	return ret, err
+}
+
+// Get retrieves the EventstoreSubscription from the indexer for a given namespace and name.
+func (s eventstoreSubscriptionNamespaceLister) Get(name string) (*v1alpha1.EventstoreSubscription, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("eventstoresubscription"), name)
+	}
+	return obj.(*v1alpha1.EventstoreSubscription), nil
+}
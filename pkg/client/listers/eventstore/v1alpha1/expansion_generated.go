@@ -25,3 +25,11 @@ type EventstoreListerExpansion interface{}
 // EventstoreNamespaceListerExpansion allows custom methods to be added to
 // EventstoreNamespaceLister.
 type EventstoreNamespaceListerExpansion interface{}
+
+// EventstoreSubscriptionListerExpansion allows custom methods to be added to
+// EventstoreSubscriptionLister.
+type EventstoreSubscriptionListerExpansion interface{}
+
+// EventstoreSubscriptionNamespaceListerExpansion allows custom methods to be added to
+// EventstoreSubscriptionNamespaceLister.
+type EventstoreSubscriptionNamespaceListerExpansion interface{}
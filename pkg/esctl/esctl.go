@@ -0,0 +1,282 @@
+package esctl
+
+//---------------------------------------------------------------------------------------------
+// esctl exports and imports stores through the HTTP API of eventstored.
+//
+// An export is written to '<out>.partial' and renamed to '<out>' when complete. With resume, the
+// complete lines of an existing partial export are kept and the export continues after them.
+// An import posts the archive in batches, an interrupted import is resumed by running it again,
+// as eventstored skips versions that already exist. Imports are authorized with the API token
+// of eventstored.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/AndreasM009/eventstore/pkg/eventstored/archive"
+)
+
+// DefaultBatchSize is the number of lines posted per import request
+const DefaultBatchSize = 1000
+
+// Client of the eventstored HTTP API
+type Client struct {
+	endpoint string
+	token    string
+	http     *http.Client
+}
+
+// NewClient creates a new Client for the eventstored endpoint, e.g. 'http://localhost:5000'
+func NewClient(endpoint string) *Client {
+	return &Client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		http:     &http.Client{},
+	}
+}
+
+// WithToken sets the API token sent with every request
+func (c *Client) WithToken(token string) *Client {
+	c.token = token
+	return c
+}
+
+// Export exports store to the file out and returns the number of exported lines
+func (c *Client) Export(store, out string, resume bool) (int, error) {
+	partial := out + ".partial"
+	from := archive.Cursor{}
+	lines := 0
+
+	if resume {
+		last, n, err := compact(partial)
+		if err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+
+		from, lines = last, n
+	} else if err := os.Remove(partial); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close()
+
+	query := url.Values{}
+	if from.ID != "" {
+		query.Set("fromid", from.ID)
+		query.Set("fromversion", strconv.FormatInt(from.Version, 10))
+	}
+
+	resp, err := c.do(http.MethodGet, fmt.Sprintf("%s/eventstores/%s/export?%s", c.endpoint, url.PathEscape(store), query.Encode()), "", nil)
+	if err != nil {
+		return lines, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return lines, responseError(resp)
+	}
+
+	r, err := archive.NewReader(resp.Body)
+	if err != nil {
+		return lines, err
+	}
+
+	w := archive.NewWriter(f)
+
+	for {
+		line, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			w.Flush() // nolint: errcheck
+			return lines, fmt.Errorf("export interrupted after %d lines, continue with resume: %s", lines, err)
+		}
+
+		if err := w.Write(*line); err != nil {
+			return lines, err
+		}
+
+		lines++
+	}
+
+	if err := w.Close(); err != nil {
+		return lines, err
+	}
+
+	if err := f.Close(); err != nil {
+		return lines, err
+	}
+
+	return lines, os.Rename(partial, out)
+}
+
+// Import imports the archive in into store, with dryRun the archive is validated by eventstored
+// in a single request, as versions of later batches depend on the ones before.
+func (c *Client) Import(store, in string, dryRun bool, batchSize int) (archive.Summary, error) {
+	total := archive.Summary{DryRun: dryRun}
+
+	f, err := os.Open(in)
+	if err != nil {
+		return total, err
+	}
+
+	defer f.Close()
+
+	r, err := archive.NewReader(f)
+	if err != nil {
+		return total, err
+	}
+
+	if dryRun || batchSize <= 0 {
+		batchSize = -1
+	}
+
+	for done := false; !done; {
+		body := &bytes.Buffer{}
+		w := archive.NewWriter(body)
+		n := 0
+
+		for batchSize < 0 || n < batchSize {
+			line, err := r.Next()
+			if err == io.EOF {
+				done = true
+				break
+			} else if err != nil {
+				return total, err
+			}
+
+			if err := w.Write(*line); err != nil {
+				return total, err
+			}
+
+			n++
+		}
+
+		if n == 0 && total.Lines > 0 {
+			break
+		}
+
+		if err := w.Close(); err != nil {
+			return total, err
+		}
+
+		summary, err := c.postImport(store, body, dryRun)
+		if err != nil {
+			return total, fmt.Errorf("import stopped after %d lines: %s", total.Lines, err)
+		}
+
+		total.Lines += summary.Lines
+		total.Imported += summary.Imported
+		total.Skipped += summary.Skipped
+	}
+
+	return total, nil
+}
+
+func (c *Client) postImport(store string, body io.Reader, dryRun bool) (archive.Summary, error) {
+	summary := archive.Summary{}
+
+	resp, err := c.do(http.MethodPost,
+		fmt.Sprintf("%s/eventstores/%s/import?dryrun=%t", c.endpoint, url.PathEscape(store), dryRun), "application/gzip", body)
+	if err != nil {
+		return summary, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return summary, responseError(resp)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&summary)
+	return summary, err
+}
+
+func (c *Client) do(method, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	return c.http.Do(req)
+}
+
+// compact rewrites the complete lines of a partial export to a complete archive, so the export
+// can be continued with a new gzip member. The cursor after the last line and the number of the
+// lines is returned.
+func compact(partial string) (archive.Cursor, int, error) {
+	from := archive.Cursor{}
+
+	in, err := os.Open(partial)
+	if err != nil {
+		return from, 0, err
+	}
+
+	defer in.Close()
+
+	tmp := partial + ".tmp"
+
+	out, err := os.Create(tmp)
+	if err != nil {
+		return from, 0, err
+	}
+
+	defer out.Close()
+
+	w := archive.NewWriter(out)
+	lines := 0
+
+	// an empty or broken partial export is started from the beginning
+	if r, err := archive.NewReader(in); err == nil {
+		for {
+			line, err := r.Next()
+			if err != nil {
+				break
+			}
+
+			if err := w.Write(*line); err != nil {
+				return from, 0, err
+			}
+
+			from = archive.After(line)
+			lines++
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return from, 0, err
+	}
+
+	if err := out.Close(); err != nil {
+		return from, 0, err
+	}
+
+	return from, lines, os.Rename(tmp, partial)
+}
+
+func responseError(resp *http.Response) error {
+	data, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("eventstored returned %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+}
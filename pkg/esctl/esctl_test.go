@@ -0,0 +1,138 @@
+package esctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/archive"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/stretchr/testify/assert"
+)
+
+const testToken = "secret"
+
+// testServer serves export and import like eventstored, the first export can be interrupted after one line
+type testServer struct {
+	source    *journal.Store
+	target    store.EventStore
+	interrupt bool
+	requests  int
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/eventstores/test/export":
+		version, _ := strconv.ParseInt(r.URL.Query().Get("fromversion"), 10, 64)
+		from := archive.Cursor{ID: r.URL.Query().Get("fromid"), Version: version}
+		aw := archive.NewWriter(w)
+
+		if s.interrupt {
+			s.interrupt = false
+			buf := &bytes.Buffer{}
+			full := archive.NewWriter(buf)
+			archive.Export(full, s.source, from) // nolint: errcheck
+			full.Close()                         // nolint: errcheck
+
+			ar, _ := archive.NewReader(buf)
+			line, _ := ar.Next()
+			aw.Write(*line) // nolint: errcheck
+			aw.Flush()      // nolint: errcheck
+			return
+		}
+
+		archive.Export(aw, s.source, from) // nolint: errcheck
+		aw.Close()                         // nolint: errcheck
+	case "/eventstores/test/import":
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		s.requests++
+		ar, err := archive.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		summary, err := archive.Import(ar, s.target, r.URL.Query().Get("dryrun") == "true")
+		if err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+
+		json.NewEncoder(w).Encode(summary) // nolint: errcheck
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestServer(t *testing.T) *testServer {
+	j := journal.NewStore(fakestore.NewStore())
+
+	for _, id := range []string{"order-1", "order-2", "order-3"} {
+		_, err := j.Add(&store.Entity{ID: id, Data: id})
+		assert.Nil(t, err)
+	}
+
+	_, err := j.Append(&store.Entity{ID: "order-1", Data: "paid"}, store.None)
+	assert.Nil(t, err)
+
+	return &testServer{source: j, target: fakestore.NewStore()}
+}
+
+func TestExportAndImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esctl")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	ts := newTestServer(t)
+	ts.interrupt = true
+	server := httptest.NewServer(ts)
+	defer server.Close()
+
+	client := NewClient(server.URL).WithToken(testToken)
+	out := filepath.Join(dir, "test.ndjson.gz")
+
+	_, err = client.Export("test", out, false)
+	assert.NotNil(t, err)
+
+	_, err = os.Stat(out)
+	assert.True(t, os.IsNotExist(err))
+
+	lines, err := client.Export("test", out, true)
+	assert.Nil(t, err)
+	// the journal is exported with the entities
+	assert.Equal(t, 8, lines)
+
+	summary, err := client.Import("test", out, true, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, archive.Summary{Lines: 8, Imported: 8, DryRun: true}, summary)
+	assert.Equal(t, 1, ts.requests)
+
+	summary, err = client.Import("test", out, false, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, archive.Summary{Lines: 8, Imported: 8}, summary)
+	assert.Equal(t, 4, ts.requests)
+
+	ety, err := ts.target.GetByVersion("order-1", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "paid", ety.Data)
+
+	summary, err = client.Import("test", out, false, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, archive.Summary{Lines: 8, Skipped: 8}, summary)
+
+	// imports require the token
+	_, err = NewClient(server.URL).Import("test", out, false, 3)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,246 @@
+package archive
+
+//---------------------------------------------------------------------------------------------
+// An archive is a gzip compressed NDJSON file with one entity version per line. An export scans
+// all entities of a store, so it requires a backend that can enumerate its entities. Streams are
+// exported in the order of their ids, versions in order, so an export can be resumed after the
+// last line in the archive. An archive may consist of multiple gzip members, e.g. the pages of a
+// resumed export.
+//
+// Besides entities, an archive carries the streams derived from them: the journal, timestamps
+// and categories. They are restored as they were, not derived again, so an archive is meant to
+// be imported into a store that isn't written to otherwise. Other system streams, e.g.
+// checkpoints, belong to the consumers of a store and aren't archived.
+//
+// An import preserves ids and versions. Versions that already exist in the target store are
+// skipped, so an interrupted import is resumed by importing the same archive again.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/category"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/timestamps"
+)
+
+const exportPageSize = 100
+
+// Line is an entity version in an archive
+type Line struct {
+	Entity store.Entity `json:"entity"`
+}
+
+// Cursor is the version an export starts at, the zero Cursor exports everything
+type Cursor struct {
+	ID      string
+	Version int64
+}
+
+// After returns the Cursor that continues an export after line
+func After(line *Line) Cursor {
+	return Cursor{ID: line.Entity.ID, Version: line.Entity.Version + 1}
+}
+
+// Summary is the result of an import
+type Summary struct {
+	Lines    int  `json:"lines"`
+	Imported int  `json:"imported"`
+	Skipped  int  `json:"skipped"`
+	DryRun   bool `json:"dryRun"`
+}
+
+// Writer writes lines to a gzip compressed NDJSON archive
+type Writer struct {
+	gz *gzip.Writer
+}
+
+// NewWriter creates a new Writer writing to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{gz: gzip.NewWriter(w)}
+}
+
+// Write writes a line
+func (w *Writer) Write(line Line) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.gz.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Flush flushes the lines written so far to the underlying writer
+func (w *Writer) Flush() error {
+	return w.gz.Flush()
+}
+
+// Close completes the archive, it doesn't close the underlying writer
+func (w *Writer) Close() error {
+	return w.gz.Close()
+}
+
+// Reader reads lines of a gzip compressed NDJSON archive
+type Reader struct {
+	reader *bufio.Reader
+	number int
+}
+
+// NewReader creates a new Reader reading from r
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("archive: not a gzip compressed archive: %s", err)
+	}
+
+	return &Reader{reader: bufio.NewReader(gz)}, nil
+}
+
+// Next returns the next line, io.EOF is returned at the end of the archive
+func (r *Reader) Next() (*Line, error) {
+	for {
+		data, err := r.reader.ReadBytes('\n')
+		if err == io.EOF && len(data) == 0 {
+			return nil, io.EOF
+		} else if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("archive: can't read line %d: %s", r.number+1, err)
+		}
+
+		r.number++
+
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+
+		line := Line{}
+		if err := json.Unmarshal(data, &line); err != nil {
+			return nil, fmt.Errorf("archive: invalid line %d: %s", r.number, err)
+		}
+
+		return &line, nil
+	}
+}
+
+// Number returns the number of the line returned last
+func (r *Reader) Number() int {
+	return r.number
+}
+
+// Archived checks if the stream id is carried by archives
+func Archived(id string) bool {
+	return !streams.IsSystemID(id) ||
+		id == journal.StreamID ||
+		strings.HasPrefix(id, timestamps.StreamPrefix) ||
+		strings.HasPrefix(id, category.StreamPrefix)
+}
+
+// Export writes the archived streams of s, starting at from, to w. False is returned if s can't
+// enumerate its entities.
+func Export(w *Writer, s store.EventStore, from Cursor) (bool, error) {
+	return streams.ListIDs(s, "", func(id string) error {
+		if !Archived(id) || id < from.ID {
+			return nil
+		}
+
+		start := int64(1)
+		if id == from.ID && from.Version > 1 {
+			start = from.Version
+		}
+
+		return exportStream(w, s, id, start)
+	})
+}
+
+// exportStream writes the versions of the stream id from start up to its latest version
+func exportStream(w *Writer, s store.EventStore, id string, start int64) error {
+	latest, err := streams.LatestVersion(s, id)
+	if err != nil {
+		return err
+	}
+
+	for from := start; from <= latest; from += exportPageSize {
+		to := from + exportPageSize - 1
+		if to > latest {
+			to = latest
+		}
+
+		versions, err := streams.ReadRange(s, id, from, to)
+		if err != nil {
+			return fmt.Errorf("archive: can't read versions %v to %v of %s: %s", from, to, id, err)
+		}
+
+		for _, ety := range versions {
+			if err := w.Write(Line{Entity: ety}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Import writes the entity versions of an archive to s as they are, s must not derive streams
+// from writes. Each version must follow the latest version of its entity in s or in the lines
+// before. With dryRun the archive is only validated.
+func Import(r *Reader, s store.EventStore, dryRun bool) (Summary, error) {
+	summary := Summary{DryRun: dryRun}
+	latest := map[string]int64{}
+
+	for {
+		line, err := r.Next()
+		if err == io.EOF {
+			return summary, nil
+		} else if err != nil {
+			return summary, err
+		}
+
+		summary.Lines++
+		ety := line.Entity
+
+		if ety.ID == "" || !Archived(ety.ID) {
+			return summary, fmt.Errorf("archive: line %d: invalid entity id '%s'", r.Number(), ety.ID)
+		}
+
+		if ety.Version < 1 {
+			return summary, fmt.Errorf("archive: line %d: invalid version %v of %s", r.Number(), ety.Version, ety.ID)
+		}
+
+		current, ok := latest[ety.ID]
+		if !ok {
+			if current, err = streams.LatestVersion(s, ety.ID); err != nil {
+				return summary, err
+			}
+		}
+
+		if ety.Version <= current {
+			latest[ety.ID] = current
+			summary.Skipped++
+			continue
+		}
+
+		if ety.Version != current+1 {
+			return summary, fmt.Errorf("archive: line %d: version %v of %s doesn't follow version %v", r.Number(), ety.Version, ety.ID, current)
+		}
+
+		if !dryRun {
+			if err := streams.AppendVersion(s, &ety); err != nil {
+				return summary, fmt.Errorf("archive: line %d: %s", r.Number(), err)
+			}
+		}
+
+		latest[ety.ID] = ety.Version
+		summary.Imported++
+	}
+}
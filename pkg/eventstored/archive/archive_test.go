@@ -0,0 +1,177 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore-impl/store/inmemory"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestJournal(t *testing.T) *journal.Store {
+	j := journal.NewStore(fakestore.NewStore())
+
+	_, err := j.Add(&store.Entity{ID: "order-1", Metadata: "created", Data: map[string]interface{}{"total": 1}})
+	assert.Nil(t, err)
+	_, err = j.Add(&store.Entity{ID: "order-2", Metadata: "created", Data: map[string]interface{}{"total": 2}})
+	assert.Nil(t, err)
+	_, err = j.Append(&store.Entity{ID: "order-1", Metadata: "paid", Data: map[string]interface{}{"total": 3}}, store.None)
+	assert.Nil(t, err)
+
+	return j
+}
+
+func export(t *testing.T, s store.EventStore, from Cursor) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	ok, err := Export(w, s, from)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	return buf
+}
+
+func readAll(t *testing.T, buf *bytes.Buffer) []string {
+	r, err := NewReader(buf)
+	assert.Nil(t, err)
+
+	lines := []string{}
+	for {
+		line, err := r.Next()
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			return lines
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %d", line.Entity.ID, line.Entity.Version))
+	}
+}
+
+func importArchive(t *testing.T, data []byte, s store.EventStore, dryRun bool) (Summary, error) {
+	r, err := NewReader(bytes.NewReader(data))
+	assert.Nil(t, err)
+	return Import(r, s, dryRun)
+}
+
+func TestExport(t *testing.T) {
+	j := newTestJournal(t)
+	_, err := j.Add(&store.Entity{ID: "$checkpoint-sink", Data: 1})
+	assert.Nil(t, err)
+
+	// streams in the order of their ids, the journal is archived, checkpoints aren't
+	assert.Equal(t, []string{
+		"$journal 1", "$journal 2", "$journal 3",
+		"order-1 1", "order-1 2",
+		"order-2 1",
+	}, readAll(t, export(t, j, Cursor{})))
+
+	assert.Equal(t, []string{"order-1 2", "order-2 1"}, readAll(t, export(t, j, Cursor{ID: "order-1", Version: 2})))
+	assert.Equal(t, []string{"order-2 1"}, readAll(t, export(t, j, After(&Line{Entity: store.Entity{ID: "order-1", Version: 2}}))))
+}
+
+func TestExportRequiresAListableBackend(t *testing.T) {
+	s := inmemory.NewStore()
+	assert.Nil(t, s.Init(store.Metadata{}))
+
+	ok, err := Export(NewWriter(&bytes.Buffer{}), s, Cursor{})
+	assert.False(t, ok)
+	assert.Nil(t, err)
+}
+
+func TestImportPreservesVersions(t *testing.T) {
+	data := export(t, newTestJournal(t), Cursor{}).Bytes()
+	target := fakestore.NewStore()
+
+	summary, err := importArchive(t, data, target, false)
+	assert.Nil(t, err)
+	assert.Equal(t, Summary{Lines: 6, Imported: 6}, summary)
+
+	ety, err := target.GetByVersion("order-1", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "paid", ety.Metadata)
+	assert.Equal(t, map[string]interface{}{"total": float64(3)}, ety.Data)
+
+	// the journal is restored, not written again
+	records, err := journal.NewStore(target).Read(1, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(records))
+	assert.Equal(t, "order-1", records[2].ID)
+	assert.Equal(t, int64(2), records[2].Version)
+
+	// a repeated import resumes after the existing versions
+	summary, err = importArchive(t, data, target, false)
+	assert.Nil(t, err)
+	assert.Equal(t, Summary{Lines: 6, Skipped: 6}, summary)
+}
+
+func TestImportDryRun(t *testing.T) {
+	data := export(t, newTestJournal(t), Cursor{}).Bytes()
+	target := fakestore.NewStore()
+
+	summary, err := importArchive(t, data, target, true)
+	assert.Nil(t, err)
+	assert.Equal(t, Summary{Lines: 6, Imported: 6, DryRun: true}, summary)
+
+	_, err = target.GetLatestVersionNumber("order-1")
+	assert.NotNil(t, err)
+}
+
+func TestImportRejectsGaps(t *testing.T) {
+	// the archive starts with the second version of order-1
+	data := export(t, newTestJournal(t), Cursor{ID: "order-1", Version: 2}).Bytes()
+
+	summary, err := importArchive(t, data, fakestore.NewStore(), true)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, summary.Lines)
+	assert.Equal(t, 0, summary.Imported)
+}
+
+func TestImportRejectsSystemIDs(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	assert.Nil(t, w.Write(Line{Entity: store.Entity{ID: "$checkpoint-sink", Version: 1}}))
+	assert.Nil(t, w.Close())
+
+	_, err := importArchive(t, buf.Bytes(), fakestore.NewStore(), false)
+	assert.NotNil(t, err)
+}
+
+func TestReaderReadsMultipleMembers(t *testing.T) {
+	j := newTestJournal(t)
+	buf := export(t, j, Cursor{})
+	buf.Write(export(t, j, Cursor{ID: "order-1", Version: 2}).Bytes())
+
+	r, err := NewReader(buf)
+	assert.Nil(t, err)
+
+	n := 0
+	for {
+		if _, err := r.Next(); err != nil {
+			break
+		}
+		n++
+	}
+
+	assert.Equal(t, 8, n)
+}
+
+func TestReaderDetectsTruncatedArchive(t *testing.T) {
+	data := export(t, newTestJournal(t), Cursor{}).Bytes()
+
+	r, err := NewReader(bytes.NewReader(data[:len(data)-4]))
+	assert.Nil(t, err)
+
+	for {
+		_, err = r.Next()
+		if err != nil {
+			break
+		}
+	}
+
+	assert.NotEqual(t, "EOF", err.Error())
+}
@@ -0,0 +1,250 @@
+package cache
+
+//---------------------------------------------------------------------------------------------
+// The cache serves reads of hot entities from memory. Versions are immutable, so they are cached
+// until they are evicted. The latest version number of an entity changes with every append: it
+// is updated by appends through this instance and expires after a TTL, as other instances may
+// append to the same backend. System streams are not cached, they are written by decorators
+// below the cache.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"sync"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+)
+
+const (
+	// SizeKey is the store metadata key that enables the cache, the value is the maximum number
+	// of cached versions and latest version numbers
+	SizeKey = "cacheSize"
+	// LatestTTLKey is the store metadata key of the duration latest version numbers are cached,
+	// e.g. '1s'. '0' caches them until the next append through this instance, which is only
+	// correct if no other instance writes to the backend.
+	LatestTTLKey = "cacheLatestTTL"
+	// DefaultLatestTTL is the duration latest version numbers are cached by default
+	DefaultLatestTTL = time.Second
+)
+
+// Stats are the metrics of a cache
+type Stats struct {
+	Size      int   `json:"size"`
+	Entries   int   `json:"entries"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+// Store is an EventStore that caches versions and latest version numbers of entities
+type Store struct {
+	store.EventStore
+	latestTTL time.Duration
+	now       func() time.Time
+	mutex     sync.Mutex
+	lru       *lru
+	hits      int64
+	misses    int64
+}
+
+// NewStore creates a new cache Store wrapping s, holding at most size entries
+func NewStore(s store.EventStore, size int, latestTTL time.Duration) *Store {
+	return &Store{
+		EventStore: s,
+		latestTTL:  latestTTL,
+		now:        time.Now,
+		lru:        newLRU(size),
+	}
+}
+
+// From returns the cache Store in the chain of decorators of s
+func From(s store.EventStore) (*Store, bool) {
+	cs, ok := wrapper.Find(s, func(s store.EventStore) bool {
+		_, ok := s.(*Store)
+		return ok
+	}).(*Store)
+
+	return cs, ok
+}
+
+// Unwrap returns the decorated EventStore
+func (s *Store) Unwrap() store.EventStore {
+	return s.EventStore
+}
+
+// Add adds the first version of an entity and caches it
+func (s *Store) Add(entity *store.Entity) (*store.Entity, error) {
+	res, err := s.EventStore.Add(entity)
+	if err != nil {
+		return res, err
+	}
+
+	s.written(res)
+	return res, nil
+}
+
+// Append appends a new version of an entity and caches it
+func (s *Store) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	res, err := s.EventStore.Append(entity, concurrency)
+	if err != nil {
+		// a conflict means the cached latest version is outdated
+		s.Invalidate(entity.ID)
+		return res, err
+	}
+
+	s.written(res)
+	return res, nil
+}
+
+// GetLatestVersionNumber returns the latest version of an entity
+func (s *Store) GetLatestVersionNumber(id string) (int64, error) {
+	v, _, err := s.LatestVersion(id)
+	return v, err
+}
+
+// GetByVersion returns a version of an entity
+func (s *Store) GetByVersion(id string, version int64) (*store.Entity, error) {
+	ety, _, err := s.Version(id, version)
+	return ety, err
+}
+
+// GetByVersionRange returns the versions of an entity in a range, the versions are cached
+func (s *Store) GetByVersionRange(id string, startVersion, endVersion int64) ([]store.Entity, error) {
+	etys, err := s.EventStore.GetByVersionRange(id, startVersion, endVersion)
+	if err != nil || streams.IsSystemID(id) {
+		return etys, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, e := range etys {
+		s.lru.put(&entry{key: key{id: e.ID, version: e.Version}, entity: e})
+	}
+
+	return etys, nil
+}
+
+// LatestVersion returns the latest version of an entity and whether it was served from the cache
+func (s *Store) LatestVersion(id string) (int64, bool, error) {
+	if streams.IsSystemID(id) {
+		v, err := s.EventStore.GetLatestVersionNumber(id)
+		return v, false, err
+	}
+
+	s.mutex.Lock()
+	e, ok := s.lru.get(key{id: id})
+	if ok && (s.latestTTL == 0 || s.now().Before(e.expires)) {
+		s.hits++
+		s.mutex.Unlock()
+		return e.latest, true, nil
+	}
+
+	s.misses++
+	s.mutex.Unlock()
+
+	v, err := s.EventStore.GetLatestVersionNumber(id)
+	if err != nil {
+		return v, false, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.putLatest(id, v)
+	return v, false, nil
+}
+
+// Version returns a version of an entity and whether it was served from the cache
+func (s *Store) Version(id string, version int64) (*store.Entity, bool, error) {
+	if streams.IsSystemID(id) {
+		ety, err := s.EventStore.GetByVersion(id, version)
+		return ety, false, err
+	}
+
+	k := key{id: id, version: version}
+
+	s.mutex.Lock()
+	e, ok := s.lru.get(k)
+	if ok {
+		s.hits++
+		s.mutex.Unlock()
+		ety := e.entity
+		return &ety, true, nil
+	}
+
+	s.misses++
+	s.mutex.Unlock()
+
+	ety, err := s.EventStore.GetByVersion(id, version)
+	if err != nil {
+		return ety, false, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lru.put(&entry{key: k, entity: *ety})
+	return ety, false, nil
+}
+
+// Cached checks if a version of an entity, or its latest version number if version is 0, would
+// be served from the cache. The metrics are not updated.
+func (s *Store) Cached(id string, version int64) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.lru.entries[key{id: id, version: version}]
+	if !ok {
+		return false
+	}
+
+	return version != 0 || s.latestTTL == 0 || s.now().Before(e.Value.(*entry).expires)
+}
+
+// Stats returns the metrics of the cache
+func (s *Store) Stats() Stats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return Stats{
+		Size:      s.lru.size,
+		Entries:   s.lru.len(),
+		Hits:      s.hits,
+		Misses:    s.misses,
+		Evictions: s.lru.evicted,
+	}
+}
+
+// written caches a version written through this instance
+func (s *Store) written(ety *store.Entity) {
+	if streams.IsSystemID(ety.ID) {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lru.put(&entry{key: key{id: ety.ID, version: ety.Version}, entity: *ety})
+
+	// concurrent appends may finish out of order, never go back to an older version
+	if e, ok := s.lru.get(key{id: ety.ID}); ok && e.latest > ety.Version {
+		return
+	}
+
+	s.putLatest(ety.ID, ety.Version)
+}
+
+func (s *Store) putLatest(id string, version int64) {
+	s.lru.put(&entry{key: key{id: id}, latest: version, expires: s.now().Add(s.latestTTL)})
+}
+
+// Invalidate forgets the latest version number of id, e.g. after it was written below the cache
+func (s *Store) Invalidate(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lru.remove(key{id: id})
+}
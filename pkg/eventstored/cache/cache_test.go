@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionsAreCached(t *testing.T) {
+	s := NewStore(fakestore.NewStore(), 10, DefaultLatestTTL)
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "one"})
+	assert.Nil(t, err)
+
+	ety, hit, err := s.Version("order-1", 1)
+	assert.Nil(t, err)
+	assert.True(t, hit, "written versions are cached")
+	assert.Equal(t, "one", ety.Data)
+
+	s.lru.remove(key{id: "order-1", version: 1})
+
+	_, hit, err = s.Version("order-1", 1)
+	assert.Nil(t, err)
+	assert.False(t, hit)
+
+	_, hit, err = s.Version("order-1", 1)
+	assert.Nil(t, err)
+	assert.True(t, hit)
+
+	stats := s.Stats()
+	assert.Equal(t, int64(2), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+}
+
+func TestLatestVersionFollowsLocalAppends(t *testing.T) {
+	s := NewStore(fakestore.NewStore(), 10, 0)
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "one"})
+	assert.Nil(t, err)
+
+	_, err = s.Append(&store.Entity{ID: "order-1", Version: 1, Data: "two"}, store.Optimistic)
+	assert.Nil(t, err)
+
+	v, hit, err := s.LatestVersion("order-1")
+	assert.Nil(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, int64(2), v)
+}
+
+func TestLatestVersionExpires(t *testing.T) {
+	backend := fakestore.NewStore()
+	s := NewStore(backend, 10, time.Second)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "one"})
+	assert.Nil(t, err)
+
+	// another instance appends to the backend
+	_, err = backend.Append(&store.Entity{ID: "order-1", Version: 1, Data: "two"}, store.Optimistic)
+	assert.Nil(t, err)
+
+	v, hit, err := s.LatestVersion("order-1")
+	assert.Nil(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, int64(1), v)
+
+	now = now.Add(2 * time.Second)
+
+	v, hit, err = s.LatestVersion("order-1")
+	assert.Nil(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, int64(2), v)
+}
+
+func TestConflictInvalidatesLatestVersion(t *testing.T) {
+	backend := fakestore.NewStore()
+	s := NewStore(backend, 10, 0)
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "one"})
+	assert.Nil(t, err)
+
+	_, err = backend.Append(&store.Entity{ID: "order-1", Version: 1, Data: "two"}, store.Optimistic)
+	assert.Nil(t, err)
+
+	_, err = s.Append(&store.Entity{ID: "order-1", Version: 1, Data: "three"}, store.Optimistic)
+	assert.NotNil(t, err)
+
+	v, hit, err := s.LatestVersion("order-1")
+	assert.Nil(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, int64(2), v)
+}
+
+func TestEviction(t *testing.T) {
+	s := NewStore(fakestore.NewStore(), 2, 0)
+
+	for _, id := range []string{"order-1", "order-2"} {
+		_, err := s.Add(&store.Entity{ID: id, Data: id})
+		assert.Nil(t, err)
+	}
+
+	stats := s.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, int64(2), stats.Evictions)
+
+	// the latest version of order-2 was written last
+	_, hit, err := s.LatestVersion("order-2")
+	assert.Nil(t, err)
+	assert.True(t, hit)
+
+	_, hit, err = s.Version("order-1", 1)
+	assert.Nil(t, err)
+	assert.False(t, hit)
+}
+
+func TestSystemStreamsAreNotCached(t *testing.T) {
+	s := NewStore(fakestore.NewStore(), 10, 0)
+
+	_, err := s.Add(&store.Entity{ID: "$ce-order", Data: "one"})
+	assert.Nil(t, err)
+
+	_, hit, err := s.Version("$ce-order", 1)
+	assert.Nil(t, err)
+	assert.False(t, hit)
+
+	_, hit, err = s.LatestVersion("$ce-order")
+	assert.Nil(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, 0, s.Stats().Entries)
+}
+
+func TestCached(t *testing.T) {
+	s := NewStore(fakestore.NewStore(), 10, time.Second)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "one"})
+	assert.Nil(t, err)
+
+	assert.True(t, s.Cached("order-1", 1))
+	assert.True(t, s.Cached("order-1", 0))
+	assert.False(t, s.Cached("order-1", 2))
+
+	now = now.Add(2 * time.Second)
+	assert.False(t, s.Cached("order-1", 0), "expired latest versions are not cached")
+	assert.Equal(t, int64(0), s.Stats().Hits)
+}
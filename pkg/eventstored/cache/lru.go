@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+)
+
+// key of a cached entry, version 0 is the latest version number of an entity
+type key struct {
+	id      string
+	version int64
+}
+
+type entry struct {
+	key     key
+	entity  store.Entity
+	latest  int64
+	expires time.Time
+}
+
+// lru is a least recently used cache of a fixed number of entries, it's not safe for concurrent use
+type lru struct {
+	size    int
+	order   *list.List
+	entries map[key]*list.Element
+	evicted int64
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:    size,
+		order:   list.New(),
+		entries: map[key]*list.Element{},
+	}
+}
+
+func (c *lru) get(k key) (*entry, bool) {
+	e, ok := c.entries[k]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(e)
+	return e.Value.(*entry), true
+}
+
+func (c *lru) put(value *entry) {
+	if e, ok := c.entries[value.key]; ok {
+		e.Value = value
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.entries[value.key] = c.order.PushFront(value)
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+		c.evicted++
+	}
+}
+
+func (c *lru) remove(k key) {
+	if e, ok := c.entries[k]; ok {
+		c.order.Remove(e)
+		delete(c.entries, k)
+	}
+}
+
+func (c *lru) len() int {
+	return c.order.Len()
+}
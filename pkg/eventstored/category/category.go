@@ -0,0 +1,325 @@
+package category
+
+//---------------------------------------------------------------------------------------------
+// Category streams group entities by the prefix of their ids, e.g. with separator '-' the
+// entities 'order-1' and 'order-2' belong to category 'order'. Every committed entity version
+// is indexed in the system stream '$ce-<category>', so the position of an event in its category
+// is the version of the index entry and reflects write order.
+// The index entry is written after the entity version was committed. If it can't be written, the
+// version and all later versions of the entity are indexed in order by the background repair of
+// the store.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+)
+
+const (
+	// SeparatorKey is the store metadata key that enables category streams
+	SeparatorKey = "categorySeparator"
+	// StreamPrefix is the prefix of category streams, followed by the category
+	StreamPrefix = streams.SystemPrefix + "ce-"
+)
+
+// Event is an entity version read from a category stream
+type Event struct {
+	Position int64 `json:"position"`
+	store.Entity
+}
+
+type indexEntry struct {
+	ID      string `json:"id"`
+	Version int64  `json:"version"`
+}
+
+// Store is an EventStore that maintains category streams for all entities written through it
+type Store struct {
+	store.EventStore
+	separator   string
+	mutex       sync.Mutex
+	subscribers map[chan struct{}]struct{}
+	// pendingMutex guards pending
+	pendingMutex sync.Mutex
+	// pending holds the versions that still have to be indexed in order by entity id
+	pending  map[string][]int64
+	repair   chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	started  bool
+}
+
+// NewStore creates a new category Store wrapping s. Stores that are written to must be started,
+// so versions that failed to be indexed are repaired.
+func NewStore(s store.EventStore, separator string) *Store {
+	return &Store{
+		EventStore:  s,
+		separator:   separator,
+		subscribers: map[chan struct{}]struct{}{},
+		pending:     map[string][]int64{},
+		repair:      make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start repairs versions that failed to be indexed in the background, retrying with backoff
+func (s *Store) Start(backoff journal.Backoff) {
+	s.started = true
+	go s.run(backoff)
+}
+
+// Close stops the repair of a started store
+func (s *Store) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+
+	if s.started {
+		<-s.done
+	}
+
+	return nil
+}
+
+// From returns the category Store in the chain of decorators of s
+func From(s store.EventStore) (*Store, bool) {
+	cs, ok := wrapper.Find(s, func(s store.EventStore) bool {
+		_, ok := s.(*Store)
+		return ok
+	}).(*Store)
+
+	return cs, ok
+}
+
+// Unwrap returns the decorated EventStore
+func (s *Store) Unwrap() store.EventStore {
+	return s.EventStore
+}
+
+// Add adds a new entity and indexes its first version
+func (s *Store) Add(entity *store.Entity) (*store.Entity, error) {
+	res, err := s.EventStore.Add(entity)
+	if err != nil {
+		return res, err
+	}
+
+	s.index(res)
+	return res, nil
+}
+
+// Append appends a new version of an entity and indexes it
+func (s *Store) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	res, err := s.EventStore.Append(entity, concurrency)
+	if err != nil {
+		return res, err
+	}
+
+	s.index(res)
+	return res, nil
+}
+
+// Category returns the category of an entity id, false is returned if the id has no category
+func (s *Store) Category(id string) (string, bool) {
+	return Of(id, s.separator)
+}
+
+// Of returns the category of an entity id with the separator, false is returned if the id has no category
+func Of(id, separator string) (string, bool) {
+	if streams.IsSystemID(id) {
+		return "", false
+	}
+
+	i := strings.Index(id, separator)
+	if i <= 0 {
+		return "", false
+	}
+
+	return id[:i], true
+}
+
+// Read reads at most max events of a category, starting at position from
+func (s *Store) Read(category string, from int64, max int) ([]Event, error) {
+	stream := StreamPrefix + category
+
+	latest, err := streams.LatestVersion(s.EventStore, stream)
+	if err != nil {
+		return nil, err
+	}
+
+	if from < 1 {
+		from = 1
+	}
+
+	result := []Event{}
+
+	if latest < from || max <= 0 {
+		return result, nil
+	}
+
+	end := from + int64(max) - 1
+	if end > latest {
+		end = latest
+	}
+
+	entries, err := streams.ReadRange(s.EventStore, stream, from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		entry := indexEntry{}
+		if err := streams.Decode(&entries[i], &entry); err != nil {
+			return nil, fmt.Errorf("category: can't decode index entry %v of %s: %s", entries[i].Version, stream, err)
+		}
+
+		ety, err := s.EventStore.GetByVersion(entry.ID, entry.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, Event{
+			Position: entries[i].Version,
+			Entity:   *ety,
+		})
+	}
+
+	return result, nil
+}
+
+// Subscribe returns a channel that is signaled whenever an entity version was indexed.
+// Signals are coalesced, so readers must read all categories they are interested in.
+// The returned function cancels the subscription.
+func (s *Store) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	s.mutex.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mutex.Unlock()
+
+	return ch, func() {
+		s.mutex.Lock()
+		delete(s.subscribers, ch)
+		s.mutex.Unlock()
+	}
+}
+
+func (s *Store) index(entity *store.Entity) {
+	if _, ok := s.Category(entity.ID); !ok {
+		return
+	}
+
+	s.pendingMutex.Lock()
+	waiting := len(s.pending[entity.ID]) > 0
+	s.pendingMutex.Unlock()
+
+	// later versions must not overtake versions that are waiting for the repair
+	if !waiting {
+		err := s.write(entity.ID, entity.Version)
+		if err == nil {
+			return
+		}
+
+		log.Printf("category: failed to index version %v of %s, it's repaired in the background: %s\n", entity.Version, entity.ID, err)
+	}
+
+	s.pendingMutex.Lock()
+	s.pending[entity.ID] = append(s.pending[entity.ID], entity.Version)
+	s.pendingMutex.Unlock()
+
+	select {
+	case s.repair <- struct{}{}:
+	default:
+	}
+}
+
+// write appends the entry of version of entity id to the stream of its category
+func (s *Store) write(id string, version int64) error {
+	category, _ := s.Category(id)
+
+	if _, err := streams.Append(s.EventStore, StreamPrefix+category, indexEntry{ID: id, Version: version}); err != nil {
+		return err
+	}
+
+	s.notify()
+	return nil
+}
+
+func (s *Store) run(backoff journal.Backoff) {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.repair:
+		}
+
+		for attempt := 0; !s.repairPending(); attempt++ {
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(backoff.Delay(attempt)):
+			}
+		}
+	}
+}
+
+// repairPending indexes the pending versions, true is returned if none is left
+func (s *Store) repairPending() bool {
+	s.pendingMutex.Lock()
+	ids := make([]string, 0, len(s.pending))
+	for id := range s.pending {
+		ids = append(ids, id)
+	}
+	s.pendingMutex.Unlock()
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		for {
+			s.pendingMutex.Lock()
+			if len(s.pending[id]) == 0 {
+				delete(s.pending, id)
+				s.pendingMutex.Unlock()
+				break
+			}
+
+			version := s.pending[id][0]
+			s.pendingMutex.Unlock()
+
+			if err := s.write(id, version); err != nil {
+				log.Printf("category: failed to repair version %v of %s: %s\n", version, id, err)
+				return false
+			}
+
+			s.pendingMutex.Lock()
+			s.pending[id] = s.pending[id][1:]
+			s.pendingMutex.Unlock()
+		}
+	}
+
+	return true
+}
+
+func (s *Store) notify() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
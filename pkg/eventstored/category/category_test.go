@@ -0,0 +1,178 @@
+package category
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategory(t *testing.T) {
+	s := NewStore(fakestore.NewStore(), "-")
+
+	c, ok := s.Category("order-123")
+	assert.True(t, ok)
+	assert.Equal(t, "order", c)
+
+	c, ok = s.Category("order-123-456")
+	assert.True(t, ok)
+	assert.Equal(t, "order", c)
+
+	_, ok = s.Category("order")
+	assert.False(t, ok)
+
+	_, ok = s.Category("-123")
+	assert.False(t, ok)
+
+	_, ok = s.Category("$ce-order")
+	assert.False(t, ok)
+}
+
+func TestReadInWriteOrder(t *testing.T) {
+	s := NewStore(fakestore.NewStore(), "-")
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "created"})
+	assert.Nil(t, err)
+	_, err = s.Add(&store.Entity{ID: "customer-1", Data: "created"})
+	assert.Nil(t, err)
+	_, err = s.Add(&store.Entity{ID: "order-2", Data: "created"})
+	assert.Nil(t, err)
+	_, err = s.Append(&store.Entity{ID: "order-1", Data: "shipped"}, store.None)
+	assert.Nil(t, err)
+
+	events, err := s.Read("order", 1, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(events))
+
+	assert.Equal(t, int64(1), events[0].Position)
+	assert.Equal(t, "order-1", events[0].ID)
+	assert.Equal(t, int64(1), events[0].Version)
+	assert.Equal(t, "order-2", events[1].ID)
+	assert.Equal(t, "order-1", events[2].ID)
+	assert.Equal(t, int64(2), events[2].Version)
+	assert.Equal(t, "shipped", events[2].Data)
+
+	events, err = s.Read("order", 3, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, int64(3), events[0].Position)
+
+	events, err = s.Read("order", 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(events))
+
+	events, err = s.Read("customer", 1, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+}
+
+func TestReadUnknownCategory(t *testing.T) {
+	s := NewStore(fakestore.NewStore(), "-")
+
+	events, err := s.Read("invoice", 1, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(events))
+}
+
+func TestSubscribe(t *testing.T) {
+	s := NewStore(fakestore.NewStore(), "-")
+
+	signal, cancel := s.Subscribe()
+	defer cancel()
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "created"})
+	assert.Nil(t, err)
+
+	select {
+	case <-signal:
+	default:
+		t.Error("subscriber was not notified")
+	}
+}
+
+func TestFrom(t *testing.T) {
+	s := NewStore(fakestore.NewStore(), "-")
+
+	found, ok := From(s)
+	assert.True(t, ok)
+	assert.Equal(t, s, found)
+
+	_, ok = From(fakestore.NewStore())
+	assert.False(t, ok)
+}
+
+// failingIndex fails writes of category streams while failing is set
+type failingIndex struct {
+	store.EventStore
+	mutex   sync.Mutex
+	failing bool
+}
+
+func (f *failingIndex) setFailing(failing bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.failing = failing
+}
+
+func (f *failingIndex) fails(entity *store.Entity) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.failing && strings.HasPrefix(entity.ID, StreamPrefix)
+}
+
+func (f *failingIndex) Add(entity *store.Entity) (*store.Entity, error) {
+	if f.fails(entity) {
+		return nil, errors.New("index unavailable")
+	}
+
+	return f.EventStore.Add(entity)
+}
+
+func (f *failingIndex) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	if f.fails(entity) {
+		return nil, errors.New("index unavailable")
+	}
+
+	return f.EventStore.Append(entity, concurrency)
+}
+
+func TestFailedIndexEntriesAreRepaired(t *testing.T) {
+	backend := &failingIndex{EventStore: fakestore.NewStore(), failing: true}
+	s := NewStore(backend, "-")
+	s.Start(journal.Backoff{Min: time.Millisecond, Max: time.Millisecond})
+	defer s.Close()
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "created"})
+	assert.Nil(t, err)
+	_, err = s.Append(&store.Entity{ID: "order-1", Data: "shipped"}, store.None)
+	assert.Nil(t, err)
+
+	events, err := s.Read("order", 1, 100)
+	assert.Nil(t, err)
+	assert.Empty(t, events)
+
+	backend.setFailing(false)
+
+	assert.Eventually(t, func() bool {
+		events, err = s.Read("order", 1, 100)
+		return err == nil && len(events) == 2
+	}, time.Second, time.Millisecond)
+
+	// the versions are indexed in order
+	assert.Equal(t, int64(1), events[0].Version)
+	assert.Equal(t, int64(2), events[1].Version)
+
+	// later versions are indexed directly again
+	_, err = s.Append(&store.Entity{ID: "order-1", Data: "paid"}, store.None)
+	assert.Nil(t, err)
+	events, err = s.Read("order", 3, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, int64(3), events[0].Version)
+}
@@ -0,0 +1,353 @@
+package chaos
+
+//---------------------------------------------------------------------------------------------
+// The chaos store injects faults into the calls of another backend, so services can be tested
+// against a failing eventstored. The fault profile is read from the store metadata and can be
+// replaced at runtime.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+)
+
+const (
+	// TargetKey is the store metadata key of the type of the backend the faults are injected
+	// into, e.g. 'eventstore.inmemory'. All other metadata is passed to the backend.
+	TargetKey = "chaosTarget"
+	// LatencyKey is the store metadata key of the latency added to every call, e.g. '100ms'
+	LatencyKey = "chaosLatency"
+	// ErrorRateKey is the store metadata key of the rate of calls that fail, e.g. '0.1'. The
+	// rate of a single operation is configured with '<ErrorRateKey>.<operation>'.
+	ErrorRateKey = "chaosErrorRate"
+	// ConflictRateKey is the store metadata key of the rate of appends that fail with a
+	// version conflict
+	ConflictRateKey = "chaosConflictRate"
+	// StaleReadRateKey is the store metadata key of the rate of reads of the latest version
+	// number that return the version before the latest one
+	StaleReadRateKey = "chaosStaleReadRate"
+)
+
+// Operations of an EventStore, used for error rates per operation
+const (
+	OpAdd                    = "add"
+	OpAppend                 = "append"
+	OpGetLatestVersionNumber = "getLatestVersionNumber"
+	OpGetByVersion           = "getByVersion"
+	OpGetByVersionRange      = "getByVersionRange"
+)
+
+var operations = []string{OpAdd, OpAppend, OpGetLatestVersionNumber, OpGetByVersion, OpGetByVersionRange}
+
+// Duration is a time.Duration that is serialized as a string, e.g. '100ms'
+type Duration time.Duration
+
+// MarshalJSON serializes d as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON parses d from a string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+// Profile describes the faults that are injected
+type Profile struct {
+	Latency Duration `json:"latency"`
+	// ErrorRate applies to all operations without an own rate in ErrorRates
+	ErrorRate     float64            `json:"errorRate"`
+	ErrorRates    map[string]float64 `json:"errorRates,omitempty"`
+	ConflictRate  float64            `json:"conflictRate"`
+	StaleReadRate float64            `json:"staleReadRate"`
+}
+
+// Validate checks the rates and operations of the profile
+func (p Profile) Validate() error {
+	rates := map[string]float64{
+		"errorRate":     p.ErrorRate,
+		"conflictRate":  p.ConflictRate,
+		"staleReadRate": p.StaleReadRate,
+	}
+
+	for op, rate := range p.ErrorRates {
+		if !isOperation(op) {
+			return fmt.Errorf("chaos: unknown operation '%s', expected one of %s", op, strings.Join(operations, ", "))
+		}
+
+		rates["errorRates."+op] = rate
+	}
+
+	for name, rate := range rates {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("chaos: %s must be between 0 and 1", name)
+		}
+	}
+
+	if p.Latency < 0 {
+		return fmt.Errorf("chaos: latency must not be negative")
+	}
+
+	return nil
+}
+
+// ProfileFrom reads the fault profile from store metadata
+func ProfileFrom(metadata store.Metadata) (Profile, error) {
+	profile := Profile{
+		ErrorRates: map[string]float64{},
+	}
+
+	if value := metadata.Properties[LatencyKey]; value != "" {
+		latency, err := time.ParseDuration(value)
+		if err != nil {
+			return profile, fmt.Errorf("chaos: invalid %s '%s', expected a duration like '100ms'", LatencyKey, value)
+		}
+
+		profile.Latency = Duration(latency)
+	}
+
+	rates := map[string]*float64{
+		ErrorRateKey:     &profile.ErrorRate,
+		ConflictRateKey:  &profile.ConflictRate,
+		StaleReadRateKey: &profile.StaleReadRate,
+	}
+
+	for k, value := range metadata.Properties {
+		if !strings.HasPrefix(k, ErrorRateKey+".") || value == "" {
+			continue
+		}
+
+		rate, err := parseRate(k, value)
+		if err != nil {
+			return profile, err
+		}
+
+		profile.ErrorRates[strings.TrimPrefix(k, ErrorRateKey+".")] = rate
+	}
+
+	for k, r := range rates {
+		value := metadata.Properties[k]
+		if value == "" {
+			continue
+		}
+
+		rate, err := parseRate(k, value)
+		if err != nil {
+			return profile, err
+		}
+
+		*r = rate
+	}
+
+	return profile, profile.Validate()
+}
+
+// Store is an EventStore that injects faults into the calls of a backend
+type Store struct {
+	store.EventStore
+	backend func(typ string) (store.EventStore, error)
+	mutex   sync.Mutex
+	profile Profile
+	random  func() float64
+	sleep   func(time.Duration)
+}
+
+// NewStore creates a new chaos Store, the backend is created by Init with the factory backend
+func NewStore(backend func(typ string) (store.EventStore, error)) *Store {
+	return &Store{
+		backend: backend,
+		random:  rand.Float64,
+		sleep:   time.Sleep,
+	}
+}
+
+// From returns the chaos Store in the chain of decorators of s
+func From(s store.EventStore) (*Store, bool) {
+	cs, ok := wrapper.Find(s, func(s store.EventStore) bool {
+		_, ok := s.(*Store)
+		return ok
+	}).(*Store)
+
+	return cs, ok
+}
+
+// Unwrap returns the backend
+func (s *Store) Unwrap() store.EventStore {
+	return s.EventStore
+}
+
+// Init creates and initializes the backend and reads the fault profile
+func (s *Store) Init(metadata store.Metadata) error {
+	typ := metadata.Properties[TargetKey]
+	if typ == "" {
+		return fmt.Errorf("chaos: %s is missing", TargetKey)
+	}
+
+	profile, err := ProfileFrom(metadata)
+	if err != nil {
+		return err
+	}
+
+	target, err := s.backend(typ)
+	if err != nil {
+		return err
+	}
+
+	if err := target.Init(metadata); err != nil {
+		return err
+	}
+
+	s.EventStore = target
+	s.profile = profile
+	return nil
+}
+
+// Profile returns the current fault profile
+func (s *Store) Profile() Profile {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.profile
+}
+
+// SetProfile replaces the fault profile
+func (s *Store) SetProfile(profile Profile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.profile = profile
+	return nil
+}
+
+// Add adds the first version of an entity
+func (s *Store) Add(entity *store.Entity) (*store.Entity, error) {
+	if err := s.inject(OpAdd); err != nil {
+		return nil, err
+	}
+
+	return s.EventStore.Add(entity)
+}
+
+// Append appends a new version of an entity, it may fail with a forced version conflict
+func (s *Store) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	if err := s.inject(OpAppend); err != nil {
+		return nil, err
+	}
+
+	if s.chance(s.Profile().ConflictRate) {
+		return nil, store.EventStoreError{
+			Text:      "chaos: injected version conflict",
+			ErrorType: store.VersionConflict,
+		}
+	}
+
+	return s.EventStore.Append(entity, concurrency)
+}
+
+// GetLatestVersionNumber returns the latest version of an entity, it may be a stale read
+func (s *Store) GetLatestVersionNumber(id string) (int64, error) {
+	if err := s.inject(OpGetLatestVersionNumber); err != nil {
+		return 0, err
+	}
+
+	v, err := s.EventStore.GetLatestVersionNumber(id)
+	if err == nil && v > 1 && s.chance(s.Profile().StaleReadRate) {
+		return v - 1, nil
+	}
+
+	return v, err
+}
+
+// GetByVersion returns a version of an entity
+func (s *Store) GetByVersion(id string, version int64) (*store.Entity, error) {
+	if err := s.inject(OpGetByVersion); err != nil {
+		return nil, err
+	}
+
+	return s.EventStore.GetByVersion(id, version)
+}
+
+// GetByVersionRange returns the versions of an entity in a range
+func (s *Store) GetByVersionRange(id string, startVersion, endVersion int64) ([]store.Entity, error) {
+	if err := s.inject(OpGetByVersionRange); err != nil {
+		return nil, err
+	}
+
+	return s.EventStore.GetByVersionRange(id, startVersion, endVersion)
+}
+
+// inject waits for the latency of the profile and returns an error, if the call of op should fail
+func (s *Store) inject(op string) error {
+	profile := s.Profile()
+
+	if profile.Latency > 0 {
+		s.sleep(time.Duration(profile.Latency))
+	}
+
+	rate, ok := profile.ErrorRates[op]
+	if !ok {
+		rate = profile.ErrorRate
+	}
+
+	if s.chance(rate) {
+		return store.EventStoreError{
+			Text:      fmt.Sprintf("chaos: injected failure of %s", op),
+			ErrorType: store.InternalError,
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.random() < rate
+}
+
+func isOperation(op string) bool {
+	for _, o := range operations {
+		if o == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseRate(key, value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("chaos: invalid %s '%s', expected a rate between 0 and 1", key, value)
+	}
+
+	return rate, nil
+}
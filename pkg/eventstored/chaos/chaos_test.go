@@ -0,0 +1,124 @@
+package chaos
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T, properties map[string]string) *Store {
+	s := NewStore(func(typ string) (store.EventStore, error) {
+		if typ != "eventstore.fake" {
+			return nil, errors.New("unknown type")
+		}
+
+		return fakestore.NewStore(), nil
+	})
+
+	properties[TargetKey] = "eventstore.fake"
+	assert.Nil(t, s.Init(store.Metadata{Properties: properties}))
+
+	s.sleep = func(time.Duration) {}
+	return s
+}
+
+func TestProfileFrom(t *testing.T) {
+	profile, err := ProfileFrom(store.Metadata{Properties: map[string]string{
+		LatencyKey:                 "100ms",
+		ErrorRateKey:               "0.1",
+		ErrorRateKey + "." + OpAdd: "0.5",
+		ConflictRateKey:            "0.2",
+		StaleReadRateKey:           "0.3",
+	}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, Profile{
+		Latency:       Duration(100 * time.Millisecond),
+		ErrorRate:     0.1,
+		ErrorRates:    map[string]float64{OpAdd: 0.5},
+		ConflictRate:  0.2,
+		StaleReadRate: 0.3,
+	}, profile)
+
+	_, err = ProfileFrom(store.Metadata{Properties: map[string]string{ErrorRateKey: "2"}})
+	assert.NotNil(t, err)
+
+	_, err = ProfileFrom(store.Metadata{Properties: map[string]string{ErrorRateKey + ".delete": "0.5"}})
+	assert.NotNil(t, err)
+}
+
+func TestInitRequiresTarget(t *testing.T) {
+	s := NewStore(func(typ string) (store.EventStore, error) {
+		return nil, errors.New("unknown type")
+	})
+
+	assert.NotNil(t, s.Init(store.Metadata{Properties: map[string]string{}}))
+	assert.NotNil(t, s.Init(store.Metadata{Properties: map[string]string{TargetKey: "eventstore.unknown"}}))
+}
+
+func TestErrorRatePerOperation(t *testing.T) {
+	s := newTestStore(t, map[string]string{
+		ErrorRateKey + "." + OpGetByVersion: "1",
+	})
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "one"})
+	assert.Nil(t, err)
+
+	_, err = s.GetLatestVersionNumber("order-1")
+	assert.Nil(t, err)
+
+	_, err = s.GetByVersion("order-1", 1)
+	assert.Equal(t, store.InternalError, err.(store.EventStoreError).ErrorType)
+}
+
+func TestConflictsAndStaleReads(t *testing.T) {
+	s := newTestStore(t, map[string]string{})
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "one"})
+	assert.Nil(t, err)
+
+	_, err = s.Append(&store.Entity{ID: "order-1", Version: 1, Data: "two"}, store.Optimistic)
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.SetProfile(Profile{ConflictRate: 1, StaleReadRate: 1}))
+
+	_, err = s.Append(&store.Entity{ID: "order-1", Version: 2, Data: "three"}, store.Optimistic)
+	assert.Equal(t, store.VersionConflict, err.(store.EventStoreError).ErrorType)
+
+	v, err := s.GetLatestVersionNumber("order-1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), v)
+}
+
+func TestLatency(t *testing.T) {
+	s := newTestStore(t, map[string]string{LatencyKey: "50ms"})
+
+	var slept time.Duration
+	s.sleep = func(d time.Duration) { slept += d }
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "one"})
+	assert.Nil(t, err)
+	assert.Equal(t, 50*time.Millisecond, slept)
+}
+
+func TestSetProfileValidates(t *testing.T) {
+	s := newTestStore(t, map[string]string{})
+
+	assert.NotNil(t, s.SetProfile(Profile{StaleReadRate: -1}))
+	assert.NotNil(t, s.SetProfile(Profile{ErrorRates: map[string]float64{"delete": 1}}))
+}
+
+func TestProfileJSON(t *testing.T) {
+	profile := Profile{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"latency":"1s","errorRates":{"append":0.5}}`), &profile))
+	assert.Equal(t, Duration(time.Second), profile.Latency)
+
+	data, err := json.Marshal(profile)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"latency":"1s","errorRate":0,"errorRates":{"append":0.5},"conflictRate":0,"staleReadRate":0}`, string(data))
+}
@@ -1,20 +1,64 @@
 package config
 
-// ConfigurationMetadata metatdata props of config
+// ConfigurationMetadata metatdata props of config. Namespace and ResourceVersion are the namespace
+// and the version of the Eventstore in Kubernetes, they are empty in standalone mode.
 type ConfigurationMetadata struct {
-	Name string `yaml:"name"`
+	Name            string `yaml:"name"`
+	Namespace       string `yaml:"namespace,omitempty"`
+	ResourceVersion string `yaml:"resourceVersion,omitempty"`
 }
 
-// SpecMetadata spec metadata part
+// SpecMetadata spec metadata part. In standalone mode ValueFrom and SecretKeyRef are resolved into
+// Value when the configuration is loaded, SecretKeyRef from a directory of secret files. In
+// Kubernetes the operator resolves SecretKeyRef when it pushes the configuration, the watched
+// configurations have no secret values. ValueFrom is standalone only, the Eventstore schema
+// doesn't accept it.
 type SpecMetadata struct {
-	Name  string `yaml:"name"`
-	Value string `yaml:"value"`
+	Name         string        `yaml:"name"`
+	Value        string        `yaml:"value"`
+	ValueFrom    *ValueFrom    `yaml:"valueFrom,omitempty"`
+	SecretKeyRef *SecretKeyRef `yaml:"secretKeyRef,omitempty"`
 }
 
-// Spec spec part of config
-type Spec struct {
+// ValueFrom is the source of a metadata value, either a file or an environment variable
+type ValueFrom struct {
+	File string `yaml:"file"`
+	Env  string `yaml:"env"`
+}
+
+// SecretKeyRef is a reference to the key of a Kubernetes secret
+type SecretKeyRef struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+// SinkSpec sink part of spec
+type SinkSpec struct {
+	Type     string         `yaml:"type"`
+	Metadata []SpecMetadata `yaml:"metadata"`
+}
+
+// ProjectionSpec projection part of spec
+type ProjectionSpec struct {
+	Name     string         `yaml:"name"`
+	Type     string         `yaml:"type"`
+	Metadata []SpecMetadata `yaml:"metadata"`
+}
+
+// MigrationSpec migration part of spec
+type MigrationSpec struct {
 	Type     string         `yaml:"type"`
 	Metadata []SpecMetadata `yaml:"metadata"`
+	Stage    string         `yaml:"stage"`
+}
+
+// Spec spec part of config
+type Spec struct {
+	Type        string           `yaml:"type"`
+	Metadata    []SpecMetadata   `yaml:"metadata"`
+	Sink        *SinkSpec        `yaml:"sink"`
+	Projections []ProjectionSpec `yaml:"projections"`
+	Migration   *MigrationSpec   `yaml:"migration"`
 }
 
 // Configuration for evenstore to use
@@ -28,3 +72,9 @@ type Configuration struct {
 type ConfigurationProvider interface {
 	LoadConfig() ([]Configuration, error)
 }
+
+// ConfigurationWatcher is implemented by providers that follow the changes of the configuration.
+// Watch calls apply with the configuration of all stores whenever it changed, until stop is closed.
+type ConfigurationWatcher interface {
+	Watch(stop <-chan struct{}, apply func([]Configuration))
+}
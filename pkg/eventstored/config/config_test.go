@@ -33,3 +33,61 @@ func TestReadConfig(t *testing.T) {
 	assert.Equal(t, "storageAccountKey", config.Spec.Metadata[1].Name)
 	assert.Equal(t, "testaccountkey", config.Spec.Metadata[1].Value)
 }
+
+var testConfigWithSink = `
+kind: eventstore
+metadata:
+  name: myeventstore
+spec:
+  type: eventstore.inmemory
+  sink:
+    type: nats
+    metadata:
+    - name: url
+      value: "nats://localhost:4222"
+    - name: subject
+      value: "orders"
+`
+
+func TestReadConfigWithSink(t *testing.T) {
+	config := Configuration{}
+	err := yaml.Unmarshal([]byte(testConfigWithSink), &config)
+	assert.Nil(t, err)
+
+	assert.NotNil(t, config.Spec.Sink)
+	assert.Equal(t, "nats", config.Spec.Sink.Type)
+	assert.Equal(t, "url", config.Spec.Sink.Metadata[0].Name)
+	assert.Equal(t, "nats://localhost:4222", config.Spec.Sink.Metadata[0].Value)
+	assert.Equal(t, "subject", config.Spec.Sink.Metadata[1].Name)
+}
+
+var testConfigWithProjections = `
+kind: eventstore
+metadata:
+  name: myeventstore
+spec:
+  type: eventstore.inmemory
+  projections:
+  - name: orders
+    type: mergepatch
+  - name: totals
+    type: starlark
+    metadata:
+    - name: script
+      value: |
+        def reduce(state, entity):
+            return entity["data"]
+`
+
+func TestReadConfigWithProjections(t *testing.T) {
+	config := Configuration{}
+	err := yaml.Unmarshal([]byte(testConfigWithProjections), &config)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 2, len(config.Spec.Projections))
+	assert.Equal(t, "orders", config.Spec.Projections[0].Name)
+	assert.Equal(t, "mergepatch", config.Spec.Projections[0].Type)
+	assert.Equal(t, "totals", config.Spec.Projections[1].Name)
+	assert.Equal(t, "script", config.Spec.Projections[1].Metadata[0].Name)
+	assert.Contains(t, config.Spec.Projections[1].Metadata[0].Value, "def reduce(state, entity):")
+}
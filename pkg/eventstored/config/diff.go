@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Diff describes the changes from old to new of a store's configuration. Values of metadata are
+// left out, they may contain secrets.
+func Diff(old, new Configuration) []string {
+	changes := []string{}
+
+	if old.Spec.Type != new.Spec.Type {
+		changes = append(changes, fmt.Sprintf("type %s -> %s", old.Spec.Type, new.Spec.Type))
+	}
+
+	changes = append(changes, diffMetadata("metadata", old.Spec.Metadata, new.Spec.Metadata)...)
+
+	if !reflect.DeepEqual(old.Spec.Sink, new.Spec.Sink) {
+		changes = append(changes, "sink changed")
+	}
+
+	if !reflect.DeepEqual(old.Spec.Projections, new.Spec.Projections) {
+		changes = append(changes, "projections changed")
+	}
+
+	if !reflect.DeepEqual(old.Spec.Migration, new.Spec.Migration) {
+		changes = append(changes, "migration changed")
+	}
+
+	if old.Kind != new.Kind {
+		changes = append(changes, fmt.Sprintf("kind %s -> %s", old.Kind, new.Kind))
+	}
+
+	return changes
+}
+
+func diffMetadata(prefix string, old, new []SpecMetadata) []string {
+	oldValues := map[string]string{}
+	for _, m := range old {
+		oldValues[m.Name] = m.Value
+	}
+
+	newValues := map[string]string{}
+	for _, m := range new {
+		newValues[m.Name] = m.Value
+	}
+
+	changes := []string{}
+
+	for name, value := range newValues {
+		oldValue, ok := oldValues[name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("%s %s added", prefix, name))
+		} else if oldValue != value {
+			changes = append(changes, fmt.Sprintf("%s %s changed", prefix, name))
+		}
+	}
+
+	for name := range oldValues {
+		if _, ok := newValues[name]; !ok {
+			changes = append(changes, fmt.Sprintf("%s %s removed", prefix, name))
+		}
+	}
+
+	sort.Strings(changes)
+	return changes
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	old := Configuration{
+		Kind: "eventstore",
+		Spec: Spec{
+			Type: "eventstore.inmemory",
+			Metadata: []SpecMetadata{
+				{Name: "journal", Value: "true"},
+				{Name: "cacheSize", Value: "100"},
+				{Name: "storageAccountKey", Value: "secret"},
+			},
+		},
+	}
+
+	assert.Empty(t, Diff(old, old))
+
+	new := Configuration{
+		Kind: "eventstore",
+		Spec: Spec{
+			Type: "eventstore.chaos",
+			Metadata: []SpecMetadata{
+				{Name: "cacheSize", Value: "200"},
+				{Name: "storageAccountKey", Value: "secret"},
+				{Name: "chaosTarget", Value: "eventstore.inmemory"},
+			},
+			Sink: &SinkSpec{Type: "kafka"},
+		},
+	}
+
+	changes := Diff(old, new)
+	assert.Equal(t, []string{
+		"type eventstore.inmemory -> eventstore.chaos",
+		"metadata cacheSize changed",
+		"metadata chaosTarget added",
+		"metadata journal removed",
+		"sink changed",
+	}, changes)
+
+	for _, c := range changes {
+		assert.NotContains(t, c, "200", "values are not part of the diff")
+	}
+}
+
+func TestDiffIgnoresMetadataOrder(t *testing.T) {
+	old := Configuration{Spec: Spec{Metadata: []SpecMetadata{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}}}
+	new := Configuration{Spec: Spec{Metadata: []SpecMetadata{{Name: "b", Value: "2"}, {Name: "a", Value: "1"}}}}
+
+	assert.Empty(t, Diff(old, new))
+}
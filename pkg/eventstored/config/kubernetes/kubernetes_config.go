@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
@@ -13,16 +14,22 @@ import (
 
 type kubernetesConfigurationProvider struct {
 	evenstoreNames   []string
+	namespace        string
 	operatorEndpoint string
 }
 
-// NewKubernetes creates a new Kubernetes ConfigurationProvider
-func NewKubernetes(eventstoreNames, operatorEndpoint string) (config.ConfigurationProvider, error) {
+// NewKubernetes creates a new Kubernetes ConfigurationProvider for the Eventstores with
+// eventstoreNames in namespace, the namespace of the pod
+func NewKubernetes(eventstoreNames, namespace, operatorEndpoint string) (config.ConfigurationProvider, error) {
 	n := strings.Split(strings.Trim(eventstoreNames, "'"), ",")
 	if n[0] == "" {
 		return nil, errors.New("no evenstores defined")
 	}
 
+	if namespace == "" {
+		return nil, errors.New("no namespace defined")
+	}
+
 	names := make([]string, len(n))
 	for i, s := range n {
 		names[i] = strings.TrimSpace(s)
@@ -30,12 +37,13 @@ func NewKubernetes(eventstoreNames, operatorEndpoint string) (config.Configurati
 
 	return &kubernetesConfigurationProvider{
 		evenstoreNames:   names,
+		namespace:        namespace,
 		operatorEndpoint: operatorEndpoint,
 	}, nil
 }
 
 func (k *kubernetesConfigurationProvider) LoadConfig() ([]config.Configuration, error) {
-	url := fmt.Sprintf("%s/eventstores", k.operatorEndpoint)
+	url := fmt.Sprintf("%s/eventstores?namespace=%s", k.operatorEndpoint, url.QueryEscape(k.namespace))
 
 	resp, err := http.Get(url)
 	if err != nil {
@@ -80,7 +88,7 @@ func (k *kubernetesConfigurationProvider) filterConfigs(cfgs []config.Configurat
 	result := []config.Configuration{}
 
 	for _, v := range cfgs {
-		if containsStoreName(k.evenstoreNames, v.Metadata.Name) {
+		if v.Metadata.Namespace == k.namespace && containsStoreName(k.evenstoreNames, v.Metadata.Name) {
 			result = append(result, v)
 		}
 	}
@@ -9,8 +9,9 @@ import (
 )
 
 const (
-	storeNameOne = "teststore-one"
-	storeNameTwo = "teststore-two"
+	storeNameOne  = "teststore-one"
+	storeNameTwo  = "teststore-two"
+	testNamespace = "default"
 )
 
 func createtestConfiguration() []config.Configuration {
@@ -18,7 +19,8 @@ func createtestConfiguration() []config.Configuration {
 		config.Configuration{
 			Kind: "eventstore",
 			Metadata: config.ConfigurationMetadata{
-				Name: storeNameOne,
+				Name:      storeNameOne,
+				Namespace: testNamespace,
 			},
 			Spec: config.Spec{
 				Type: "eventstore.inmemory",
@@ -37,7 +39,8 @@ func createtestConfiguration() []config.Configuration {
 		config.Configuration{
 			Kind: "eventstore",
 			Metadata: config.ConfigurationMetadata{
-				Name: storeNameTwo,
+				Name:      storeNameTwo,
+				Namespace: testNamespace,
 			},
 			Spec: config.Spec{
 				Type: "eventstore.inmemory",
@@ -60,7 +63,13 @@ func createtestConfiguration() []config.Configuration {
 func TestEmptyEventstores(t *testing.T) {
 	stores := ""
 
-	cfg, err := NewKubernetes(stores, "")
+	cfg, err := NewKubernetes(stores, testNamespace, "")
+	assert.NotNil(t, err)
+	assert.Nil(t, cfg)
+}
+
+func TestMissingNamespace(t *testing.T) {
+	cfg, err := NewKubernetes("a,b", "", "")
 	assert.NotNil(t, err)
 	assert.Nil(t, cfg)
 }
@@ -68,7 +77,7 @@ func TestEmptyEventstores(t *testing.T) {
 func TestSplitEventStores(t *testing.T) {
 	stores := "a,b,c,d"
 
-	cfg, err := NewKubernetes(stores, "")
+	cfg, err := NewKubernetes(stores, testNamespace, "")
 	assert.Nil(t, err)
 	assert.NotNil(t, cfg)
 
@@ -83,7 +92,7 @@ func TestSplitEventStores(t *testing.T) {
 func TestSplitEventStoresSpaces(t *testing.T) {
 	stores := "a, b, c, d"
 
-	cfg, err := NewKubernetes(stores, "")
+	cfg, err := NewKubernetes(stores, testNamespace, "")
 	assert.Nil(t, err)
 	assert.NotNil(t, cfg)
 
@@ -98,7 +107,7 @@ func TestSplitEventStoresSpaces(t *testing.T) {
 func TestSplitEventStoresWithQuotes(t *testing.T) {
 	stores := "'a,b,c,d'"
 
-	cfg, err := NewKubernetes(stores, "")
+	cfg, err := NewKubernetes(stores, testNamespace, "")
 	assert.Nil(t, err)
 	assert.NotNil(t, cfg)
 
@@ -114,7 +123,7 @@ func TestFilterConfigs(t *testing.T) {
 	stores := fmt.Sprintf("%s,%s", storeNameOne, storeNameTwo)
 
 	data := createtestConfiguration()
-	cfg, err := NewKubernetes(stores, "")
+	cfg, err := NewKubernetes(stores, testNamespace, "")
 
 	assert.Nil(t, err)
 	assert.NotNil(t, cfg)
@@ -122,4 +131,11 @@ func TestFilterConfigs(t *testing.T) {
 	result := cfg.(*kubernetesConfigurationProvider).filterConfigs(data)
 
 	assert.Equal(t, 2, len(*result))
+
+	// Eventstores of other namespaces with the same name aren't used
+	data[1].Metadata.Namespace = "other"
+	result = cfg.(*kubernetesConfigurationProvider).filterConfigs(data)
+
+	assert.Equal(t, 1, len(*result))
+	assert.Equal(t, storeNameOne, (*result)[0].Metadata.Name)
 }
@@ -0,0 +1,179 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+)
+
+const (
+	// watchTimeoutSeconds is the time the operator holds a watch without changes
+	watchTimeoutSeconds = 30
+
+	eventDeleted = "DELETED"
+)
+
+var (
+	minWatchBackoff = time.Second
+	maxWatchBackoff = 30 * time.Second
+)
+
+// watchClient gives up on watches the operator doesn't answer in time, e.g. after a lost connection
+var watchClient = &http.Client{Timeout: (watchTimeoutSeconds + 30) * time.Second}
+
+// errWatchExpired is returned when the operator can't resume the watch at its resourceVersion
+var errWatchExpired = errors.New("watch expired")
+
+type watchEvent struct {
+	Type   string               `json:"type"`
+	Object config.Configuration `json:"object"`
+}
+
+type watchResponse struct {
+	ResourceVersion string       `json:"resourceVersion"`
+	Events          []watchEvent `json:"events"`
+}
+
+// Watch follows the changes of the Eventstores with the watch API of the operator. The watch is
+// resumed at the last resourceVersion, if it's expired all Eventstores are read again. Failed
+// watches are retried with backoff.
+func (k *kubernetesConfigurationProvider) Watch(stop <-chan struct{}, apply func([]config.Configuration)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// configs by namespace/name
+	configs := map[string]config.Configuration{}
+	resourceVersion := ""
+	backoff := minWatchBackoff
+
+	for ctx.Err() == nil {
+		response, err := k.watch(ctx, resourceVersion)
+		if err == errWatchExpired {
+			log.Printf("kubernetes config: watch at resourceVersion %s expired, reading all Eventstores\n", resourceVersion)
+			resourceVersion = ""
+			continue
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			// full jitter keeps restarted operators from being hit by all sidecars at once
+			delay := time.Duration(rand.Int63n(int64(backoff)))
+			log.Printf("kubernetes config: %s, retrying in %s\n", err, delay)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			if backoff *= 2; backoff > maxWatchBackoff {
+				backoff = maxWatchBackoff
+			}
+
+			continue
+		}
+
+		backoff = minWatchBackoff
+		changed := resourceVersion == "" || len(response.Events) != 0
+
+		if resourceVersion == "" {
+			// a watch without resourceVersion returns all Eventstores
+			configs = map[string]config.Configuration{}
+		}
+
+		for _, e := range response.Events {
+			if e.Object.Metadata.Namespace != k.namespace {
+				// operators that don't filter by namespace
+				continue
+			}
+
+			key := e.Object.Metadata.Namespace + "/" + e.Object.Metadata.Name
+
+			if e.Type == eventDeleted {
+				delete(configs, key)
+			} else {
+				configs[key] = e.Object
+			}
+		}
+
+		resourceVersion = response.ResourceVersion
+
+		if changed {
+			apply(sortedConfigs(configs))
+		}
+	}
+}
+
+func (k *kubernetesConfigurationProvider) watch(ctx context.Context, resourceVersion string) (watchResponse, error) {
+	query := url.Values{}
+	query.Set("names", strings.Join(k.evenstoreNames, ","))
+	query.Set("namespace", k.namespace)
+	query.Set("resourceVersion", resourceVersion)
+	query.Set("timeoutSeconds", fmt.Sprint(watchTimeoutSeconds))
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/eventstores/watch?%s", k.operatorEndpoint, query.Encode()), nil)
+	if err != nil {
+		return watchResponse{}, err
+	}
+
+	resp, err := watchClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return watchResponse{}, fmt.Errorf("can't watch configuration: %v", err)
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return watchResponse{}, fmt.Errorf("can't read watch response: %v", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusGone:
+		return watchResponse{}, errWatchExpired
+	default:
+		return watchResponse{}, fmt.Errorf("watch of configuration returned %d: %s", resp.StatusCode, body)
+	}
+
+	response := watchResponse{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return watchResponse{}, fmt.Errorf("can't deserialize watch response: %v", err)
+	}
+
+	return response, nil
+}
+
+func sortedConfigs(configs map[string]config.Configuration) []config.Configuration {
+	result := make([]config.Configuration, 0, len(configs))
+	for _, c := range configs {
+		result = append(result, c)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Metadata.Name < result[j].Metadata.Name
+	})
+
+	return result
+}
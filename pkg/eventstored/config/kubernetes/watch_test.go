@@ -0,0 +1,132 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func testWatchEvent(typ, name, storeType string) watchEvent {
+	return testWatchEventIn(testNamespace, typ, name, storeType)
+}
+
+func testWatchEventIn(namespace, typ, name, storeType string) watchEvent {
+	return watchEvent{
+		Type: typ,
+		Object: config.Configuration{
+			Metadata: config.ConfigurationMetadata{Name: name, Namespace: namespace},
+			Spec:     config.Spec{Type: storeType},
+		},
+	}
+}
+
+func TestWatch(t *testing.T) {
+	minWatchBackoff = time.Millisecond
+	defer func() { minWatchBackoff = time.Second }()
+
+	mutex := sync.Mutex{}
+	requested := []string{}
+
+	// the operator's answers by call
+	answers := []func(w http.ResponseWriter){
+		func(w http.ResponseWriter) {
+			json.NewEncoder(w).Encode(watchResponse{ResourceVersion: "10", Events: []watchEvent{
+				testWatchEvent("ADDED", storeNameOne, "eventstore.inmemory"),
+				testWatchEvent("ADDED", storeNameTwo, "eventstore.inmemory"),
+			}})
+		},
+		func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+		func(w http.ResponseWriter) {
+			json.NewEncoder(w).Encode(watchResponse{ResourceVersion: "12", Events: []watchEvent{
+				testWatchEvent("MODIFIED", storeNameOne, "eventstore.chaos"),
+				testWatchEvent("DELETED", storeNameTwo, "eventstore.inmemory"),
+				// the same name in another namespace is another Eventstore
+				testWatchEventIn("other", "DELETED", storeNameOne, "eventstore.chaos"),
+			}})
+		},
+		func(w http.ResponseWriter) {
+			// a watch without changes
+			json.NewEncoder(w).Encode(watchResponse{ResourceVersion: "13", Events: []watchEvent{}})
+		},
+		func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusGone)
+		},
+		func(w http.ResponseWriter) {
+			json.NewEncoder(w).Encode(watchResponse{ResourceVersion: "20", Events: []watchEvent{
+				testWatchEvent("ADDED", storeNameTwo, "eventstore.inmemory"),
+			}})
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		call := len(requested)
+		requested = append(requested, r.URL.Query().Get("resourceVersion"))
+		mutex.Unlock()
+
+		assert.Equal(t, storeNameOne+","+storeNameTwo, r.URL.Query().Get("names"))
+		assert.Equal(t, testNamespace, r.URL.Query().Get("namespace"))
+
+		if call < len(answers) {
+			answers[call](w)
+			return
+		}
+
+		// hold the watch like the operator does
+		time.Sleep(100 * time.Millisecond)
+		json.NewEncoder(w).Encode(watchResponse{ResourceVersion: "20", Events: []watchEvent{}})
+	}))
+	defer server.Close()
+
+	provider, err := NewKubernetes(storeNameOne+","+storeNameTwo, testNamespace, server.URL)
+	assert.Nil(t, err)
+
+	applied := make(chan []config.Configuration, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		provider.(config.ConfigurationWatcher).Watch(stop, func(cfg []config.Configuration) {
+			applied <- cfg
+		})
+		close(done)
+	}()
+
+	next := func() []config.Configuration {
+		select {
+		case cfg := <-applied:
+			return cfg
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "configuration wasn't applied")
+			return nil
+		}
+	}
+
+	cfg := next()
+	assert.Len(t, cfg, 2)
+
+	cfg = next()
+	assert.Len(t, cfg, 1)
+	assert.Equal(t, storeNameOne, cfg[0].Metadata.Name)
+	assert.Equal(t, "eventstore.chaos", cfg[0].Spec.Type)
+
+	// the expired watch starts again with all Eventstores
+	cfg = next()
+	assert.Len(t, cfg, 1)
+	assert.Equal(t, storeNameTwo, cfg[0].Metadata.Name)
+
+	close(stop)
+	<-done
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []string{"", "10", "10", "12", "13", ""}, requested[:6])
+}
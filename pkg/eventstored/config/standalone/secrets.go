@@ -0,0 +1,173 @@
+package standalone
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+)
+
+const (
+	// secretsDirEnv names the environment variable with the directory of the secrets referenced by
+	// secretKeyRef, by default it's the directory 'secrets' next to the config file
+	secretsDirEnv     = "EVENTSTORE_SECRETS_DIR"
+	defaultSecretsDir = "secrets"
+)
+
+// variable matches '${NAME}' and the escaped form '$${NAME}'
+var variable = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// resolve replaces the metadata values of cfg with their references: '${NAME}' in values is
+// expanded with the environment variable NAME, valueFrom reads the value from a file or an
+// environment variable. Relative files are read from dir, the directory of the config file.
+// secretKeyRef reads the file '<name>/<key>' in the secrets directory, laid out like secrets
+// mounted into a pod, so manifests applied to the cluster can be used unchanged.
+func resolve(cfg *config.Configuration, dir string) error {
+	metadata := [][]config.SpecMetadata{cfg.Spec.Metadata}
+
+	if cfg.Spec.Sink != nil {
+		metadata = append(metadata, cfg.Spec.Sink.Metadata)
+	}
+
+	for _, p := range cfg.Spec.Projections {
+		metadata = append(metadata, p.Metadata)
+	}
+
+	if cfg.Spec.Migration != nil {
+		metadata = append(metadata, cfg.Spec.Migration.Metadata)
+	}
+
+	for _, items := range metadata {
+		for i := range items {
+			if err := resolveItem(&items[i], dir); err != nil {
+				return fmt.Errorf("metadata %s: %s", items[i].Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func resolveItem(item *config.SpecMetadata, dir string) error {
+	ref := item.SecretKeyRef
+	item.SecretKeyRef = nil
+
+	if ref != nil && ref.Name != "" {
+		if item.Value != "" || item.ValueFrom != nil {
+			return errors.New("secretKeyRef is exclusive with value and valueFrom")
+		}
+
+		value, err := readSecret(dir, *ref)
+		if err != nil {
+			return err
+		}
+
+		item.Value = value
+		return nil
+	}
+
+	if item.ValueFrom == nil {
+		value, err := expand(item.Value)
+		if err != nil {
+			return err
+		}
+
+		item.Value = value
+		return nil
+	}
+
+	from := item.ValueFrom
+
+	switch {
+	case item.Value != "":
+		return errors.New("value and valueFrom are exclusive")
+	case from.File != "" && from.Env != "":
+		return errors.New("valueFrom has both file and env, expected one of them")
+	case from.File != "":
+		path := from.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		value, err := readValue(path)
+		if err != nil {
+			return fmt.Errorf("can't read valueFrom file: %s", err)
+		}
+
+		item.Value = value
+	case from.Env != "":
+		value, ok := os.LookupEnv(from.Env)
+		if !ok {
+			return fmt.Errorf("environment variable %s of valueFrom is not set", from.Env)
+		}
+
+		item.Value = value
+	default:
+		return errors.New("valueFrom is empty, expected file or env")
+	}
+
+	item.ValueFrom = nil
+	return nil
+}
+
+// readSecret reads the value of a secret reference from the secrets directory
+func readSecret(dir string, ref config.SecretKeyRef) (string, error) {
+	for _, part := range []string{ref.Name, ref.Key} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", fmt.Errorf("invalid secretKeyRef %s/%s", ref.Name, ref.Key)
+		}
+	}
+
+	secrets := os.Getenv(secretsDirEnv)
+	if secrets == "" {
+		secrets = filepath.Join(dir, defaultSecretsDir)
+	}
+
+	value, err := readValue(filepath.Join(secrets, ref.Name, ref.Key))
+	if err != nil {
+		return "", fmt.Errorf("can't read secretKeyRef %s/%s: %s", ref.Name, ref.Key, err)
+	}
+
+	return value, nil
+}
+
+// readValue reads a value from a file, files written by editors and echo end with a newline
+func readValue(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// expand replaces the variables in value with the values of the environment
+func expand(value string) (string, error) {
+	missing := []string{}
+
+	expanded := variable.ReplaceAllStringFunc(value, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+
+		name := variable.FindStringSubmatch(match)[1]
+
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+
+		return v
+	})
+
+	if len(missing) != 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+
+	return expanded, nil
+}
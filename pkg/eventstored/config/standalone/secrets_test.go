@@ -0,0 +1,157 @@
+package standalone
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpand(t *testing.T) {
+	os.Setenv("EVENTSTORE_TEST_ACCOUNT", "testaccount")
+	defer os.Unsetenv("EVENTSTORE_TEST_ACCOUNT")
+
+	value, err := expand("https://${EVENTSTORE_TEST_ACCOUNT}.table.core.windows.net")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://testaccount.table.core.windows.net", value)
+
+	value, err = expand("pa$$word$${EVENTSTORE_TEST_ACCOUNT}")
+	assert.Nil(t, err)
+	assert.Equal(t, "pa$$word${EVENTSTORE_TEST_ACCOUNT}", value, "only variables in braces are expanded")
+
+	_, err = expand("${EVENTSTORE_TEST_MISSING}")
+	assert.EqualError(t, err, "environment variable EVENTSTORE_TEST_MISSING is not set")
+}
+
+func TestResolveValueFrom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "key"), []byte("testaccountkey\n"), 0600))
+
+	os.Setenv("EVENTSTORE_TEST_ACCOUNT", "testaccount")
+	defer os.Unsetenv("EVENTSTORE_TEST_ACCOUNT")
+
+	cfg := config.Configuration{
+		Spec: config.Spec{
+			Metadata: []config.SpecMetadata{
+				{Name: "storageAccountName", ValueFrom: &config.ValueFrom{Env: "EVENTSTORE_TEST_ACCOUNT"}},
+				{Name: "storageAccountKey", ValueFrom: &config.ValueFrom{File: "key"}},
+			},
+			Sink: &config.SinkSpec{
+				Metadata: []config.SpecMetadata{
+					{Name: "brokers", Value: "${EVENTSTORE_TEST_ACCOUNT}:9092"},
+				},
+			},
+		},
+	}
+
+	assert.Nil(t, resolve(&cfg, dir))
+	assert.Equal(t, []config.SpecMetadata{
+		{Name: "storageAccountName", Value: "testaccount"},
+		{Name: "storageAccountKey", Value: "testaccountkey"},
+	}, cfg.Spec.Metadata)
+	assert.Equal(t, "testaccount:9092", cfg.Spec.Sink.Metadata[0].Value)
+}
+
+func TestUnresolvedReferencesFail(t *testing.T) {
+	tests := map[string]config.SpecMetadata{
+		"environment variable EVENTSTORE_TEST_MISSING of valueFrom is not set": {
+			ValueFrom: &config.ValueFrom{Env: "EVENTSTORE_TEST_MISSING"},
+		},
+		"can't read valueFrom file": {
+			ValueFrom: &config.ValueFrom{File: "missing"},
+		},
+		"value and valueFrom are exclusive": {
+			Value: "key", ValueFrom: &config.ValueFrom{Env: "HOME"},
+		},
+		"valueFrom has both file and env": {
+			ValueFrom: &config.ValueFrom{File: "key", Env: "HOME"},
+		},
+		"valueFrom is empty": {
+			ValueFrom: &config.ValueFrom{},
+		},
+		"can't read secretKeyRef storage/key": {
+			SecretKeyRef: &config.SecretKeyRef{Name: "storage", Key: "key"},
+		},
+		"invalid secretKeyRef ../key": {
+			SecretKeyRef: &config.SecretKeyRef{Name: "..", Key: "key"},
+		},
+		"secretKeyRef is exclusive with value and valueFrom": {
+			Value: "key", SecretKeyRef: &config.SecretKeyRef{Name: "storage", Key: "key"},
+		},
+	}
+
+	for expected, item := range tests {
+		item.Name = "storageAccountKey"
+		cfg := config.Configuration{Spec: config.Spec{Metadata: []config.SpecMetadata{item}}}
+
+		err := resolve(&cfg, os.TempDir())
+		if assert.NotNil(t, err, expected) {
+			assert.Contains(t, err.Error(), "metadata storageAccountKey: "+expected)
+		}
+	}
+}
+
+func TestResolveSecretKeyRef(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "secrets", "storage"), 0700))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "secrets", "storage", "key"), []byte("testaccountkey\n"), 0600))
+
+	item := func() config.SpecMetadata {
+		return config.SpecMetadata{Name: "storageAccountKey", SecretKeyRef: &config.SecretKeyRef{Name: "storage", Key: "key"}}
+	}
+
+	cfg := config.Configuration{Spec: config.Spec{Metadata: []config.SpecMetadata{item()}}}
+	assert.Nil(t, resolve(&cfg, dir))
+	assert.Equal(t, []config.SpecMetadata{{Name: "storageAccountKey", Value: "testaccountkey"}}, cfg.Spec.Metadata)
+
+	// the secrets directory can be moved, e.g. to where secrets are mounted
+	os.Setenv(secretsDirEnv, filepath.Join(dir, "secrets"))
+	defer os.Unsetenv(secretsDirEnv)
+
+	cfg = config.Configuration{Spec: config.Spec{Metadata: []config.SpecMetadata{item()}}}
+	assert.Nil(t, resolve(&cfg, os.TempDir()))
+	assert.Equal(t, "testaccountkey", cfg.Spec.Metadata[0].Value)
+}
+
+func TestLoadConfigResolvesValues(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"store.yaml": `apiVersion: eventstore.io/v1alpha1
+kind: Eventstore
+metadata:
+  name: myeventstore
+spec:
+  type: eventstore.azure.tablestorage
+  metadata:
+    - name: storageAccountName
+      value: ${EVENTSTORE_TEST_ACCOUNT}
+    - name: storageAccountKey
+      valueFrom:
+        file: secrets/key
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := NewStandalone(dir).LoadConfig()
+	assert.Contains(t, err.Error(), "Eventstore 'myeventstore': metadata storageAccountName: environment variable EVENTSTORE_TEST_ACCOUNT is not set")
+
+	os.Setenv("EVENTSTORE_TEST_ACCOUNT", "testaccount")
+	defer os.Unsetenv("EVENTSTORE_TEST_ACCOUNT")
+
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "secrets"), 0700))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "secrets", "key"), []byte("testaccountkey"), 0600))
+
+	cfg, err := NewStandalone(dir).LoadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "testaccount", cfg[0].Spec.Metadata[0].Value)
+	assert.Equal(t, "testaccountkey", cfg[0].Spec.Metadata[1].Value)
+	assert.Nil(t, cfg[0].Spec.Metadata[1].ValueFrom)
+}
@@ -1,38 +1,229 @@
 package standalone
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
 	"gopkg.in/yaml.v2"
 )
 
+const (
+	// kind of the plain standalone configuration
+	configKind = "eventstore"
+	// kind and apiVersion of the Eventstore manifests applied to the cluster
+	manifestKind       = "Eventstore"
+	manifestAPIVersion = "eventstore.io/v1alpha1"
+)
+
 type standalongConfigurationProvider struct {
 	configFilePath string
 }
 
-// NewStandalone creates a new standaloneConfigurationProvider
+// NewStandalone creates a new standaloneConfigurationProvider. filePath is a YAML file or a
+// directory of YAML files, each file may hold multiple documents.
 func NewStandalone(filePath string) config.ConfigurationProvider {
 	return &standalongConfigurationProvider{
 		configFilePath: filePath,
 	}
 }
 
+// LoadConfig reads all configurations. If some can't be read, the valid ones are returned with an
+// error that lists the failures of every file.
 func (p *standalongConfigurationProvider) LoadConfig() ([]config.Configuration, error) {
-	data, err := ioutil.ReadFile(p.configFilePath)
+	files, err := readFiles(p.configFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseFiles(files)
+}
+
+// configFile is the content of a config file
+type configFile struct {
+	name string
+	data []byte
+}
+
+// document is a document of a config file, it's either a plain configuration or an Eventstore
+// manifest
+type document struct {
+	APIVersion           string `yaml:"apiVersion"`
+	config.Configuration `yaml:",inline"`
+}
+
+// readFiles reads the file at path, or the YAML files in the directory at path ordered by name
+func readFiles(path string) ([]configFile, error) {
+	info, err := os.Stat(path)
 	if err != nil {
 		return nil, fmt.Errorf("standalone config: can't read config file: %s", err)
 	}
 
-	cnfg := config.Configuration{}
+	if !info.IsDir() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("standalone config: can't read config file: %s", err)
+		}
 
-	err = yaml.Unmarshal(data, &cnfg)
+		return []configFile{{name: path, data: data}}, nil
+	}
+
+	entries, err := ioutil.ReadDir(path)
 	if err != nil {
-		return nil, fmt.Errorf("standalone config: can't read yaml in config file: %s", err)
+		return nil, fmt.Errorf("standalone config: can't read config directory: %s", err)
+	}
+
+	files := []configFile{}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+
+		// hidden entries are skipped, mounted ConfigMaps keep their data in '..data'
+		if strings.HasPrefix(name, ".") || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		filePath := filepath.Join(path, name)
+
+		// entries of mounted ConfigMaps are symlinks, they are followed
+		if info, err := os.Stat(filePath); err != nil || info.IsDir() {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("standalone config: can't read config file: %s", err)
+		}
+
+		files = append(files, configFile{name: filePath, data: data})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+
+	return files, nil
+}
+
+// parseFiles reads the configurations of all documents in files. Documents of other kinds are
+// skipped, so a directory may hold other manifests, too.
+func parseFiles(files []configFile) ([]config.Configuration, error) {
+	result := []config.Configuration{}
+	// origins of the configurations by name to report duplicates
+	origins := map[string]string{}
+	builder := strings.Builder{}
+
+	for _, file := range files {
+		configs, err := parse(file.name, file.data)
+		if err != nil {
+			builder.WriteString(fmt.Sprintf("%s\n", err))
+		}
+
+		for _, c := range configs {
+			origin := fmt.Sprintf("%s, document %d", file.name, c.index)
+
+			if first, ok := origins[c.cfg.Metadata.Name]; ok {
+				builder.WriteString(fmt.Sprintf("standalone config: %s: duplicate Eventstore '%s', first defined in %s\n", origin, c.cfg.Metadata.Name, first))
+				continue
+			}
+
+			origins[c.cfg.Metadata.Name] = origin
+			result = append(result, c.cfg)
+		}
+	}
+
+	if builder.Len() != 0 {
+		return result, errors.New(strings.TrimSuffix(builder.String(), "\n"))
+	}
+
+	return result, nil
+}
+
+// indexedConfiguration is a configuration with the position of its document in a file
+type indexedConfiguration struct {
+	index int
+	cfg   config.Configuration
+}
+
+// parse reads the configurations of all documents in data and resolves their metadata values, the
+// valid ones are returned even if some documents are invalid
+func parse(name string, data []byte) ([]indexedConfiguration, error) {
+	result := []indexedConfiguration{}
+	errs := []string{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	for index := 1; ; index++ {
+		doc := document{}
+
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			// the decoder can't continue after a syntax error
+			errs = append(errs, fmt.Sprintf("standalone config: %s, document %d: can't read yaml in config file: %s", name, index, err))
+			break
+		}
+
+		cfg, ok, err := doc.configuration()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("standalone config: %s, document %d: %s", name, index, err))
+			continue
+		}
+
+		if !ok {
+			if doc.Kind != "" {
+				log.Printf("standalone config: %s, document %d: skipping kind '%s'\n", name, index, doc.Kind)
+			}
+
+			continue
+		}
+
+		if err := resolve(&cfg, filepath.Dir(name)); err != nil {
+			errs = append(errs, fmt.Sprintf("standalone config: %s, document %d: Eventstore '%s': %s", name, index, cfg.Metadata.Name, err))
+			continue
+		}
+
+		result = append(result, indexedConfiguration{index: index, cfg: cfg})
 	}
 
-	result := []config.Configuration{cnfg}
+	if len(errs) != 0 {
+		return result, errors.New(strings.Join(errs, "\n"))
+	}
 
 	return result, nil
 }
+
+// configuration returns the configuration of the document, ok is false if it's of another kind
+func (d document) configuration() (config.Configuration, bool, error) {
+	switch {
+	case d.Kind == configKind:
+	case d.Kind == manifestKind && d.APIVersion == manifestAPIVersion:
+		// manifests are served like the configurations the operator sends
+		d.Kind = configKind
+	case d.Kind == manifestKind:
+		return config.Configuration{}, false, fmt.Errorf("unsupported apiVersion '%s' of kind %s, expected %s", d.APIVersion, manifestKind, manifestAPIVersion)
+	default:
+		return config.Configuration{}, false, nil
+	}
+
+	if d.Metadata.Name == "" {
+		return config.Configuration{}, false, errors.New("metadata.name is missing")
+	}
+
+	if d.Spec.Type == "" {
+		return config.Configuration{}, false, fmt.Errorf("spec.type of Eventstore '%s' is missing", d.Metadata.Name)
+	}
+
+	return d.Configuration, true, nil
+}
@@ -1,6 +1,10 @@
 package standalone
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -23,3 +27,70 @@ func TestLoadConfig(t *testing.T) {
 	assert.Equal(t, "storageAccountKey", config[0].Spec.Metadata[1].Name)
 	assert.Equal(t, "testaccountkey", config[0].Spec.Metadata[1].Value)
 }
+
+func TestLoadMultipleDocuments(t *testing.T) {
+	p := NewStandalone("./testconfigs.yaml")
+
+	config, err := p.LoadConfig()
+	assert.Nil(t, err)
+	assert.Len(t, config, 2)
+
+	assert.Equal(t, "orders", config[0].Metadata.Name)
+	assert.Equal(t, "eventstore.inmemory", config[0].Spec.Type)
+
+	// manifests are read like plain configurations
+	assert.Equal(t, "eventstore", config[1].Kind)
+	assert.Equal(t, "customers", config[1].Metadata.Name)
+	assert.Equal(t, "eventstore.azure.tablestorage", config[1].Spec.Type)
+	assert.Equal(t, "testaccount", config[1].Spec.Metadata[0].Value)
+}
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "standalone")
+	assert.Nil(t, err)
+
+	for name, content := range files {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	return dir
+}
+
+func TestLoadDirectory(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"b.yaml":    "kind: eventstore\nmetadata:\n  name: two\nspec:\n  type: eventstore.inmemory\n",
+		"a.yml":     "kind: eventstore\nmetadata:\n  name: one\nspec:\n  type: eventstore.inmemory\n",
+		"notes.txt": "kind: eventstore\nmetadata:\n  name: three\nspec:\n  type: eventstore.inmemory\n",
+	})
+	defer os.RemoveAll(dir)
+
+	config, err := NewStandalone(dir).LoadConfig()
+	assert.Nil(t, err)
+	assert.Len(t, config, 2)
+	assert.Equal(t, "one", config[0].Metadata.Name)
+	assert.Equal(t, "two", config[1].Metadata.Name)
+}
+
+func TestLoadReportsErrorsPerFile(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.yaml": "kind: eventstore\nmetadata:\n  name: one\nspec:\n  type: eventstore.inmemory\n",
+		"b.yaml": "kind: eventstore\nmetadata:\n  name: one\nspec:\n  type: eventstore.inmemory\n",
+		"c.yaml": "kind: eventstore\nmetadata:\n  name: two\nspec:\n  type: eventstore.inmemory\n---\nkind: [\n",
+		"d.yaml": "apiVersion: eventstore.io/v2\nkind: Eventstore\nmetadata:\n  name: three\nspec:\n  type: eventstore.inmemory\n",
+	})
+	defer os.RemoveAll(dir)
+
+	config, err := NewStandalone(dir).LoadConfig()
+	assert.NotNil(t, err)
+
+	// the valid configurations are returned with the error
+	assert.Len(t, config, 2)
+	assert.Equal(t, "one", config[0].Metadata.Name)
+	assert.Equal(t, "two", config[1].Metadata.Name)
+
+	lines := strings.Split(err.Error(), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], filepath.Join(dir, "b.yaml")+", document 1: duplicate Eventstore 'one', first defined in "+filepath.Join(dir, "a.yaml"))
+	assert.Contains(t, lines[1], filepath.Join(dir, "c.yaml")+", document 2: can't read yaml")
+	assert.Contains(t, lines[2], filepath.Join(dir, "d.yaml")+", document 1: unsupported apiVersion 'eventstore.io/v2'")
+}
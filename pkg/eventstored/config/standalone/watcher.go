@@ -0,0 +1,90 @@
+package standalone
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"time"
+
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+)
+
+// Watcher reloads the config file, or directory, when its content changes or a reload is requested.
+// The files are polled, as editors and ConfigMap updates replace files instead of writing to them.
+// Files referenced by valueFrom and secretKeyRef aren't watched, a reload reads them again.
+type Watcher struct {
+	configFilePath string
+	interval       time.Duration
+	content        []byte
+}
+
+// NewWatcher creates a new Watcher of the config file or directory at filePath, polled every interval
+func NewWatcher(filePath string, interval time.Duration) *Watcher {
+	return &Watcher{
+		configFilePath: filePath,
+		interval:       interval,
+	}
+}
+
+// LoadConfig reads the configurations and remembers the content of the files to detect changes.
+// Like with the standalone provider, the valid configurations are returned with the error.
+func (w *Watcher) LoadConfig() ([]config.Configuration, error) {
+	files, err := readFiles(w.configFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	w.content = content(files)
+	return parseFiles(files)
+}
+
+// Run calls apply with the configuration whenever the config file changed or reload receives a
+// signal, until stop is closed. An invalid config file is logged and not applied.
+func (w *Watcher) Run(reload <-chan os.Signal, stop <-chan struct{}, apply func([]config.Configuration)) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-reload:
+			log.Printf("standalone config: reloading %s\n", w.configFilePath)
+			w.reload(apply)
+		case <-ticker.C:
+			files, err := readFiles(w.configFilePath)
+			if err != nil || bytes.Equal(content(files), w.content) {
+				// a missing file is reported when it's reloaded on purpose
+				continue
+			}
+
+			log.Printf("standalone config: %s changed\n", w.configFilePath)
+			w.reload(apply)
+		}
+	}
+}
+
+func (w *Watcher) reload(apply func([]config.Configuration)) {
+	cfg, err := w.LoadConfig()
+	if err != nil {
+		// applying the valid part would remove the stores of invalid files
+		log.Printf("%s\nstandalone config: keeping the previous configuration\n", err)
+		return
+	}
+
+	apply(cfg)
+}
+
+// content joins the names and data of files, a changed content means changed files
+func content(files []configFile) []byte {
+	buffer := bytes.Buffer{}
+
+	for _, file := range files {
+		buffer.WriteString(file.name)
+		buffer.WriteByte(0)
+		buffer.Write(file.data)
+		buffer.WriteByte(0)
+	}
+
+	return buffer.Bytes()
+}
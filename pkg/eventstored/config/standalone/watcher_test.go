@@ -0,0 +1,75 @@
+package standalone
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfig replaces the file like editors do, the watcher may poll while it's written
+func writeConfig(t *testing.T, path, content string) {
+	assert.Nil(t, ioutil.WriteFile(path+".tmp", []byte(content), 0644))
+	assert.Nil(t, os.Rename(path+".tmp", path))
+}
+
+func TestWatcherAppliesChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watcher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig(t, path, "kind: eventstore\nmetadata:\n  name: one\nspec:\n  type: eventstore.inmemory\n")
+
+	w := NewWatcher(path, 10*time.Millisecond)
+	cfg, err := w.LoadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "one", cfg[0].Metadata.Name)
+
+	applied := make(chan []config.Configuration, 10)
+	reload := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go w.Run(reload, stop, func(cfg []config.Configuration) {
+		applied <- cfg
+	})
+
+	writeConfig(t, path, "kind: eventstore\nmetadata:\n  name: two\nspec:\n  type: eventstore.inmemory\n")
+
+	select {
+	case cfg := <-applied:
+		assert.Equal(t, "two", cfg[0].Metadata.Name)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "changed config was not applied")
+	}
+
+	// invalid files are not applied
+	writeConfig(t, path, "kind: [")
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, applied)
+
+	writeConfig(t, path, "kind: eventstore\nmetadata:\n  name: three\nspec:\n  type: eventstore.inmemory\n")
+	time.Sleep(100 * time.Millisecond)
+
+	select {
+	case cfg := <-applied:
+		assert.Equal(t, "three", cfg[0].Metadata.Name)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "changed config was not applied")
+	}
+
+	// a reload applies the config even if it didn't change
+	reload <- os.Interrupt
+
+	select {
+	case cfg := <-applied:
+		assert.Equal(t, "three", cfg[0].Metadata.Name)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "config was not reloaded")
+	}
+}
@@ -0,0 +1,473 @@
+package conformance
+
+//---------------------------------------------------------------------------------------------
+// The conformance suite describes the behavior eventstored expects from a store.EventStore
+// backend. The contract follows the Azure backends: errors are store.EventStoreErrors typed as
+// EntityNotFound or VersionConflict, ranges are inclusive, in version order and never fail for
+// versions that don't exist. Backends that knowingly deviate skip the affected checks with a
+// reason, so the deviations are listed wherever the suite runs.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/stretchr/testify/assert"
+)
+
+// Names of the checks, used as subtest names and as keys of Options.Skip
+const (
+	CheckAdd                   = "Add"
+	CheckAddExisting           = "AddExisting"
+	CheckOrdering              = "Ordering"
+	CheckOptimisticConcurrency = "OptimisticConcurrency"
+	CheckNoConcurrencyControl  = "NoConcurrencyControl"
+	CheckNotFound              = "NotFound"
+	CheckRangeBoundaries       = "RangeBoundaries"
+	CheckRangeOrdering         = "RangeOrdering"
+	CheckConcurrentAppenders   = "ConcurrentAppenders"
+	CheckLargePayload          = "LargePayload"
+)
+
+const (
+	// DefaultPayloadSize is the size of the large payload, it stays below the 64 KiB
+	// property limit of Azure Table Storage
+	DefaultPayloadSize = 32 * 1024
+	// DefaultAppenders is the number of concurrent appenders
+	DefaultAppenders = 8
+
+	appendsPerAppender = 5
+	maxAppendAttempts  = 100
+)
+
+// Options configures a run of the suite
+type Options struct {
+	// Skip maps the names of checks the backend is known not to pass to the reason
+	Skip map[string]string
+	// PayloadSize is the size in bytes of the large payload, defaults to DefaultPayloadSize
+	PayloadSize int
+	// Appenders is the number of concurrent appenders, defaults to DefaultAppenders
+	Appenders int
+}
+
+type check struct {
+	name string
+	run  func(t *testing.T, s store.EventStore, ids *idGenerator, options Options)
+}
+
+var checks = []check{
+	{CheckAdd, checkAdd},
+	{CheckAddExisting, checkAddExisting},
+	{CheckOrdering, checkOrdering},
+	{CheckOptimisticConcurrency, checkOptimisticConcurrency},
+	{CheckNoConcurrencyControl, checkNoConcurrencyControl},
+	{CheckNotFound, checkNotFound},
+	{CheckRangeBoundaries, checkRangeBoundaries},
+	{CheckRangeOrdering, checkRangeOrdering},
+	{CheckConcurrentAppenders, checkConcurrentAppenders},
+	{CheckLargePayload, checkLargePayload},
+}
+
+// Run runs all checks as subtests against the initialized store s. Every check works on
+// entities with unique ids, so s may contain data of earlier runs.
+func Run(t *testing.T, s store.EventStore, options Options) {
+	if options.PayloadSize <= 0 {
+		options.PayloadSize = DefaultPayloadSize
+	}
+
+	if options.Appenders <= 0 {
+		options.Appenders = DefaultAppenders
+	}
+
+	ids := &idGenerator{run: time.Now().UnixNano()}
+
+	for _, c := range checks {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if reason, ok := options.Skip[c.name]; ok {
+				t.Skip(reason)
+			}
+
+			c.run(t, s, ids, options)
+		})
+	}
+}
+
+type idGenerator struct {
+	run   int64
+	count int
+	mutex sync.Mutex
+}
+
+// next returns an id that is unique across runs, prefixed with the name of the check
+func (g *idGenerator) next(t *testing.T) string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.count++
+	name := strings.ToLower(strings.ReplaceAll(t.Name(), "/", "-"))
+	return fmt.Sprintf("%s-%d-%d", name, g.run, g.count)
+}
+
+func checkAdd(t *testing.T, s store.EventStore, ids *idGenerator, options Options) {
+	id := ids.next(t)
+
+	ety, err := s.Add(&store.Entity{ID: id, Metadata: "created", Data: payload(0)})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, int64(1), ety.Version)
+	assertLatest(t, s, id, 1)
+	assertVersion(t, s, id, 1, "created", payload(0))
+}
+
+func checkAddExisting(t *testing.T, s store.EventStore, ids *idGenerator, options Options) {
+	id := ids.next(t)
+
+	_, err := s.Add(&store.Entity{ID: id, Metadata: "first", Data: payload(0)})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, err = s.Add(&store.Entity{ID: id, Metadata: "second", Data: payload(1)})
+	assert.NotNil(t, err, "adding an existing entity must fail")
+
+	// the existing entity is untouched
+	assertLatest(t, s, id, 1)
+	assertVersion(t, s, id, 1, "first", payload(0))
+}
+
+func checkOrdering(t *testing.T, s store.EventStore, ids *idGenerator, options Options) {
+	const versions = 12
+	id := ids.next(t)
+
+	if !add(t, s, id) {
+		return
+	}
+
+	for v := int64(2); v <= versions; v++ {
+		ety, err := s.Append(&store.Entity{ID: id, Version: v - 1, Metadata: fmt.Sprint(v), Data: payload(v)}, store.Optimistic)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Equal(t, v, ety.Version)
+	}
+
+	assertLatest(t, s, id, versions)
+
+	for v := int64(2); v <= versions; v++ {
+		assertVersion(t, s, id, v, fmt.Sprint(v), payload(v))
+	}
+}
+
+func checkOptimisticConcurrency(t *testing.T, s store.EventStore, ids *idGenerator, options Options) {
+	id := ids.next(t)
+
+	if !add(t, s, id) {
+		return
+	}
+
+	_, err := s.Append(&store.Entity{ID: id, Version: 1, Data: payload(2)}, store.Optimistic)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// stale version
+	_, err = s.Append(&store.Entity{ID: id, Version: 1, Data: payload(3)}, store.Optimistic)
+	assert.True(t, streams.IsVersionConflict(err), "appending a stale version must be a VersionConflict, got %v", err)
+
+	// version from the future
+	_, err = s.Append(&store.Entity{ID: id, Version: 5, Data: payload(3)}, store.Optimistic)
+	assert.True(t, streams.IsVersionConflict(err), "appending an unknown version must be a VersionConflict, got %v", err)
+
+	assertLatest(t, s, id, 2)
+	assertVersion(t, s, id, 2, "", payload(2))
+}
+
+func checkNoConcurrencyControl(t *testing.T, s store.EventStore, ids *idGenerator, options Options) {
+	id := ids.next(t)
+
+	if !add(t, s, id) {
+		return
+	}
+
+	// without concurrency control the version of the entity is ignored
+	ety, err := s.Append(&store.Entity{ID: id, Version: 0, Data: payload(2)}, store.None)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, int64(2), ety.Version)
+	assertLatest(t, s, id, 2)
+	assertVersion(t, s, id, 2, "", payload(2))
+}
+
+func checkNotFound(t *testing.T, s store.EventStore, ids *idGenerator, options Options) {
+	missing := ids.next(t)
+
+	_, err := s.GetLatestVersionNumber(missing)
+	assert.True(t, streams.IsNotFound(err), "latest version of a missing entity must be EntityNotFound, got %v", err)
+
+	_, err = s.GetByVersion(missing, 1)
+	assert.True(t, streams.IsNotFound(err), "version of a missing entity must be EntityNotFound, got %v", err)
+
+	_, err = s.Append(&store.Entity{ID: missing, Version: 1, Data: payload(2)}, store.Optimistic)
+	assert.True(t, streams.IsNotFound(err), "appending to a missing entity must be EntityNotFound, got %v", err)
+
+	id := ids.next(t)
+	if !add(t, s, id) {
+		return
+	}
+
+	_, err = s.GetByVersion(id, 2)
+	assert.True(t, streams.IsNotFound(err), "a missing version must be EntityNotFound, got %v", err)
+
+	_, err = s.GetByVersion(id, 0)
+	assert.True(t, streams.IsNotFound(err), "version 0 must be EntityNotFound, got %v", err)
+}
+
+func checkRangeBoundaries(t *testing.T, s store.EventStore, ids *idGenerator, options Options) {
+	id := ids.next(t)
+
+	if !add(t, s, id) {
+		return
+	}
+
+	for v := int64(2); v <= 5; v++ {
+		if _, err := s.Append(&store.Entity{ID: id, Version: v - 1, Data: payload(v)}, store.Optimistic); !assert.Nil(t, err) {
+			return
+		}
+	}
+
+	ranges := []struct {
+		from, to int64
+		expected []int64
+	}{
+		{2, 4, []int64{2, 3, 4}},
+		{1, 1, []int64{1}},
+		{1, 5, []int64{1, 2, 3, 4, 5}},
+		{4, 10, []int64{4, 5}},
+		{6, 10, []int64{}},
+		{4, 2, []int64{}},
+	}
+
+	for _, r := range ranges {
+		etys, err := s.GetByVersionRange(id, r.from, r.to)
+		if !assert.Nil(t, err, "range %v..%v", r.from, r.to) {
+			continue
+		}
+
+		// the order is checked by CheckRangeOrdering
+		assert.Equal(t, r.expected, sortedVersionsOf(etys), "range %v..%v", r.from, r.to)
+
+		for _, e := range etys {
+			assertData(t, payload(e.Version), e.Data)
+		}
+	}
+
+	etys, err := s.GetByVersionRange(ids.next(t), 1, 10)
+	assert.Nil(t, err, "range of a missing entity must be empty")
+	assert.Empty(t, etys)
+}
+
+func checkRangeOrdering(t *testing.T, s store.EventStore, ids *idGenerator, options Options) {
+	// more than 9 versions, so ordering versions as strings puts 10 before 2
+	const versions = 12
+	id := ids.next(t)
+
+	if !add(t, s, id) {
+		return
+	}
+
+	for v := int64(2); v <= versions; v++ {
+		if _, err := s.Append(&store.Entity{ID: id, Version: v - 1, Data: payload(v)}, store.Optimistic); !assert.Nil(t, err) {
+			return
+		}
+	}
+
+	etys, err := s.GetByVersionRange(id, 1, versions)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	expected := make([]int64, 0, versions)
+	for v := int64(1); v <= versions; v++ {
+		expected = append(expected, v)
+	}
+
+	assert.Equal(t, expected, versionsOf(etys), "a range must be in version order")
+
+	etys, err = s.GetByVersionRange(id, 8, 11)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []int64{8, 9, 10, 11}, versionsOf(etys), "a range must be in version order")
+	}
+}
+
+func checkConcurrentAppenders(t *testing.T, s store.EventStore, ids *idGenerator, options Options) {
+	id := ids.next(t)
+
+	if !add(t, s, id) {
+		return
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, options.Appenders)
+
+	for a := 0; a < options.Appenders; a++ {
+		wg.Add(1)
+		go func(appender int) {
+			defer wg.Done()
+
+			for i := 0; i < appendsPerAppender; i++ {
+				data := fmt.Sprintf("%d-%d", appender, i)
+				if err := appendWithRetry(s, id, data); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(a)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+
+	total := int64(1 + options.Appenders*appendsPerAppender)
+	assertLatest(t, s, id, total)
+
+	// every append ends up in exactly one version
+	seen := map[string]int64{}
+	for v := int64(2); v <= total; v++ {
+		ety, err := s.GetByVersion(id, v)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		data := fmt.Sprint(ety.Data)
+		if previous, ok := seen[data]; ok {
+			assert.Fail(t, "duplicate append", "%s was written as version %v and %v", data, previous, v)
+		}
+
+		seen[data] = v
+	}
+
+	assert.Len(t, seen, options.Appenders*appendsPerAppender)
+}
+
+func checkLargePayload(t *testing.T, s store.EventStore, ids *idGenerator, options Options) {
+	id := ids.next(t)
+	data := map[string]interface{}{
+		"payload": strings.Repeat("x", options.PayloadSize),
+	}
+
+	_, err := s.Add(&store.Entity{ID: id, Data: data})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, err = s.Append(&store.Entity{ID: id, Version: 1, Data: data}, store.Optimistic)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assertVersion(t, s, id, 1, "", data)
+	assertVersion(t, s, id, 2, "", data)
+}
+
+// appendWithRetry appends data with optimistic concurrency, retrying on conflicts
+func appendWithRetry(s store.EventStore, id string, data interface{}) error {
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		latest, err := s.GetLatestVersionNumber(id)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.Append(&store.Entity{ID: id, Version: latest, Data: data}, store.Optimistic)
+		if err == nil {
+			return nil
+		}
+
+		if !streams.IsVersionConflict(err) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("conformance: append to %s still conflicting after %v attempts", id, maxAppendAttempts)
+}
+
+func add(t *testing.T, s store.EventStore, id string) bool {
+	ety, err := s.Add(&store.Entity{ID: id, Data: payload(1)})
+	return assert.Nil(t, err) && assert.Equal(t, int64(1), ety.Version)
+}
+
+func payload(version int64) interface{} {
+	return map[string]interface{}{
+		"version": version,
+		"text":    fmt.Sprintf("version %v", version),
+	}
+}
+
+func assertLatest(t *testing.T, s store.EventStore, id string, expected int64) {
+	t.Helper()
+
+	latest, err := s.GetLatestVersionNumber(id)
+	if assert.Nil(t, err) {
+		assert.Equal(t, expected, latest)
+	}
+}
+
+func assertVersion(t *testing.T, s store.EventStore, id string, version int64, metadata string, data interface{}) {
+	t.Helper()
+
+	ety, err := s.GetByVersion(id, version)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, id, ety.ID)
+	assert.Equal(t, version, ety.Version)
+	assert.Equal(t, metadata, ety.Metadata)
+	assertData(t, data, ety.Data)
+}
+
+// assertData compares data by its JSON representation, backends that serialize entities
+// return numbers as float64
+func assertData(t *testing.T, expected, actual interface{}) {
+	t.Helper()
+
+	e, err := json.Marshal(expected)
+	assert.Nil(t, err)
+
+	a, err := json.Marshal(actual)
+	assert.Nil(t, err)
+
+	assert.JSONEq(t, string(e), string(a))
+}
+
+// versionsOf returns the versions of etys in the order of etys
+func versionsOf(etys []store.Entity) []int64 {
+	versions := make([]int64, 0, len(etys))
+	for _, e := range etys {
+		versions = append(versions, e.Version)
+	}
+
+	return versions
+}
+
+func sortedVersionsOf(etys []store.Entity) []int64 {
+	versions := versionsOf(etys)
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
@@ -0,0 +1,72 @@
+package conformance
+
+import (
+	"os"
+	"testing"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore-impl/store/azure/cosmosdb"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/eventstore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/tablestorage"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestInMemory runs against the backend of eventstore.inmemory as created by the registry
+func TestInMemory(t *testing.T) {
+	s, err := eventstore.NewRegistry().CreateBackend(config.Configuration{
+		Spec: config.Spec{Type: "eventstore.inmemory"},
+	})
+	assert.Nil(t, err)
+
+	Run(t, s, Options{})
+}
+
+// TestTableStorage runs against Azurite or a storage account, e.g.
+// EVENTSTORE_TABLESTORAGE_ACCOUNT=devstoreaccount1 for Azurite
+func TestTableStorage(t *testing.T) {
+	account := os.Getenv("EVENTSTORE_TABLESTORAGE_ACCOUNT")
+	if account == "" {
+		t.Skip("EVENTSTORE_TABLESTORAGE_ACCOUNT is not set")
+	}
+
+	s := tablestorage.NewStore()
+	assert.Nil(t, s.Init(store.Metadata{
+		Properties: map[string]string{
+			"storageAccountName": account,
+			"storageAccountKey":  os.Getenv("EVENTSTORE_TABLESTORAGE_KEY"),
+			"tableNameSuffix":    "conformance",
+		},
+	}))
+
+	Run(t, s, Options{
+		Skip: map[string]string{
+			CheckRangeOrdering: "versions are ordered by their row keys, which are strings",
+		},
+	})
+}
+
+// TestCosmosDB runs against the Cosmos DB emulator or an account
+func TestCosmosDB(t *testing.T) {
+	url := os.Getenv("EVENTSTORE_COSMOSDB_URL")
+	if url == "" {
+		t.Skip("EVENTSTORE_COSMOSDB_URL is not set")
+	}
+
+	s := cosmosdb.NewStore()
+	assert.Nil(t, s.Init(store.Metadata{
+		Properties: map[string]string{
+			"url":       url,
+			"masterKey": os.Getenv("EVENTSTORE_COSMOSDB_MASTERKEY"),
+			"database":  "eventstore",
+			"container": "conformance",
+		},
+	}))
+
+	Run(t, s, Options{
+		Skip: map[string]string{
+			CheckRangeBoundaries: "empty ranges are EntityNotFound errors instead of empty results",
+			CheckRangeOrdering:   "the range query has no ORDER BY",
+		},
+	})
+}
@@ -0,0 +1,210 @@
+package eventstore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/cache"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/category"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/chaos"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/idempotency"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/migration"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/outbox"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/projection"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/resilience"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/timestamps"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+)
+
+// decorator wraps an initialized EventStore depending on the store's configuration
+type decorator = func(s store.EventStore, cfg config.Configuration, metadata store.Metadata) (store.EventStore, error)
+
+// withMigration creates the target backend of a migration and wraps the source backend s
+func (r *eventstoreRegistry) withMigration(s store.EventStore, cfg config.Configuration, metadata store.Metadata) (store.EventStore, error) {
+	m := cfg.Spec.Migration
+	if m == nil {
+		return s, nil
+	}
+
+	targetCfg := config.Configuration{
+		Kind:     cfg.Kind,
+		Metadata: cfg.Metadata,
+		Spec: config.Spec{
+			Type:     m.Type,
+			Metadata: m.Metadata,
+		},
+	}
+
+	target, err := r.CreateBackend(targetCfg)
+	if err != nil {
+		return s, fmt.Errorf("registry: can't create migration target: %s", err)
+	}
+
+	target, err = withResilience(target, targetCfg, metadataOf(targetCfg))
+	if err != nil {
+		return s, err
+	}
+
+	ms, err := migration.NewStore(s, target, migration.Options{Stage: m.Stage}, journal.DefaultBackoff)
+
+	if err != nil {
+		return s, err
+	}
+
+	return ms, nil
+}
+
+func withResilience(s store.EventStore, cfg config.Configuration, metadata store.Metadata) (store.EventStore, error) {
+	// the in memory backend can't hang or throttle, faults injected into it reach clients
+	if cfg.Spec.Type == inmemoryType || (cfg.Spec.Type == chaosType && metadata.Properties[chaos.TargetKey] == inmemoryType) {
+		return s, nil
+	}
+
+	options, err := resilience.OptionsFrom(metadata)
+	if err != nil {
+		return s, err
+	}
+
+	return resilience.NewStore(s, options), nil
+}
+
+func withCategories(s store.EventStore, cfg config.Configuration, metadata store.Metadata) (store.EventStore, error) {
+	separator, ok := metadata.Properties[category.SeparatorKey]
+	if !ok || separator == "" {
+		return s, nil
+	}
+
+	cs := category.NewStore(s, separator)
+	cs.Start(journal.DefaultBackoff)
+	return cs, nil
+}
+
+func withTimestamps(s store.EventStore, cfg config.Configuration, metadata store.Metadata) (store.EventStore, error) {
+	if !isEnabled(metadata, timestamps.IndexKey) {
+		return s, nil
+	}
+
+	return timestamps.NewStore(s), nil
+}
+
+func withIdempotency(s store.EventStore, cfg config.Configuration, metadata store.Metadata) (store.EventStore, error) {
+	value, ok := metadata.Properties[idempotency.WindowKey]
+	if !ok || value == "" {
+		return s, nil
+	}
+
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		return s, fmt.Errorf("registry: invalid %s '%s', expected a positive duration like '24h'", idempotency.WindowKey, value)
+	}
+
+	return idempotency.NewStore(s, window), nil
+}
+
+func withOutbox(s store.EventStore, cfg config.Configuration, metadata store.Metadata) (store.EventStore, error) {
+	if cfg.Spec.Sink == nil {
+		return s, nil
+	}
+
+	sink, err := outbox.NewSink(*cfg.Spec.Sink)
+	if err != nil {
+		return s, err
+	}
+
+	s = ensureJournal(s)
+	j, _ := journal.From(s)
+
+	return outbox.NewStore(s, j, cfg.Metadata.Name, sink, journal.DefaultBackoff), nil
+}
+
+func withProjections(s store.EventStore, cfg config.Configuration, metadata store.Metadata) (store.EventStore, error) {
+	if len(cfg.Spec.Projections) == 0 {
+		return s, nil
+	}
+
+	s = ensureJournal(s)
+	j, _ := journal.From(s)
+
+	ps, err := projection.NewStore(s, j, cfg.Spec.Projections, journal.DefaultBackoff)
+	if err != nil {
+		return s, err
+	}
+
+	return ps, nil
+}
+
+func withCache(s store.EventStore, cfg config.Configuration, metadata store.Metadata) (store.EventStore, error) {
+	value, ok := metadata.Properties[cache.SizeKey]
+	if !ok || value == "" {
+		return s, nil
+	}
+
+	size, err := strconv.Atoi(value)
+	if err != nil || size <= 0 {
+		return s, fmt.Errorf("registry: invalid %s '%s', expected a positive number", cache.SizeKey, value)
+	}
+
+	ttl := cache.DefaultLatestTTL
+	if value, ok := metadata.Properties[cache.LatestTTLKey]; ok && value != "" {
+		ttl, err = time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return s, fmt.Errorf("registry: invalid %s '%s', expected a duration like '1s'", cache.LatestTTLKey, value)
+		}
+	}
+
+	return cache.NewStore(s, size, ttl), nil
+}
+
+func withJournal(s store.EventStore, cfg config.Configuration, metadata store.Metadata) (store.EventStore, error) {
+	// a migration copies the journal, so consumers continue in the target after the cutover
+	if !isEnabled(metadata, journal.EnabledKey) && cfg.Spec.Migration == nil {
+		return s, nil
+	}
+
+	return ensureJournal(s), nil
+}
+
+// HasJournal checks if the stores created from cfg journal their versions. The journal is enabled
+// by the metadata and required by sinks, projections and migrations.
+func HasJournal(cfg config.Configuration) bool {
+	return isEnabled(metadataOf(cfg), journal.EnabledKey) || cfg.Spec.Sink != nil || len(cfg.Spec.Projections) > 0 || cfg.Spec.Migration != nil
+}
+
+// ensureJournal wraps s with a journal, unless a journal is already in the chain of decorators
+func ensureJournal(s store.EventStore) store.EventStore {
+	if _, ok := journal.From(s); ok {
+		return s
+	}
+
+	j := journal.NewStore(s)
+	j.Start(journal.DefaultBackoff)
+	return j
+}
+
+func isEnabled(metadata store.Metadata, key string) bool {
+	switch strings.ToLower(metadata.Properties[key]) {
+	case "y", "yes", "true", "on", "1":
+		return true
+	default:
+		return false
+	}
+}
+
+// Storage returns the part of the chain of decorators of s that stores streams as they are
+// written: the backend with its resilience and migration, below the decorators that derive
+// streams from writes. Archives are imported through it.
+func Storage(s store.EventStore) store.EventStore {
+	return wrapper.Find(s, func(s store.EventStore) bool {
+		switch s.(type) {
+		case *cache.Store, *outbox.Store, *projection.Store, *journal.Store, *idempotency.Store, *category.Store, *timestamps.Store:
+			return false
+		default:
+			return true
+		}
+	})
+}
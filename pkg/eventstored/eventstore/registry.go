@@ -4,26 +4,81 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 
 	"github.com/AndreasM009/eventstore-impl/store/azure/cosmosdb"
 
-	"github.com/AndreasM009/eventstore-impl/store/azure/tablestorage"
-
-	"github.com/AndreasM009/eventstore-impl/store/inmemory"
-
 	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/chaos"
 	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/memory"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/tablestorage"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+)
+
+const (
+	inmemoryType     = "eventstore.inmemory"
+	tablestorageType = "eventstore.azure.tablestorage"
+	cosmosdbType     = "eventstore.azure.cosmosdb"
+	chaosType        = "eventstore.chaos"
 )
 
+// requiredMetadata holds the metadata keys the backends can't be initialized without by type.
+// It's checked before Eventstores reach the registry, e.g. by the admission webhook.
+var requiredMetadata = map[string][]string{
+	inmemoryType:     {},
+	tablestorageType: {"storageAccountName", "storageAccountKey"},
+	cosmosdbType:     {"url", "masterKey", "database", "container"},
+	chaosType:        {chaos.TargetKey},
+}
+
+// Types returns the sorted types of the backends the registry creates
+func Types() []string {
+	types := make([]string, 0, len(requiredMetadata))
+	for typ := range requiredMetadata {
+		types = append(types, typ)
+	}
+
+	sort.Strings(types)
+	return types
+}
+
+// RequiredMetadata returns the metadata keys the backend of typ requires, ok is false if the
+// registry can't create typ. The chaos type requires the keys of its target, too.
+func RequiredMetadata(typ string) (keys []string, ok bool) {
+	keys, ok = requiredMetadata[typ]
+	return keys, ok
+}
+
+// IsChaosType checks if typ injects faults into the backend of the type in its chaosTarget metadata
+func IsChaosType(typ string) bool {
+	return typ == chaosType
+}
+
+// IsShared checks if the backend of cfg is shared by the sidecars, so its streams can be read
+// outside of them. The in memory backend lives in each sidecar.
+func IsShared(cfg config.Configuration) bool {
+	typ := cfg.Spec.Type
+	if typ == chaosType {
+		typ = metadataOf(cfg).Properties[chaos.TargetKey]
+	}
+
+	_, ok := requiredMetadata[typ]
+	return ok && typ != inmemoryType && typ != chaosType
+}
+
 // Registry interface
 type Registry interface {
 	Create(cfg config.Configuration) (store.EventStore, error)
+	CreateBackend(cfg config.Configuration) (store.EventStore, error)
+	Decorate(cfg config.Configuration, backend store.EventStore) (store.EventStore, error)
 	CreateFromConfiguration(configs []config.Configuration) (map[string]store.EventStore, error)
 }
 
 type eventstoreRegistry struct {
-	factory map[string]func() store.EventStore
+	factory    map[string]func() store.EventStore
+	decorators []decorator
 }
 
 // NewRegistry creates a new registry
@@ -32,22 +87,59 @@ func NewRegistry() Registry {
 		factory: map[string]func() store.EventStore{},
 	}
 
-	r.factory["eventstore.inmemory"] = func() store.EventStore {
-		return inmemory.NewStore()
+	// resilience guards the calls to the backend and the migration decorates it, so all other
+	// decorators write through both. The cache comes last to see every write of clients.
+	r.decorators = []decorator{withResilience, r.withMigration, withTimestamps, withCategories, withIdempotency, withJournal, withProjections, withOutbox, withCache}
+
+	r.factory[inmemoryType] = func() store.EventStore {
+		return memory.NewStore()
 	}
 
-	r.factory["eventstore.azure.tablestorage"] = func() store.EventStore {
+	r.factory[tablestorageType] = func() store.EventStore {
 		return tablestorage.NewStore()
 	}
 
-	r.factory["eventstore.azure.cosmosdb"] = func() store.EventStore {
+	r.factory[cosmosdbType] = func() store.EventStore {
 		return cosmosdb.NewStore()
 	}
 
+	r.factory[chaosType] = func() store.EventStore {
+		return chaos.NewStore(r.newTarget)
+	}
+
 	return r
 }
 
 func (r *eventstoreRegistry) Create(cfg config.Configuration) (store.EventStore, error) {
+	s, err := r.CreateBackend(cfg)
+	if err != nil {
+		return s, err
+	}
+
+	return r.Decorate(cfg, s)
+}
+
+// Decorate applies the decorators of cfg to a backend created by CreateBackend, e.g. to keep the
+// in memory backend of a store that is recreated
+func (r *eventstoreRegistry) Decorate(cfg config.Configuration, s store.EventStore) (store.EventStore, error) {
+	metadata := metadataOf(cfg)
+
+	for _, decorate := range r.decorators {
+		d, err := decorate(s, cfg, metadata)
+		if err != nil {
+			// stop the background work of the decorators applied so far
+			wrapper.Close(s)
+			return s, err
+		}
+
+		s = d
+	}
+
+	return s, nil
+}
+
+// CreateBackend creates the store without decorators, e.g. to read a store outside of eventstored
+func (r *eventstoreRegistry) CreateBackend(cfg config.Configuration) (store.EventStore, error) {
 	factory, ok := r.factory[cfg.Spec.Type]
 
 	if !ok {
@@ -56,19 +148,21 @@ func (r *eventstoreRegistry) Create(cfg config.Configuration) (store.EventStore,
 
 	s := factory()
 
-	metadata := store.Metadata{
-		Properties: map[string]string{},
+	if err := s.Init(metadataOf(cfg)); err != nil {
+		return s, err
 	}
 
-	for _, m := range cfg.Spec.Metadata {
-		metadata.Properties[m.Name] = m.Value
-	}
+	return s, nil
+}
 
-	if err := s.Init(metadata); err != nil {
-		return s, err
+// newTarget creates an uninitialized backend of type typ for a chaos store
+func (r *eventstoreRegistry) newTarget(typ string) (store.EventStore, error) {
+	factory, ok := r.factory[typ]
+	if !ok || typ == chaosType {
+		return nil, fmt.Errorf("registry: can't create eventstore %s as chaos target", typ)
 	}
 
-	return s, nil
+	return factory(), nil
 }
 
 func (r *eventstoreRegistry) CreateFromConfiguration(configs []config.Configuration) (map[string]store.EventStore, error) {
@@ -92,3 +186,15 @@ func (r *eventstoreRegistry) CreateFromConfiguration(configs []config.Configurat
 
 	return resultmap, nil
 }
+
+func metadataOf(cfg config.Configuration) store.Metadata {
+	metadata := store.Metadata{
+		Properties: map[string]string{},
+	}
+
+	for _, m := range cfg.Spec.Metadata {
+		metadata.Properties[m.Name] = m.Value
+	}
+
+	return metadata
+}
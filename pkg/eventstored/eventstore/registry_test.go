@@ -3,7 +3,16 @@ package eventstore
 import (
 	"testing"
 
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/cache"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/category"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/chaos"
 	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/migration"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/resilience"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -110,3 +119,246 @@ func TestLoadConfigurationWithBadConfig(t *testing.T) {
 	assert.Nil(t, s)
 	assert.True(t, ok)
 }
+
+func TestCreateWithCategories(t *testing.T) {
+	registry := NewRegistry()
+
+	s, err := registry.Create(config.Configuration{
+		Kind: "eventstore",
+		Metadata: config.ConfigurationMetadata{
+			Name: storeNameOne,
+		},
+		Spec: config.Spec{
+			Type: "eventstore.inmemory",
+			Metadata: []config.SpecMetadata{
+				config.SpecMetadata{
+					Name:  category.SeparatorKey,
+					Value: "-",
+				},
+			},
+		},
+	})
+
+	assert.Nil(t, err)
+
+	_, ok := category.From(s)
+	assert.True(t, ok)
+}
+
+func TestCreateWithMigration(t *testing.T) {
+	registry := NewRegistry()
+
+	cfg := config.Configuration{
+		Kind: "eventstore",
+		Metadata: config.ConfigurationMetadata{
+			Name: storeNameOne,
+		},
+		Spec: config.Spec{
+			Type: "eventstore.inmemory",
+			Migration: &config.MigrationSpec{
+				Type:  "eventstore.inmemory",
+				Stage: migration.StageDualWrite,
+			},
+		},
+	}
+
+	s, err := registry.Create(cfg)
+	assert.Nil(t, err)
+
+	_, ok := migration.From(s)
+	assert.True(t, ok)
+
+	// the migration copies the journal
+	_, ok = journal.From(s)
+	assert.True(t, ok)
+
+	// archives are imported through the migration
+	_, ok = Storage(s).(*migration.Store)
+	assert.True(t, ok)
+
+	cfg.Spec.Migration.Stage = "unknown"
+	_, err = registry.Create(cfg)
+	assert.NotNil(t, err)
+
+	cfg.Spec.Migration = &config.MigrationSpec{Type: "eventstore.unknown", Stage: migration.StageDualWrite}
+	_, err = registry.Create(cfg)
+	assert.NotNil(t, err)
+}
+
+func TestStorage(t *testing.T) {
+	s, err := NewRegistry().Create(config.Configuration{
+		Kind:     "eventstore",
+		Metadata: config.ConfigurationMetadata{Name: storeNameOne},
+		Spec: config.Spec{
+			Type: "eventstore.inmemory",
+			Metadata: []config.SpecMetadata{
+				{Name: category.SeparatorKey, Value: "-"},
+				{Name: journal.EnabledKey, Value: "true"},
+				{Name: cache.SizeKey, Value: "100"},
+			},
+		},
+	})
+	assert.Nil(t, err)
+	defer wrapper.Close(s)
+
+	// writes through the storage aren't journaled
+	_, err = Storage(s).Add(&store.Entity{ID: "order-1", Data: 1})
+	assert.Nil(t, err)
+
+	j, _ := journal.From(s)
+	head, err := j.Head()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), head)
+}
+
+func TestCreateWithCache(t *testing.T) {
+	registry := NewRegistry()
+
+	cfg := config.Configuration{
+		Kind: "eventstore",
+		Metadata: config.ConfigurationMetadata{
+			Name: storeNameOne,
+		},
+		Spec: config.Spec{
+			Type: "eventstore.inmemory",
+			Metadata: []config.SpecMetadata{
+				config.SpecMetadata{
+					Name:  cache.SizeKey,
+					Value: "100",
+				},
+			},
+		},
+	}
+
+	s, err := registry.Create(cfg)
+	assert.Nil(t, err)
+
+	_, ok := cache.From(s)
+	assert.True(t, ok)
+
+	cfg.Spec.Metadata[0].Value = "none"
+	_, err = registry.Create(cfg)
+	assert.NotNil(t, err)
+}
+
+func TestCreateWithResilience(t *testing.T) {
+	registry := NewRegistry().(*eventstoreRegistry)
+	registry.factory["eventstore.fake"] = func() store.EventStore {
+		return fakestore.NewStore()
+	}
+
+	cfg := config.Configuration{
+		Kind: "eventstore",
+		Metadata: config.ConfigurationMetadata{
+			Name: storeNameOne,
+		},
+		Spec: config.Spec{
+			Type: "eventstore.fake",
+		},
+	}
+
+	s, err := registry.Create(cfg)
+	assert.Nil(t, err)
+
+	_, ok := resilience.From(s)
+	assert.True(t, ok)
+
+	cfg.Spec.Metadata = []config.SpecMetadata{
+		config.SpecMetadata{
+			Name:  resilience.TimeoutKey,
+			Value: "soon",
+		},
+	}
+
+	_, err = registry.Create(cfg)
+	assert.NotNil(t, err)
+
+	// the in memory backend is used without resilience
+	cfg.Spec.Type = inmemoryType
+	s, err = registry.Create(cfg)
+	assert.Nil(t, err)
+
+	_, ok = resilience.From(s)
+	assert.False(t, ok)
+}
+
+func TestCreateChaos(t *testing.T) {
+	registry := NewRegistry()
+
+	cfg := config.Configuration{
+		Kind: "eventstore",
+		Metadata: config.ConfigurationMetadata{
+			Name: storeNameOne,
+		},
+		Spec: config.Spec{
+			Type: chaosType,
+			Metadata: []config.SpecMetadata{
+				config.SpecMetadata{
+					Name:  chaos.TargetKey,
+					Value: inmemoryType,
+				},
+			},
+		},
+	}
+
+	s, err := registry.Create(cfg)
+	assert.Nil(t, err)
+
+	_, ok := chaos.From(s)
+	assert.True(t, ok)
+
+	// faults injected into the in memory backend are not retried
+	_, ok = resilience.From(s)
+	assert.False(t, ok)
+
+	cfg.Spec.Metadata[0].Value = chaosType
+	_, err = registry.Create(cfg)
+	assert.NotNil(t, err)
+}
+
+func TestRequiredMetadataCoversAllTypes(t *testing.T) {
+	r := NewRegistry().(*eventstoreRegistry)
+
+	types := []string{}
+	for typ := range r.factory {
+		types = append(types, typ)
+
+		_, ok := RequiredMetadata(typ)
+		assert.True(t, ok, typ)
+	}
+
+	assert.ElementsMatch(t, types, Types())
+
+	_, ok := RequiredMetadata("eventstore.azure.cosmos")
+	assert.False(t, ok)
+}
+
+func TestIsShared(t *testing.T) {
+	cfg := config.Configuration{Spec: config.Spec{Type: tablestorageType}}
+	assert.True(t, IsShared(cfg))
+
+	cfg.Spec.Type = inmemoryType
+	assert.False(t, IsShared(cfg))
+
+	cfg.Spec.Type = chaosType
+	cfg.Spec.Metadata = []config.SpecMetadata{{Name: chaos.TargetKey, Value: inmemoryType}}
+	assert.False(t, IsShared(cfg))
+
+	cfg.Spec.Metadata[0].Value = cosmosdbType
+	assert.True(t, IsShared(cfg))
+
+	cfg.Spec.Type = "eventstore.azure.cosmos"
+	assert.False(t, IsShared(cfg))
+}
+
+func TestHasJournal(t *testing.T) {
+	cfg := config.Configuration{Spec: config.Spec{Type: tablestorageType}}
+	assert.False(t, HasJournal(cfg))
+
+	cfg.Spec.Metadata = []config.SpecMetadata{{Name: journal.EnabledKey, Value: "true"}}
+	assert.True(t, HasJournal(cfg))
+
+	cfg.Spec.Metadata = nil
+	cfg.Spec.Projections = []config.ProjectionSpec{{Name: "orders", Type: "mergepatch"}}
+	assert.True(t, HasJournal(cfg))
+}
@@ -0,0 +1,400 @@
+package eventstore
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/memory"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+)
+
+// ErrOutdated is returned by Update for a configuration with an older resourceVersion than the
+// applied one, pushes of the operator may arrive out of order
+var ErrOutdated = errors.New("registry: configuration is older than the applied one")
+
+// drainTimeout bounds how long a replaced or removed store waits for the requests using it,
+// before it's closed
+var drainTimeout = time.Minute
+
+// Stores is the set of named EventStores served by eventstored, it's safe for concurrent use.
+// A name may be known without a store, if its configuration couldn't be applied. Removed stores
+// are remembered, so outdated configurations don't bring them back. Replaced and removed stores
+// are closed once the requests that acquired them released them.
+type Stores struct {
+	// updating serializes changes of configurations
+	updating sync.Mutex
+	mutex    sync.RWMutex
+	stores   map[string]*entry
+	configs  map[string]config.Configuration
+	removed  map[string]config.Configuration
+}
+
+// entry is a store with the requests using it
+type entry struct {
+	es    store.EventStore
+	users sync.WaitGroup
+}
+
+// NewStores creates a new empty set of stores
+func NewStores() *Stores {
+	return &Stores{
+		stores:  map[string]*entry{},
+		configs: map[string]config.Configuration{},
+		removed: map[string]config.Configuration{},
+	}
+}
+
+// Get returns the store with name, if it was created successfully. The store may be closed as
+// soon as it's replaced, requests use Acquire.
+func (s *Stores) Get(name string) (store.EventStore, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	es := s.stores[name].store()
+	return es, es != nil
+}
+
+// Acquire returns the store with name like Get, it isn't closed until release is called
+func (s *Stores) Acquire(name string) (es store.EventStore, release func(), ok bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	e, ok := s.stores[name]
+	if !ok || e.es == nil {
+		return nil, func() {}, false
+	}
+
+	e.users.Add(1)
+	return e.es, e.users.Done, true
+}
+
+// Has checks if name is known, even if its store couldn't be created or was removed
+func (s *Stores) Has(name string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, ok := s.stores[name]
+	_, removed := s.removed[name]
+	return ok || removed
+}
+
+// Removed checks if the store with name was removed
+func (s *Stores) Removed(name string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, ok := s.removed[name]
+	return ok
+}
+
+// Names returns the sorted names of all known stores
+func (s *Stores) Names() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	names := make([]string, 0, len(s.stores))
+	for name := range s.stores {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Set replaces the store of cfg's name with es and closes the replaced store, once it's released
+func (s *Stores) Set(cfg config.Configuration, es store.EventStore) {
+	s.mutex.Lock()
+	old := s.stores[cfg.Metadata.Name]
+	s.stores[cfg.Metadata.Name] = &entry{es: es}
+	s.configs[cfg.Metadata.Name] = cfg
+	delete(s.removed, cfg.Metadata.Name)
+	s.mutex.Unlock()
+
+	closeStore(cfg.Metadata.Name, old, es)
+}
+
+// Remove removes and closes the store with name
+func (s *Stores) Remove(name string) {
+	s.remove(name, "")
+}
+
+// Delete removes the store with name, because its configuration was deleted at resourceVersion.
+// It returns ErrOutdated if the applied configuration is newer.
+func (s *Stores) Delete(name, resourceVersion string) error {
+	s.updating.Lock()
+	defer s.updating.Unlock()
+
+	s.mutex.RLock()
+	cfg, known := s.configs[name]
+	s.mutex.RUnlock()
+
+	if !known {
+		return nil
+	}
+
+	deleted := config.Configuration{Metadata: config.ConfigurationMetadata{Name: name, ResourceVersion: resourceVersion}}
+	if isOutdated(cfg, deleted) {
+		return ErrOutdated
+	}
+
+	s.remove(name, resourceVersion)
+	log.Printf("registry: Eventstore '%s' deleted\n", name)
+	return nil
+}
+
+func (s *Stores) remove(name, resourceVersion string) {
+	s.mutex.Lock()
+	old := s.stores[name]
+	cfg := s.configs[name]
+	delete(s.stores, name)
+	delete(s.configs, name)
+
+	if resourceVersion != "" {
+		cfg.Metadata.ResourceVersion = resourceVersion
+	}
+
+	s.removed[name] = cfg
+	s.mutex.Unlock()
+
+	closeStore(name, old, nil)
+}
+
+// Update applies the configuration of a single store: the store is created if its name is new
+// or recreated if cfg changed. If it can't be created, the previous store stays in place.
+func (s *Stores) Update(r Registry, cfg config.Configuration) error {
+	s.updating.Lock()
+	defer s.updating.Unlock()
+
+	return s.update(r, cfg)
+}
+
+// Apply makes configs the configuration of the set: every configuration is applied like with
+// Update and stores missing in configs are removed. The error lists all failed stores. Secret
+// references without a value keep the value of the applied configuration, the operator serves
+// Eventstores without secret values and only sends them with Update.
+func (s *Stores) Apply(r Registry, configs []config.Configuration) error {
+	s.updating.Lock()
+	defer s.updating.Unlock()
+
+	builder := strings.Builder{}
+	names := map[string]bool{}
+
+	for _, cfg := range configs {
+		names[cfg.Metadata.Name] = true
+
+		s.mutex.RLock()
+		applied := s.configs[cfg.Metadata.Name]
+		s.mutex.RUnlock()
+
+		if err := s.update(r, withSecretValues(cfg, applied)); err != nil {
+			builder.WriteString(fmt.Sprintf("%s\n", err))
+		}
+	}
+
+	for _, name := range s.Names() {
+		if !names[name] {
+			s.Remove(name)
+			log.Printf("registry: Eventstore '%s' removed\n", name)
+		}
+	}
+
+	if builder.Len() != 0 {
+		return errors.New(builder.String())
+	}
+
+	return nil
+}
+
+func (s *Stores) update(r Registry, cfg config.Configuration) error {
+	name := cfg.Metadata.Name
+
+	s.mutex.RLock()
+	old, known := s.stores[name]
+	oldCfg := s.configs[name]
+	removedCfg, removed := s.removed[name]
+	s.mutex.RUnlock()
+
+	if removed && isOutdated(removedCfg, cfg) {
+		return ErrOutdated
+	}
+
+	var changes []string
+	if known {
+		if isOutdated(oldCfg, cfg) {
+			return ErrOutdated
+		}
+
+		changes = config.Diff(oldCfg, cfg)
+		if len(changes) == 0 && old.store() != nil {
+			// the store is kept, but the resourceVersion is remembered
+			s.mutex.Lock()
+			s.configs[name] = cfg
+			s.mutex.Unlock()
+			return nil
+		}
+	}
+
+	es, err := create(r, cfg, old.store(), oldCfg)
+	if err != nil {
+		if old.store() == nil {
+			// remember the name, a later configuration may succeed
+			s.Set(cfg, nil)
+		} else {
+			log.Printf("registry: Eventstore '%s' keeps its previous configuration\n", name)
+		}
+
+		return err
+	}
+
+	s.Set(cfg, es)
+
+	switch {
+	case !known:
+		log.Printf("registry: Eventstore '%s' added\n", name)
+	case len(changes) != 0:
+		log.Printf("registry: Eventstore '%s' changed: %s\n", name, strings.Join(changes, ", "))
+	default:
+		log.Printf("registry: Eventstore '%s' initialized\n", name)
+	}
+
+	return nil
+}
+
+// withSecretValues returns cfg with the values of the secret references that have none taken from
+// applied, if it references the same secrets
+func withSecretValues(cfg, applied config.Configuration) config.Configuration {
+	cfg.Spec.Metadata = secretValues(cfg.Spec.Metadata, applied.Spec.Metadata)
+
+	if cfg.Spec.Sink != nil && applied.Spec.Sink != nil {
+		sink := *cfg.Spec.Sink
+		sink.Metadata = secretValues(sink.Metadata, applied.Spec.Sink.Metadata)
+		cfg.Spec.Sink = &sink
+	}
+
+	if cfg.Spec.Migration != nil && applied.Spec.Migration != nil {
+		migration := *cfg.Spec.Migration
+		migration.Metadata = secretValues(migration.Metadata, applied.Spec.Migration.Metadata)
+		cfg.Spec.Migration = &migration
+	}
+
+	projections := make([]config.ProjectionSpec, len(cfg.Spec.Projections))
+	for i, p := range cfg.Spec.Projections {
+		for _, a := range applied.Spec.Projections {
+			if a.Name == p.Name {
+				p.Metadata = secretValues(p.Metadata, a.Metadata)
+			}
+		}
+
+		projections[i] = p
+	}
+
+	if cfg.Spec.Projections != nil {
+		cfg.Spec.Projections = projections
+	}
+
+	return cfg
+}
+
+// secretValues returns a copy of items, whose secret references without a value have the value
+// of the same item in applied
+func secretValues(items, applied []config.SpecMetadata) []config.SpecMetadata {
+	if items == nil {
+		return nil
+	}
+
+	result := make([]config.SpecMetadata, len(items))
+
+	for i, m := range items {
+		result[i] = m
+
+		if m.Value != "" || m.SecretKeyRef == nil || m.SecretKeyRef.Name == "" {
+			continue
+		}
+
+		for _, a := range applied {
+			if a.Name == m.Name && a.SecretKeyRef != nil && *a.SecretKeyRef == *m.SecretKeyRef {
+				result[i].Value = a.Value
+			}
+		}
+	}
+
+	return result
+}
+
+// create creates the store of cfg. A store of the in memory backend that is recreated keeps the
+// backend of the replaced store old, its entities would be lost otherwise.
+func create(r Registry, cfg config.Configuration, old store.EventStore, oldCfg config.Configuration) (store.EventStore, error) {
+	if old != nil && cfg.Spec.Type == inmemoryType && oldCfg.Spec.Type == inmemoryType {
+		backend, ok := wrapper.Find(old, func(s store.EventStore) bool {
+			_, ok := s.(*memory.Store)
+			return ok
+		}).(*memory.Store)
+
+		if ok {
+			return r.Decorate(cfg, backend)
+		}
+	}
+
+	return r.Create(cfg)
+}
+
+func (e *entry) store() store.EventStore {
+	if e == nil {
+		return nil
+	}
+
+	return e.es
+}
+
+// closeStore closes the store of old in the background, once all requests released it or the
+// drainTimeout elapsed
+func closeStore(name string, old *entry, replacement store.EventStore) {
+	if old.store() == nil || old.es == replacement {
+		return
+	}
+
+	timeout := drainTimeout
+
+	go func() {
+		drained := make(chan struct{})
+
+		go func() {
+			old.users.Wait()
+			close(drained)
+		}()
+
+		select {
+		case <-drained:
+		case <-time.After(timeout):
+			log.Printf("registry: closing replaced Eventstore %s with requests in progress after %s\n", name, timeout)
+		}
+
+		if err := wrapper.Close(old.es); err != nil {
+			log.Printf("registry: failed to close replaced Eventstore %s: %s\n", name, err)
+		}
+	}()
+}
+
+// isOutdated checks if cfg has an older resourceVersion than applied. Configurations without a
+// numeric resourceVersion, like the standalone ones, are never outdated.
+func isOutdated(applied, cfg config.Configuration) bool {
+	appliedVersion, err := strconv.ParseUint(applied.Metadata.ResourceVersion, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	version, err := strconv.ParseUint(cfg.Metadata.ResourceVersion, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return version < appliedVersion
+}
@@ -0,0 +1,290 @@
+package eventstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/cache"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStoreConfiguration(name string, metadata ...config.SpecMetadata) config.Configuration {
+	return config.Configuration{
+		Kind: "eventstore",
+		Metadata: config.ConfigurationMetadata{
+			Name: name,
+		},
+		Spec: config.Spec{
+			Type:     inmemoryType,
+			Metadata: metadata,
+		},
+	}
+}
+
+func TestApplyAddsAndRemovesStores(t *testing.T) {
+	registry := NewRegistry()
+	stores := NewStores()
+
+	err := stores.Apply(registry, []config.Configuration{
+		testStoreConfiguration(storeNameOne),
+		testStoreConfiguration(storeNameTwo),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{storeNameOne, storeNameTwo}, stores.Names())
+
+	err = stores.Apply(registry, []config.Configuration{
+		testStoreConfiguration(storeNameOne),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{storeNameOne}, stores.Names())
+
+	_, ok := stores.Get(storeNameTwo)
+	assert.False(t, ok)
+}
+
+func TestApplyRecreatesChangedStoresOnly(t *testing.T) {
+	registry := NewRegistry()
+	stores := NewStores()
+
+	cfg := testStoreConfiguration(storeNameOne)
+	assert.Nil(t, stores.Apply(registry, []config.Configuration{cfg}))
+	first, _ := stores.Get(storeNameOne)
+
+	assert.Nil(t, stores.Apply(registry, []config.Configuration{cfg}))
+	same, _ := stores.Get(storeNameOne)
+	assert.True(t, first == same, "unchanged stores are kept")
+
+	cfg = testStoreConfiguration(storeNameOne, config.SpecMetadata{Name: cache.SizeKey, Value: "10"})
+	assert.Nil(t, stores.Apply(registry, []config.Configuration{cfg}))
+	changed, _ := stores.Get(storeNameOne)
+	assert.False(t, first == changed)
+
+	_, ok := cache.From(changed)
+	assert.True(t, ok)
+}
+
+func TestApplyKeepsPreviousStoreOnFailure(t *testing.T) {
+	registry := NewRegistry()
+	stores := NewStores()
+
+	cfg := testStoreConfiguration(storeNameOne)
+	assert.Nil(t, stores.Apply(registry, []config.Configuration{cfg}))
+	previous, _ := stores.Get(storeNameOne)
+
+	invalid := testStoreConfiguration(storeNameOne, config.SpecMetadata{Name: cache.SizeKey, Value: "many"})
+	assert.NotNil(t, stores.Apply(registry, []config.Configuration{invalid}))
+
+	current, ok := stores.Get(storeNameOne)
+	assert.True(t, ok)
+	assert.True(t, previous == current)
+}
+
+func TestFailedStoresAreKnownByName(t *testing.T) {
+	registry := NewRegistry()
+	stores := NewStores()
+
+	cfg := testStoreConfiguration(storeNameOne)
+	cfg.Spec.Type = "eventstore.notimplemented"
+
+	assert.NotNil(t, stores.Update(registry, cfg))
+	assert.True(t, stores.Has(storeNameOne))
+
+	_, ok := stores.Get(storeNameOne)
+	assert.False(t, ok)
+
+	// the same configuration is retried, as there is no store yet
+	cfg.Spec.Type = inmemoryType
+	assert.Nil(t, stores.Update(registry, cfg))
+
+	_, ok = stores.Get(storeNameOne)
+	assert.True(t, ok)
+}
+
+func TestUpdateIgnoresOutdatedConfigurations(t *testing.T) {
+	registry := NewRegistry()
+	stores := NewStores()
+
+	cfg := testStoreConfiguration(storeNameOne)
+	cfg.Metadata.ResourceVersion = "10"
+	assert.Nil(t, stores.Update(registry, cfg))
+	first, _ := stores.Get(storeNameOne)
+
+	// an unchanged configuration advances the resourceVersion
+	cfg.Metadata.ResourceVersion = "12"
+	assert.Nil(t, stores.Update(registry, cfg))
+
+	outdated := testStoreConfiguration(storeNameOne, config.SpecMetadata{Name: cache.SizeKey, Value: "10"})
+	outdated.Metadata.ResourceVersion = "11"
+	assert.Equal(t, ErrOutdated, stores.Update(registry, outdated))
+
+	current, _ := stores.Get(storeNameOne)
+	assert.True(t, first == current)
+
+	// configurations without resourceVersion are applied
+	outdated.Metadata.ResourceVersion = ""
+	assert.Nil(t, stores.Update(registry, outdated))
+
+	current, _ = stores.Get(storeNameOne)
+	assert.False(t, first == current)
+}
+
+func TestApplyKeepsPushedSecretValues(t *testing.T) {
+	registry := NewRegistry()
+	stores := NewStores()
+
+	ref := &config.SecretKeyRef{Name: "storage", Key: "key"}
+	pushed := testStoreConfiguration(storeNameOne, config.SpecMetadata{Name: "storageAccountKey", Value: "testaccountkey", SecretKeyRef: ref})
+	pushed.Metadata.ResourceVersion = "10"
+	assert.Nil(t, stores.Update(registry, pushed))
+	first, _ := stores.Get(storeNameOne)
+
+	// the operator serves Eventstores without secret values
+	watched := testStoreConfiguration(storeNameOne, config.SpecMetadata{Name: "storageAccountKey", SecretKeyRef: ref})
+	watched.Metadata.ResourceVersion = "10"
+	assert.Nil(t, stores.Apply(registry, []config.Configuration{watched}))
+
+	current, _ := stores.Get(storeNameOne)
+	assert.True(t, first == current)
+	assert.Equal(t, "testaccountkey", stores.configs[storeNameOne].Spec.Metadata[0].Value)
+	assert.Empty(t, watched.Spec.Metadata[0].Value)
+
+	// a reference to another secret doesn't get the value
+	watched.Spec.Metadata[0].SecretKeyRef = &config.SecretKeyRef{Name: "other", Key: "key"}
+	assert.Nil(t, stores.Apply(registry, []config.Configuration{watched}))
+	assert.Empty(t, stores.configs[storeNameOne].Spec.Metadata[0].Value)
+}
+
+func TestDeletedStoresAreRemembered(t *testing.T) {
+	registry := NewRegistry()
+	stores := NewStores()
+
+	cfg := testStoreConfiguration(storeNameOne)
+	cfg.Metadata.ResourceVersion = "10"
+	assert.Nil(t, stores.Update(registry, cfg))
+
+	assert.Equal(t, ErrOutdated, stores.Delete(storeNameOne, "9"))
+	assert.False(t, stores.Removed(storeNameOne))
+
+	assert.Nil(t, stores.Delete(storeNameOne, "11"))
+
+	_, ok := stores.Get(storeNameOne)
+	assert.False(t, ok)
+	assert.True(t, stores.Removed(storeNameOne))
+	assert.True(t, stores.Has(storeNameOne))
+	assert.Empty(t, stores.Names())
+
+	// a push that was overtaken by the deletion doesn't bring the store back
+	assert.Equal(t, ErrOutdated, stores.Update(registry, cfg))
+	assert.True(t, stores.Removed(storeNameOne))
+
+	// an Eventstore created again with the same name has a newer resourceVersion
+	cfg.Metadata.ResourceVersion = "12"
+	assert.Nil(t, stores.Update(registry, cfg))
+	assert.False(t, stores.Removed(storeNameOne))
+
+	_, ok = stores.Get(storeNameOne)
+	assert.True(t, ok)
+
+	// unknown stores are deleted without error
+	assert.Nil(t, stores.Delete(storeNameTwo, "13"))
+	assert.False(t, stores.Has(storeNameTwo))
+}
+
+// closingStore records that it was closed
+type closingStore struct {
+	store.EventStore
+	closed chan struct{}
+}
+
+func (s *closingStore) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func isClosed(s *closingStore) bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestReplacedStoresAreClosedWhenReleased(t *testing.T) {
+	stores := NewStores()
+	cfg := testStoreConfiguration(storeNameOne)
+
+	first := &closingStore{EventStore: memory.NewStore(), closed: make(chan struct{})}
+	stores.Set(cfg, first)
+
+	es, release, ok := stores.Acquire(storeNameOne)
+	assert.True(t, ok)
+	assert.True(t, es == first)
+
+	second := &closingStore{EventStore: memory.NewStore(), closed: make(chan struct{})}
+	stores.Set(cfg, second)
+
+	// the request in progress keeps using the replaced store
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, isClosed(first))
+
+	release()
+	assert.Eventually(t, func() bool { return isClosed(first) }, time.Second, 5*time.Millisecond)
+
+	// removed stores are drained, too
+	_, release, ok = stores.Acquire(storeNameOne)
+	assert.True(t, ok)
+
+	stores.Remove(storeNameOne)
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, isClosed(second))
+
+	release()
+	assert.Eventually(t, func() bool { return isClosed(second) }, time.Second, 5*time.Millisecond)
+
+	_, _, ok = stores.Acquire(storeNameOne)
+	assert.False(t, ok)
+}
+
+func TestReplacedStoresAreClosedAfterTheDrainTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { drainTimeout = timeout }(drainTimeout)
+	drainTimeout = 10 * time.Millisecond
+
+	stores := NewStores()
+	cfg := testStoreConfiguration(storeNameOne)
+
+	first := &closingStore{EventStore: memory.NewStore(), closed: make(chan struct{})}
+	stores.Set(cfg, first)
+
+	_, release, ok := stores.Acquire(storeNameOne)
+	assert.True(t, ok)
+	defer release()
+
+	stores.Set(cfg, memory.NewStore())
+	assert.Eventually(t, func() bool { return isClosed(first) }, time.Second, 5*time.Millisecond)
+}
+
+func TestRecreatedInMemoryStoresKeepTheirEntities(t *testing.T) {
+	registry := NewRegistry()
+	stores := NewStores()
+
+	cfg := testStoreConfiguration(storeNameOne)
+	assert.Nil(t, stores.Apply(registry, []config.Configuration{cfg}))
+
+	first, _ := stores.Get(storeNameOne)
+	_, err := first.Add(&store.Entity{ID: "order-1", Data: "created"})
+	assert.Nil(t, err)
+
+	cfg = testStoreConfiguration(storeNameOne, config.SpecMetadata{Name: cache.SizeKey, Value: "10"})
+	assert.Nil(t, stores.Apply(registry, []config.Configuration{cfg}))
+
+	changed, _ := stores.Get(storeNameOne)
+	assert.False(t, first == changed)
+
+	v, err := changed.GetLatestVersionNumber("order-1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), v)
+}
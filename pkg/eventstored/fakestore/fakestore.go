@@ -0,0 +1,14 @@
+package fakestore
+
+import (
+	"github.com/AndreasM009/eventstore/pkg/eventstored/memory"
+)
+
+// Store is an in memory EventStore for tests. It's the backend of eventstore.inmemory, which
+// matches the behavior of the Azure backends unlike the in memory store of eventstore-impl.
+type Store = memory.Store
+
+// NewStore creates a new fake store
+func NewStore() *Store {
+	return memory.NewStore()
+}
@@ -0,0 +1,129 @@
+package fakestore
+
+import (
+	"sync"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+)
+
+// Faults are injected into the calls of a FaultyStore
+type Faults struct {
+	// Latency is added to every call
+	Latency time.Duration
+	// Err is returned instead of calling the wrapped store
+	Err error
+	// Failures is the number of calls that fail with Err, all calls fail if it's negative
+	Failures int
+}
+
+// FaultyStore is an EventStore that injects latency and errors into the calls of a wrapped store
+type FaultyStore struct {
+	store.EventStore
+	mutex  sync.Mutex
+	faults Faults
+	calls  int
+}
+
+// NewFaultyStore creates a new FaultyStore wrapping s, without any faults
+func NewFaultyStore(s store.EventStore) *FaultyStore {
+	return &FaultyStore{
+		EventStore: s,
+	}
+}
+
+// Inject replaces the injected faults
+func (s *FaultyStore) Inject(faults Faults) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults = faults
+}
+
+// Faults returns the remaining faults
+func (s *FaultyStore) Faults() Faults {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.faults
+}
+
+// Calls returns the number of calls to the store
+func (s *FaultyStore) Calls() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.calls
+}
+
+// Unwrap returns the wrapped EventStore
+func (s *FaultyStore) Unwrap() store.EventStore {
+	return s.EventStore
+}
+
+// Add adds the first version of a new entity
+func (s *FaultyStore) Add(entity *store.Entity) (*store.Entity, error) {
+	if err := s.fault(); err != nil {
+		return nil, err
+	}
+
+	return s.EventStore.Add(entity)
+}
+
+// Append appends a new version to an existing entity
+func (s *FaultyStore) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	if err := s.fault(); err != nil {
+		return nil, err
+	}
+
+	return s.EventStore.Append(entity, concurrency)
+}
+
+// GetLatestVersionNumber returns the latest version of an entity
+func (s *FaultyStore) GetLatestVersionNumber(id string) (int64, error) {
+	if err := s.fault(); err != nil {
+		return 0, err
+	}
+
+	return s.EventStore.GetLatestVersionNumber(id)
+}
+
+// GetByVersion returns a single version of an entity
+func (s *FaultyStore) GetByVersion(id string, version int64) (*store.Entity, error) {
+	if err := s.fault(); err != nil {
+		return nil, err
+	}
+
+	return s.EventStore.GetByVersion(id, version)
+}
+
+// GetByVersionRange returns all versions between startVersion and endVersion, both inclusive
+func (s *FaultyStore) GetByVersionRange(id string, startVersion, endVersion int64) ([]store.Entity, error) {
+	if err := s.fault(); err != nil {
+		return nil, err
+	}
+
+	return s.EventStore.GetByVersionRange(id, startVersion, endVersion)
+}
+
+// fault waits for the injected latency and returns the injected error, if the call should fail
+func (s *FaultyStore) fault() error {
+	s.mutex.Lock()
+	s.calls++
+	latency := s.faults.Latency
+	var err error
+
+	if s.faults.Err != nil && s.faults.Failures != 0 {
+		err = s.faults.Err
+		if s.faults.Failures > 0 {
+			s.faults.Failures--
+		}
+	}
+	s.mutex.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	return err
+}
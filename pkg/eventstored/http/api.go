@@ -7,17 +7,42 @@ package http
 // PUT /entities/{id} -> adds a new entity version
 // GET /entities/{id}?version={versionnumber} -> gets an entity with specified version
 // GET /entities/{id} -> gets the latest version available for specified entity
+// GET /entities/{id}?asof={RFC3339} -> gets the latest version written at or before a point in time
+// GET /entities/{id}?from={RFC3339}&to={RFC3339} -> gets all versions written in a period of time
+// asof and from/to are answered with 409, if the write timestamps of the latest versions are missing
+// GET /categories/{category}?from={position} -> gets the events of a category in write order
+// GET /categories/{category}/subscription?from={position} -> streams the events of a category
+// GET /projections/{projection}/{id} -> gets the state of an entity in a projection
+// POST /projections/{projection}/rebuild -> rebuilds a projection from the beginning of the journal
+// GET /export?fromid={id}&fromversion={version} -> exports all entities as gzip compressed NDJSON
+// POST /import?dryrun=true -> imports (or validates) a gzip compressed NDJSON archive, requires
+// the API token as bearer token in the Authorization header
+// GET /migration -> gets the stage and progress of a migration to another backend
+// GET /cache -> gets the hit and miss metrics of the cache
+// GET /chaos -> gets the fault profile of an eventstore.chaos store
+// PUT /chaos -> replaces the fault profile of an eventstore.chaos store
+// Entity requests may carry an Eventstore-Timeout header that bounds the calls to the backend.
+// Calls that time out are answered with 504, calls while the backend's circuit breaker is
+// open with 503 and a Retry-After header.
 //---------------------------------------------------------------------------------------------
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
+	"time"
 
 	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/cache"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/category"
 	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
 	registry "github.com/AndreasM009/eventstore/pkg/eventstored/eventstore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/migration"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/projection"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/timestamps"
 	routing "github.com/qiangxue/fasthttp-routing"
 	"github.com/valyala/fasthttp"
 )
@@ -28,8 +53,9 @@ type APIRoutes interface {
 }
 
 type api struct {
-	evtstores map[string]store.EventStore
+	evtstores *registry.Stores
 	registry  registry.Registry
+	token     string
 }
 
 const (
@@ -38,24 +64,49 @@ const (
 	versionQueryParam          = "version"
 	startVersionQueryParameter = "startversion"
 	endVersionQueryParameter   = "endversion"
+	categoryParam              = "category"
+	projectionParam            = "projection"
+	fromQueryParameter         = "from"
+	toQueryParameter           = "to"
+	asOfQueryParameter         = "asof"
+	maxQueryParameter          = "max"
+	defaultCategoryPageSize    = 100
+	maxCategoryPageSize        = 1000
 )
 
-// NewAPI creates a new server instance
-func NewAPI(evtstores map[string]store.EventStore, registry registry.Registry) APIRoutes {
+// NewAPI creates a new server instance, token authorizes imports
+func NewAPI(evtstores *registry.Stores, registry registry.Registry, token string) APIRoutes {
 	api := &api{
 		evtstores: evtstores,
 		registry:  registry,
+		token:     token,
 	}
 	return api
 }
 
 func (a *api) RegisterRoutes(r *routing.Router) {
-	r.Post("/eventstores/<name>/entities/<id>", a.onPostEntity)
-	r.Put("/eventstores/<name>/entities/<id>", a.onPutEntity)
+	// writes may carry an Idempotency-Key header
+	r.Post("/eventstores/<name>/entities/<id>", a.withIdempotency("ERR_INVOKE_POST_ENTITY", a.onPostEntity))
+	r.Put("/eventstores/<name>/entities/<id>", a.withIdempotency("ERR_INVOKE_PUT_ENTITY", a.onPutEntity))
 	// /eventstore/<name>/entities/<id>?version=1
 	// /eventstore/<name>/entities/<id>?startversion=1&endversion=5
+	// /eventstore/<name>/entities/<id>?asof=2020-03-01T00:00:00Z
+	// /eventstore/<name>/entities/<id>?from=2020-03-01T00:00:00Z&to=2020-04-01T00:00:00Z
 	r.Get("/eventstores/<name>/entities/<id>", a.onGetEntity)
+	// /eventstores/<name>/categories/<category>?from=1&max=100
+	r.Get("/eventstores/<name>/categories/<category>", a.onGetCategory)
+	r.Get("/eventstores/<name>/categories/<category>/subscription", a.onSubscribeCategory)
+	r.Get("/eventstores/<name>/projections/<projection>/<id>", a.onGetProjection)
+	r.Post("/eventstores/<name>/projections/<projection>/rebuild", a.onRebuildProjection)
+	r.Get("/eventstores/<name>/export", a.onExport)
+	r.Post("/eventstores/<name>/import", a.onImport)
+	r.Get("/eventstores/<name>/migration", a.onGetMigration)
+	r.Get("/eventstores/<name>/cache", a.onGetCache)
+	r.Get("/eventstores/<name>/chaos", a.onGetChaos)
+	r.Put("/eventstores/<name>/chaos", a.onPutChaos)
 	r.Post("/configurations/<name>", a.onPostConfiguration)
+	// /configurations/<name>?resourceVersion=42
+	r.Delete("/configurations/<name>", a.onDeleteConfiguration)
 }
 
 func (a *api) onPostEntity(c *routing.Context) error {
@@ -63,16 +114,30 @@ func (a *api) onPostEntity(c *routing.Context) error {
 	name := c.Param(eventstoreNameParam)
 	body := c.PostBody()
 
-	eventstore, ok := a.evtstores[name]
+	eventstore, release, ok := a.evtstores.Acquire(name)
 	if !ok {
-		msg := NewErrorResponse("ERR_INVOKE_POST_ENTITY", fmt.Sprintf("Evenstore %s not found", name))
-		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+		a.respondWithStoreNotFound(c.RequestCtx, "ERR_INVOKE_POST_ENTITY", name)
+		return nil
+	}
+
+	defer release()
+
+	eventstore, err := withDeadline(c, eventstore)
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_POST_ENTITY", err.Error())
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+		return nil
+	}
+
+	if streams.IsSystemID(id) {
+		msg := NewErrorResponse("ERR_INVOKE_POST_ENTITY", fmt.Sprintf("entity id %s is reserved", id))
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
 		return nil
 	}
 
 	ety := store.Entity{}
 
-	err := json.Unmarshal(body, &ety)
+	err = json.Unmarshal(body, &ety)
 	if err != nil {
 		msg := NewErrorResponse("ERR_INVOKE_POST_ENTITY", fmt.Sprintf("can't deserialize request: %s", err))
 		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
@@ -84,6 +149,10 @@ func (a *api) onPostEntity(c *routing.Context) error {
 
 	res, err := eventstore.Add(&ety)
 	if err != nil {
+		if respondWithUnavailable(c.RequestCtx, "ERR_INVOKE_POST_ENTITY", err) {
+			return nil
+		}
+
 		msg := NewErrorResponse("ERR_INVOKE_POST_ENTITY", fmt.Sprintf("can't append entity to eventstore: %s", err))
 		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
 		return nil
@@ -122,16 +191,30 @@ func (a *api) onPutEntity(c *routing.Context) error {
 		version = v
 	}
 
-	eventstore, ok := a.evtstores[name]
+	eventstore, release, ok := a.evtstores.Acquire(name)
 	if !ok {
-		msg := NewErrorResponse("ERR_INVOKE_PUT_ENTITY", fmt.Sprintf("Evenstore %s not found", name))
-		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+		a.respondWithStoreNotFound(c.RequestCtx, "ERR_INVOKE_PUT_ENTITY", name)
+		return nil
+	}
+
+	defer release()
+
+	eventstore, err := withDeadline(c, eventstore)
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_PUT_ENTITY", err.Error())
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+		return nil
+	}
+
+	if streams.IsSystemID(id) {
+		msg := NewErrorResponse("ERR_INVOKE_PUT_ENTITY", fmt.Sprintf("entity id %s is reserved", id))
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
 		return nil
 	}
 
 	ety := store.Entity{}
 
-	err := json.Unmarshal(body, &ety)
+	err = json.Unmarshal(body, &ety)
 	if err != nil {
 		msg := NewErrorResponse("ERR_INVOKE_PUT_ENTITY", fmt.Sprintf("can't deserialize request: %s", err))
 		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
@@ -143,6 +226,10 @@ func (a *api) onPutEntity(c *routing.Context) error {
 
 	res, err := eventstore.Append(&ety, concurrencyMode)
 	if err != nil {
+		if respondWithUnavailable(c.RequestCtx, "ERR_INVOKE_PUT_ENTITY", err) {
+			return nil
+		}
+
 		evterr, ok := err.(store.EventStoreError)
 
 		if !ok {
@@ -183,17 +270,37 @@ func (a *api) onGetEntity(c *routing.Context) error {
 	var version int64 = -1
 	var startversion int64 = -1
 	var endversion int64 = -1
+	// a read is a cache hit if no lookup reached the backend
+	hit := true
 
 	id := c.Param(entityIDParam)
 	name := c.Param(eventstoreNameParam)
 	vstr := c.QueryArgs().Peek(versionQueryParam)
 	startversionstr := c.QueryArgs().Peek(startVersionQueryParameter)
 	endversionstr := c.QueryArgs().Peek(endVersionQueryParameter)
+	asofstr := c.QueryArgs().Peek(asOfQueryParameter)
+	fromstr := c.QueryArgs().Peek(fromQueryParameter)
+	tostr := c.QueryArgs().Peek(toQueryParameter)
 
-	eventstore, ok := a.evtstores[name]
+	eventstore, release, ok := a.evtstores.Acquire(name)
 	if !ok {
-		msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("Evenstore %s not found", name))
-		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+		a.respondWithStoreNotFound(c.RequestCtx, "ERR_INVOKE_GET_ENTITY", name)
+		return nil
+	}
+
+	defer release()
+
+	eventstore, err := withDeadline(c, eventstore)
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", err.Error())
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+		return nil
+	}
+
+	// system streams hold e.g. the stored responses of idempotent requests
+	if streams.IsSystemID(id) {
+		msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("entity id %s is reserved", id))
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
 		return nil
 	}
 
@@ -224,21 +331,115 @@ func (a *api) onGetEntity(c *routing.Context) error {
 
 		startversion = start
 		endversion = end
+	} else if asofstr != nil {
+		asof, err := time.Parse(time.RFC3339, string(asofstr))
+		if err != nil {
+			msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("asof is not a valid RFC3339 timestamp: %s", err))
+			respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+			return nil
+		}
+
+		ts, ok := timestamps.From(eventstore)
+		if !ok {
+			msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("write timestamps are not enabled for Eventstore %s", name))
+			respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+			return nil
+		}
+
+		v, err := ts.VersionAt(id, asof)
+		if errors.Is(err, timestamps.ErrUnindexed) {
+			msg := NewErrorResponse("ERR_ENTITY_UNINDEXED", fmt.Sprintf("can't resolve version at %s: %s", asof.Format(time.RFC3339), err))
+			respondWithError(c.RequestCtx, fasthttp.StatusConflict, msg)
+			return nil
+		}
+
+		if err != nil {
+			msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("can't resolve version at %s: %s", asof.Format(time.RFC3339), err))
+			respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+			return nil
+		}
+
+		version = v
+	} else if fromstr != nil && tostr != nil {
+		from, err := time.Parse(time.RFC3339, string(fromstr))
+		if err != nil {
+			msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("from is not a valid RFC3339 timestamp: %s", err))
+			respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+			return nil
+		}
+
+		to, err := time.Parse(time.RFC3339, string(tostr))
+		if err != nil {
+			msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("to is not a valid RFC3339 timestamp: %s", err))
+			respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+			return nil
+		}
+
+		ts, ok := timestamps.From(eventstore)
+		if !ok {
+			msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("write timestamps are not enabled for Eventstore %s", name))
+			respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+			return nil
+		}
+
+		start, end, found, err := ts.VersionRange(id, from, to)
+		if errors.Is(err, timestamps.ErrUnindexed) {
+			msg := NewErrorResponse("ERR_ENTITY_UNINDEXED", fmt.Sprintf("can't resolve versions between %s and %s: %s", from.Format(time.RFC3339), to.Format(time.RFC3339), err))
+			respondWithError(c.RequestCtx, fasthttp.StatusConflict, msg)
+			return nil
+		}
+
+		if err != nil {
+			msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("can't resolve versions between %s and %s: %s", from.Format(time.RFC3339), to.Format(time.RFC3339), err))
+			respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+			return nil
+		}
+
+		etys := []store.Entity{}
+
+		if found {
+			// all versions in the range are known to exist
+			etys, err = streams.ReadRange(eventstore, id, start, end)
+			if err != nil {
+				msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("can't load entity versions: %s", err))
+				respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+				return nil
+			}
+		}
+
+		resdata, err := json.Marshal(etys)
+		if err != nil {
+			msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("can't serialize to respond: %s", err))
+			respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+			return nil
+		}
+
+		respondWithJSON(c.RequestCtx, fasthttp.StatusOK, resdata)
+		return nil
 	} else {
-		v, err := eventstore.GetLatestVersionNumber(id)
+		v, latestHit, err := latestVersion(eventstore, id)
 		if err != nil {
+			if respondWithUnavailable(c.RequestCtx, "ERR_INVOKE_GET_ENTITY", err) {
+				return nil
+			}
+
 			msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("can't get latest version: %s", err))
 			respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
 			return nil
 		}
 
 		version = v
+		hit = latestHit
 	}
 
 	if version != -1 {
-		ety, err := eventstore.GetByVersion(id, version)
+		ety, versionHit, err := entityVersion(eventstore, id, version)
 
 		if err != nil {
+			if respondWithUnavailable(c.RequestCtx, "ERR_INVOKE_GET_ENTITY", err) {
+				return nil
+			}
+
 			msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("can't load entity: %s", err))
 			respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
 			return nil
@@ -252,11 +453,16 @@ func (a *api) onGetEntity(c *routing.Context) error {
 		}
 
 		respondWithJSON(c.RequestCtx, fasthttp.StatusOK, resdata)
+		setCacheHeader(c.RequestCtx, eventstore, hit && versionHit)
 		return nil
 	}
 
 	etys, err := eventstore.GetByVersionRange(id, startversion, endversion)
 	if err != nil {
+		if respondWithUnavailable(c.RequestCtx, "ERR_INVOKE_GET_ENTITY", err) {
+			return nil
+		}
+
 		msg := NewErrorResponse("ERR_INVOKE_GET_ENTITY", fmt.Sprintf("can't load entity versions: %s", err))
 		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
 		return nil
@@ -273,13 +479,250 @@ func (a *api) onGetEntity(c *routing.Context) error {
 	return nil
 }
 
-func (a *api) onPostConfiguration(c *routing.Context) error {
+func (a *api) onGetCategory(c *routing.Context) error {
 	name := c.Param(eventstoreNameParam)
-	body := c.PostBody()
+	categoryName := c.Param(categoryParam)
+
+	eventstore, release, ok := a.evtstores.Acquire(name)
+	if !ok {
+		a.respondWithStoreNotFound(c.RequestCtx, "ERR_INVOKE_GET_CATEGORY", name)
+		return nil
+	}
+
+	defer release()
+
+	categories, ok := category.From(eventstore)
+	if !ok {
+		msg := NewErrorResponse("ERR_INVOKE_GET_CATEGORY", fmt.Sprintf("category streams are not enabled for Eventstore %s", name))
+		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+		return nil
+	}
+
+	from, err := parseInt64Query(c, fromQueryParameter, 1)
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_GET_CATEGORY", fmt.Sprintf("can't convert from to number: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+		return nil
+	}
+
+	max, err := parseInt64Query(c, maxQueryParameter, defaultCategoryPageSize)
+	if err != nil || max < 1 || max > maxCategoryPageSize {
+		msg := NewErrorResponse("ERR_INVOKE_GET_CATEGORY", fmt.Sprintf("max must be a number between 1 and %d", maxCategoryPageSize))
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+		return nil
+	}
+
+	events, err := categories.Read(categoryName, from, int(max))
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_GET_CATEGORY", fmt.Sprintf("can't read category: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+		return nil
+	}
+
+	resdata, err := json.Marshal(events)
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_GET_CATEGORY", fmt.Sprintf("can't serialize to respond: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+		return nil
+	}
+
+	respondWithJSON(c.RequestCtx, fasthttp.StatusOK, resdata)
+	return nil
+}
+
+func (a *api) onSubscribeCategory(c *routing.Context) error {
+	name := c.Param(eventstoreNameParam)
+	categoryName := c.Param(categoryParam)
+
+	eventstore, release, ok := a.evtstores.Acquire(name)
+	if !ok {
+		a.respondWithStoreNotFound(c.RequestCtx, "ERR_INVOKE_SUBSCRIBE_CATEGORY", name)
+		return nil
+	}
+
+	defer release()
+
+	categories, ok := category.From(eventstore)
+	if !ok {
+		msg := NewErrorResponse("ERR_INVOKE_SUBSCRIBE_CATEGORY", fmt.Sprintf("category streams are not enabled for Eventstore %s", name))
+		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+		return nil
+	}
+
+	from, err := parseInt64Query(c, fromQueryParameter, 1)
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_SUBSCRIBE_CATEGORY", fmt.Sprintf("can't convert from to number: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+		return nil
+	}
+
+	// reconnecting EventSource clients resume after the last event they received
+	if lastEventID := c.RequestCtx.Request.Header.Peek("Last-Event-ID"); len(lastEventID) > 0 {
+		last, err := strconv.ParseInt(string(lastEventID), 10, 64)
+		if err != nil {
+			msg := NewErrorResponse("ERR_INVOKE_SUBSCRIBE_CATEGORY", fmt.Sprintf("Last-Event-ID not a valid number: %s", err))
+			respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+			return nil
+		}
+
+		from = last + 1
+	}
+
+	respondWithEventStream(c.RequestCtx, newCategoryEventSource(categories, categoryName, from))
+	return nil
+}
+
+func (a *api) onGetProjection(c *routing.Context) error {
+	name := c.Param(eventstoreNameParam)
+	projectionName := c.Param(projectionParam)
+	id := c.Param(entityIDParam)
+
+	eventstore, release, ok := a.evtstores.Acquire(name)
+	if !ok {
+		a.respondWithStoreNotFound(c.RequestCtx, "ERR_INVOKE_GET_PROJECTION", name)
+		return nil
+	}
+
+	defer release()
+
+	projections, ok := projection.From(eventstore)
+	if !ok {
+		msg := NewErrorResponse("ERR_INVOKE_GET_PROJECTION", fmt.Sprintf("Eventstore %s has no projections", name))
+		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+		return nil
+	}
+
+	state, err := projections.Get(projectionName, id)
+	if err == projection.ErrProjectionNotFound {
+		msg := NewErrorResponse("ERR_INVOKE_GET_PROJECTION", fmt.Sprintf("projection %s not found", projectionName))
+		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+		return nil
+	} else if err == projection.ErrStateNotFound {
+		msg := NewErrorResponse("ERR_INVOKE_GET_PROJECTION", fmt.Sprintf("projection %s has no state for entity %s", projectionName, id))
+		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+		return nil
+	} else if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_GET_PROJECTION", fmt.Sprintf("can't read projection: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+		return nil
+	}
+
+	resdata, err := json.Marshal(state)
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_GET_PROJECTION", fmt.Sprintf("can't serialize to respond: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+		return nil
+	}
+
+	respondWithJSON(c.RequestCtx, fasthttp.StatusOK, resdata)
+	return nil
+}
+
+func (a *api) onRebuildProjection(c *routing.Context) error {
+	name := c.Param(eventstoreNameParam)
+	projectionName := c.Param(projectionParam)
+
+	eventstore, release, ok := a.evtstores.Acquire(name)
+	if !ok {
+		a.respondWithStoreNotFound(c.RequestCtx, "ERR_INVOKE_REBUILD_PROJECTION", name)
+		return nil
+	}
+
+	defer release()
+
+	projections, ok := projection.From(eventstore)
+	if !ok {
+		msg := NewErrorResponse("ERR_INVOKE_REBUILD_PROJECTION", fmt.Sprintf("Eventstore %s has no projections", name))
+		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+		return nil
+	}
+
+	err := projections.Rebuild(projectionName)
+	if err == projection.ErrProjectionNotFound {
+		msg := NewErrorResponse("ERR_INVOKE_REBUILD_PROJECTION", fmt.Sprintf("projection %s not found", projectionName))
+		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+		return nil
+	} else if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_REBUILD_PROJECTION", fmt.Sprintf("can't rebuild projection: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+		return nil
+	}
+
+	// the new generation folds the journal in the background
+	respondWithStatus(c.RequestCtx, fasthttp.StatusAccepted)
+	return nil
+}
+
+func (a *api) onGetMigration(c *routing.Context) error {
+	name := c.Param(eventstoreNameParam)
+
+	eventstore, release, ok := a.evtstores.Acquire(name)
+	if !ok {
+		a.respondWithStoreNotFound(c.RequestCtx, "ERR_INVOKE_GET_MIGRATION", name)
+		return nil
+	}
+
+	defer release()
+
+	m, ok := migration.From(eventstore)
+	if !ok {
+		msg := NewErrorResponse("ERR_INVOKE_GET_MIGRATION", fmt.Sprintf("Eventstore %s is not migrated", name))
+		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+		return nil
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_GET_MIGRATION", fmt.Sprintf("can't get migration status: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+		return nil
+	}
+
+	resdata, err := json.Marshal(status)
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_GET_MIGRATION", fmt.Sprintf("can't serialize to respond: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+		return nil
+	}
 
-	_, ok := a.evtstores[name]
+	respondWithJSON(c.RequestCtx, fasthttp.StatusOK, resdata)
+	return nil
+}
+
+func (a *api) onGetCache(c *routing.Context) error {
+	name := c.Param(eventstoreNameParam)
 
+	eventstore, release, ok := a.evtstores.Acquire(name)
 	if !ok {
+		a.respondWithStoreNotFound(c.RequestCtx, "ERR_INVOKE_GET_CACHE", name)
+		return nil
+	}
+
+	defer release()
+
+	cs, ok := cache.From(eventstore)
+	if !ok {
+		msg := NewErrorResponse("ERR_INVOKE_GET_CACHE", fmt.Sprintf("the cache is not enabled for Eventstore %s", name))
+		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+		return nil
+	}
+
+	resdata, err := json.Marshal(cs.Stats())
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_GET_CACHE", fmt.Sprintf("can't serialize to respond: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+		return nil
+	}
+
+	respondWithJSON(c.RequestCtx, fasthttp.StatusOK, resdata)
+	return nil
+}
+
+func (a *api) onPostConfiguration(c *routing.Context) error {
+	name := c.Param(eventstoreNameParam)
+	body := c.PostBody()
+
+	if !a.evtstores.Has(name) {
 		// not my configuration
 		respondWithStatus(c.RequestCtx, fasthttp.StatusOK)
 		return nil
@@ -294,15 +737,58 @@ func (a *api) onPostConfiguration(c *routing.Context) error {
 		return nil
 	}
 
-	s, err := a.registry.Create(cfg)
+	// the store is only recreated if its configuration changed
+	err = a.evtstores.Update(a.registry, cfg)
+	if err == registry.ErrOutdated {
+		log.Printf("api: ignoring configuration for Eventstore %s with outdated resourceVersion %s", cfg.Metadata.Name, cfg.Metadata.ResourceVersion)
+		respondWithStatus(c.RequestCtx, fasthttp.StatusConflict)
+		return nil
+	}
+
 	if err != nil {
 		log.Printf("api: failed to update store from configuration: %s", err)
 		respondWithStatus(c.RequestCtx, fasthttp.StatusInternalServerError)
 		return nil
 	}
 
-	a.evtstores[cfg.Metadata.Name] = s
 	log.Printf("api: configuration for Eventstore %s updated", cfg.Metadata.Name)
 	respondWithStatus(c.RequestCtx, fasthttp.StatusOK)
 	return nil
 }
+
+func (a *api) onDeleteConfiguration(c *routing.Context) error {
+	name := c.Param(eventstoreNameParam)
+	resourceVersion := string(c.QueryArgs().Peek("resourceVersion"))
+
+	err := a.evtstores.Delete(name, resourceVersion)
+	if err == registry.ErrOutdated {
+		log.Printf("api: ignoring deletion of Eventstore %s with outdated resourceVersion %s", name, resourceVersion)
+		respondWithStatus(c.RequestCtx, fasthttp.StatusConflict)
+		return nil
+	}
+
+	respondWithStatus(c.RequestCtx, fasthttp.StatusOK)
+	return nil
+}
+
+// respondWithStoreNotFound answers requests for an unknown Eventstore. Requests for a removed one
+// get their own error code, its configuration was deleted.
+func (a *api) respondWithStoreNotFound(ctx *fasthttp.RequestCtx, errorCode, name string) {
+	if a.evtstores.Removed(name) {
+		msg := NewErrorResponse("ERR_EVENTSTORE_REMOVED", fmt.Sprintf("Eventstore %s was removed", name))
+		respondWithError(ctx, fasthttp.StatusNotFound, msg)
+		return
+	}
+
+	msg := NewErrorResponse(errorCode, fmt.Sprintf("Evenstore %s not found", name))
+	respondWithError(ctx, fasthttp.StatusNotFound, msg)
+}
+
+func parseInt64Query(c *routing.Context, key string, defaultValue int64) (int64, error) {
+	value := c.QueryArgs().Peek(key)
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+
+	return strconv.ParseInt(string(value), 10, 64)
+}
@@ -0,0 +1,174 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/archive"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/cache"
+	registry "github.com/AndreasM009/eventstore/pkg/eventstored/eventstore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	routing "github.com/qiangxue/fasthttp-routing"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	archiveContentType    = "application/gzip"
+	dryRunQueryParameter  = "dryrun"
+	fromIDQueryParameter  = "fromid"
+	fromVersionQueryParam = "fromversion"
+	authorizationHeader   = "Authorization"
+	bearerPrefix          = "Bearer "
+)
+
+// onExport streams all entities of the store as archive, a failed export ends with a truncated archive
+func (a *api) onExport(c *routing.Context) error {
+	name := c.Param(eventstoreNameParam)
+
+	eventstore, release, ok := a.evtstores.Acquire(name)
+	if !ok {
+		a.respondWithStoreNotFound(c.RequestCtx, "ERR_INVOKE_EXPORT", name)
+		return nil
+	}
+
+	// the archive is written after the handler returned, the store is released when it's done
+	streaming := false
+	defer func() {
+		if !streaming {
+			release()
+		}
+	}()
+
+	s := registry.Storage(eventstore)
+	if !streams.CanList(s) {
+		msg := NewErrorResponse("ERR_INVOKE_EXPORT", fmt.Sprintf("export requires a backend that can enumerate its entities, the backend of Eventstore %s can't", name))
+		respondWithError(c.RequestCtx, fasthttp.StatusNotImplemented, msg)
+		return nil
+	}
+
+	version, err := parseInt64Query(c, fromVersionQueryParam, 1)
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_EXPORT", fmt.Sprintf("can't convert fromversion to number: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+		return nil
+	}
+
+	from := archive.Cursor{ID: string(c.QueryArgs().Peek(fromIDQueryParameter)), Version: version}
+
+	c.Response.Header.SetContentType(archiveContentType)
+	c.Response.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".ndjson.gz"))
+	c.SetStatusCode(fasthttp.StatusOK)
+
+	streaming = true
+	c.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer release()
+
+		aw := archive.NewWriter(w)
+
+		if _, err := archive.Export(aw, s, from); err != nil {
+			// without the end of the gzip stream, readers detect the archive as incomplete
+			log.Printf("api: export of %s failed: %s\n", name, err)
+			aw.Flush() // nolint: errcheck
+			return
+		}
+
+		if err := aw.Close(); err != nil {
+			log.Printf("api: export of %s failed: %s\n", name, err)
+		}
+	})
+
+	return nil
+}
+
+// onImport restores an archive below the decorators of the store, so timestamps, categories and
+// the journal are restored as archived instead of being derived from the imported versions
+func (a *api) onImport(c *routing.Context) error {
+	if !a.authorize(c.RequestCtx, "ERR_INVOKE_IMPORT") {
+		return nil
+	}
+
+	name := c.Param(eventstoreNameParam)
+
+	eventstore, release, ok := a.evtstores.Acquire(name)
+	if !ok {
+		a.respondWithStoreNotFound(c.RequestCtx, "ERR_INVOKE_IMPORT", name)
+		return nil
+	}
+
+	defer release()
+
+	dryRun := string(c.QueryArgs().Peek(dryRunQueryParameter)) == "true"
+
+	r, err := archive.NewReader(bytes.NewReader(c.PostBody()))
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_IMPORT", err.Error())
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+		return nil
+	}
+
+	target := registry.Storage(eventstore)
+	if cs, ok := cache.From(eventstore); ok {
+		target = &invalidating{EventStore: target, cache: cs}
+	}
+
+	summary, err := archive.Import(r, target, dryRun)
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_IMPORT", fmt.Sprintf("import stopped after %d imported and %d skipped versions: %s", summary.Imported, summary.Skipped, err))
+		respondWithError(c.RequestCtx, fasthttp.StatusUnprocessableEntity, msg)
+		return nil
+	}
+
+	resdata, err := json.Marshal(summary)
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_IMPORT", fmt.Sprintf("can't serialize to respond: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+		return nil
+	}
+
+	respondWithJSON(c.RequestCtx, fasthttp.StatusOK, resdata)
+	return nil
+}
+
+// authorize checks the bearer token of a request against the API token, requests are refused
+// if eventstored has no API token
+func (a *api) authorize(ctx *fasthttp.RequestCtx, errorCode string) bool {
+	if a.token == "" {
+		msg := NewErrorResponse(errorCode, "the request requires the API token of eventstored, but none is configured")
+		respondWithError(ctx, fasthttp.StatusForbidden, msg)
+		return false
+	}
+
+	header := ctx.Request.Header.Peek(authorizationHeader)
+	token := bytes.TrimPrefix(header, []byte(bearerPrefix))
+
+	if len(token) == len(header) || subtle.ConstantTimeCompare(token, []byte(a.token)) != 1 {
+		msg := NewErrorResponse(errorCode, "the request requires the API token of eventstored as bearer token")
+		respondWithError(ctx, fasthttp.StatusUnauthorized, msg)
+		ctx.Response.Header.Set("WWW-Authenticate", "Bearer")
+		return false
+	}
+
+	return true
+}
+
+// invalidating is an EventStore that invalidates the latest version numbers of the cache above
+// it for all writes
+type invalidating struct {
+	store.EventStore
+	cache *cache.Store
+}
+
+func (s *invalidating) Add(entity *store.Entity) (*store.Entity, error) {
+	defer s.cache.Invalidate(entity.ID)
+	return s.EventStore.Add(entity)
+}
+
+func (s *invalidating) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	defer s.cache.Invalidate(entity.ID)
+	return s.EventStore.Append(entity, concurrency)
+}
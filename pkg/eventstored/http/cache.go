@@ -0,0 +1,47 @@
+package http
+
+import (
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/cache"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// cacheHeader tells if a read was served from the cache, it's only set if the cache is enabled
+	cacheHeader = "Eventstore-Cache"
+	cacheHit    = "hit"
+	cacheMiss   = "miss"
+)
+
+// latestVersion returns the latest version of entity id and whether it was served from the cache
+func latestVersion(s store.EventStore, id string) (int64, bool, error) {
+	hit := isCached(s, id, 0)
+
+	v, err := s.GetLatestVersionNumber(id)
+	return v, hit && err == nil, err
+}
+
+// entityVersion returns a version of entity id and whether it was served from the cache
+func entityVersion(s store.EventStore, id string, version int64) (*store.Entity, bool, error) {
+	hit := isCached(s, id, version)
+
+	ety, err := s.GetByVersion(id, version)
+	return ety, hit && err == nil, err
+}
+
+func isCached(s store.EventStore, id string, version int64) bool {
+	cs, ok := cache.From(s)
+	return ok && cs.Cached(id, version)
+}
+
+func setCacheHeader(ctx *fasthttp.RequestCtx, s store.EventStore, hit bool) {
+	if _, ok := cache.From(s); !ok {
+		return
+	}
+
+	if hit {
+		ctx.Response.Header.Set(cacheHeader, cacheHit)
+	} else {
+		ctx.Response.Header.Set(cacheHeader, cacheMiss)
+	}
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/AndreasM009/eventstore/pkg/eventstored/chaos"
+	routing "github.com/qiangxue/fasthttp-routing"
+	"github.com/valyala/fasthttp"
+)
+
+// onGetChaos responds with the fault profile of a chaos store
+func (a *api) onGetChaos(c *routing.Context) error {
+	name := c.Param(eventstoreNameParam)
+
+	cs, ok := a.chaosStore(c, "ERR_INVOKE_GET_CHAOS", name)
+	if !ok {
+		return nil
+	}
+
+	resdata, err := json.Marshal(cs.Profile())
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_GET_CHAOS", fmt.Sprintf("can't serialize to respond: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+		return nil
+	}
+
+	respondWithJSON(c.RequestCtx, fasthttp.StatusOK, resdata)
+	return nil
+}
+
+// onPutChaos replaces the fault profile of a chaos store, it's kept until the store is reconfigured
+func (a *api) onPutChaos(c *routing.Context) error {
+	name := c.Param(eventstoreNameParam)
+
+	cs, ok := a.chaosStore(c, "ERR_INVOKE_PUT_CHAOS", name)
+	if !ok {
+		return nil
+	}
+
+	profile := chaos.Profile{}
+	if err := json.Unmarshal(c.PostBody(), &profile); err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_PUT_CHAOS", fmt.Sprintf("can't deserialize request: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+		return nil
+	}
+
+	if err := cs.SetProfile(profile); err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_PUT_CHAOS", err.Error())
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+		return nil
+	}
+
+	log.Printf("api: fault profile of Eventstore %s updated\n", name)
+
+	resdata, err := json.Marshal(profile)
+	if err != nil {
+		msg := NewErrorResponse("ERR_INVOKE_PUT_CHAOS", fmt.Sprintf("can't serialize to respond: %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+		return nil
+	}
+
+	respondWithJSON(c.RequestCtx, fasthttp.StatusOK, resdata)
+	return nil
+}
+
+func (a *api) chaosStore(c *routing.Context, errorCode, name string) (*chaos.Store, bool) {
+	eventstore, ok := a.evtstores.Get(name)
+	if !ok {
+		a.respondWithStoreNotFound(c.RequestCtx, errorCode, name)
+		return nil, false
+	}
+
+	cs, ok := chaos.From(eventstore)
+	if !ok {
+		msg := NewErrorResponse(errorCode, fmt.Sprintf("Eventstore %s is not of type eventstore.chaos", name))
+		respondWithError(c.RequestCtx, fasthttp.StatusNotFound, msg)
+		return nil, false
+	}
+
+	return cs, true
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/AndreasM009/eventstore/pkg/eventstored/category"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	eventStreamContentType  = "text/event-stream"
+	eventStreamPollInterval = time.Second
+	eventStreamKeepAlive    = 15 * time.Second
+	eventStreamPageSize     = 100
+)
+
+// streamEvent is a single server-sent event
+type streamEvent struct {
+	id   int64
+	data []byte
+}
+
+// eventSource is read by an event stream until the client disconnects
+type eventSource interface {
+	// next returns the events following the ones already returned
+	next() ([]streamEvent, error)
+	// notify is signaled when new events might be available, sources are polled anyway
+	notify() <-chan struct{}
+	close()
+}
+
+// respondWithEventStream streams the events of source as server-sent events
+func respondWithEventStream(ctx *fasthttp.RequestCtx, source eventSource) {
+	ctx.Response.Header.SetContentType(eventStreamContentType)
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer source.close()
+		lastWrite := time.Now()
+
+		for {
+			events, err := source.next()
+			if err != nil {
+				log.Printf("api: event stream failed: %s\n", err)
+				fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+				w.Flush() // nolint: errcheck
+				return
+			}
+
+			for _, e := range events {
+				fmt.Fprintf(w, "id: %d\ndata: %s\n\n", e.id, e.data)
+			}
+
+			if len(events) == 0 && time.Since(lastWrite) >= eventStreamKeepAlive {
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+
+			if w.Buffered() > 0 {
+				// a failed flush means the client has gone away
+				if err := w.Flush(); err != nil {
+					return
+				}
+
+				lastWrite = time.Now()
+			}
+
+			if len(events) >= eventStreamPageSize {
+				continue
+			}
+
+			select {
+			case <-source.notify():
+			case <-time.After(eventStreamPollInterval):
+			}
+		}
+	})
+}
+
+type categoryEventSource struct {
+	categories *category.Store
+	category   string
+	position   int64
+	signal     <-chan struct{}
+	cancel     func()
+}
+
+func newCategoryEventSource(categories *category.Store, categoryName string, from int64) eventSource {
+	signal, cancel := categories.Subscribe()
+
+	return &categoryEventSource{
+		categories: categories,
+		category:   categoryName,
+		position:   from,
+		signal:     signal,
+		cancel:     cancel,
+	}
+}
+
+func (s *categoryEventSource) next() ([]streamEvent, error) {
+	events, err := s.categories.Read(s.category, s.position, eventStreamPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]streamEvent, len(events))
+
+	for i, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+
+		result[i] = streamEvent{id: e.Position, data: data}
+		s.position = e.Position + 1
+	}
+
+	return result, nil
+}
+
+func (s *categoryEventSource) notify() <-chan struct{} {
+	return s.signal
+}
+
+func (s *categoryEventSource) close() {
+	s.cancel()
+}
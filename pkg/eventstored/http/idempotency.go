@@ -0,0 +1,121 @@
+package http
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/AndreasM009/eventstore/pkg/eventstored/idempotency"
+	routing "github.com/qiangxue/fasthttp-routing"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyKeyUnsupported = "idempotency keys are not enabled for Eventstore %s"
+)
+
+// withIdempotency replays the remembered response of a write request, if the request carries an
+// idempotency key that was already used successfully. Otherwise the key is claimed, next is invoked
+// and its response is remembered if it succeeded. Requests with a key that is claimed by a request
+// in progress on another replica are rejected with a conflict.
+func (a *api) withIdempotency(errorCode string, next routing.Handler) routing.Handler {
+	return func(c *routing.Context) error {
+		key := string(c.RequestCtx.Request.Header.Peek(idempotencyKeyHeader))
+		if key == "" {
+			return next(c)
+		}
+
+		name := c.Param(eventstoreNameParam)
+
+		eventstore, releaseStore, ok := a.evtstores.Acquire(name)
+		if !ok {
+			// let next respond with not found
+			return next(c)
+		}
+
+		defer releaseStore()
+
+		if len(key) > maxIdempotencyKeyLength {
+			msg := NewErrorResponse(errorCode, fmt.Sprintf("%s must not be longer than %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength))
+			respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+			return nil
+		}
+
+		idem, ok := idempotency.From(eventstore)
+		if !ok {
+			msg := NewErrorResponse(errorCode, fmt.Sprintf(idempotencyKeyUnsupported, name))
+			respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+			return nil
+		}
+
+		request := idempotency.Request{
+			Method: string(c.Method()),
+			ID:     c.Param(entityIDParam),
+		}
+
+		unlock := idem.Lock(key)
+		defer unlock()
+
+		claim, result, err := idem.Claim(key, request)
+		if err == idempotency.ErrInProgress {
+			msg := NewErrorResponse(errorCode, fmt.Sprintf("a request with %s %s is in progress", idempotencyKeyHeader, key))
+			respondWithError(c.RequestCtx, fasthttp.StatusConflict, msg)
+			return nil
+		}
+
+		if err != nil {
+			msg := NewErrorResponse(errorCode, fmt.Sprintf("can't claim %s: %s", idempotencyKeyHeader, err))
+			respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+			return nil
+		}
+
+		if result != nil {
+			if result.Request != request {
+				msg := NewErrorResponse(errorCode, fmt.Sprintf("%s was already used for %s of entity %s", idempotencyKeyHeader, result.Request.Method, result.Request.ID))
+				respondWithError(c.RequestCtx, fasthttp.StatusUnprocessableEntity, msg)
+				return nil
+			}
+
+			respondWithJSON(c.RequestCtx, result.StatusCode, result.Body)
+			if result.Location != "" {
+				c.RequestCtx.Response.Header.Add("Location", result.Location)
+			}
+			c.RequestCtx.Response.Header.Set(idempotentReplayedHeader, "true")
+			return nil
+		}
+
+		if err := next(c); err != nil {
+			release(idem, claim, key)
+			return err
+		}
+
+		status := c.RequestCtx.Response.StatusCode()
+		if status < fasthttp.StatusOK || status >= fasthttp.StatusMultipleChoices {
+			// failed requests may be retried with the same key
+			release(idem, claim, key)
+			return nil
+		}
+
+		body := append([]byte(nil), c.RequestCtx.Response.Body()...)
+
+		err = idem.Complete(claim, idempotency.Result{
+			StatusCode: status,
+			Location:   string(c.RequestCtx.Response.Header.Peek("Location")),
+			Body:       body,
+		})
+		if err != nil {
+			log.Printf("api: failed to remember result of %s %s: %s\n", idempotencyKeyHeader, key, err)
+		}
+
+		return nil
+	}
+}
+
+// release releases the claim of a request that failed, a claim that can't be released expires
+func release(idem *idempotency.Store, claim *idempotency.Claim, key string) {
+	if err := idem.Release(claim); err != nil {
+		log.Printf("api: failed to release %s %s: %s\n", idempotencyKeyHeader, key, err)
+	}
+}
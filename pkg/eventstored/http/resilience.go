@@ -0,0 +1,58 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/resilience"
+	routing "github.com/qiangxue/fasthttp-routing"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// timeoutHeader is the time a client waits for a response, e.g. '2s'
+	timeoutHeader    = "Eventstore-Timeout"
+	retryAfterHeader = "Retry-After"
+)
+
+// withDeadline bounds all calls to s, including the retries of the backend calls, by the timeout
+// the client sent with the request
+func withDeadline(c *routing.Context, s store.EventStore) (store.EventStore, error) {
+	value := c.RequestCtx.Request.Header.Peek(timeoutHeader)
+	if len(value) == 0 {
+		return s, nil
+	}
+
+	timeout, err := time.ParseDuration(string(value))
+	if err != nil || timeout <= 0 {
+		return s, fmt.Errorf("%s is not a positive duration like '2s'", timeoutHeader)
+	}
+
+	return resilience.WithDeadline(s, time.Now().Add(timeout)), nil
+}
+
+// respondWithUnavailable responds to timeouts and open circuit breakers of the backend, it
+// returns false for all other errors
+func respondWithUnavailable(ctx *fasthttp.RequestCtx, errorCode string, err error) bool {
+	var openErr *resilience.OpenError
+	if errors.As(err, &openErr) {
+		msg := NewErrorResponse(errorCode, fmt.Sprintf("backend unavailable: %s", err))
+		respondWithError(ctx, fasthttp.StatusServiceUnavailable, msg)
+		seconds := int(math.Ceil(openErr.RetryAfter.Seconds()))
+		ctx.Response.Header.Set(retryAfterHeader, strconv.Itoa(seconds))
+		return true
+	}
+
+	if errors.Is(err, resilience.ErrTimeout) {
+		// writes that timed out aren't retried, they may still be applied
+		msg := NewErrorResponse(errorCode, fmt.Sprintf("backend unavailable, a write may still be applied: %s", err))
+		respondWithError(ctx, fasthttp.StatusGatewayTimeout, msg)
+		return true
+	}
+
+	return false
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	cors "github.com/AdhityaRamadhanus/fasthttpcors"
-	"github.com/AndreasM009/eventstore-impl/store"
 	registry "github.com/AndreasM009/eventstore/pkg/eventstored/eventstore"
 	routing "github.com/qiangxue/fasthttp-routing"
 	"github.com/valyala/fasthttp"
@@ -15,20 +14,31 @@ type Server interface {
 	StartNonBlocking()
 }
 
+// Options configure the API Server
+type Options struct {
+	// MaxRequestBodySize is the maximum size of a request body in bytes, it bounds the size of
+	// an imported archive batch
+	MaxRequestBodySize int
+	// APIToken authorizes imports, imports are refused if it's empty
+	APIToken string
+}
+
 type server struct {
 	api      APIRoutes
 	port     int
-	evtstore map[string]store.EventStore
+	options  Options
+	evtstore *registry.Stores
 	router   *routing.Router
 	registry registry.Registry
 }
 
 // NewServer creates a new API Server
-func NewServer(port int, eventStores map[string]store.EventStore, registry registry.Registry) Server {
+func NewServer(port int, eventStores *registry.Stores, registry registry.Registry, options Options) Server {
 	return &server{
 		port:     port,
+		options:  options,
 		evtstore: eventStores,
-		api:      NewAPI(eventStores, registry),
+		api:      NewAPI(eventStores, registry, options.APIToken),
 		registry: registry,
 	}
 }
@@ -37,8 +47,13 @@ func (s *server) StartNonBlocking() {
 	handler := s.useCors(
 		s.useRouter())
 
+	srv := &fasthttp.Server{
+		Handler:            handler,
+		MaxRequestBodySize: s.options.MaxRequestBodySize,
+	}
+
 	go func() {
-		err := fasthttp.ListenAndServe(fmt.Sprintf(":%v", s.port), handler)
+		err := srv.ListenAndServe(fmt.Sprintf(":%v", s.port))
 		if err != nil {
 			fmt.Println(err)
 		}
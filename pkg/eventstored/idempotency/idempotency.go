@@ -0,0 +1,352 @@
+package idempotency
+
+//---------------------------------------------------------------------------------------------
+// Idempotency keys are supplied by clients to make retried writes safe. A request with a key
+// first claims the system stream '$idem-<hash of key>' by appending to it with optimistic
+// concurrency, so only one request per key is executed, even across replicas of the sidecar.
+// The result of a successful request is appended to the stream and replayed for every request
+// with the same key within the configured window. Claims of requests that never finished expire
+// after claimTimeout.
+//
+// Expired streams are deleted periodically if the backend can enumerate and delete its entities,
+// like eventstore.inmemory. The Azure backends can't, there expired results are ignored but kept.
+// A stream is deleted after reading its latest entry, so a replica that claims the key in between
+// recreates the stream with its result.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+)
+
+const (
+	// WindowKey is the store metadata key that enables idempotency keys, the value is the
+	// duration results are remembered, e.g. '24h'
+	WindowKey = "idempotencyWindow"
+
+	streamPrefix     = streams.SystemPrefix + "idem-"
+	claimTimeout     = time.Minute
+	maxSweepInterval = time.Hour
+)
+
+// states of the entries of a stream, results remembered before claims existed have no state
+const (
+	stateCompleted = ""
+	statePending   = "pending"
+	stateReleased  = "released"
+)
+
+// ErrInProgress is returned by Claim if another request with the key is in progress
+var ErrInProgress = errors.New("idempotency: a request with the key is in progress")
+
+// Request identifies the request an idempotency key was used with
+type Request struct {
+	Method string `json:"method"`
+	ID     string `json:"id"`
+}
+
+// Result is the remembered response of a request
+type Result struct {
+	Request    Request         `json:"request"`
+	StatusCode int             `json:"statusCode"`
+	Location   string          `json:"location,omitempty"`
+	Body       json.RawMessage `json:"body"`
+	Timestamp  time.Time       `json:"timestamp"`
+}
+
+// entry is an entry of the stream of a key
+type entry struct {
+	Result
+	State string `json:"state,omitempty"`
+}
+
+// Claim is held by the request that is executed for a key
+type Claim struct {
+	stream  string
+	version int64
+	request Request
+}
+
+type keyLock struct {
+	mutex sync.Mutex
+	refs  int
+}
+
+// Store is an EventStore that remembers the results of requests by idempotency key
+type Store struct {
+	store.EventStore
+	window time.Duration
+	now    func() time.Time
+	mutex  sync.Mutex
+	locks  map[string]*keyLock
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewStore creates a new idempotency Store wrapping s, results are remembered for window. Expired
+// results are deleted in the background until the Store is closed.
+func NewStore(s store.EventStore, window time.Duration) *Store {
+	is := &Store{
+		EventStore: s,
+		window:     window,
+		now:        time.Now,
+		locks:      map[string]*keyLock{},
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	go is.runSweeps()
+	return is
+}
+
+// From returns the idempotency Store in the chain of decorators of s
+func From(s store.EventStore) (*Store, bool) {
+	is, ok := wrapper.Find(s, func(s store.EventStore) bool {
+		_, ok := s.(*Store)
+		return ok
+	}).(*Store)
+
+	return is, ok
+}
+
+// Unwrap returns the decorated EventStore
+func (s *Store) Unwrap() store.EventStore {
+	return s.EventStore
+}
+
+// Close stops deleting expired results
+func (s *Store) Close() error {
+	close(s.stop)
+	<-s.done
+	return nil
+}
+
+// Lock serializes requests with the same key within this sidecar, so a retry that arrives while
+// the original request is still processed waits for its result instead of failing with
+// ErrInProgress. The returned function unlocks the key.
+func (s *Store) Lock(key string) func() {
+	return s.lockStream(streamID(key))
+}
+
+// Claim claims key for request. If a result was remembered for key within the window, it's
+// returned instead of a claim. ErrInProgress is returned if another request holds the key.
+func (s *Store) Claim(key string, request Request) (*Claim, *Result, error) {
+	stream := streamID(key)
+
+	latest, err := streams.LatestVersion(s.EventStore, stream)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if latest != 0 {
+		e, err := s.read(stream, latest)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch {
+		case e.State == stateCompleted && !s.expired(e):
+			return nil, &e.Result, nil
+		case e.State == statePending && !s.expired(e):
+			return nil, nil, ErrInProgress
+		}
+	}
+
+	pending := entry{
+		Result: Result{Request: request, Timestamp: s.now().UTC()},
+		State:  statePending,
+	}
+
+	ety, err := s.write(stream, latest, pending)
+	if err == nil {
+		return &Claim{stream: stream, version: ety.Version, request: request}, nil, nil
+	}
+
+	// the key was claimed by someone else in the meantime, if the stream exists now
+	if streams.IsVersionConflict(err) {
+		return nil, nil, ErrInProgress
+	}
+
+	if current, lerr := streams.LatestVersion(s.EventStore, stream); lerr == nil && current != latest {
+		return nil, nil, ErrInProgress
+	}
+
+	return nil, nil, err
+}
+
+// Complete remembers the result of the request that holds claim
+func (s *Store) Complete(claim *Claim, result Result) error {
+	result.Request = claim.request
+	result.Timestamp = s.now().UTC()
+
+	_, err := s.write(claim.stream, claim.version, entry{Result: result, State: stateCompleted})
+	if streams.IsNotFound(err) {
+		// deleted by the sweep of another replica after the claim
+		_, err = s.write(claim.stream, 0, entry{Result: result, State: stateCompleted})
+	}
+
+	return err
+}
+
+// Release releases claim without a result, so the key can be used again
+func (s *Store) Release(claim *Claim) error {
+	released := entry{
+		Result: Result{Request: claim.request, Timestamp: s.now().UTC()},
+		State:  stateReleased,
+	}
+
+	_, err := s.write(claim.stream, claim.version, released)
+	if streams.IsVersionConflict(err) || streams.IsNotFound(err) {
+		// the claim expired and the key was used again or deleted
+		return nil
+	}
+
+	return err
+}
+
+// Sweep deletes the streams of expired keys. False is returned if the backend can't enumerate or
+// delete entities.
+func (s *Store) Sweep() (bool, error) {
+	deletable := true
+
+	ok, err := streams.ListIDs(s.EventStore, streamPrefix, func(stream string) error {
+		unlock := s.lockStream(stream)
+		defer unlock()
+
+		latest, err := streams.LatestVersion(s.EventStore, stream)
+		if err != nil || latest == 0 {
+			return err
+		}
+
+		e, err := s.read(stream, latest)
+		if err != nil {
+			return err
+		}
+
+		if !s.expired(e) {
+			return nil
+		}
+
+		deletable, err = streams.Delete(s.EventStore, stream)
+		if !deletable {
+			return errors.New("idempotency: the backend can't delete entities")
+		}
+
+		return err
+	})
+
+	if !ok || !deletable {
+		return false, nil
+	}
+
+	return true, err
+}
+
+// expired checks if the entry no longer holds the key
+func (s *Store) expired(e entry) bool {
+	age := s.now().Sub(e.Timestamp)
+
+	switch e.State {
+	case stateCompleted:
+		return age > s.window
+	case statePending:
+		return age > claimTimeout
+	default:
+		return true
+	}
+}
+
+func (s *Store) runSweeps() {
+	defer close(s.done)
+
+	interval := s.window
+	if interval > maxSweepInterval {
+		interval = maxSweepInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		supported, err := s.Sweep()
+		if !supported {
+			log.Println("idempotency: the backend can't enumerate and delete entities, expired results are kept")
+			<-s.stop
+			return
+		}
+
+		if err != nil {
+			log.Printf("idempotency: failed to delete expired results: %s\n", err)
+		}
+	}
+}
+
+func (s *Store) lockStream(stream string) func() {
+	s.mutex.Lock()
+	l, ok := s.locks[stream]
+	if !ok {
+		l = &keyLock{}
+		s.locks[stream] = l
+	}
+	l.refs++
+	s.mutex.Unlock()
+
+	l.mutex.Lock()
+
+	return func() {
+		l.mutex.Unlock()
+
+		s.mutex.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(s.locks, stream)
+		}
+		s.mutex.Unlock()
+	}
+}
+
+func (s *Store) read(stream string, version int64) (entry, error) {
+	e := entry{}
+
+	ety, err := s.EventStore.GetByVersion(stream, version)
+	if err != nil {
+		return e, err
+	}
+
+	if err := streams.Decode(ety, &e); err != nil {
+		return e, fmt.Errorf("idempotency: can't decode entry %v of %s: %s", version, stream, err)
+	}
+
+	return e, nil
+}
+
+// write writes e as the version after latest of stream, it fails if another version was written
+func (s *Store) write(stream string, latest int64, e entry) (*store.Entity, error) {
+	if latest == 0 {
+		return s.EventStore.Add(&store.Entity{ID: stream, Data: e})
+	}
+
+	return s.EventStore.Append(&store.Entity{ID: stream, Version: latest, Data: e}, store.Optimistic)
+}
+
+// keys are hashed, as they may contain characters that aren't allowed in entity ids of all backends
+func streamID(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return streamPrefix + hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,183 @@
+package idempotency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore-impl/store/inmemory"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/stretchr/testify/assert"
+)
+
+var putOrder = Request{Method: "PUT", ID: "order-1"}
+
+func newTestStore(t *testing.T, s store.EventStore) (*Store, *time.Time) {
+	is := NewStore(s, time.Hour)
+	t.Cleanup(func() { is.Close() })
+
+	now := time.Now()
+	is.now = func() time.Time {
+		return now
+	}
+
+	return is, &now
+}
+
+func TestClaimAndComplete(t *testing.T) {
+	s, _ := newTestStore(t, fakestore.NewStore())
+
+	claim, result, err := s.Claim("key-1", putOrder)
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+	assert.NotNil(t, claim)
+
+	err = s.Complete(claim, Result{
+		StatusCode: 200,
+		Body:       []byte(`{"id":"order-1","version":2}`),
+	})
+	assert.Nil(t, err)
+
+	claim, result, err = s.Claim("key-1", putOrder)
+	assert.Nil(t, err)
+	assert.Nil(t, claim)
+	assert.Equal(t, putOrder, result.Request)
+	assert.Equal(t, 200, result.StatusCode)
+	assert.JSONEq(t, `{"id":"order-1","version":2}`, string(result.Body))
+
+	claim, result, err = s.Claim("key-2", putOrder)
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+	assert.NotNil(t, claim)
+}
+
+func TestClaimOfKeyInProgress(t *testing.T) {
+	backend := fakestore.NewStore()
+	s, now := newTestStore(t, backend)
+
+	// another replica shares the backend
+	other, _ := newTestStore(t, backend)
+	other.now = s.now
+
+	claim, _, err := s.Claim("key-1", putOrder)
+	assert.Nil(t, err)
+
+	_, _, err = other.Claim("key-1", putOrder)
+	assert.Equal(t, ErrInProgress, err)
+
+	// a released claim can be taken
+	assert.Nil(t, s.Release(claim))
+
+	claim, _, err = other.Claim("key-1", putOrder)
+	assert.Nil(t, err)
+	assert.NotNil(t, claim)
+
+	// the claim of a crashed request expires
+	*now = now.Add(2 * claimTimeout)
+
+	claim, _, err = s.Claim("key-1", putOrder)
+	assert.Nil(t, err)
+	assert.NotNil(t, claim)
+}
+
+func TestExpiredResult(t *testing.T) {
+	s, now := newTestStore(t, fakestore.NewStore())
+
+	claim, _, err := s.Claim("key-1", putOrder)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Complete(claim, Result{StatusCode: 201, Body: []byte(`{}`)}))
+
+	*now = now.Add(2 * time.Hour)
+
+	// an expired key can be used again
+	claim, result, err := s.Claim("key-1", putOrder)
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+	assert.Nil(t, s.Complete(claim, Result{StatusCode: 200, Body: []byte(`{}`)}))
+
+	_, result, err = s.Claim("key-1", putOrder)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, result.StatusCode)
+}
+
+func TestSweepDeletesExpiredKeys(t *testing.T) {
+	backend := fakestore.NewStore()
+	s, now := newTestStore(t, backend)
+
+	claim, _, err := s.Claim("old", putOrder)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Complete(claim, Result{StatusCode: 200, Body: []byte(`{}`)}))
+
+	*now = now.Add(50 * time.Minute)
+
+	claim, _, err = s.Claim("new", putOrder)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Complete(claim, Result{StatusCode: 200, Body: []byte(`{}`)}))
+
+	*now = now.Add(20 * time.Minute)
+
+	supported, err := s.Sweep()
+	assert.True(t, supported)
+	assert.Nil(t, err)
+
+	v, _ := streams.LatestVersion(backend, streamID("old"))
+	assert.Equal(t, int64(0), v)
+
+	v, _ = streams.LatestVersion(backend, streamID("new"))
+	assert.Equal(t, int64(2), v)
+}
+
+func TestCompleteAfterSweepOfAnotherReplica(t *testing.T) {
+	backend := fakestore.NewStore()
+	s, _ := newTestStore(t, backend)
+
+	claim, _, err := s.Claim("key-1", putOrder)
+	assert.Nil(t, err)
+
+	assert.Nil(t, backend.Delete(streamID("key-1")))
+
+	assert.Nil(t, s.Complete(claim, Result{StatusCode: 200, Body: []byte(`{}`)}))
+
+	_, result, err := s.Claim("key-1", putOrder)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, result.StatusCode)
+}
+
+func TestSweepWithoutBackendSupport(t *testing.T) {
+	backend := inmemory.NewStore()
+	assert.Nil(t, backend.Init(store.Metadata{}))
+	s, _ := newTestStore(t, backend)
+
+	supported, err := s.Sweep()
+	assert.False(t, supported)
+	assert.Nil(t, err)
+}
+
+func TestLockSerializesKey(t *testing.T) {
+	s, _ := newTestStore(t, fakestore.NewStore())
+
+	unlock := s.Lock("key-1")
+	locked := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		unlock := s.Lock("key-1")
+		close(locked)
+		unlock()
+		close(done)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("key was locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	<-done
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	assert.Equal(t, 0, len(s.locks))
+}
@@ -0,0 +1,202 @@
+package journal
+
+import (
+	"log"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+)
+
+const (
+	consumerBatchSize    = 100
+	consumerPollInterval = time.Second
+)
+
+// Handler processes a journaled entity version
+type Handler func(record Record, entity *store.Entity) error
+
+// Backoff configures the delays between retries of a failed record, the delay doubles with
+// each attempt, starting with Min, until Max is reached.
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// DefaultBackoff is the Backoff used by consumers unless configured otherwise
+var DefaultBackoff = Backoff{
+	Min: 100 * time.Millisecond,
+	Max: 30 * time.Second,
+}
+
+// Delay returns the delay before the given retry, it doubles from Min up to Max
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Min
+	for i := 0; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+
+	if d > b.Max {
+		return b.Max
+	}
+
+	return d
+}
+
+// Consumer follows the journal from its checkpoint and invokes a Handler for each record.
+// A failed record is retried until it succeeds, so records are handled in order and at least once.
+// With a limit of attempts, the consumer records a dead letter for the record instead and moves on.
+type Consumer struct {
+	journal     *Store
+	name        string
+	backoff     Backoff
+	handle      Handler
+	maxAttempts int
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// NewConsumer creates a new Consumer, the name identifies its checkpoint
+func NewConsumer(journal *Store, name string, backoff Backoff, handle Handler) *Consumer {
+	return &Consumer{
+		journal: journal,
+		name:    name,
+		backoff: backoff,
+		handle:  handle,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// WithMaxAttempts limits the attempts to handle a record, a record that still fails is recorded
+// as dead letter of the consumer. Must be called before Start.
+func (c *Consumer) WithMaxAttempts(maxAttempts int) *Consumer {
+	c.maxAttempts = maxAttempts
+	return c
+}
+
+// Start starts following the journal in the background
+func (c *Consumer) Start() {
+	go c.run()
+}
+
+// Close stops the consumer and waits until the record in progress was handled
+func (c *Consumer) Close() error {
+	close(c.stop)
+	<-c.done
+	return nil
+}
+
+func (c *Consumer) run() {
+	defer close(c.done)
+
+	signal, cancel := c.journal.Subscribe()
+	defer cancel()
+
+	var position, saved int64
+
+	for attempt := 0; ; attempt++ {
+		p, err := c.journal.LoadCheckpoint(c.name)
+		if err == nil {
+			position, saved = p, p
+			break
+		}
+
+		log.Printf("journal: consumer %s can't load checkpoint: %s\n", c.name, err)
+		if !c.wait(c.backoff.Delay(attempt)) {
+			return
+		}
+	}
+
+	for failures := 0; ; {
+		records, err := c.journal.Read(position+1, consumerBatchSize)
+		if err != nil {
+			log.Printf("journal: consumer %s can't read journal: %s\n", c.name, err)
+			if !c.wait(c.backoff.Delay(failures)) {
+				return
+			}
+			failures++
+			continue
+		}
+
+		failures = 0
+
+		stopped := false
+
+		for _, r := range records {
+			if !c.process(r) {
+				stopped = true
+				break
+			}
+
+			position = r.Position
+		}
+
+		if position != saved {
+			if err := c.journal.SaveCheckpoint(c.name, position); err != nil {
+				log.Printf("journal: consumer %s can't save checkpoint %v: %s\n", c.name, position, err)
+			} else {
+				saved = position
+			}
+		}
+
+		if stopped {
+			return
+		}
+
+		if len(records) == consumerBatchSize {
+			continue
+		}
+
+		select {
+		case <-c.stop:
+			return
+		case <-signal:
+		case <-time.After(consumerPollInterval):
+		}
+	}
+}
+
+// process handles a record until it succeeds, false is returned if the consumer was stopped before
+func (c *Consumer) process(r Record) bool {
+	for attempt := 0; ; attempt++ {
+		ety, err := c.journal.EventStore.GetByVersion(r.ID, r.Version)
+		if err == nil {
+			err = c.handle(r, ety)
+		}
+
+		if err == nil {
+			return true
+		}
+
+		log.Printf("journal: consumer %s failed to handle version %v of %s (attempt %d): %s\n", c.name, r.Version, r.ID, attempt+1, err)
+
+		if c.maxAttempts > 0 && attempt+1 >= c.maxAttempts {
+			deadLetter := DeadLetter{
+				Record:    r,
+				Attempts:  attempt + 1,
+				Error:     err.Error(),
+				Timestamp: time.Now().UTC(),
+			}
+
+			// without a dead letter the record is retried, so it doesn't get lost
+			if dlerr := c.journal.SaveDeadLetter(c.name, deadLetter); dlerr == nil {
+				log.Printf("journal: consumer %s gave up on version %v of %s\n", c.name, r.Version, r.ID)
+				return true
+			}
+		}
+
+		if !c.wait(c.backoff.Delay(attempt)) {
+			return false
+		}
+	}
+}
+
+// wait waits for d, false is returned if the consumer was stopped in the meantime
+func (c *Consumer) wait(d time.Duration) bool {
+	select {
+	case <-c.stop:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -0,0 +1,548 @@
+package journal
+
+//---------------------------------------------------------------------------------------------
+// The journal is the system stream '$journal' that references every committed entity version
+// of a store in write order. It is followed by consumers, e.g. the outbox, which persist their
+// position in the journal as checkpoint in the system stream '$checkpoint-<consumer>'.
+//
+// The journal entry is written after the entity version was committed. If it can't be written,
+// the version and all later versions of the entity are journaled in order by the background
+// repair of the store. Concurrent writes are appended to the journal in batches, the versions of
+// an entity in order. Versions lost by a crash in between are journaled by the reconciliation when
+// the store is started again: it compares the latest version of the entities journaled since the
+// last reconciliation with the backend and saves the journal position it reconciled as checkpoint.
+// Entities that weren't written since the last reconciliation, except for the lost versions, are
+// not reconciled. Versions before the first journaled version of an entity are taken for versions
+// written before the journal was enabled and are left out, like entities that aren't in the journal
+// at all.
+// Consumers get each entry at least once.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+)
+
+const (
+	// EnabledKey is the store metadata key that enables the journal
+	EnabledKey = "journal"
+	// StreamID is the id of the journal stream
+	StreamID = streams.SystemPrefix + "journal"
+
+	checkpointPrefix = streams.SystemPrefix + "checkpoint-"
+	deadLetterPrefix = streams.SystemPrefix + "deadletter-"
+	// reconciler is the consumer name of the reconciliation's checkpoint
+	reconciler = streams.SystemPrefix + "reconcile"
+)
+
+// Record is an entry of the journal
+type Record struct {
+	Position int64  `json:"position"`
+	ID       string `json:"id"`
+	Version  int64  `json:"version"`
+}
+
+// DeadLetter is recorded for a journal record a consumer gave up on
+type DeadLetter struct {
+	Record    Record    `json:"record"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type entry struct {
+	ID      string `json:"id"`
+	Version int64  `json:"version"`
+}
+
+type checkpoint struct {
+	Position int64 `json:"position"`
+}
+
+// errStopped is returned by the reconciliation if the store was closed before it finished
+var errStopped = errors.New("journal: stopped")
+
+// Store is an EventStore that journals all entity versions written through it
+type Store struct {
+	store.EventStore
+	mutex       sync.Mutex
+	subscribers map[chan struct{}]struct{}
+	// writeMutex guards queue and journaled, it isn't held while writing to the backend
+	writeMutex sync.Mutex
+	// queue holds the versions that wait for the next batch in write order
+	queue []entry
+	// flushMutex serializes the writes to the journal, it guards pending
+	flushMutex sync.Mutex
+	// pending holds the versions that still have to be journaled in order by entity id
+	pending map[string][]int64
+	// journaled holds the journaled versions by entity id while the journal is reconciled
+	journaled map[string]*versionSet
+	repair    chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	stopOnce  sync.Once
+	started   bool
+}
+
+// NewStore creates a new journal Store wrapping s. Stores that are written to must be started,
+// so versions that failed to be journaled are repaired.
+func NewStore(s store.EventStore) *Store {
+	return &Store{
+		EventStore:  s,
+		subscribers: map[chan struct{}]struct{}{},
+		pending:     map[string][]int64{},
+		repair:      make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start reconciles the journal with the backend and repairs versions that failed to be journaled
+// in the background, retrying with backoff
+func (s *Store) Start(backoff Backoff) {
+	s.started = true
+	go s.run(backoff)
+}
+
+// Close stops the reconciliation and repair of a started store. Versions that weren't journaled
+// yet are reconciled on the next start.
+func (s *Store) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+
+	if s.started {
+		<-s.done
+	}
+
+	return nil
+}
+
+// From returns the journal Store in the chain of decorators of s
+func From(s store.EventStore) (*Store, bool) {
+	js, ok := wrapper.Find(s, func(s store.EventStore) bool {
+		_, ok := s.(*Store)
+		return ok
+	}).(*Store)
+
+	return js, ok
+}
+
+// Unwrap returns the decorated EventStore
+func (s *Store) Unwrap() store.EventStore {
+	return s.EventStore
+}
+
+// Add adds a new entity and journals its first version
+func (s *Store) Add(entity *store.Entity) (*store.Entity, error) {
+	res, err := s.EventStore.Add(entity)
+	if err != nil {
+		return res, err
+	}
+
+	s.record(res)
+	return res, nil
+}
+
+// Append appends a new version of an entity and journals it
+func (s *Store) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	res, err := s.EventStore.Append(entity, concurrency)
+	if err != nil {
+		return res, err
+	}
+
+	s.record(res)
+	return res, nil
+}
+
+// Read reads at most max records of the journal, starting at position from
+func (s *Store) Read(from int64, max int) ([]Record, error) {
+	latest, err := streams.LatestVersion(s.EventStore, StreamID)
+	if err != nil {
+		return nil, err
+	}
+
+	if from < 1 {
+		from = 1
+	}
+
+	result := []Record{}
+
+	if latest < from || max <= 0 {
+		return result, nil
+	}
+
+	end := from + int64(max) - 1
+	if end > latest {
+		end = latest
+	}
+
+	etys, err := streams.ReadRange(s.EventStore, StreamID, from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range etys {
+		e := entry{}
+		if err := streams.Decode(&etys[i], &e); err != nil {
+			return nil, fmt.Errorf("journal: can't decode entry %v: %s", etys[i].Version, err)
+		}
+
+		result = append(result, Record{
+			Position: etys[i].Version,
+			ID:       e.ID,
+			Version:  e.Version,
+		})
+	}
+
+	return result, nil
+}
+
+// Subscribe returns a channel that is signaled whenever an entity version was journaled.
+// Signals are coalesced. The returned function cancels the subscription.
+func (s *Store) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	s.mutex.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mutex.Unlock()
+
+	return ch, func() {
+		s.mutex.Lock()
+		delete(s.subscribers, ch)
+		s.mutex.Unlock()
+	}
+}
+
+// LoadCheckpoint returns the position of the last journal record the consumer has processed
+func (s *Store) LoadCheckpoint(consumer string) (int64, error) {
+	stream := checkpointPrefix + consumer
+
+	latest, err := streams.LatestVersion(s.EventStore, stream)
+	if err != nil || latest == 0 {
+		return 0, err
+	}
+
+	ety, err := s.EventStore.GetByVersion(stream, latest)
+	if err != nil {
+		return 0, err
+	}
+
+	cp := checkpoint{}
+	if err := streams.Decode(ety, &cp); err != nil {
+		return 0, fmt.Errorf("journal: can't decode checkpoint of %s: %s", consumer, err)
+	}
+
+	return cp.Position, nil
+}
+
+// Head returns the position of the latest journal record
+func (s *Store) Head() (int64, error) {
+	return streams.LatestVersion(s.EventStore, StreamID)
+}
+
+// SaveCheckpoint saves the position of the last journal record the consumer has processed
+func (s *Store) SaveCheckpoint(consumer string, position int64) error {
+	_, err := streams.Append(s.EventStore, checkpointPrefix+consumer, checkpoint{Position: position})
+	return err
+}
+
+// Forget deletes the checkpoint and the dead letters of a consumer that is no longer used. False
+// is returned if the backend can't delete entities.
+func (s *Store) Forget(consumer string) (bool, error) {
+	for _, stream := range []string{checkpointPrefix + consumer, deadLetterPrefix + consumer} {
+		if ok, err := streams.Delete(s.EventStore, stream); !ok || err != nil {
+			return ok, err
+		}
+	}
+
+	return true, nil
+}
+
+// SaveDeadLetter records a journal record the consumer gave up on in the system stream '$deadletter-<consumer>'
+func (s *Store) SaveDeadLetter(consumer string, deadLetter DeadLetter) error {
+	_, err := streams.Append(s.EventStore, deadLetterPrefix+consumer, deadLetter)
+	return err
+}
+
+func (s *Store) record(entity *store.Entity) {
+	if streams.IsSystemID(entity.ID) {
+		return
+	}
+
+	s.writeMutex.Lock()
+
+	if s.journaled != nil {
+		if s.journaled[entity.ID].contains(entity.Version) {
+			// already journaled by the reconciliation
+			s.writeMutex.Unlock()
+			return
+		}
+
+		s.journaled[entity.ID] = s.journaled[entity.ID].add(entity.Version)
+	}
+
+	s.queue = append(s.queue, entry{ID: entity.ID, Version: entity.Version})
+	s.writeMutex.Unlock()
+
+	s.flush()
+}
+
+// flush writes the queued versions to the journal as batch. When it returns, the versions queued
+// before are journaled or wait for the repair.
+func (s *Store) flush() {
+	s.flushMutex.Lock()
+	defer s.flushMutex.Unlock()
+
+	s.writeMutex.Lock()
+	batch := s.queue
+	s.queue = nil
+	s.writeMutex.Unlock()
+
+	for _, e := range batch {
+		// later versions must not overtake versions that are waiting for the repair
+		if len(s.pending[e.ID]) == 0 {
+			err := s.write(e.ID, e.Version)
+			if err == nil {
+				continue
+			}
+
+			log.Printf("journal: failed to journal version %v of %s, it's repaired in the background: %s\n", e.Version, e.ID, err)
+		}
+
+		s.pending[e.ID] = append(s.pending[e.ID], e.Version)
+
+		select {
+		case s.repair <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// write appends the entry of version of entity id to the journal, s.flushMutex must be held
+func (s *Store) write(id string, version int64) error {
+	if _, err := streams.Append(s.EventStore, StreamID, entry{ID: id, Version: version}); err != nil {
+		return err
+	}
+
+	s.notify()
+	return nil
+}
+
+func (s *Store) notify() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Store) run(backoff Backoff) {
+	defer close(s.done)
+
+	if err := s.reconcile(); err != nil && err != errStopped {
+		log.Printf("journal: reconciliation failed, versions lost by a crash may be missing: %s\n", err)
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.repair:
+		}
+
+		for attempt := 0; !s.repairPending(); attempt++ {
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(backoff.Delay(attempt)):
+			}
+		}
+	}
+}
+
+// repairPending journals the pending versions, true is returned if none is left
+func (s *Store) repairPending() bool {
+	s.flushMutex.Lock()
+	defer s.flushMutex.Unlock()
+
+	ids := make([]string, 0, len(s.pending))
+	for id := range s.pending {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		for len(s.pending[id]) > 0 {
+			version := s.pending[id][0]
+
+			if err := s.write(id, version); err != nil {
+				log.Printf("journal: failed to repair version %v of %s: %s\n", version, id, err)
+				return false
+			}
+
+			s.pending[id] = s.pending[id][1:]
+		}
+
+		delete(s.pending, id)
+	}
+
+	return true
+}
+
+// reconcile journals the missing versions of the entities journaled since the last reconciliation
+func (s *Store) reconcile() error {
+	reconciled, err := s.LoadCheckpoint(reconciler)
+	if err != nil {
+		return err
+	}
+
+	head, err := s.Head()
+	if err != nil || head <= reconciled {
+		return err
+	}
+
+	// the versions that are queued or pending are journaled by the batches and the repair
+	s.flushMutex.Lock()
+	s.writeMutex.Lock()
+	s.journaled = map[string]*versionSet{}
+	for id, versions := range s.pending {
+		for _, v := range versions {
+			s.journaled[id] = s.journaled[id].add(v)
+		}
+	}
+	for _, e := range s.queue {
+		s.journaled[e.ID] = s.journaled[e.ID].add(e.Version)
+	}
+	s.writeMutex.Unlock()
+	s.flushMutex.Unlock()
+
+	defer func() {
+		s.writeMutex.Lock()
+		s.journaled = nil
+		s.writeMutex.Unlock()
+	}()
+
+	// versions recorded after head are added to journaled by record
+	ids := []string{}
+	seen := map[string]bool{}
+
+	for position := reconciled + 1; position <= head; position += consumerBatchSize {
+		select {
+		case <-s.stop:
+			return errStopped
+		default:
+		}
+
+		records, err := s.Read(position, consumerBatchSize)
+		if err != nil {
+			return err
+		}
+
+		s.writeMutex.Lock()
+		for _, r := range records {
+			if !seen[r.ID] {
+				seen[r.ID] = true
+				ids = append(ids, r.ID)
+			}
+
+			s.journaled[r.ID] = s.journaled[r.ID].add(r.Version)
+		}
+		s.writeMutex.Unlock()
+	}
+
+	repaired := 0
+
+	for _, id := range ids {
+		select {
+		case <-s.stop:
+			return errStopped
+		default:
+		}
+
+		n, err := s.reconcileEntity(id)
+		if err != nil {
+			return err
+		}
+
+		repaired += n
+	}
+
+	if repaired > 0 {
+		log.Printf("journal: reconciliation journaled %d missing versions\n", repaired)
+	}
+
+	return s.SaveCheckpoint(reconciler, head)
+}
+
+// reconcileEntity journals the missing versions of entity id, the number of missing versions is returned
+func (s *Store) reconcileEntity(id string) (int, error) {
+	latest, err := streams.LatestVersion(s.EventStore, id)
+	if err != nil {
+		return 0, err
+	}
+
+	s.writeMutex.Lock()
+
+	set := s.journaled[id]
+	missing := 0
+
+	for v := set.min; v <= latest; v++ {
+		if set.contains(v) {
+			continue
+		}
+
+		set.add(v)
+		s.queue = append(s.queue, entry{ID: id, Version: v})
+		missing++
+	}
+
+	s.writeMutex.Unlock()
+
+	if missing > 0 {
+		s.flush()
+	}
+
+	return missing, nil
+}
+
+// versionSet is the set of journaled versions of an entity
+type versionSet struct {
+	min      int64
+	versions map[int64]struct{}
+}
+
+func (vs *versionSet) add(version int64) *versionSet {
+	if vs == nil {
+		vs = &versionSet{min: version, versions: map[int64]struct{}{}}
+	}
+
+	if version < vs.min {
+		vs.min = version
+	}
+
+	vs.versions[version] = struct{}{}
+	return vs
+}
+
+func (vs *versionSet) contains(version int64) bool {
+	if vs == nil {
+		return false
+	}
+
+	_, ok := vs.versions[version]
+	return ok
+}
@@ -0,0 +1,353 @@
+package journal
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/stretchr/testify/assert"
+)
+
+var testBackoff = Backoff{
+	Min: time.Millisecond,
+	Max: 10 * time.Millisecond,
+}
+
+func writeTestEntities(t *testing.T, s store.EventStore) {
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: 1})
+	assert.Nil(t, err)
+	_, err = s.Add(&store.Entity{ID: "order-2", Data: 2})
+	assert.Nil(t, err)
+	_, err = s.Append(&store.Entity{ID: "order-1", Data: 3}, store.None)
+	assert.Nil(t, err)
+}
+
+func TestRead(t *testing.T) {
+	s := NewStore(fakestore.NewStore())
+	writeTestEntities(t, s)
+
+	records, err := s.Read(1, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, []Record{
+		{Position: 1, ID: "order-1", Version: 1},
+		{Position: 2, ID: "order-2", Version: 1},
+		{Position: 3, ID: "order-1", Version: 2},
+	}, records)
+
+	records, err = s.Read(3, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(records))
+
+	records, err = s.Read(4, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(records))
+}
+
+func TestCheckpoint(t *testing.T) {
+	s := NewStore(fakestore.NewStore())
+
+	p, err := s.LoadCheckpoint("test")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), p)
+
+	assert.Nil(t, s.SaveCheckpoint("test", 5))
+
+	p, err = s.LoadCheckpoint("test")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), p)
+
+	ok, err := s.Forget("test")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	p, err = s.LoadCheckpoint("test")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), p)
+}
+
+type recorder struct {
+	mutex    sync.Mutex
+	records  []Record
+	failures int
+}
+
+func (r *recorder) handle(record Record, entity *store.Entity) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("failed")
+	}
+
+	r.records = append(r.records, record)
+	return nil
+}
+
+func (r *recorder) count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.records)
+}
+
+func TestConsumerRetriesInOrder(t *testing.T) {
+	s := NewStore(fakestore.NewStore())
+	r := &recorder{failures: 3}
+
+	c := NewConsumer(s, "test", testBackoff, r.handle)
+	c.Start()
+
+	writeTestEntities(t, s)
+
+	assert.Eventually(t, func() bool {
+		return r.count() == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, c.Close())
+
+	assert.Equal(t, int64(1), r.records[0].Position)
+	assert.Equal(t, int64(2), r.records[1].Position)
+	assert.Equal(t, int64(3), r.records[2].Position)
+
+	p, err := s.LoadCheckpoint("test")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), p)
+}
+
+func TestConsumerResumesFromCheckpoint(t *testing.T) {
+	s := NewStore(fakestore.NewStore())
+	writeTestEntities(t, s)
+	assert.Nil(t, s.SaveCheckpoint("test", 2))
+
+	r := &recorder{}
+	c := NewConsumer(s, "test", testBackoff, r.handle)
+	c.Start()
+
+	assert.Eventually(t, func() bool {
+		return r.count() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, c.Close())
+	assert.Equal(t, "order-1", r.records[0].ID)
+	assert.Equal(t, int64(2), r.records[0].Version)
+}
+
+func TestConsumerRecordsDeadLetter(t *testing.T) {
+	s := NewStore(fakestore.NewStore())
+	r := &recorder{failures: 2}
+
+	c := NewConsumer(s, "test", testBackoff, r.handle).WithMaxAttempts(2)
+	c.Start()
+
+	writeTestEntities(t, s)
+
+	assert.Eventually(t, func() bool {
+		return r.count() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, c.Close())
+
+	assert.Equal(t, int64(2), r.records[0].Position)
+	assert.Equal(t, int64(3), r.records[1].Position)
+
+	ety, err := s.GetByVersion(deadLetterPrefix+"test", 1)
+	assert.Nil(t, err)
+
+	deadLetter := DeadLetter{}
+	assert.Nil(t, streams.Decode(ety, &deadLetter))
+	assert.Equal(t, Record{Position: 1, ID: "order-1", Version: 1}, deadLetter.Record)
+	assert.Equal(t, 2, deadLetter.Attempts)
+	assert.Equal(t, "failed", deadLetter.Error)
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Min: time.Second, Max: 5 * time.Second}
+
+	assert.Equal(t, time.Second, b.Delay(0))
+	assert.Equal(t, 2*time.Second, b.Delay(1))
+	assert.Equal(t, 4*time.Second, b.Delay(2))
+	assert.Equal(t, 5*time.Second, b.Delay(3))
+	assert.Equal(t, 5*time.Second, b.Delay(100))
+}
+
+// failingJournal fails all writes to the journal while failing is set
+type failingJournal struct {
+	store.EventStore
+	mutex   sync.Mutex
+	failing bool
+}
+
+func (s *failingJournal) fail(failing bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.failing = failing
+}
+
+func (s *failingJournal) err(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.failing && id == StreamID {
+		return errors.New("unavailable")
+	}
+
+	return nil
+}
+
+func (s *failingJournal) Add(entity *store.Entity) (*store.Entity, error) {
+	if err := s.err(entity.ID); err != nil {
+		return nil, err
+	}
+
+	return s.EventStore.Add(entity)
+}
+
+func (s *failingJournal) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	if err := s.err(entity.ID); err != nil {
+		return nil, err
+	}
+
+	return s.EventStore.Append(entity, concurrency)
+}
+
+func readAll(t *testing.T, s *Store) []Record {
+	records, err := s.Read(1, 100)
+	assert.Nil(t, err)
+	return records
+}
+
+func TestRecordRepairsFailedVersionsInOrder(t *testing.T) {
+	backend := &failingJournal{EventStore: fakestore.NewStore()}
+	s := NewStore(backend)
+	s.Start(testBackoff)
+	defer s.Close()
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: 1})
+	assert.Nil(t, err)
+
+	backend.fail(true)
+
+	_, err = s.Append(&store.Entity{ID: "order-1", Data: 2}, store.None)
+	assert.Nil(t, err)
+
+	backend.fail(false)
+
+	// waits for version 2 of order-1
+	_, err = s.Append(&store.Entity{ID: "order-1", Data: 3}, store.None)
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		return len(readAll(t, s)) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, []Record{
+		{Position: 1, ID: "order-1", Version: 1},
+		{Position: 2, ID: "order-1", Version: 2},
+		{Position: 3, ID: "order-1", Version: 3},
+	}, readAll(t, s))
+}
+
+func TestReconcileJournalsVersionsLostByACrash(t *testing.T) {
+	backend := fakestore.NewStore()
+
+	_, err := backend.Add(&store.Entity{ID: "legacy-1", Data: 0})
+	assert.Nil(t, err)
+
+	writeTestEntities(t, NewStore(backend))
+
+	// eventstored died before journaling these versions
+	_, err = backend.Append(&store.Entity{ID: "order-1", Data: 4}, store.None)
+	assert.Nil(t, err)
+	_, err = backend.Append(&store.Entity{ID: "order-2", Data: 5}, store.None)
+	assert.Nil(t, err)
+
+	s := NewStore(backend)
+	s.Start(testBackoff)
+	defer s.Close()
+
+	assert.Eventually(t, func() bool {
+		return len(readAll(t, s)) == 5
+	}, 5*time.Second, 10*time.Millisecond)
+
+	records := readAll(t, s)
+	assert.Equal(t, []Record{
+		{Position: 4, ID: "order-1", Version: 3},
+		{Position: 5, ID: "order-2", Version: 2},
+	}, records[3:])
+
+	// writes after the reconciliation are journaled once
+	_, err = s.Append(&store.Entity{ID: "order-1", Data: 6}, store.None)
+	assert.Nil(t, err)
+	assert.Len(t, readAll(t, s), 6)
+}
+
+func TestReconcileResumesFromCheckpoint(t *testing.T) {
+	backend := fakestore.NewStore()
+	writeTestEntities(t, NewStore(backend))
+
+	s := NewStore(backend)
+	s.Start(testBackoff)
+
+	assert.Eventually(t, func() bool {
+		position, err := s.LoadCheckpoint(reconciler)
+		return err == nil && position == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err := s.Add(&store.Entity{ID: "order-3", Data: 4})
+	assert.Nil(t, err)
+	assert.Nil(t, s.Close())
+
+	// eventstored died before journaling this version
+	_, err = backend.Append(&store.Entity{ID: "order-3", Data: 5}, store.None)
+	assert.Nil(t, err)
+
+	s = NewStore(backend)
+	s.Start(testBackoff)
+	defer s.Close()
+
+	assert.Eventually(t, func() bool {
+		position, err := s.LoadCheckpoint(reconciler)
+		return err == nil && position == 4
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, []Record{
+		{Position: 4, ID: "order-3", Version: 1},
+		{Position: 5, ID: "order-3", Version: 2},
+	}, readAll(t, s)[3:])
+}
+
+func TestConcurrentWritesAreJournaledInOrder(t *testing.T) {
+	s := NewStore(fakestore.NewStore())
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"order-1", "order-2", "order-3", "order-4"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+
+			_, err := s.Add(&store.Entity{ID: id, Data: 0})
+			assert.Nil(t, err)
+
+			for i := 1; i < 10; i++ {
+				_, err := s.Append(&store.Entity{ID: id, Data: i}, store.None)
+				assert.Nil(t, err)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	records := readAll(t, s)
+	assert.Len(t, records, 40)
+
+	latest := map[string]int64{}
+	for _, r := range records {
+		assert.Equal(t, latest[r.ID]+1, r.Version)
+		latest[r.ID] = r.Version
+	}
+}
@@ -0,0 +1,160 @@
+package memory
+
+//---------------------------------------------------------------------------------------------
+// The in memory backend of eventstore.inmemory. It follows the contract of the conformance
+// suite like the Azure backends: errors are typed store.EventStoreErrors, appends without
+// concurrency control are supported and ranges are returned in version order. Unlike the Azure
+// backends it can enumerate and delete its entities.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+)
+
+// Store is an in memory EventStore
+type Store struct {
+	entities map[string][]store.Entity
+	mutex    sync.Mutex
+}
+
+// NewStore creates a new in memory store
+func NewStore() *Store {
+	return &Store{
+		entities: map[string][]store.Entity{},
+	}
+}
+
+// Init initializes the store, metadata is ignored. Entities written before are kept.
+func (s *Store) Init(metadata store.Metadata) error {
+	return nil
+}
+
+// Add adds the first version of a new entity
+func (s *Store) Add(entity *store.Entity) (*store.Entity, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.entities[entity.ID]; exists {
+		return nil, store.EventStoreError{
+			Text:      fmt.Sprintf("entity %s already exists", entity.ID),
+			ErrorType: store.InternalError,
+		}
+	}
+
+	entity.Version = 1
+	s.entities[entity.ID] = []store.Entity{*entity}
+	return entity, nil
+}
+
+// Append appends a new version to an existing entity
+func (s *Store) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	versions, exists := s.entities[entity.ID]
+	if !exists {
+		return nil, store.EventStoreError{
+			Text:      fmt.Sprintf("entity %s does not exist", entity.ID),
+			ErrorType: store.EntityNotFound,
+		}
+	}
+
+	latest := int64(len(versions))
+	if concurrency == store.Optimistic && entity.Version != latest {
+		return nil, store.EventStoreError{
+			Text:      "entity has gone stale, a newer version already exists",
+			ErrorType: store.VersionConflict,
+		}
+	}
+
+	entity.Version = latest + 1
+	s.entities[entity.ID] = append(versions, *entity)
+	return entity, nil
+}
+
+// GetLatestVersionNumber returns the latest version of an entity
+func (s *Store) GetLatestVersionNumber(id string) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	versions, exists := s.entities[id]
+	if !exists {
+		return 0, store.EventStoreError{
+			Text:      fmt.Sprintf("entity %s does not exist", id),
+			ErrorType: store.EntityNotFound,
+		}
+	}
+
+	return int64(len(versions)), nil
+}
+
+// GetByVersion returns a single version of an entity
+func (s *Store) GetByVersion(id string, version int64) (*store.Entity, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	versions, exists := s.entities[id]
+	if !exists || version < 1 || version > int64(len(versions)) {
+		return nil, store.EventStoreError{
+			Text:      fmt.Sprintf("version %v of entity %s does not exist", version, id),
+			ErrorType: store.EntityNotFound,
+		}
+	}
+
+	ety := versions[version-1]
+	return &ety, nil
+}
+
+// GetByVersionRange returns all versions between startVersion and endVersion, both inclusive,
+// in version order
+func (s *Store) GetByVersionRange(id string, startVersion, endVersion int64) ([]store.Entity, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	versions := s.entities[id]
+	result := []store.Entity{}
+
+	for _, v := range versions {
+		if v.Version >= startVersion && v.Version <= endVersion {
+			result = append(result, v)
+		}
+	}
+
+	return result, nil
+}
+
+// ListIDs calls fn with the sorted ids of all entities that start with prefix
+func (s *Store) ListIDs(prefix string, fn func(id string) error) error {
+	s.mutex.Lock()
+	ids := make([]string, 0, len(s.entities))
+	for id := range s.entities {
+		if strings.HasPrefix(id, prefix) {
+			ids = append(ids, id)
+		}
+	}
+	s.mutex.Unlock()
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if err := fn(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes an entity with all its versions, deleting a missing entity is not an error
+func (s *Store) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.entities, id)
+	return nil
+}
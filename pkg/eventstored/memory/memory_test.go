@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListIDs(t *testing.T) {
+	s := NewStore()
+
+	for _, id := range []string{"order-2", "$journal", "order-1", "customer-1"} {
+		_, err := s.Add(&store.Entity{ID: id})
+		assert.Nil(t, err)
+	}
+
+	ids := []string{}
+	assert.Nil(t, s.ListIDs("order-", func(id string) error {
+		ids = append(ids, id)
+		return nil
+	}))
+	assert.Equal(t, []string{"order-1", "order-2"}, ids)
+
+	stop := errors.New("stop")
+	count := 0
+	assert.Equal(t, stop, s.ListIDs("", func(id string) error {
+		count++
+		return stop
+	}))
+	assert.Equal(t, 1, count)
+}
+
+func TestDelete(t *testing.T) {
+	s := NewStore()
+
+	_, err := s.Add(&store.Entity{ID: "order-1"})
+	assert.Nil(t, err)
+	_, err = s.Append(&store.Entity{ID: "order-1"}, store.None)
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Delete("order-1"))
+	assert.Nil(t, s.Delete("order-2"))
+
+	_, err = s.GetLatestVersionNumber("order-1")
+	assert.NotNil(t, err)
+
+	// the id can be used again
+	ety, err := s.Add(&store.Entity{ID: "order-1"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), ety.Version)
+}
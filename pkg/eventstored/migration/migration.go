@@ -0,0 +1,649 @@
+package migration
+
+//---------------------------------------------------------------------------------------------
+// A migration moves a store from a source to a target backend while eventstored keeps serving
+// it. The stages are passed in order:
+//   dualwrite: writes go to the source and are mirrored to the target
+//   backfill:  additionally copies the history of all entities of the source to the target
+//   verify:    additionally compares version counts and checksums of all entities, periodically
+//   cutover:   reads and writes go to the target, writes are mirrored to the source for a rollback
+//
+// Entity streams and the streams derived from them (journal, timestamps and categories) are
+// copied with their versions. A mirrored version is only written if it follows the latest
+// version in the other backend, otherwise it's left to the backfill. Other system streams,
+// e.g. checkpoints, hold a latest value, they are mirrored as new versions but not backfilled.
+// Streams whose mirrored write failed, or was skipped after the dualwrite stage, are repaired
+// in the background by copying their missing versions.
+//
+// The backfill and the verification scan all entities of the source, so they require a backend
+// that can enumerate its entities, like eventstore.inmemory and eventstore.azure.tablestorage.
+// Every completed verification is saved in the source, the cutover is refused unless the latest
+// one found no mismatches.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/category"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/timestamps"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+)
+
+const (
+	// StageDualWrite mirrors writes to the target
+	StageDualWrite = "dualwrite"
+	// StageBackfill mirrors writes and copies the history to the target
+	StageBackfill = "backfill"
+	// StageVerify mirrors writes, copies the history and compares source and target
+	StageVerify = "verify"
+	// StageCutover serves the target and mirrors writes to the source
+	StageCutover = "cutover"
+
+	// DefaultVerifyInterval is the time between two verifications unless configured otherwise
+	DefaultVerifyInterval = 10 * time.Minute
+
+	verificationStreamID = streams.SystemPrefix + "migration-verification"
+	maxMismatches        = 100
+)
+
+var errStopped = errors.New("migration: stopped")
+
+// Options of a migration
+type Options struct {
+	Stage string
+	// VerifyInterval is the time between two verifications, DefaultVerifyInterval if 0
+	VerifyInterval time.Duration
+}
+
+// Progress of the backfill's scan of the source
+type Progress struct {
+	Copied int    `json:"copied"`
+	Done   bool   `json:"done"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Mismatch is an entity that differs between source and target
+type Mismatch struct {
+	ID            string `json:"id"`
+	SourceVersion int64  `json:"sourceVersion"`
+	TargetVersion int64  `json:"targetVersion"`
+	Reason        string `json:"reason"`
+}
+
+// Verification is the result of comparing source and target, at most 100 mismatches are reported
+type Verification struct {
+	Started    time.Time  `json:"started"`
+	Done       bool       `json:"done"`
+	Entities   int        `json:"entities"`
+	Mismatches []Mismatch `json:"mismatches"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Status of a migration
+type Status struct {
+	Stage    string    `json:"stage"`
+	Backfill *Progress `json:"backfill,omitempty"`
+	// Verification is the latest completed verification, or the first one while it's running
+	Verification *Verification `json:"verification,omitempty"`
+	// Repairs is the number of streams whose mirrored writes wait for a repair
+	Repairs int `json:"repairs"`
+}
+
+// Store is an EventStore that migrates a source to a target backend. It serves the source
+// until the cutover and the target afterwards.
+type Store struct {
+	store.EventStore
+	secondary    store.EventStore
+	source       store.EventStore
+	target       store.EventStore
+	options      Options
+	backoff      journal.Backoff
+	mutex        sync.Mutex
+	backfill     *Progress
+	verification *Verification
+	verified     *Verification
+	failed       map[string]bool
+	repairs      chan struct{}
+	stop         chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewStore creates a new migration Store and starts the background work of the stage. The
+// backfill and verify stages fail if the source can't enumerate its entities, the cutover
+// fails unless the latest verification found no mismatches.
+func NewStore(source, target store.EventStore, options Options, backoff journal.Backoff) (*Store, error) {
+	if options.VerifyInterval == 0 {
+		options.VerifyInterval = DefaultVerifyInterval
+	}
+
+	s := &Store{
+		EventStore: source,
+		secondary:  target,
+		source:     source,
+		target:     target,
+		options:    options,
+		backoff:    backoff,
+		failed:     map[string]bool{},
+		repairs:    make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+	}
+
+	switch options.Stage {
+	case StageDualWrite:
+	case StageBackfill, StageVerify:
+		if !streams.CanList(source) {
+			return nil, fmt.Errorf("migration: stage '%s' scans all entities of the source, but its backend can't enumerate them", options.Stage)
+		}
+
+		s.backfill = &Progress{}
+	case StageCutover:
+		v, err := lastVerification(source)
+		if err != nil {
+			return nil, fmt.Errorf("migration: can't read the latest verification: %s", err)
+		}
+
+		if v == nil || !v.Done || v.Error != "" || len(v.Mismatches) != 0 {
+			return nil, errors.New("migration: the cutover requires a completed verification without mismatches, run the verify stage first")
+		}
+
+		s.verified = v
+		s.EventStore, s.secondary = target, source
+	default:
+		return nil, fmt.Errorf("migration: unknown stage '%s'", options.Stage)
+	}
+
+	s.wg.Add(1)
+	go s.runRepairs()
+
+	if s.backfill != nil {
+		s.wg.Add(1)
+		go s.run()
+	}
+
+	return s, nil
+}
+
+// From returns the migration Store in the chain of decorators of s
+func From(s store.EventStore) (*Store, bool) {
+	ms, ok := wrapper.Find(s, func(s store.EventStore) bool {
+		_, ok := s.(*Store)
+		return ok
+	}).(*Store)
+
+	return ms, ok
+}
+
+// Unwrap returns the backend that is served
+func (s *Store) Unwrap() store.EventStore {
+	return s.EventStore
+}
+
+// Add adds a new entity and mirrors it
+func (s *Store) Add(entity *store.Entity) (*store.Entity, error) {
+	res, err := s.EventStore.Add(entity)
+	if err != nil {
+		return res, err
+	}
+
+	s.mirror(res)
+	return res, nil
+}
+
+// Append appends a new version of an entity and mirrors it
+func (s *Store) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	res, err := s.EventStore.Append(entity, concurrency)
+	if err != nil {
+		return res, err
+	}
+
+	s.mirror(res)
+	return res, nil
+}
+
+// Status returns the stage and the progress of the migration
+func (s *Store) Status() (Status, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	status := Status{Stage: s.options.Stage, Repairs: len(s.failed)}
+
+	if s.backfill != nil {
+		p := *s.backfill
+		status.Backfill = &p
+	}
+
+	v := s.verified
+	if v == nil {
+		v = s.verification
+	}
+
+	if v != nil {
+		c := *v
+		c.Mismatches = append([]Mismatch{}, v.Mismatches...)
+		status.Verification = &c
+	}
+
+	return status, nil
+}
+
+// Close stops the backfill, the verification and the repairs
+func (s *Store) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+	return nil
+}
+
+// copied returns true for streams that are copied with their versions
+func (s *Store) copied(id string) bool {
+	return !streams.IsSystemID(id) ||
+		id == journal.StreamID ||
+		strings.HasPrefix(id, timestamps.StreamPrefix) ||
+		strings.HasPrefix(id, category.StreamPrefix)
+}
+
+// mirror writes a version to the secondary backend, failures are repaired in the background
+func (s *Store) mirror(entity *store.Entity) {
+	var err error
+	written := true
+
+	if s.copied(entity.ID) {
+		written, err = mirrorVersion(s.secondary, entity)
+	} else {
+		err = mirrorLatest(s.secondary, entity)
+	}
+
+	if err != nil {
+		log.Printf("migration: failed to mirror version %v of %s, it's repaired later: %s\n", entity.Version, entity.ID, err)
+		s.repair(entity.ID)
+		return
+	}
+
+	// only the dualwrite stage leaves the history to a later backfill
+	if !written && s.options.Stage != StageDualWrite {
+		s.repair(entity.ID)
+	}
+}
+
+// mirrorVersion writes the version if it follows the latest version in s, otherwise it returns
+// false
+func mirrorVersion(s store.EventStore, entity *store.Entity) (bool, error) {
+	latest, err := streams.LatestVersion(s, entity.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if latest != entity.Version-1 {
+		return false, nil
+	}
+
+	return true, streams.AppendVersion(s, entity)
+}
+
+// mirrorLatest writes the version as new latest version
+func mirrorLatest(s store.EventStore, entity *store.Entity) error {
+	latest, err := streams.LatestVersion(s, entity.ID)
+	if err != nil {
+		return err
+	}
+
+	if latest == 0 {
+		_, err = s.Add(&store.Entity{ID: entity.ID, Metadata: entity.Metadata, Data: entity.Data})
+		return err
+	}
+
+	_, err = s.Append(&store.Entity{ID: entity.ID, Version: latest, Metadata: entity.Metadata, Data: entity.Data}, store.None)
+	return err
+}
+
+// repair schedules the stream id to be brought up to date in the secondary backend
+func (s *Store) repair(id string) {
+	s.mutex.Lock()
+	s.failed[id] = true
+	s.mutex.Unlock()
+
+	select {
+	case s.repairs <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Store) runRepairs() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.repairs:
+		}
+
+		for attempt := 0; !s.repairFailed(); attempt++ {
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(s.backoff.Delay(attempt)):
+			}
+		}
+	}
+}
+
+// repairFailed repairs all scheduled streams in order, it returns false if a repair failed
+func (s *Store) repairFailed() bool {
+	s.mutex.Lock()
+	ids := make([]string, 0, len(s.failed))
+	for id := range s.failed {
+		ids = append(ids, id)
+	}
+	s.mutex.Unlock()
+
+	sort.Strings(ids)
+	ok := true
+
+	for _, id := range ids {
+		// cleared before the repair, so writes during the repair schedule it again
+		s.mutex.Lock()
+		delete(s.failed, id)
+		s.mutex.Unlock()
+
+		if err := s.repairStream(id); err != nil {
+			log.Printf("migration: failed to repair %s: %s\n", id, err)
+
+			s.mutex.Lock()
+			s.failed[id] = true
+			s.mutex.Unlock()
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+func (s *Store) repairStream(id string) error {
+	if s.copied(id) {
+		return copyStream(s.EventStore, s.secondary, id)
+	}
+
+	latest, err := streams.LatestVersion(s.EventStore, id)
+	if err != nil || latest == 0 {
+		return err
+	}
+
+	ety, err := s.EventStore.GetByVersion(id, latest)
+	if err != nil {
+		return err
+	}
+
+	return mirrorLatest(s.secondary, ety)
+}
+
+// copyStream copies the versions of the stream id that are missing in to
+func copyStream(from, to store.EventStore, id string) error {
+	fromLatest, err := streams.LatestVersion(from, id)
+	if err != nil {
+		return err
+	}
+
+	toLatest, err := streams.LatestVersion(to, id)
+	if err != nil {
+		return err
+	}
+
+	for v := toLatest + 1; v <= fromLatest; v++ {
+		ety, err := from.GetByVersion(id, v)
+		if err != nil {
+			return err
+		}
+
+		if err := streams.AppendVersion(to, ety); err != nil {
+			// mirrored in the meantime
+			if latest, lerr := streams.LatestVersion(to, id); lerr == nil && latest >= v {
+				continue
+			}
+
+			return fmt.Errorf("migration: can't copy version %v of %s: %s", v, id, err)
+		}
+	}
+
+	return nil
+}
+
+// run backfills the target and verifies it periodically in the verify stage
+func (s *Store) run() {
+	defer s.wg.Done()
+
+	if !s.runBackfill() || s.options.Stage != StageVerify {
+		return
+	}
+
+	for {
+		if !s.verify() {
+			return
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(s.options.VerifyInterval):
+		}
+	}
+}
+
+// runBackfill scans the source until all copied streams are copied, it returns false if the
+// Store was closed before
+func (s *Store) runBackfill() bool {
+	for attempt := 0; ; attempt++ {
+		s.updateBackfill(func(p *Progress) {
+			p.Copied = 0
+		})
+
+		_, err := streams.ListIDs(s.source, "", func(id string) error {
+			if !s.copied(id) {
+				return nil
+			}
+
+			if s.stopped() {
+				return errStopped
+			}
+
+			if err := copyStream(s.source, s.target, id); err != nil {
+				return err
+			}
+
+			s.updateBackfill(func(p *Progress) {
+				p.Copied++
+			})
+
+			return nil
+		})
+
+		if err == errStopped {
+			return false
+		}
+
+		if err == nil {
+			s.updateBackfill(func(p *Progress) {
+				p.Done = true
+				p.Error = ""
+			})
+
+			return true
+		}
+
+		log.Printf("migration: backfill failed, it's retried: %s\n", err)
+		s.updateBackfill(func(p *Progress) {
+			p.Error = err.Error()
+		})
+
+		select {
+		case <-s.stop:
+			return false
+		case <-time.After(s.backoff.Delay(attempt)):
+		}
+	}
+}
+
+func (s *Store) updateBackfill(update func(p *Progress)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	update(s.backfill)
+}
+
+// verify compares all entities of source and target and saves the result in the source, it
+// returns false if the Store was closed before
+func (s *Store) verify() bool {
+	s.mutex.Lock()
+	s.verification = &Verification{Started: time.Now().UTC(), Mismatches: []Mismatch{}}
+	s.mutex.Unlock()
+
+	_, err := streams.ListIDs(s.source, "", func(id string) error {
+		if streams.IsSystemID(id) {
+			return nil
+		}
+
+		if s.stopped() {
+			return errStopped
+		}
+
+		mismatch, err := s.compare(id)
+		if err == nil && mismatch != nil && mismatch.TargetVersion < mismatch.SourceVersion {
+			// written while comparing or still waiting for a repair
+			if err = copyStream(s.source, s.target, id); err == nil {
+				mismatch, err = s.compare(id)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		s.updateVerification(func(v *Verification) {
+			v.Entities++
+			if mismatch != nil && len(v.Mismatches) < maxMismatches {
+				v.Mismatches = append(v.Mismatches, *mismatch)
+			}
+		})
+
+		return nil
+	})
+
+	if err == errStopped {
+		return false
+	}
+
+	s.mutex.Lock()
+	v := s.verification
+	v.Done = true
+	if err != nil {
+		v.Error = err.Error()
+	}
+	s.verified = v
+	s.mutex.Unlock()
+
+	if _, err := streams.Append(s.source, verificationStreamID, v); err != nil {
+		log.Printf("migration: failed to save the verification: %s\n", err)
+	}
+
+	return true
+}
+
+func (s *Store) updateVerification(update func(v *Verification)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	update(s.verification)
+}
+
+func (s *Store) stopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// lastVerification returns the latest verification saved in s or nil if there is none
+func lastVerification(s store.EventStore) (*Verification, error) {
+	latest, err := streams.LatestVersion(s, verificationStreamID)
+	if err != nil || latest == 0 {
+		return nil, err
+	}
+
+	ety, err := s.GetByVersion(verificationStreamID, latest)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Verification{}
+	if err := streams.Decode(ety, v); err != nil {
+		return nil, fmt.Errorf("migration: can't decode verification: %s", err)
+	}
+
+	return v, nil
+}
+
+// compare compares the version count and the checksums of all versions of an entity
+func (s *Store) compare(id string) (*Mismatch, error) {
+	sourceLatest, err := streams.LatestVersion(s.source, id)
+	if err != nil {
+		return nil, err
+	}
+
+	targetLatest, err := streams.LatestVersion(s.target, id)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatch := &Mismatch{ID: id, SourceVersion: sourceLatest, TargetVersion: targetLatest}
+
+	if sourceLatest != targetLatest {
+		mismatch.Reason = "version count differs"
+		return mismatch, nil
+	}
+
+	sourceVersions, err := streams.ReadRange(s.source, id, 1, sourceLatest)
+	if err != nil {
+		return nil, err
+	}
+
+	targetVersions, err := streams.ReadRange(s.target, id, 1, targetLatest)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sourceVersions) != len(targetVersions) {
+		mismatch.Reason = "versions are missing"
+		return mismatch, nil
+	}
+
+	for i := range sourceVersions {
+		if checksum(&sourceVersions[i]) != checksum(&targetVersions[i]) {
+			mismatch.Reason = fmt.Sprintf("checksum of version %v differs", sourceVersions[i].Version)
+			return mismatch, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func checksum(entity *store.Entity) [sha256.Size]byte {
+	data, _ := json.Marshal(struct {
+		Version  int64       `json:"version"`
+		Metadata string      `json:"metadata"`
+		Data     interface{} `json:"data"`
+	}{entity.Version, entity.Metadata, entity.Data})
+
+	return sha256.Sum256(data)
+}
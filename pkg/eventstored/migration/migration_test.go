@@ -0,0 +1,281 @@
+package migration
+
+import (
+	"testing"
+	"time"
+
+	"errors"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore-impl/store/inmemory"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/stretchr/testify/assert"
+)
+
+var testBackoff = journal.Backoff{
+	Min: time.Millisecond,
+	Max: 10 * time.Millisecond,
+}
+
+func latest(t *testing.T, s store.EventStore, id string) int64 {
+	v, err := streams.LatestVersion(s, id)
+	assert.Nil(t, err)
+	return v
+}
+
+// newTestSource returns a source with history journaled before the migration
+func newTestSource(t *testing.T) store.EventStore {
+	source := fakestore.NewStore()
+	j := journal.NewStore(source)
+
+	_, err := j.Add(&store.Entity{ID: "order-1", Data: 1})
+	assert.Nil(t, err)
+	_, err = j.Append(&store.Entity{ID: "order-1", Data: 2}, store.None)
+	assert.Nil(t, err)
+
+	return source
+}
+
+// waitForVerification waits until a verification of m completed
+func waitForVerification(t *testing.T, m *Store) *Verification {
+	var status Status
+	var err error
+
+	assert.Eventually(t, func() bool {
+		status, err = m.Status()
+		return err == nil && status.Verification != nil && status.Verification.Done
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return status.Verification
+}
+
+// verify runs the verify stage until a verification completed
+func verify(t *testing.T, source, target store.EventStore) *Verification {
+	m, err := NewStore(source, target, Options{Stage: StageVerify}, testBackoff)
+	assert.Nil(t, err)
+	defer m.Close()
+
+	return waitForVerification(t, m)
+}
+
+func TestUnknownStage(t *testing.T) {
+	_, err := NewStore(fakestore.NewStore(), fakestore.NewStore(), Options{Stage: "copy"}, testBackoff)
+	assert.NotNil(t, err)
+}
+
+func TestScanningStagesRequireAListableSource(t *testing.T) {
+	source := inmemory.NewStore()
+	assert.Nil(t, source.Init(store.Metadata{}))
+
+	for _, stage := range []string{StageBackfill, StageVerify} {
+		_, err := NewStore(source, fakestore.NewStore(), Options{Stage: stage}, testBackoff)
+		assert.NotNil(t, err, stage)
+	}
+
+	m, err := NewStore(source, fakestore.NewStore(), Options{Stage: StageDualWrite}, testBackoff)
+	assert.Nil(t, err)
+	assert.Nil(t, m.Close())
+}
+func TestDualWrite(t *testing.T) {
+	source, target := newTestSource(t), fakestore.NewStore()
+
+	m, err := NewStore(source, target, Options{Stage: StageDualWrite}, testBackoff)
+	assert.Nil(t, err)
+	defer m.Close()
+
+	j := journal.NewStore(m)
+
+	_, err = j.Append(&store.Entity{ID: "order-1", Data: 3}, store.None)
+	assert.Nil(t, err)
+	_, err = j.Add(&store.Entity{ID: "order-2", Data: 1})
+	assert.Nil(t, err)
+	assert.Nil(t, j.SaveCheckpoint("test", 4))
+
+	// versions following a gap are left to the backfill
+	assert.Equal(t, int64(3), latest(t, source, "order-1"))
+	assert.Equal(t, int64(0), latest(t, target, "order-1"))
+	assert.Equal(t, int64(4), latest(t, source, journal.StreamID))
+	assert.Equal(t, int64(0), latest(t, target, journal.StreamID))
+
+	// new entities are mirrored with their versions
+	assert.Equal(t, int64(1), latest(t, target, "order-2"))
+
+	// other system streams are mirrored as latest value
+	p, err := journal.NewStore(target).LoadCheckpoint("test")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), p)
+}
+
+func TestDualWriteRepairsFailedMirrors(t *testing.T) {
+	source := fakestore.NewStore()
+	target := fakestore.NewFaultyStore(fakestore.NewStore())
+
+	m, err := NewStore(source, target, Options{Stage: StageDualWrite}, testBackoff)
+	assert.Nil(t, err)
+	defer m.Close()
+
+	target.Inject(fakestore.Faults{Err: errors.New("unavailable"), Failures: 3})
+
+	_, err = m.Add(&store.Entity{ID: "order-1", Data: 1})
+	assert.Nil(t, err)
+	_, err = m.Append(&store.Entity{ID: "order-1", Data: 2}, store.None)
+	assert.Nil(t, err)
+	_, err = m.Add(&store.Entity{ID: "$checkpoint-test", Data: 7})
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		status, err := m.Status()
+		return err == nil && status.Repairs == 0 &&
+			latest(t, target, "order-1") == 2 && latest(t, target, "$checkpoint-test") == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	ety, err := target.GetByVersion("order-1", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, ety.Data)
+}
+
+func TestBackfillAndVerify(t *testing.T) {
+	source, target := newTestSource(t), fakestore.NewStore()
+
+	m, err := NewStore(source, target, Options{Stage: StageDualWrite}, testBackoff)
+	assert.Nil(t, err)
+
+	j := journal.NewStore(m)
+	_, err = j.Add(&store.Entity{ID: "order-2", Data: 1})
+	assert.Nil(t, err)
+	assert.Nil(t, m.Close())
+
+	// entities written before the journal was enabled and the index streams derived from
+	// entities are copied, other system streams aren't
+	_, err = source.Add(&store.Entity{ID: "legacy-1", Data: 1})
+	assert.Nil(t, err)
+	_, err = source.Add(&store.Entity{ID: "$ts-order-1", Data: "t1"})
+	assert.Nil(t, err)
+	_, err = source.Add(&store.Entity{ID: "$ce-order", Data: "c1"})
+	assert.Nil(t, err)
+	_, err = source.Add(&store.Entity{ID: "$checkpoint-test", Data: 1})
+	assert.Nil(t, err)
+
+	m, err = NewStore(source, target, Options{Stage: StageBackfill}, testBackoff)
+	assert.Nil(t, err)
+
+	var status Status
+	assert.Eventually(t, func() bool {
+		status, err = m.Status()
+		return err == nil && status.Backfill.Done
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, m.Close())
+	assert.Equal(t, 6, status.Backfill.Copied)
+
+	assert.Equal(t, int64(2), latest(t, target, "order-1"))
+	assert.Equal(t, int64(1), latest(t, target, "order-2"))
+	assert.Equal(t, int64(1), latest(t, target, "legacy-1"))
+	assert.Equal(t, int64(3), latest(t, target, journal.StreamID))
+	assert.Equal(t, int64(1), latest(t, target, "$ts-order-1"))
+	assert.Equal(t, int64(1), latest(t, target, "$ce-order"))
+	assert.Equal(t, int64(0), latest(t, target, "$checkpoint-test"))
+
+	v := verify(t, source, target)
+	assert.Equal(t, 3, v.Entities)
+	assert.Equal(t, 0, len(v.Mismatches))
+	assert.Equal(t, "", v.Error)
+
+	// the verification is saved
+	saved, err := lastVerification(source)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, saved.Entities)
+	assert.True(t, saved.Done)
+}
+
+func TestVerifyRunsPeriodically(t *testing.T) {
+	source, target := newTestSource(t), fakestore.NewStore()
+
+	m, err := NewStore(source, target, Options{Stage: StageVerify, VerifyInterval: 10 * time.Millisecond}, testBackoff)
+	assert.Nil(t, err)
+	defer m.Close()
+
+	assert.Equal(t, 1, waitForVerification(t, m).Entities)
+
+	// written without being mirrored, e.g. by another replica before its migration started
+	_, err = source.Add(&store.Entity{ID: "order-2", Data: 1})
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		status, err := m.Status()
+		return err == nil && status.Verification.Entities == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	status, err := m.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(status.Verification.Mismatches))
+	assert.Equal(t, int64(1), latest(t, target, "order-2"))
+}
+func TestVerifyReportsMismatches(t *testing.T) {
+	source, target := newTestSource(t), fakestore.NewStore()
+
+	_, err := target.Add(&store.Entity{ID: "order-1", Data: 1})
+	assert.Nil(t, err)
+	_, err = target.Append(&store.Entity{ID: "order-1", Data: 5}, store.None)
+	assert.Nil(t, err)
+
+	// the backfill has nothing to copy, both have two versions
+	v := verify(t, source, target)
+
+	assert.Equal(t, 1, v.Entities)
+	assert.Equal(t, []Mismatch{{
+		ID:            "order-1",
+		SourceVersion: 2,
+		TargetVersion: 2,
+		Reason:        "checksum of version 2 differs",
+	}}, v.Mismatches)
+
+	// the cutover is refused
+	_, err = NewStore(source, target, Options{Stage: StageCutover}, testBackoff)
+	assert.NotNil(t, err)
+}
+
+func TestCutover(t *testing.T) {
+	source, target := newTestSource(t), fakestore.NewStore()
+
+	_, err := target.Add(&store.Entity{ID: "order-1", Data: 1})
+	assert.Nil(t, err)
+	_, err = target.Append(&store.Entity{ID: "order-1", Data: 2}, store.None)
+	assert.Nil(t, err)
+
+	// the cutover requires a verification
+	_, err = NewStore(source, target, Options{Stage: StageCutover}, testBackoff)
+	assert.NotNil(t, err)
+
+	verification := verify(t, source, target)
+	assert.Equal(t, 0, len(verification.Mismatches))
+
+	m, err := NewStore(source, target, Options{Stage: StageCutover}, testBackoff)
+	assert.Nil(t, err)
+	defer m.Close()
+
+	_, err = m.Append(&store.Entity{ID: "order-1", Data: 3}, store.None)
+	assert.Nil(t, err)
+	_, err = m.Add(&store.Entity{ID: "order-2", Data: 1})
+	assert.Nil(t, err)
+
+	// reads are served by the target
+	_, err = target.Add(&store.Entity{ID: "order-3", Data: 1})
+	assert.Nil(t, err)
+
+	v, err := m.GetLatestVersionNumber("order-3")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), v)
+
+	// writes are mirrored to the source
+	assert.Equal(t, int64(3), latest(t, source, "order-1"))
+	assert.Equal(t, int64(1), latest(t, source, "order-2"))
+
+	status, err := m.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, StageCutover, status.Stage)
+	assert.Nil(t, status.Backfill)
+	assert.Equal(t, 1, status.Verification.Entities)
+}
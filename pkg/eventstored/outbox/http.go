@@ -0,0 +1,70 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	httpURLKey         = "url"
+	httpTimeoutKey     = "timeout"
+	httpDefaultTimeout = 10 * time.Second
+)
+
+// httpSink posts messages as JSON to a webhook, any 2xx status code acknowledges a message
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *httpSink) Init(metadata map[string]string) error {
+	url, ok := metadata[httpURLKey]
+	if !ok || url == "" {
+		return errors.New("outbox: http sink url is missing")
+	}
+
+	timeout := httpDefaultTimeout
+	if t, ok := metadata[httpTimeoutKey]; ok && t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return fmt.Errorf("outbox: invalid http sink timeout %s: %s", t, err)
+		}
+
+		timeout = d
+	}
+
+	s.url = url
+	s.client = &http.Client{Timeout: timeout}
+	return nil
+}
+
+func (s *httpSink) Publish(msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body) // nolint: errcheck
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("outbox: http sink returned %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	return nil
+}
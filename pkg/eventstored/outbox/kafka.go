@@ -0,0 +1,85 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+const (
+	kafkaBrokersKey = "brokers"
+	kafkaTopicKey   = "topic"
+)
+
+// kafkaSink produces messages to a Kafka topic. Messages are keyed by entity id, so all versions
+// of an entity end up in the same partition in order.
+type kafkaSink struct {
+	brokers  []string
+	topic    string
+	config   *sarama.Config
+	producer sarama.SyncProducer
+}
+
+func (s *kafkaSink) Init(metadata map[string]string) error {
+	brokers, ok := metadata[kafkaBrokersKey]
+	if !ok || brokers == "" {
+		return errors.New("outbox: kafka sink brokers are missing")
+	}
+
+	topic, ok := metadata[kafkaTopicKey]
+	if !ok || topic == "" {
+		return errors.New("outbox: kafka sink topic is missing")
+	}
+
+	addrs := strings.Split(brokers, ",")
+	for i, a := range addrs {
+		addrs[i] = strings.TrimSpace(a)
+	}
+
+	cfg := sarama.NewConfig()
+	cfg.ClientID = "eventstored"
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Return.Successes = true
+	// the outbox retries failed messages itself
+	cfg.Producer.Retry.Max = 0
+
+	s.brokers = addrs
+	s.topic = topic
+	s.config = cfg
+	return nil
+}
+
+func (s *kafkaSink) Publish(msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	// connect lazily, so unavailable brokers are retried like failed messages
+	if s.producer == nil {
+		producer, err := sarama.NewSyncProducer(s.brokers, s.config)
+		if err != nil {
+			return err
+		}
+
+		s.producer = producer
+	}
+
+	_, _, err = s.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: s.topic,
+		Key:   sarama.StringEncoder(msg.Entity.ID),
+		Value: sarama.ByteEncoder(payload),
+	})
+
+	return err
+}
+
+func (s *kafkaSink) Close() error {
+	if s.producer == nil {
+		return nil
+	}
+
+	return s.producer.Close()
+}
@@ -0,0 +1,180 @@
+package outbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	natsURLKey     = "url"
+	natsSubjectKey = "subject"
+	natsTimeout    = 10 * time.Second
+)
+
+// natsSink publishes messages to a NATS subject. It speaks the plain NATS client protocol and
+// sends a PING after each PUB, a message is acknowledged once the server answers with PONG.
+type natsSink struct {
+	address string
+	user    *url.Userinfo
+	subject string
+	mutex   sync.Mutex
+	conn    net.Conn
+	reader  *bufio.Reader
+}
+
+type natsConnect struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"pass,omitempty"`
+}
+
+func (s *natsSink) Init(metadata map[string]string) error {
+	rawurl, ok := metadata[natsURLKey]
+	if !ok || rawurl == "" {
+		return errors.New("outbox: nats sink url is missing")
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("outbox: invalid nats sink url %s", rawurl)
+	}
+
+	subject, ok := metadata[natsSubjectKey]
+	if !ok || subject == "" {
+		return errors.New("outbox: nats sink subject is missing")
+	}
+
+	if strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("outbox: invalid nats sink subject %s", subject)
+	}
+
+	s.address = u.Host
+	if u.Port() == "" {
+		s.address = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	s.user = u.User
+	s.subject = subject
+	return nil
+}
+
+func (s *natsSink) Publish(msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.publish(payload); err != nil {
+		// reconnect with the next attempt
+		s.disconnect()
+		return err
+	}
+
+	return nil
+}
+
+func (s *natsSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.disconnect()
+	return nil
+}
+
+func (s *natsSink) connect() error {
+	conn, err := net.DialTimeout("tcp", s.address, natsTimeout)
+	if err != nil {
+		return fmt.Errorf("outbox: can't connect to nats server %s: %s", s.address, err)
+	}
+
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+
+	if err := conn.SetDeadline(time.Now().Add(natsTimeout)); err != nil {
+		s.disconnect()
+		return err
+	}
+
+	// the server greets with INFO
+	line, err := s.reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO") {
+		s.disconnect()
+		return fmt.Errorf("outbox: unexpected greeting from nats server %s: %q %v", s.address, line, err)
+	}
+
+	connect := natsConnect{Name: "eventstored"}
+	if s.user != nil {
+		connect.User = s.user.Username()
+		connect.Password, _ = s.user.Password()
+	}
+
+	options, err := json.Marshal(connect)
+	if err != nil {
+		s.disconnect()
+		return err
+	}
+
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", options); err != nil {
+		s.disconnect()
+		return err
+	}
+
+	return nil
+}
+
+func (s *natsSink) publish(payload []byte) error {
+	if err := s.conn.SetDeadline(time.Now().Add(natsTimeout)); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(s.conn, "PUB %s %d\r\n%s\r\nPING\r\n", s.subject, len(payload), payload); err != nil {
+		return err
+	}
+
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := fmt.Fprint(s.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("outbox: nats server %s returned %s", s.address, line)
+		}
+	}
+}
+
+func (s *natsSink) disconnect() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+
+	s.conn = nil
+	s.reader = nil
+}
@@ -0,0 +1,104 @@
+package outbox
+
+//---------------------------------------------------------------------------------------------
+// The outbox publishes every committed entity version of a store to a sink, e.g. a message
+// broker. It follows the store's journal, so publishing survives restarts and failed messages
+// are retried with backoff until the sink accepts them. Delivery is at least once, consumers
+// must tolerate duplicates, e.g. by the entity id and version of a message.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"fmt"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+)
+
+const (
+	consumerName = "outbox"
+)
+
+// Message is published to a sink for each committed entity version
+type Message struct {
+	Store    string       `json:"store"`
+	Position int64        `json:"position"`
+	Entity   store.Entity `json:"entity"`
+}
+
+// Sink publishes messages to a message broker
+type Sink interface {
+	Init(metadata map[string]string) error
+	Publish(msg Message) error
+	Close() error
+}
+
+var sinks = map[string]func() Sink{
+	"http":  func() Sink { return &httpSink{} },
+	"nats":  func() Sink { return &natsSink{} },
+	"kafka": func() Sink { return &kafkaSink{} },
+}
+
+// NewSink creates and initializes a sink from its spec
+func NewSink(spec config.SinkSpec) (Sink, error) {
+	factory, ok := sinks[spec.Type]
+	if !ok {
+		return nil, fmt.Errorf("outbox: unknown sink type %s", spec.Type)
+	}
+
+	metadata := map[string]string{}
+	for _, m := range spec.Metadata {
+		metadata[m.Name] = m.Value
+	}
+
+	s := factory()
+	if err := s.Init(metadata); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Store is an EventStore that publishes all versions journaled by j to a sink
+type Store struct {
+	store.EventStore
+	name     string
+	sink     Sink
+	consumer *journal.Consumer
+}
+
+// NewStore creates a new outbox Store wrapping s and starts publishing the records of j.
+// name is the name of the Eventstore messages are published for.
+func NewStore(s store.EventStore, j *journal.Store, name string, sink Sink, backoff journal.Backoff) *Store {
+	o := &Store{
+		EventStore: s,
+		name:       name,
+		sink:       sink,
+	}
+
+	o.consumer = journal.NewConsumer(j, consumerName, backoff, o.publish)
+	o.consumer.Start()
+	return o
+}
+
+// Unwrap returns the decorated EventStore
+func (s *Store) Unwrap() store.EventStore {
+	return s.EventStore
+}
+
+// Close stops publishing and closes the sink
+func (s *Store) Close() error {
+	if err := s.consumer.Close(); err != nil {
+		return err
+	}
+
+	return s.sink.Close()
+}
+
+func (s *Store) publish(record journal.Record, entity *store.Entity) error {
+	return s.sink.Publish(Message{
+		Store:    s.name,
+		Position: record.Position,
+		Entity:   *entity,
+	})
+}
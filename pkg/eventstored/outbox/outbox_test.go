@@ -0,0 +1,227 @@
+package outbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+var testBackoff = journal.Backoff{
+	Min: time.Millisecond,
+	Max: 10 * time.Millisecond,
+}
+
+type messages struct {
+	mutex sync.Mutex
+	items []Message
+}
+
+func (m *messages) add(msg Message) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.items = append(m.items, msg)
+}
+
+func (m *messages) get() []Message {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]Message(nil), m.items...)
+}
+
+func newSinkSpec(sinkType string, metadata map[string]string) config.SinkSpec {
+	spec := config.SinkSpec{Type: sinkType}
+	for k, v := range metadata {
+		spec.Metadata = append(spec.Metadata, config.SpecMetadata{Name: k, Value: v})
+	}
+
+	return spec
+}
+
+func TestUnknownSink(t *testing.T) {
+	_, err := NewSink(config.SinkSpec{Type: "amqp"})
+	assert.NotNil(t, err)
+}
+
+func TestSinkMetadataMissing(t *testing.T) {
+	for _, sinkType := range []string{"http", "nats", "kafka"} {
+		_, err := NewSink(config.SinkSpec{Type: sinkType})
+		assert.NotNil(t, err, sinkType)
+	}
+}
+
+func TestStorePublishesWithRetries(t *testing.T) {
+	received := &messages{}
+	failures := 2
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		msg := Message{}
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Nil(t, json.Unmarshal(body, &msg))
+		received.add(msg)
+	}))
+	defer server.Close()
+
+	sink, err := NewSink(newSinkSpec("http", map[string]string{"url": server.URL}))
+	assert.Nil(t, err)
+
+	j := journal.NewStore(fakestore.NewStore())
+	s := NewStore(j, j, "orders", sink, testBackoff)
+
+	_, err = s.Add(&store.Entity{ID: "order-1", Data: "created"})
+	assert.Nil(t, err)
+	_, err = s.Append(&store.Entity{ID: "order-1", Data: "shipped"}, store.None)
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		return len(received.get()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, s.Close())
+
+	msgs := received.get()
+	assert.Equal(t, "orders", msgs[0].Store)
+	assert.Equal(t, int64(1), msgs[0].Position)
+	assert.Equal(t, "order-1", msgs[0].Entity.ID)
+	assert.Equal(t, int64(1), msgs[0].Entity.Version)
+	assert.Equal(t, int64(2), msgs[1].Entity.Version)
+	assert.Equal(t, "shipped", msgs[1].Entity.Data)
+
+	// restarting doesn't publish again
+	p, err := j.LoadCheckpoint(consumerName)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), p)
+}
+
+// serveNats is a stand-in NATS server, that records published payloads
+func serveNats(t *testing.T, listener net.Listener, received *messages) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func(conn net.Conn) {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n")) // nolint: errcheck
+
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+
+				fields := strings.Fields(line)
+				switch {
+				case len(fields) == 3 && fields[0] == "PUB":
+					size, _ := strconv.Atoi(fields[2])
+					payload := make([]byte, size+2)
+					if _, err := reader.Read(payload); err != nil {
+						return
+					}
+
+					msg := Message{}
+					assert.Nil(t, json.Unmarshal(payload[:size], &msg))
+					assert.Equal(t, "eventstore.orders", fields[1])
+					received.add(msg)
+				case len(fields) == 1 && fields[0] == "PING":
+					conn.Write([]byte("PONG\r\n")) // nolint: errcheck
+				}
+			}
+		}(conn)
+	}
+}
+
+func TestNatsSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	received := &messages{}
+	go serveNats(t, listener, received)
+
+	sink, err := NewSink(newSinkSpec("nats", map[string]string{
+		"url":     "nats://" + listener.Addr().String(),
+		"subject": "eventstore.orders",
+	}))
+	assert.Nil(t, err)
+	defer sink.Close()
+
+	for i := 1; i <= 2; i++ {
+		err = sink.Publish(Message{Store: "orders", Position: int64(i), Entity: store.Entity{ID: "order-1", Version: int64(i)}})
+		assert.Nil(t, err)
+	}
+
+	msgs := received.get()
+	assert.Equal(t, 2, len(msgs))
+	assert.Equal(t, int64(2), msgs[1].Entity.Version)
+}
+
+func TestNatsSinkServerUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	sink, err := NewSink(newSinkSpec("nats", map[string]string{
+		"url":     "nats://" + address,
+		"subject": "eventstore.orders",
+	}))
+	assert.Nil(t, err)
+
+	err = sink.Publish(Message{Store: "orders", Entity: store.Entity{ID: "order-1", Version: 1}})
+	assert.NotNil(t, err)
+}
+
+func TestKafkaSink(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("orders", 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t).
+			SetError("orders", 0, sarama.ErrNoError),
+	})
+
+	sink, err := NewSink(newSinkSpec("kafka", map[string]string{
+		"brokers": broker.Addr(),
+		"topic":   "orders",
+	}))
+	assert.Nil(t, err)
+
+	err = sink.Publish(Message{Store: "orders", Position: 1, Entity: store.Entity{ID: "order-1", Version: 1}})
+	assert.Nil(t, err)
+	assert.Nil(t, sink.Close())
+
+	produced := 0
+	for _, r := range broker.History() {
+		if _, ok := r.Request.(*sarama.ProduceRequest); ok {
+			produced++
+		}
+	}
+
+	assert.Equal(t, 1, produced)
+}
@@ -0,0 +1,453 @@
+package projection
+
+//---------------------------------------------------------------------------------------------
+// A projection folds the versions of each entity of a store into a read model, the state of the
+// entity. Projections follow the store's journal with a consumer per projection. The state of an
+// entity is appended to the system stream '$projection-<name>-<generation>-<id>' together with
+// the entity version it reflects, versions that are delivered again are skipped.
+//
+// A rebuild starts a new generation of the projection, that folds the journal from its beginning.
+// Queries are served from the previous generation until the new one reached the head of the
+// journal, then the generations are swapped and the streams of the previous generation are
+// deleted, if the backend can delete entities. The generations are recorded in the system
+// stream '$projection-<name>', the version of the entry that started a generation is its number.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+)
+
+const (
+	streamPrefix = streams.SystemPrefix + "projection-"
+	// a version the reducer fails on is recorded as dead letter of the generation's consumer
+	maxAttempts = 10
+)
+
+var (
+	// ErrProjectionNotFound is returned if the store has no projection with the name
+	ErrProjectionNotFound = errors.New("projection: projection not found")
+	// ErrStateNotFound is returned if the projection has no state for the entity
+	ErrStateNotFound = errors.New("projection: state not found")
+)
+
+// State is the state of an entity in a projection
+type State struct {
+	Projection string          `json:"projection"`
+	ID         string          `json:"id"`
+	Version    int64           `json:"version"`
+	State      json.RawMessage `json:"state"`
+}
+
+type record struct {
+	Version int64           `json:"version"`
+	State   json.RawMessage `json:"state"`
+}
+
+// generationEntry is an entry of the generation stream, entries without fields start a
+// generation that is served right away
+type generationEntry struct {
+	// Served is the generation queries are served from, if it isn't the entry's generation
+	Served int64 `json:"served,omitempty"`
+	// Building is true if the entry starts a generation that is rebuilt while Served is served
+	Building bool `json:"building,omitempty"`
+}
+
+type projection struct {
+	name    string
+	reducer Reducer
+	journal *journal.Store
+	backoff journal.Backoff
+	mutex   sync.RWMutex
+	// served is the generation queries are served from, building the generation that is
+	// rebuilt or 0
+	served   int64
+	building int64
+	consumer *journal.Consumer
+	rebuild  *journal.Consumer
+	// head is the head of the journal the rebuild has to reach
+	head   int64
+	closed bool
+	// retiring tracks the deletion of previous generations
+	retiring sync.WaitGroup
+}
+
+// Store is an EventStore that runs projections of the versions journaled by j
+type Store struct {
+	store.EventStore
+	projections map[string]*projection
+}
+
+// NewStore creates a new projection Store wrapping s and starts the projections of the specs
+func NewStore(s store.EventStore, j *journal.Store, specs []config.ProjectionSpec, backoff journal.Backoff) (*Store, error) {
+	ps := &Store{
+		EventStore:  s,
+		projections: map[string]*projection{},
+	}
+
+	for _, spec := range specs {
+		if spec.Name == "" {
+			ps.Close()
+			return nil, errors.New("projection: projection name is missing")
+		}
+
+		if _, ok := ps.projections[spec.Name]; ok {
+			ps.Close()
+			return nil, fmt.Errorf("projection: duplicate projection %s", spec.Name)
+		}
+
+		reducer, err := NewReducer(spec)
+		if err != nil {
+			ps.Close()
+			return nil, err
+		}
+
+		p := &projection{
+			name:    spec.Name,
+			reducer: reducer,
+			journal: j,
+			backoff: backoff,
+		}
+
+		served, building, err := p.loadGenerations()
+		if err != nil {
+			ps.Close()
+			return nil, err
+		}
+
+		if served == 0 {
+			ety, err := streams.Append(j.EventStore, p.generationStream(), generationEntry{})
+			if err != nil {
+				ps.Close()
+				return nil, err
+			}
+
+			served = ety.Version
+		}
+
+		ps.projections[spec.Name] = p
+		p.start(served, building)
+	}
+
+	return ps, nil
+}
+
+// From returns the projection Store in the chain of decorators of s
+func From(s store.EventStore) (*Store, bool) {
+	ps, ok := wrapper.Find(s, func(s store.EventStore) bool {
+		_, ok := s.(*Store)
+		return ok
+	}).(*Store)
+
+	return ps, ok
+}
+
+// Unwrap returns the decorated EventStore
+func (s *Store) Unwrap() store.EventStore {
+	return s.EventStore
+}
+
+// Get returns the state of an entity in a projection
+func (s *Store) Get(projection, id string) (*State, error) {
+	p, ok := s.projections[projection]
+	if !ok {
+		return nil, ErrProjectionNotFound
+	}
+
+	p.mutex.RLock()
+	generation := p.served
+	p.mutex.RUnlock()
+
+	r, err := p.load(p.stateStream(generation, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if r == nil {
+		return nil, ErrStateNotFound
+	}
+
+	return &State{
+		Projection: projection,
+		ID:         id,
+		Version:    r.Version,
+		State:      r.State,
+	}, nil
+}
+
+// Rebuild starts a new generation of a projection, that folds the journal from its beginning.
+// A rebuild that is in progress is replaced.
+func (s *Store) Rebuild(projection string) error {
+	p, ok := s.projections[projection]
+	if !ok {
+		return ErrProjectionNotFound
+	}
+
+	p.mutex.Lock()
+
+	ety, err := streams.Append(p.journal.EventStore, p.generationStream(), generationEntry{Served: p.served, Building: true})
+	if err != nil {
+		p.mutex.Unlock()
+		return err
+	}
+
+	replaced, replacedGeneration := p.rebuild, p.building
+	p.startRebuildLocked(ety.Version)
+	p.mutex.Unlock()
+
+	// closed without the lock, its last record may wait for it
+	if replaced != nil {
+		replaced.Close()
+		p.retire(replacedGeneration)
+	}
+
+	return p.checkCaughtUp(ety.Version)
+}
+
+// Close stops all projections
+func (s *Store) Close() error {
+	for _, p := range s.projections {
+		p.mutex.Lock()
+		p.closed = true
+		consumer, rebuild := p.consumer, p.rebuild
+		p.mutex.Unlock()
+
+		if rebuild != nil {
+			rebuild.Close()
+		}
+
+		consumer.Close()
+		p.retiring.Wait()
+	}
+
+	return nil
+}
+
+func (p *projection) start(served, building int64) {
+	p.mutex.Lock()
+	p.served = served
+	p.consumer = p.newConsumer(served, p.handler(served))
+	p.consumer.Start()
+
+	if building != 0 {
+		p.startRebuildLocked(building)
+	}
+	p.mutex.Unlock()
+
+	if building != 0 {
+		if err := p.checkCaughtUp(building); err != nil {
+			log.Printf("projection: %s can't check the rebuild of generation %d: %s\n", p.name, building, err)
+		}
+	}
+}
+
+func (p *projection) startRebuildLocked(generation int64) {
+	p.building = generation
+	p.head = 0
+
+	handle := p.handler(generation)
+	p.rebuild = p.newConsumer(generation, func(r journal.Record, entity *store.Entity) error {
+		if err := handle(r, entity); err != nil {
+			return err
+		}
+
+		return p.caughtUp(generation, r.Position)
+	})
+	p.rebuild.Start()
+}
+
+func (p *projection) newConsumer(generation int64, handle journal.Handler) *journal.Consumer {
+	return journal.NewConsumer(p.journal, p.consumerName(generation), p.backoff, handle).WithMaxAttempts(maxAttempts)
+}
+
+// checkCaughtUp swaps to the rebuilt generation if its consumer already reached the head of the
+// journal, e.g. because the journal is empty
+func (p *projection) checkCaughtUp(generation int64) error {
+	position, err := p.journal.LoadCheckpoint(p.consumerName(generation))
+	if err != nil {
+		return err
+	}
+
+	return p.caughtUp(generation, position)
+}
+
+// caughtUp swaps to the rebuilt generation once position reached the head of the journal
+func (p *projection) caughtUp(generation, position int64) error {
+	p.mutex.Lock()
+
+	if p.closed || p.building != generation || position < p.head {
+		p.mutex.Unlock()
+		return nil
+	}
+
+	head, err := p.journal.Head()
+	if err != nil {
+		p.mutex.Unlock()
+		return err
+	}
+
+	p.head = head
+	if position < head {
+		p.mutex.Unlock()
+		return nil
+	}
+
+	// an error retries the record, so the swap isn't lost
+	if _, err := streams.Append(p.journal.EventStore, p.generationStream(), generationEntry{Served: generation}); err != nil {
+		p.mutex.Unlock()
+		return err
+	}
+
+	previous, previousGeneration := p.consumer, p.served
+	p.served, p.consumer = generation, p.rebuild
+	p.building, p.rebuild = 0, nil
+	p.mutex.Unlock()
+
+	previous.Close()
+	p.retire(previousGeneration)
+	return nil
+}
+
+// retire deletes the streams of a generation that is no longer used in the background
+func (p *projection) retire(generation int64) {
+	p.retiring.Add(1)
+
+	go func() {
+		defer p.retiring.Done()
+
+		if err := p.deleteGeneration(generation); err != nil {
+			log.Printf("projection: failed to delete generation %d of %s: %s\n", generation, p.name, err)
+		}
+	}()
+}
+
+func (p *projection) deleteGeneration(generation int64) error {
+	ids := []string{}
+
+	ok, err := streams.ListIDs(p.journal.EventStore, p.stateStream(generation, ""), func(id string) error {
+		ids = append(ids, id)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.New("the backend can't list entities")
+	}
+
+	for _, id := range ids {
+		if ok, err := streams.Delete(p.journal.EventStore, id); err != nil {
+			return err
+		} else if !ok {
+			return errors.New("the backend can't delete entities")
+		}
+	}
+
+	if ok, err := p.journal.Forget(p.consumerName(generation)); err != nil {
+		return err
+	} else if !ok {
+		return errors.New("the backend can't delete entities")
+	}
+
+	return nil
+}
+
+// loadGenerations returns the served and the rebuilt generation recorded in the generation
+// stream, served is 0 if there is none yet
+func (p *projection) loadGenerations() (int64, int64, error) {
+	latest, err := streams.LatestVersion(p.journal.EventStore, p.generationStream())
+	if err != nil || latest == 0 {
+		return 0, 0, err
+	}
+
+	ety, err := p.journal.EventStore.GetByVersion(p.generationStream(), latest)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	e := generationEntry{}
+	if err := streams.Decode(ety, &e); err != nil {
+		return 0, 0, fmt.Errorf("projection: can't decode generation of %s: %s", p.name, err)
+	}
+
+	switch {
+	case e.Building:
+		return e.Served, latest, nil
+	case e.Served != 0:
+		return e.Served, 0, nil
+	default:
+		return latest, 0, nil
+	}
+}
+
+func (p *projection) handler(generation int64) journal.Handler {
+	return func(r journal.Record, entity *store.Entity) error {
+		stream := p.stateStream(generation, r.ID)
+
+		current, err := p.load(stream)
+		if err != nil {
+			return err
+		}
+
+		var state json.RawMessage
+
+		if current != nil {
+			if current.Version >= entity.Version {
+				return nil
+			}
+
+			state = current.State
+		}
+
+		next, err := p.reducer.Reduce(state, entity)
+		if err != nil {
+			return err
+		}
+
+		_, err = streams.Append(p.journal.EventStore, stream, record{Version: entity.Version, State: next})
+		return err
+	}
+}
+
+// load returns the latest record of a state stream, nil if there is none
+func (p *projection) load(stream string) (*record, error) {
+	latest, err := streams.LatestVersion(p.journal.EventStore, stream)
+	if err != nil || latest == 0 {
+		return nil, err
+	}
+
+	ety, err := p.journal.EventStore.GetByVersion(stream, latest)
+	if err != nil {
+		return nil, err
+	}
+
+	r := record{}
+	if err := streams.Decode(ety, &r); err != nil {
+		return nil, fmt.Errorf("projection: can't decode state of %s: %s", stream, err)
+	}
+
+	return &r, nil
+}
+
+func (p *projection) consumerName(generation int64) string {
+	return fmt.Sprintf("projection-%s-%d", p.name, generation)
+}
+
+func (p *projection) generationStream() string {
+	return streamPrefix + p.name
+}
+
+func (p *projection) stateStream(generation int64, id string) string {
+	return fmt.Sprintf("%s%s-%d-%s", streamPrefix, p.name, generation, id)
+}
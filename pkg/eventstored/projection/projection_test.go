@@ -0,0 +1,288 @@
+package projection
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/stretchr/testify/assert"
+)
+
+var testBackoff = journal.Backoff{
+	Min: time.Millisecond,
+	Max: 10 * time.Millisecond,
+}
+
+const testScript = `
+def reduce(state, entity):
+    if state == None:
+        state = {"count": 0, "items": []}
+    state["count"] += 1
+    state["items"].append(entity["data"]["item"])
+    return state
+`
+
+func TestMergePatchReducer(t *testing.T) {
+	r := mergePatchReducer{}
+
+	state, err := r.Reduce(nil, &store.Entity{Data: map[string]interface{}{"name": "order", "total": 1}})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"name":"order","total":1}`, string(state))
+
+	state, err = r.Reduce(state, &store.Entity{Data: map[string]interface{}{"total": 2, "name": nil}})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"total":2}`, string(state))
+
+	// data that is no object replaces the state
+	state, err = r.Reduce(state, &store.Entity{Data: "closed"})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `"closed"`, string(state))
+
+	state, err = r.Reduce(state, &store.Entity{Data: map[string]interface{}{"total": 3}})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"total":3}`, string(state))
+}
+
+func TestStarlarkReducer(t *testing.T) {
+	r, err := newStarlarkReducer(map[string]string{starlarkScriptKey: testScript})
+	assert.Nil(t, err)
+
+	state, err := r.Reduce(nil, &store.Entity{ID: "order-1", Version: 1, Data: map[string]interface{}{"item": "book"}})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"count":1,"items":["book"]}`, string(state))
+
+	state, err = r.Reduce(state, &store.Entity{ID: "order-1", Version: 2, Data: map[string]interface{}{"item": "pen"}})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"count":2,"items":["book","pen"]}`, string(state))
+}
+
+func TestStarlarkReducerErrors(t *testing.T) {
+	_, err := newStarlarkReducer(map[string]string{})
+	assert.NotNil(t, err)
+
+	_, err = newStarlarkReducer(map[string]string{starlarkScriptKey: "x = 1"})
+	assert.NotNil(t, err)
+
+	_, err = newStarlarkReducer(map[string]string{starlarkScriptKey: "def reduce(state"})
+	assert.NotNil(t, err)
+
+	r, err := newStarlarkReducer(map[string]string{starlarkScriptKey: "def reduce(state, entity):\n    return {1: 2}\n"})
+	assert.Nil(t, err)
+
+	_, err = r.Reduce(nil, &store.Entity{ID: "order-1", Version: 1})
+	assert.NotNil(t, err)
+
+	r, err = newStarlarkReducer(map[string]string{starlarkScriptKey: "def reduce(state, entity):\n    for x in range(100000000):\n        pass\n"})
+	assert.Nil(t, err)
+
+	_, err = r.Reduce(nil, &store.Entity{ID: "order-1", Version: 1})
+	assert.NotNil(t, err)
+}
+
+func TestNewStoreErrors(t *testing.T) {
+	j := journal.NewStore(fakestore.NewStore())
+
+	_, err := NewStore(j, j, []config.ProjectionSpec{{Name: "orders", Type: "unknown"}}, testBackoff)
+	assert.NotNil(t, err)
+
+	_, err = NewStore(j, j, []config.ProjectionSpec{{Type: "mergepatch"}}, testBackoff)
+	assert.NotNil(t, err)
+
+	_, err = NewStore(j, j, []config.ProjectionSpec{
+		{Name: "orders", Type: "mergepatch"},
+		{Name: "orders", Type: "mergepatch"},
+	}, testBackoff)
+	assert.NotNil(t, err)
+}
+
+func waitForState(t *testing.T, s *Store, projection, id string, version int64) *State {
+	var state *State
+
+	assert.Eventually(t, func() bool {
+		st, err := s.Get(projection, id)
+		if err != nil {
+			return false
+		}
+
+		state = st
+		return st.Version == version
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return state
+}
+
+func TestProjection(t *testing.T) {
+	j := journal.NewStore(fakestore.NewStore())
+
+	s, err := NewStore(j, j, []config.ProjectionSpec{{Name: "orders", Type: "mergepatch"}}, testBackoff)
+	assert.Nil(t, err)
+	defer s.Close()
+
+	_, err = s.Add(&store.Entity{ID: "order-1", Data: map[string]interface{}{"status": "created", "total": 1}})
+	assert.Nil(t, err)
+	_, err = s.Append(&store.Entity{ID: "order-1", Data: map[string]interface{}{"status": "paid"}}, store.None)
+	assert.Nil(t, err)
+
+	state := waitForState(t, s, "orders", "order-1", 2)
+	assert.Equal(t, "orders", state.Projection)
+	assert.Equal(t, "order-1", state.ID)
+	assert.JSONEq(t, `{"status":"paid","total":1}`, string(state.State))
+
+	_, err = s.Get("orders", "order-2")
+	assert.Equal(t, ErrStateNotFound, err)
+
+	_, err = s.Get("customers", "order-1")
+	assert.Equal(t, ErrProjectionNotFound, err)
+}
+
+func TestProjectionSkipsDeliveredVersions(t *testing.T) {
+	s := &projection{name: "orders", reducer: mergePatchReducer{}, journal: journal.NewStore(fakestore.NewStore())}
+	handle := s.handler(1)
+
+	assert.Nil(t, handle(journal.Record{Position: 1, ID: "order-1", Version: 1}, &store.Entity{ID: "order-1", Version: 1, Data: map[string]interface{}{"total": 1}}))
+	assert.Nil(t, handle(journal.Record{Position: 2, ID: "order-1", Version: 2}, &store.Entity{ID: "order-1", Version: 2, Data: map[string]interface{}{"total": 2}}))
+	// delivered again
+	assert.Nil(t, handle(journal.Record{Position: 1, ID: "order-1", Version: 1}, &store.Entity{ID: "order-1", Version: 1, Data: map[string]interface{}{"total": 1}}))
+
+	r, err := s.load(s.stateStream(1, "order-1"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), r.Version)
+	assert.JSONEq(t, `{"total":2}`, string(r.State))
+}
+
+func TestRebuild(t *testing.T) {
+	j := journal.NewStore(fakestore.NewStore())
+
+	s, err := NewStore(j, j, []config.ProjectionSpec{{Name: "orders", Type: "mergepatch"}}, testBackoff)
+	assert.Nil(t, err)
+
+	_, err = s.Add(&store.Entity{ID: "order-1", Data: map[string]interface{}{"total": 1}})
+	assert.Nil(t, err)
+	waitForState(t, s, "orders", "order-1", 1)
+
+	assert.Nil(t, s.Rebuild("orders"))
+	assert.Equal(t, ErrProjectionNotFound, s.Rebuild("customers"))
+
+	state := waitForState(t, s, "orders", "order-1", 1)
+	assert.JSONEq(t, `{"total":1}`, string(state.State))
+	assert.Nil(t, s.Close())
+
+	// a restarted store continues with the latest generation
+	s, err = NewStore(j, j, []config.ProjectionSpec{{Name: "orders", Type: "mergepatch"}}, testBackoff)
+	assert.Nil(t, err)
+	defer s.Close()
+
+	assert.Equal(t, int64(2), s.projections["orders"].served)
+
+	state = waitForState(t, s, "orders", "order-1", 1)
+	assert.JSONEq(t, `{"total":1}`, string(state.State))
+
+	raw, err := json.Marshal(state)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"projection":"orders","id":"order-1","version":1,"state":{"total":1}}`, string(raw))
+}
+
+// heldReducer is a merge patch reducer that waits while it's held
+type heldReducer struct {
+	held *int32
+}
+
+func (r heldReducer) Reduce(state json.RawMessage, entity *store.Entity) (json.RawMessage, error) {
+	for atomic.LoadInt32(r.held) == 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	return mergePatchReducer{}.Reduce(state, entity)
+}
+
+func TestRebuildServesThePreviousGenerationUntilCaughtUp(t *testing.T) {
+	held := int32(0)
+	reducers["held"] = func(map[string]string) (Reducer, error) { return heldReducer{held: &held}, nil }
+	defer delete(reducers, "held")
+
+	j := journal.NewStore(fakestore.NewStore())
+
+	s, err := NewStore(j, j, []config.ProjectionSpec{{Name: "orders", Type: "held"}}, testBackoff)
+	assert.Nil(t, err)
+	defer s.Close()
+
+	_, err = s.Add(&store.Entity{ID: "order-1", Data: map[string]interface{}{"total": 1}})
+	assert.Nil(t, err)
+	waitForState(t, s, "orders", "order-1", 1)
+
+	atomic.StoreInt32(&held, 1)
+	assert.Nil(t, s.Rebuild("orders"))
+
+	// the rebuild is held, queries are served from the first generation
+	time.Sleep(50 * time.Millisecond)
+	state, err := s.Get("orders", "order-1")
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"total":1}`, string(state.State))
+
+	p := s.projections["orders"]
+	p.mutex.RLock()
+	assert.Equal(t, int64(1), p.served)
+	assert.Equal(t, int64(2), p.building)
+	p.mutex.RUnlock()
+
+	atomic.StoreInt32(&held, 0)
+
+	assert.Eventually(t, func() bool {
+		p.mutex.RLock()
+		defer p.mutex.RUnlock()
+		return p.served == 2 && p.building == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	state, err = s.Get("orders", "order-1")
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"total":1}`, string(state.State))
+
+	// the first generation is deleted
+	assert.Eventually(t, func() bool {
+		v, err := streams.LatestVersion(j.EventStore, p.stateStream(1, "order-1"))
+		return err == nil && v == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		position, err := j.LoadCheckpoint(p.consumerName(1))
+		return err == nil && position == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRebuildResumesAfterRestart(t *testing.T) {
+	held := int32(1)
+	reducers["held"] = func(map[string]string) (Reducer, error) { return heldReducer{held: &held}, nil }
+	defer delete(reducers, "held")
+
+	j := journal.NewStore(fakestore.NewStore())
+
+	s, err := NewStore(j, j, []config.ProjectionSpec{{Name: "orders", Type: "held"}}, testBackoff)
+	assert.Nil(t, err)
+
+	_, err = s.Add(&store.Entity{ID: "order-1", Data: map[string]interface{}{"total": 1}})
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Rebuild("orders"))
+	atomic.StoreInt32(&held, 0)
+	assert.Nil(t, s.Close())
+
+	s, err = NewStore(j, j, []config.ProjectionSpec{{Name: "orders", Type: "held"}}, testBackoff)
+	assert.Nil(t, err)
+	defer s.Close()
+
+	p := s.projections["orders"]
+	assert.Eventually(t, func() bool {
+		p.mutex.RLock()
+		defer p.mutex.RUnlock()
+		return p.served == 2 && p.building == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	state := waitForState(t, s, "orders", "order-1", 1)
+	assert.JSONEq(t, `{"total":1}`, string(state.State))
+}
@@ -0,0 +1,62 @@
+package projection
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	jsonpatch "github.com/evanphx/json-patch"
+)
+
+// Reducer folds an entity version into the state of the entity, state is nil for the first version
+type Reducer interface {
+	Reduce(state json.RawMessage, entity *store.Entity) (json.RawMessage, error)
+}
+
+var reducers = map[string]func(metadata map[string]string) (Reducer, error){
+	"mergepatch": func(map[string]string) (Reducer, error) { return mergePatchReducer{}, nil },
+	"starlark":   newStarlarkReducer,
+}
+
+// NewReducer creates the reducer of a projection from its spec
+func NewReducer(spec config.ProjectionSpec) (Reducer, error) {
+	factory, ok := reducers[spec.Type]
+	if !ok {
+		return nil, fmt.Errorf("projection: unknown projection type %s", spec.Type)
+	}
+
+	metadata := map[string]string{}
+	for _, m := range spec.Metadata {
+		metadata[m.Name] = m.Value
+	}
+
+	return factory(metadata)
+}
+
+// mergePatchReducer applies the data of each entity version as JSON merge patch (RFC 7386) to the state
+type mergePatchReducer struct{}
+
+func (mergePatchReducer) Reduce(state json.RawMessage, entity *store.Entity) (json.RawMessage, error) {
+	patch, err := json.Marshal(entity.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	// a patch that is no object replaces the state, a state that is no object is patched like an empty object
+	if !isObject(patch) {
+		return patch, nil
+	}
+
+	if !isObject(state) {
+		state = json.RawMessage("{}")
+	}
+
+	return jsonpatch.MergePatch(state, patch)
+}
+
+func isObject(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '{'
+}
@@ -0,0 +1,212 @@
+package projection
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"go.starlark.net/starlark"
+)
+
+const (
+	starlarkScriptKey      = "script"
+	starlarkFunction       = "reduce"
+	starlarkMaxSteps       = 1000000
+	starlarkScriptFilename = "projection.star"
+)
+
+// starlarkReducer calls the function 'reduce(state, entity)' of a Starlark script for each entity version.
+// state is None for the first version, entity is a dict with the keys id, version, metadata and data.
+// The function returns the new state, state and entity data are converted from and to JSON.
+type starlarkReducer struct {
+	reduce starlark.Callable
+}
+
+func newStarlarkReducer(metadata map[string]string) (Reducer, error) {
+	script, ok := metadata[starlarkScriptKey]
+	if !ok || script == "" {
+		return nil, errors.New("projection: starlark script is missing")
+	}
+
+	globals, err := starlark.ExecFile(&starlark.Thread{Name: "init"}, starlarkScriptFilename, script, nil)
+	if err != nil {
+		return nil, fmt.Errorf("projection: can't load starlark script: %s", err)
+	}
+
+	reduce, ok := globals[starlarkFunction].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("projection: starlark script doesn't define function %s", starlarkFunction)
+	}
+
+	return &starlarkReducer{reduce: reduce}, nil
+}
+
+func (r *starlarkReducer) Reduce(state json.RawMessage, entity *store.Entity) (json.RawMessage, error) {
+	s := starlark.Value(starlark.None)
+
+	if len(state) > 0 {
+		v, err := decodeJSON(state)
+		if err != nil {
+			return nil, err
+		}
+
+		if s, err = toStarlark(v); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.Marshal(entity.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ety := starlark.NewDict(4)
+	ety.SetKey(starlark.String("id"), starlark.String(entity.ID))
+	ety.SetKey(starlark.String("version"), starlark.MakeInt64(entity.Version))
+	ety.SetKey(starlark.String("metadata"), starlark.String(entity.Metadata))
+
+	d, err := toStarlark(v)
+	if err != nil {
+		return nil, err
+	}
+
+	ety.SetKey(starlark.String("data"), d)
+
+	thread := &starlark.Thread{Name: entity.ID}
+	thread.SetMaxExecutionSteps(starlarkMaxSteps)
+
+	res, err := starlark.Call(thread, r.reduce, starlark.Tuple{s, ety}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("projection: starlark reduce failed: %s", err)
+	}
+
+	result, err := fromStarlark(res)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(result)
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	var v interface{}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func toStarlark(v interface{}) (starlark.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case json.Number:
+		if i, ok := new(big.Int).SetString(v.String(), 10); ok {
+			return starlark.MakeBigInt(i), nil
+		}
+
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+
+		return starlark.Float(f), nil
+	case []interface{}:
+		l := make([]starlark.Value, 0, len(v))
+		for _, e := range v {
+			sv, err := toStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+
+			l = append(l, sv)
+		}
+
+		return starlark.NewList(l), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		d := starlark.NewDict(len(v))
+		for _, k := range keys {
+			sv, err := toStarlark(v[k])
+			if err != nil {
+				return nil, err
+			}
+
+			d.SetKey(starlark.String(k), sv)
+		}
+
+		return d, nil
+	}
+
+	return nil, fmt.Errorf("projection: can't convert %T to starlark", v)
+}
+
+func fromStarlark(v starlark.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Int:
+		return json.Number(v.String()), nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.Indexable:
+		l := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+
+			l = append(l, e)
+		}
+
+		return l, nil
+	case *starlark.Dict:
+		m := map[string]interface{}{}
+		for _, item := range v.Items() {
+			k, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("projection: state has a key of type %s, keys must be strings", item[0].Type())
+			}
+
+			e, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+
+			m[k] = e
+		}
+
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("projection: can't convert %s to JSON", v.Type())
+}
@@ -0,0 +1,132 @@
+package resilience
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+)
+
+// deadlines of the calls in progress by goroutine. The chain of decorators is shared by all
+// requests and the backends have no notion of a context, so the deadline of a request reaches
+// the resilience Store at the bottom of the chain through the goroutine that serves it.
+var deadlines sync.Map
+
+// deadlineStore bounds all calls to the decorated EventStore by the deadline of a request
+type deadlineStore struct {
+	store.EventStore
+	deadline time.Time
+}
+
+// WithDeadline returns s with all calls returning ErrTimeout once deadline has passed. It's
+// meant to wrap the store for a single request. The calls of the resilience Store in the chain
+// of decorators of s are bounded by the deadline and aren't retried after it, a chain without a
+// resilience Store only checks the deadline before every call.
+func WithDeadline(s store.EventStore, deadline time.Time) store.EventStore {
+	return &deadlineStore{
+		EventStore: s,
+		deadline:   deadline,
+	}
+}
+
+// Unwrap returns the decorated EventStore
+func (s *deadlineStore) Unwrap() store.EventStore {
+	return s.EventStore
+}
+
+func (s *deadlineStore) Add(entity *store.Entity) (*store.Entity, error) {
+	defer s.begin()()
+
+	if s.expired() {
+		return nil, ErrTimeout
+	}
+
+	return s.EventStore.Add(entity)
+}
+
+func (s *deadlineStore) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	defer s.begin()()
+
+	if s.expired() {
+		return nil, ErrTimeout
+	}
+
+	return s.EventStore.Append(entity, concurrency)
+}
+
+func (s *deadlineStore) GetLatestVersionNumber(id string) (int64, error) {
+	defer s.begin()()
+
+	if s.expired() {
+		return 0, ErrTimeout
+	}
+
+	return s.EventStore.GetLatestVersionNumber(id)
+}
+
+func (s *deadlineStore) GetByVersion(id string, version int64) (*store.Entity, error) {
+	defer s.begin()()
+
+	if s.expired() {
+		return nil, ErrTimeout
+	}
+
+	return s.EventStore.GetByVersion(id, version)
+}
+
+func (s *deadlineStore) GetByVersionRange(id string, startVersion, endVersion int64) ([]store.Entity, error) {
+	defer s.begin()()
+
+	if s.expired() {
+		return nil, ErrTimeout
+	}
+
+	return s.EventStore.GetByVersionRange(id, startVersion, endVersion)
+}
+
+func (s *deadlineStore) expired() bool {
+	return !time.Now().Before(s.deadline)
+}
+
+// begin makes the deadline visible to the calls of the current goroutine, the returned function
+// restores the deadline of an enclosing call
+func (s *deadlineStore) begin() func() {
+	id := goroutineID()
+	previous, nested := deadlines.Load(id)
+	deadlines.Store(id, s.deadline)
+
+	return func() {
+		if nested {
+			deadlines.Store(id, previous)
+		} else {
+			deadlines.Delete(id)
+		}
+	}
+}
+
+// deadlineOf returns the deadline of the call of the current goroutine
+func deadlineOf() (time.Time, bool) {
+	d, ok := deadlines.Load(goroutineID())
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return d.(time.Time), true
+}
+
+// goroutineID parses the id of the current goroutine from the header of its stack trace,
+// 'goroutine 42 [running]:'
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
@@ -0,0 +1,394 @@
+package resilience
+
+//---------------------------------------------------------------------------------------------
+// The resilience decorator protects eventstored from slow and failing backends. Every call has
+// a timeout, transient failures are retried with jittered exponential backoff and a circuit
+// breaker fails calls fast while the backend keeps failing. Version conflicts, missing
+// entities and appends without concurrency control are never retried, a retried append
+// without concurrency control could write the same version twice.
+//
+// The backends have no notion of cancellation: a call that timed out keeps running in the
+// background and a write may still be applied after its timeout. Writes that timed out are
+// therefore not retried, a retry of a write that was applied fails with a version conflict.
+// Calls of a request with a deadline (see WithDeadline) are bounded by it and not retried
+// after it.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+)
+
+const (
+	// TimeoutKey is the store metadata key of the timeout of a single backend call, e.g. '5s'
+	TimeoutKey = "backendTimeout"
+	// RetriesKey is the store metadata key of the number of retries of a failed backend call
+	RetriesKey = "backendRetries"
+	// BreakerThresholdKey is the store metadata key of the number of consecutive failures that
+	// open the circuit breaker
+	BreakerThresholdKey = "breakerThreshold"
+	// BreakerCooldownKey is the store metadata key of the duration the circuit breaker stays
+	// open before a call is let through again, e.g. '30s'
+	BreakerCooldownKey = "breakerCooldown"
+
+	minBackoff = 50 * time.Millisecond
+	maxBackoff = 2 * time.Second
+)
+
+// ErrTimeout is returned when a backend call doesn't complete in time
+var ErrTimeout = errors.New("resilience: backend call timed out")
+
+// OpenError is returned while the circuit breaker is open
+type OpenError struct {
+	// RetryAfter is the remaining time until the breaker lets a call through again
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("resilience: circuit breaker is open, retry after %s", e.RetryAfter)
+}
+
+// Options configure the resilience of a store
+type Options struct {
+	Timeout          time.Duration
+	Retries          int
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// DefaultOptions are used for everything that isn't configured in the store metadata
+var DefaultOptions = Options{
+	Timeout:          10 * time.Second,
+	Retries:          3,
+	BreakerThreshold: 5,
+	BreakerCooldown:  30 * time.Second,
+}
+
+// OptionsFrom reads the Options from store metadata
+func OptionsFrom(metadata store.Metadata) (Options, error) {
+	options := DefaultOptions
+
+	durations := map[string]*time.Duration{
+		TimeoutKey:         &options.Timeout,
+		BreakerCooldownKey: &options.BreakerCooldown,
+	}
+
+	for k, d := range durations {
+		value, ok := metadata.Properties[k]
+		if !ok || value == "" {
+			continue
+		}
+
+		v, err := time.ParseDuration(value)
+		if err != nil || v <= 0 {
+			return options, fmt.Errorf("resilience: invalid %s '%s', expected a positive duration like '5s'", k, value)
+		}
+
+		*d = v
+	}
+
+	numbers := map[string]*int{
+		RetriesKey:          &options.Retries,
+		BreakerThresholdKey: &options.BreakerThreshold,
+	}
+
+	for k, n := range numbers {
+		value, ok := metadata.Properties[k]
+		if !ok || value == "" {
+			continue
+		}
+
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 0 {
+			return options, fmt.Errorf("resilience: invalid %s '%s', expected a number", k, value)
+		}
+
+		*n = v
+	}
+
+	return options, nil
+}
+
+// Store is an EventStore that applies timeouts, retries and a circuit breaker to the calls of
+// the decorated backend
+type Store struct {
+	store.EventStore
+	options Options
+	now     func() time.Time
+	sleep   func(time.Duration)
+	mutex   sync.Mutex
+	// consecutive failures
+	failures int
+	// the breaker is open until openUntil, a single trial call is let through afterwards
+	openUntil time.Time
+	trial     bool
+}
+
+// NewStore creates a new resilience Store wrapping the backend s
+func NewStore(s store.EventStore, options Options) *Store {
+	return &Store{
+		EventStore: s,
+		options:    options,
+		now:        time.Now,
+		sleep:      time.Sleep,
+	}
+}
+
+// From returns the resilience Store in the chain of decorators of s
+func From(s store.EventStore) (*Store, bool) {
+	rs, ok := wrapper.Find(s, func(s store.EventStore) bool {
+		_, ok := s.(*Store)
+		return ok
+	}).(*Store)
+
+	return rs, ok
+}
+
+// Unwrap returns the decorated EventStore
+func (s *Store) Unwrap() store.EventStore {
+	return s.EventStore
+}
+
+// Add adds the first version of an entity, a retried Add fails if an earlier attempt succeeded
+func (s *Store) Add(entity *store.Entity) (*store.Entity, error) {
+	res, err := s.do(true, true, func() (interface{}, error) {
+		// every attempt gets its own copy, a timed out attempt may still modify it
+		e := *entity
+		return s.EventStore.Add(&e)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*store.Entity), nil
+}
+
+// Append appends a new version of an entity, only appends with optimistic concurrency are retried
+func (s *Store) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	res, err := s.do(concurrency == store.Optimistic, true, func() (interface{}, error) {
+		e := *entity
+		return s.EventStore.Append(&e, concurrency)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*store.Entity), nil
+}
+
+// GetLatestVersionNumber returns the latest version of an entity
+func (s *Store) GetLatestVersionNumber(id string) (int64, error) {
+	res, err := s.do(true, false, func() (interface{}, error) {
+		return s.EventStore.GetLatestVersionNumber(id)
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return res.(int64), nil
+}
+
+// GetByVersion returns a version of an entity
+func (s *Store) GetByVersion(id string, version int64) (*store.Entity, error) {
+	res, err := s.do(true, false, func() (interface{}, error) {
+		return s.EventStore.GetByVersion(id, version)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*store.Entity), nil
+}
+
+// GetByVersionRange returns the versions of an entity in a range
+func (s *Store) GetByVersionRange(id string, startVersion, endVersion int64) ([]store.Entity, error) {
+	res, err := s.do(true, false, func() (interface{}, error) {
+		return s.EventStore.GetByVersionRange(id, startVersion, endVersion)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.([]store.Entity), nil
+}
+
+// do calls op with a timeout and retries transient failures, if retry is true. Writes aren't
+// retried after a timeout. The timeout and the retries are bounded by the deadline of the
+// request, if there is one.
+func (s *Store) do(retry, write bool, op func() (interface{}, error)) (interface{}, error) {
+	deadline, bounded := deadlineOf()
+
+	for attempt := 0; ; attempt++ {
+		timeout := s.options.Timeout
+		if bounded {
+			remaining := deadline.Sub(s.now())
+			if remaining <= 0 {
+				return nil, ErrTimeout
+			}
+
+			if remaining < timeout {
+				timeout = remaining
+			}
+		}
+
+		if err := s.allow(); err != nil {
+			return nil, err
+		}
+
+		res, err := withTimeout(timeout, op)
+
+		if timeout < s.options.Timeout && errors.Is(err, ErrTimeout) {
+			// the deadline of the request passed, that says nothing about the backend
+			s.abandon()
+			return res, err
+		}
+
+		s.record(err)
+
+		if !retry || !IsTransient(err) || attempt >= s.options.Retries {
+			return res, err
+		}
+
+		// a write that timed out may still be applied
+		if write && errors.Is(err, ErrTimeout) {
+			return res, err
+		}
+
+		delay := backoff(attempt)
+		if bounded && s.now().Add(delay).After(deadline) {
+			return res, err
+		}
+
+		s.sleep(delay)
+	}
+}
+
+// allow checks if the circuit breaker lets a call through
+func (s *Store) allow() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.openUntil.IsZero() {
+		return nil
+	}
+
+	now := s.now()
+	if now.Before(s.openUntil) || s.trial {
+		retryAfter := s.openUntil.Sub(now)
+		if retryAfter <= 0 {
+			// the trial call is still running
+			retryAfter = time.Second
+		}
+
+		return &OpenError{RetryAfter: retryAfter}
+	}
+
+	// half open, a single trial call decides if the breaker closes again
+	s.trial = true
+	return nil
+}
+
+// record updates the circuit breaker with the result of a call
+func (s *Store) record(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !IsTransient(err) {
+		s.failures = 0
+		s.openUntil = time.Time{}
+		s.trial = false
+		return
+	}
+
+	s.failures++
+
+	if s.trial || (s.options.BreakerThreshold > 0 && s.failures >= s.options.BreakerThreshold) {
+		s.openUntil = s.now().Add(s.options.BreakerCooldown)
+		s.trial = false
+	}
+}
+
+// abandon ends a call without a result for the circuit breaker, a trial call is let through
+// again
+func (s *Store) abandon() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.trial = false
+}
+
+// IsTransient checks if err is a failure of the backend that may go away with a retry: a
+// timeout, an internal error of the backend or a network error. Version conflicts, missing
+// entities and serialization errors are answers of a healthy backend, all other errors are
+// considered permanent.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrTimeout) {
+		return true
+	}
+
+	var evterr store.EventStoreError
+	if errors.As(err, &evterr) {
+		return evterr.ErrorType == store.InternalError
+	}
+
+	var neterr net.Error
+	if errors.As(err, &neterr) {
+		return true
+	}
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE)
+}
+
+// withTimeout calls op and returns ErrTimeout if it doesn't complete within timeout
+func withTimeout(timeout time.Duration, op func() (interface{}, error)) (interface{}, error) {
+	type result struct {
+		value interface{}
+		err   error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		v, err := op()
+		done <- result{v, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-timer.C:
+		return nil, ErrTimeout
+	}
+}
+
+// backoff returns a random delay up to an exponentially growing limit ("full jitter")
+func backoff(attempt int) time.Duration {
+	limit := minBackoff << uint(attempt)
+	if limit > maxBackoff || limit <= 0 {
+		limit = maxBackoff
+	}
+
+	return time.Duration(rand.Int63n(int64(limit)))
+}
@@ -0,0 +1,223 @@
+package resilience
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/stretchr/testify/assert"
+)
+
+var errThrottled = store.EventStoreError{Text: "throttled", ErrorType: store.InternalError}
+
+func newTestStore(options Options) (*Store, *fakestore.FaultyStore) {
+	faulty := fakestore.NewFaultyStore(fakestore.NewStore())
+	s := NewStore(faulty, options)
+	s.sleep = func(time.Duration) {}
+	return s, faulty
+}
+
+func TestRetriesTransientFailures(t *testing.T) {
+	s, faulty := newTestStore(DefaultOptions)
+
+	faulty.Inject(fakestore.Faults{Err: errThrottled, Failures: 2})
+
+	ety, err := s.Add(&store.Entity{ID: "order-1", Data: "one"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), ety.Version)
+	assert.Equal(t, 3, faulty.Calls())
+}
+
+func TestRetriesAreBounded(t *testing.T) {
+	s, faulty := newTestStore(Options{Timeout: time.Second, Retries: 2})
+
+	faulty.Inject(fakestore.Faults{Err: errThrottled, Failures: -1})
+
+	_, err := s.GetLatestVersionNumber("order-1")
+	assert.Equal(t, errThrottled, err)
+	assert.Equal(t, 3, faulty.Calls())
+}
+
+func TestVersionConflictsAreNotRetried(t *testing.T) {
+	s, faulty := newTestStore(DefaultOptions)
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "one"})
+	assert.Nil(t, err)
+
+	_, err = s.Append(&store.Entity{ID: "order-1", Version: 1, Data: "two"}, store.Optimistic)
+	assert.Nil(t, err)
+
+	_, err = s.Append(&store.Entity{ID: "order-1", Version: 1, Data: "three"}, store.Optimistic)
+	assert.Equal(t, store.VersionConflict, err.(store.EventStoreError).ErrorType)
+	assert.Equal(t, 3, faulty.Calls())
+}
+
+func TestAppendsWithoutConcurrencyControlAreNotRetried(t *testing.T) {
+	s, faulty := newTestStore(DefaultOptions)
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "one"})
+	assert.Nil(t, err)
+
+	faulty.Inject(fakestore.Faults{Err: errThrottled, Failures: 1})
+
+	_, err = s.Append(&store.Entity{ID: "order-1", Data: "two"}, store.None)
+	assert.Equal(t, errThrottled, err)
+	assert.Equal(t, 2, faulty.Calls())
+}
+
+func TestTimeout(t *testing.T) {
+	s, faulty := newTestStore(Options{Timeout: 10 * time.Millisecond})
+
+	faulty.Inject(fakestore.Faults{Latency: 200 * time.Millisecond})
+
+	_, err := s.GetByVersion("order-1", 1)
+	assert.Equal(t, ErrTimeout, err)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	s, faulty := newTestStore(Options{Timeout: time.Second, BreakerThreshold: 2, BreakerCooldown: 30 * time.Second})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "one"})
+	assert.Nil(t, err)
+
+	faulty.Inject(fakestore.Faults{Err: errThrottled, Failures: -1})
+
+	for i := 0; i < 2; i++ {
+		_, err = s.GetLatestVersionNumber("order-1")
+		assert.Equal(t, errThrottled, err)
+	}
+
+	// the breaker is open, calls fail without reaching the backend
+	calls := faulty.Calls()
+	_, err = s.GetLatestVersionNumber("order-1")
+
+	var openErr *OpenError
+	assert.True(t, errors.As(err, &openErr))
+	assert.Equal(t, 30*time.Second, openErr.RetryAfter)
+	assert.Equal(t, calls, faulty.Calls())
+
+	// a failing trial call opens the breaker again
+	now = now.Add(31 * time.Second)
+	_, err = s.GetLatestVersionNumber("order-1")
+	assert.Equal(t, errThrottled, err)
+
+	_, err = s.GetLatestVersionNumber("order-1")
+	assert.True(t, errors.As(err, &openErr))
+
+	// a successful trial call closes it
+	now = now.Add(31 * time.Second)
+	faulty.Inject(fakestore.Faults{})
+
+	v, err := s.GetLatestVersionNumber("order-1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), v)
+
+	_, err = s.GetByVersion("order-1", 1)
+	assert.Nil(t, err)
+}
+
+func TestMissingEntitiesDontOpenTheBreaker(t *testing.T) {
+	s, _ := newTestStore(Options{Timeout: time.Second, BreakerThreshold: 1, BreakerCooldown: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		_, err := s.GetLatestVersionNumber("order-1")
+		assert.Equal(t, store.EntityNotFound, err.(store.EventStoreError).ErrorType)
+	}
+}
+
+func TestWithDeadline(t *testing.T) {
+	s, faulty := newTestStore(DefaultOptions)
+
+	_, err := WithDeadline(s, time.Now().Add(-time.Second)).GetLatestVersionNumber("order-1")
+	assert.Equal(t, ErrTimeout, err)
+	assert.Equal(t, 0, faulty.Calls())
+
+	faulty.Inject(fakestore.Faults{Latency: 200 * time.Millisecond})
+
+	_, err = WithDeadline(s, time.Now().Add(10*time.Millisecond)).GetLatestVersionNumber("order-1")
+	assert.Equal(t, ErrTimeout, err)
+}
+
+func TestTimedOutWritesAreNotRetried(t *testing.T) {
+	s, faulty := newTestStore(Options{Timeout: 10 * time.Millisecond, Retries: 3})
+
+	faulty.Inject(fakestore.Faults{Latency: 50 * time.Millisecond})
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: "one"})
+	assert.Equal(t, ErrTimeout, err)
+	assert.Equal(t, 1, faulty.Calls())
+
+	// the write was applied after its timeout
+	faulty.Inject(fakestore.Faults{})
+	assert.Eventually(t, func() bool {
+		v, err := s.GetLatestVersionNumber("order-1")
+		return err == nil && v == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRetriesStopAtTheDeadline(t *testing.T) {
+	s, faulty := newTestStore(Options{Timeout: time.Second, Retries: 100})
+
+	faulty.Inject(fakestore.Faults{Latency: 20 * time.Millisecond, Err: errThrottled, Failures: -1})
+
+	start := time.Now()
+	_, err := WithDeadline(s, start.Add(100*time.Millisecond)).GetLatestVersionNumber("order-1")
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+
+	// nothing keeps retrying in the background
+	time.Sleep(50 * time.Millisecond)
+	calls := faulty.Calls()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, calls, faulty.Calls())
+	assert.True(t, calls < 10)
+}
+
+func TestDeadlinesDontOpenTheBreaker(t *testing.T) {
+	s, faulty := newTestStore(Options{Timeout: time.Second, BreakerThreshold: 1, BreakerCooldown: time.Minute})
+
+	faulty.Inject(fakestore.Faults{Latency: 50 * time.Millisecond})
+
+	_, err := WithDeadline(s, time.Now().Add(10*time.Millisecond)).GetLatestVersionNumber("order-1")
+	assert.Equal(t, ErrTimeout, err)
+
+	_, err = s.GetLatestVersionNumber("order-1")
+	assert.Equal(t, store.EntityNotFound, err.(store.EventStoreError).ErrorType)
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.False(t, IsTransient(nil))
+	assert.True(t, IsTransient(ErrTimeout))
+	assert.True(t, IsTransient(errThrottled))
+	assert.True(t, IsTransient(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}))
+	assert.True(t, IsTransient(fmt.Errorf("read: %w", io.ErrUnexpectedEOF)))
+	assert.False(t, IsTransient(store.EventStoreError{ErrorType: store.VersionConflict}))
+	assert.False(t, IsTransient(&OpenError{RetryAfter: time.Second}))
+	assert.False(t, IsTransient(errors.New("invalid metadata")))
+}
+
+func TestOptionsFrom(t *testing.T) {
+	options, err := OptionsFrom(store.Metadata{Properties: map[string]string{}})
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultOptions, options)
+
+	options, err = OptionsFrom(store.Metadata{Properties: map[string]string{
+		TimeoutKey:          "2s",
+		RetriesKey:          "0",
+		BreakerThresholdKey: "10",
+		BreakerCooldownKey:  "1m",
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, Options{Timeout: 2 * time.Second, Retries: 0, BreakerThreshold: 10, BreakerCooldown: time.Minute}, options)
+
+	_, err = OptionsFrom(store.Metadata{Properties: map[string]string{TimeoutKey: "soon"}})
+	assert.NotNil(t, err)
+}
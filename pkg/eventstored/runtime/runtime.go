@@ -5,13 +5,16 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
 
 	kubernetesConfig "github.com/AndreasM009/eventstore/pkg/eventstored/config/kubernetes"
 	standaloneConfig "github.com/AndreasM009/eventstore/pkg/eventstored/config/standalone"
 
-	"github.com/AndreasM009/eventstore-impl/store"
 	"github.com/AndreasM009/eventstore/pkg/eventstored/eventstore"
 	"github.com/AndreasM009/eventstore/pkg/eventstored/http"
 )
@@ -19,14 +22,21 @@ import (
 const (
 	modeKubernetes = "kubernetes"
 	modeStandalone = "standalone"
+
+	configPollInterval = 2 * time.Second
+
+	// apiTokenEnv is the environment variable of the token that authorizes imports
+	apiTokenEnv = "EVENTSTORE_API_TOKEN"
 )
 
 var (
-	modeFlag              = flag.String("mode", "standalone", "Run mode: 'standalone' or 'kubernetes'")
-	portFlag              = flag.Int("port", 5000, "Server port to use")
-	configFilePathFlag    = flag.String("config", "", "Path to config file (standalone only).")
-	eventStoreNamesFlags  = flag.String("eventstores", "", "Comma separated names of eventstores that are associated with the Application Pod (Kubernetes only).")
-	operatorEndpointFlags = flag.String("operatorendpoint", "", "Endpoint of operator control plane (kubernetes only).")
+	modeFlag               = flag.String("mode", "standalone", "Run mode: 'standalone' or 'kubernetes'")
+	portFlag               = flag.Int("port", 5000, "Server port to use")
+	configFilePathFlag     = flag.String("config", "", "Path to config file or directory of config files (standalone only).")
+	eventStoreNamesFlags   = flag.String("eventstores", "", "Comma separated names of eventstores that are associated with the Application Pod (Kubernetes only).")
+	operatorEndpointFlags  = flag.String("operatorendpoint", "", "Endpoint of operator control plane (kubernetes only).")
+	namespaceFlag          = flag.String("namespace", os.Getenv("POD_NAMESPACE"), "Namespace of the eventstores, defaults to $POD_NAMESPACE (kubernetes only).")
+	maxRequestBodySizeFlag = flag.Int("maxrequestbodysize", 64*1024*1024, "Maximum size of a request body in bytes, e.g. of an imported archive.")
 )
 
 // Runtime interface to run an EventStore
@@ -38,8 +48,10 @@ type Runtime interface {
 type runtime struct {
 	started  bool
 	registry eventstore.Registry
-	stores   map[string]store.EventStore
+	stores   *eventstore.Stores
 	server   http.Server
+	watcher  *standaloneConfig.Watcher
+	follower config.ConfigurationWatcher
 }
 
 // NewRuntime creates a new EventStore runtime
@@ -55,14 +67,14 @@ func (r *runtime) FromFlags() error {
 
 	switch *modeFlag {
 	case modeStandalone:
-		cfgProvider := standaloneConfig.NewStandalone(*configFilePathFlag)
+		r.watcher = standaloneConfig.NewWatcher(*configFilePathFlag, configPollInterval)
 
-		cfg, err = cfgProvider.LoadConfig()
+		cfg, err = r.watcher.LoadConfig()
 		if err != nil {
 			log.Println(err)
 		}
 	case modeKubernetes:
-		cfgProvider, err := kubernetesConfig.NewKubernetes(*eventStoreNamesFlags, *operatorEndpointFlags)
+		cfgProvider, err := kubernetesConfig.NewKubernetes(*eventStoreNamesFlags, *namespaceFlag, *operatorEndpointFlags)
 		if err != nil {
 			return err
 		}
@@ -70,17 +82,24 @@ func (r *runtime) FromFlags() error {
 		if err != nil {
 			return err
 		}
+
+		if follower, ok := cfgProvider.(config.ConfigurationWatcher); ok {
+			r.follower = follower
+		}
 	default:
 		return fmt.Errorf("runtime: unknown runtime mode %s", *modeFlag)
 	}
 
 	r.registry = eventstore.NewRegistry()
-	r.stores, err = r.registry.CreateFromConfiguration(cfg)
-	if err != nil {
+	r.stores = eventstore.NewStores()
+	if err := r.stores.Apply(r.registry, cfg); err != nil {
 		log.Printf("runtime: %s\n", err)
 	}
 
-	r.server = http.NewServer(*portFlag, r.stores, r.registry)
+	r.server = http.NewServer(*portFlag, r.stores, r.registry, http.Options{
+		MaxRequestBodySize: *maxRequestBodySizeFlag,
+		APIToken:           os.Getenv(apiTokenEnv),
+	})
 
 	return nil
 }
@@ -97,6 +116,26 @@ func (r *runtime) Start() error {
 
 	r.server.StartNonBlocking()
 	r.started = true
+
+	apply := func(cfg []config.Configuration) {
+		if err := r.stores.Apply(r.registry, cfg); err != nil {
+			log.Printf("runtime: %s\n", err)
+		}
+	}
+
+	if r.watcher != nil {
+		// the config file is reloaded when it changes or on SIGHUP
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+
+		go r.watcher.Run(hup, nil, apply)
+	}
+
+	if r.follower != nil {
+		// the configuration is followed in addition to the pushes of the operator
+		go r.follower.Watch(nil, apply)
+	}
+
 	log.Printf("runtime: Started on port %v\n", *portFlag)
 	return nil
 }
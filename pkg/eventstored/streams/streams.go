@@ -0,0 +1,216 @@
+package streams
+
+//---------------------------------------------------------------------------------------------
+// System streams are entities that are maintained by eventstored itself, e.g. category
+// indexes. They live in the same backend as the application's entities and are marked with
+// a reserved id prefix, so they can't be written by clients.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+)
+
+const (
+	// SystemPrefix is the reserved id prefix of system streams
+	SystemPrefix = "$"
+
+	maxAppendAttempts = 10
+)
+
+// IsSystemID checks if id is reserved for a system stream
+func IsSystemID(id string) bool {
+	return strings.HasPrefix(id, SystemPrefix)
+}
+
+// IsNotFound checks if err is an EventStoreError of type EntityNotFound
+func IsNotFound(err error) bool {
+	evterr, ok := err.(store.EventStoreError)
+	return ok && evterr.ErrorType == store.EntityNotFound
+}
+
+// IsVersionConflict checks if err is an EventStoreError of type VersionConflict
+func IsVersionConflict(err error) bool {
+	evterr, ok := err.(store.EventStoreError)
+	return ok && evterr.ErrorType == store.VersionConflict
+}
+
+// Lister is implemented by backends that can enumerate the ids of their entities
+type Lister interface {
+	// ListIDs calls fn with the id of every entity that starts with prefix, it stops at the
+	// first error of fn
+	ListIDs(prefix string, fn func(id string) error) error
+}
+
+// Deleter is implemented by backends that can delete entities with all their versions
+type Deleter interface {
+	// Delete deletes the entity id, deleting a missing entity is not an error
+	Delete(id string) error
+}
+
+// ListIDs calls fn with the ids of all entities starting with prefix of the first EventStore in the
+// chain of decorators of s that implements Lister. ok is false if none does.
+func ListIDs(s store.EventStore, prefix string, fn func(id string) error) (ok bool, err error) {
+	lister, ok := listerOf(s)
+	if !ok {
+		return false, nil
+	}
+
+	return true, lister.ListIDs(prefix, fn)
+}
+
+// CanList checks if an EventStore in the chain of decorators of s implements Lister
+func CanList(s store.EventStore) bool {
+	_, ok := listerOf(s)
+	return ok
+}
+
+func listerOf(s store.EventStore) (Lister, bool) {
+	lister, ok := wrapper.Find(s, func(s store.EventStore) bool {
+		_, ok := s.(Lister)
+		return ok
+	}).(Lister)
+
+	return lister, ok
+}
+
+// Delete deletes the entity id in the first EventStore in the chain of decorators of s that
+// implements Deleter. ok is false if none does.
+func Delete(s store.EventStore, id string) (ok bool, err error) {
+	deleter, ok := wrapper.Find(s, func(s store.EventStore) bool {
+		_, ok := s.(Deleter)
+		return ok
+	}).(Deleter)
+
+	if !ok {
+		return false, nil
+	}
+
+	return true, deleter.Delete(id)
+}
+
+// LatestVersion returns the latest version of the stream id or 0 if the stream doesn't exist yet.
+func LatestVersion(s store.EventStore, id string) (int64, error) {
+	v, err := s.GetLatestVersionNumber(id)
+	if err == nil {
+		return v, nil
+	}
+
+	// not all backends return typed errors, everything that isn't typed is treated as not found
+	if evterr, ok := err.(store.EventStoreError); ok && evterr.ErrorType != store.EntityNotFound {
+		return 0, err
+	}
+
+	return 0, nil
+}
+
+// Append appends data as new version to the stream id, the stream is created if it doesn't exist.
+// Appends are done with optimistic concurrency and retried on conflicts, so concurrent writers never
+// overwrite each other's versions.
+func Append(s store.EventStore, id string, data interface{}) (*store.Entity, error) {
+	var lasterr error
+
+	for i := 0; i < maxAppendAttempts; i++ {
+		latest, err := LatestVersion(s, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if latest == 0 {
+			ety, err := s.Add(&store.Entity{ID: id, Data: data})
+			if err == nil {
+				return ety, nil
+			}
+
+			// most likely created by someone else in the meantime
+			lasterr = err
+			continue
+		}
+
+		ety, err := s.Append(&store.Entity{ID: id, Version: latest, Data: data}, store.Optimistic)
+		if err == nil {
+			return ety, nil
+		}
+
+		if evterr, ok := err.(store.EventStoreError); ok && evterr.ErrorType != store.VersionConflict {
+			return nil, err
+		}
+
+		lasterr = err
+	}
+
+	return nil, fmt.Errorf("streams: giving up appending to %s after %d attempts: %s", id, maxAppendAttempts, lasterr)
+}
+
+// AppendVersion writes entity as exactly its version, which must follow the latest version of the
+// entity in s. It is used to copy entities between stores with their versions.
+func AppendVersion(s store.EventStore, entity *store.Entity) error {
+	var res *store.Entity
+	var err error
+
+	if entity.Version == 1 {
+		res, err = s.Add(&store.Entity{ID: entity.ID, Metadata: entity.Metadata, Data: entity.Data})
+	} else {
+		res, err = s.Append(&store.Entity{ID: entity.ID, Version: entity.Version - 1, Metadata: entity.Metadata, Data: entity.Data}, store.Optimistic)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if res.Version != entity.Version {
+		return fmt.Errorf("streams: version %v of %s was written as version %v", entity.Version, entity.ID, res.Version)
+	}
+
+	return nil
+}
+
+// ReadRange reads the versions from..to of the stream id in version order. Versions missing
+// in the range result of the backend are read one by one, as not all backends support ranges.
+func ReadRange(s store.EventStore, id string, from, to int64) ([]store.Entity, error) {
+	if to < from {
+		return []store.Entity{}, nil
+	}
+
+	etys, err := s.GetByVersionRange(id, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]store.Entity, len(etys))
+	for _, e := range etys {
+		byVersion[e.Version] = e
+	}
+
+	result := make([]store.Entity, 0, to-from+1)
+
+	for v := from; v <= to; v++ {
+		if e, ok := byVersion[v]; ok {
+			result = append(result, e)
+			continue
+		}
+
+		e, err := s.GetByVersion(id, v)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, *e)
+	}
+
+	return result, nil
+}
+
+// Decode decodes the data of a system stream entity into v
+func Decode(ety *store.Entity, v interface{}) error {
+	data, err := json.Marshal(ety.Data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
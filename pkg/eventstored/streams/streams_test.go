@@ -0,0 +1,109 @@
+package streams
+
+import (
+	"testing"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore-impl/store/inmemory"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSystemID(t *testing.T) {
+	assert.True(t, IsSystemID("$ce-order"))
+	assert.False(t, IsSystemID("order-1"))
+}
+
+func TestAppendCreatesStream(t *testing.T) {
+	s := fakestore.NewStore()
+
+	ety, err := Append(s, "$test", "one")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), ety.Version)
+
+	ety, err = Append(s, "$test", "two")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), ety.Version)
+}
+
+func TestLatestVersionOfMissingStream(t *testing.T) {
+	v, err := LatestVersion(fakestore.NewStore(), "$test")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), v)
+}
+
+func TestReadRangeWithoutBackendSupport(t *testing.T) {
+	// the in memory store of eventstore-impl doesn't implement ranges
+	s := inmemory.NewStore()
+	assert.Nil(t, s.Init(store.Metadata{}))
+
+	for i := 0; i < 3; i++ {
+		_, err := Append(s, "$test", i)
+		assert.Nil(t, err)
+	}
+
+	etys, err := ReadRange(s, "$test", 2, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(etys))
+	assert.Equal(t, int64(2), etys[0].Version)
+	assert.Equal(t, int64(3), etys[1].Version)
+}
+
+func TestDecode(t *testing.T) {
+	s := fakestore.NewStore()
+
+	_, err := Append(s, "$test", map[string]interface{}{"id": "order-1", "version": 2})
+	assert.Nil(t, err)
+
+	ety, err := s.GetByVersion("$test", 1)
+	assert.Nil(t, err)
+
+	v := struct {
+		ID      string `json:"id"`
+		Version int64  `json:"version"`
+	}{}
+
+	assert.Nil(t, Decode(ety, &v))
+	assert.Equal(t, "order-1", v.ID)
+	assert.Equal(t, int64(2), v.Version)
+}
+
+func TestListIDsAndDeleteFindTheBackend(t *testing.T) {
+	backend := fakestore.NewStore()
+	s := fakestore.NewFaultyStore(backend)
+
+	_, err := Append(s, "$test", "one")
+	assert.Nil(t, err)
+	assert.True(t, CanList(s))
+
+	ids := []string{}
+	ok, err := ListIDs(s, SystemPrefix, func(id string) error {
+		ids = append(ids, id)
+		return nil
+	})
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"$test"}, ids)
+
+	ok, err = Delete(s, "$test")
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	v, err := LatestVersion(backend, "$test")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), v)
+}
+
+func TestListIDsAndDeleteWithoutBackendSupport(t *testing.T) {
+	s := inmemory.NewStore()
+	assert.Nil(t, s.Init(store.Metadata{}))
+	assert.False(t, CanList(s))
+
+	ok, err := ListIDs(s, "", func(string) error { return nil })
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	ok, err = Delete(s, "$test")
+	assert.False(t, ok)
+	assert.Nil(t, err)
+}
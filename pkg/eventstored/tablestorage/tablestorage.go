@@ -0,0 +1,163 @@
+package tablestorage
+
+//---------------------------------------------------------------------------------------------
+// The backend of eventstore.azure.tablestorage is the Table Storage store of eventstore-impl,
+// extended to enumerate and delete its entities. Each entity is a partition of the entity table
+// with a row 'latestVersion' and a row per version, keyed by the version number.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore-impl/store/azure/tablestorage"
+	"github.com/Azure/azure-sdk-for-go/storage"
+)
+
+// the layout of the table, it must match the one of eventstore-impl
+const (
+	entityTableName     = "eventstoreentities"
+	storageAccountName  = "storageAccountName"
+	storageAccountKey   = "storageAccountKey"
+	tableNameSuffix     = "tableNameSuffix"
+	latestVersionRowKey = "latestVersion"
+
+	timeout      = 10
+	maxBatchSize = 100
+)
+
+// Store is the Azure Table Storage EventStore that can enumerate and delete entities
+type Store struct {
+	store.EventStore
+	table *storage.Table
+}
+
+// NewStore creates a new Azure Table Storage store
+func NewStore() *Store {
+	return &Store{
+		EventStore: tablestorage.NewStore(),
+	}
+}
+
+// Init initializes the store and creates the entity table if it doesn't exist
+func (s *Store) Init(metadata store.Metadata) error {
+	if err := s.EventStore.Init(metadata); err != nil {
+		return err
+	}
+
+	client, err := storage.NewBasicClient(metadata.Properties[storageAccountName], metadata.Properties[storageAccountKey])
+	if err != nil {
+		return err
+	}
+
+	tables := client.GetTableService()
+	s.table = tables.GetTableReference(entityTableName + metadata.Properties[tableNameSuffix])
+	return nil
+}
+
+// ListIDs calls fn with the ids of all entities that start with prefix, in the order of their ids
+func (s *Store) ListIDs(prefix string, fn func(id string) error) error {
+	result, err := s.table.QueryEntities(timeout, storage.MinimalMetadata, &storage.QueryOptions{
+		Filter: listFilter(prefix),
+		Select: []string{"PartitionKey"},
+	})
+
+	for {
+		if err != nil {
+			return fmt.Errorf("azure tablestorage: can't list entities: %s", err)
+		}
+
+		for _, e := range result.Entities {
+			// partitions are returned in order, the ones after the prefix follow
+			if !strings.HasPrefix(e.PartitionKey, prefix) {
+				return nil
+			}
+
+			if err := fn(e.PartitionKey); err != nil {
+				return err
+			}
+		}
+
+		if result.NextLink == nil {
+			return nil
+		}
+
+		result, err = result.NextResults(nil)
+	}
+}
+
+// Delete deletes the entity id with all its versions. The entity is gone once its latest version
+// row is deleted, which fails if the entity was written in the meantime. The version rows are
+// deleted afterwards, they keep a concurrent Add of the same id failing until they are gone.
+func (s *Store) Delete(id string) error {
+	latest := s.table.GetEntityReference(id, latestVersionRowKey)
+
+	if err := latest.Get(timeout, storage.MinimalMetadata, nil); err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+
+		return fmt.Errorf("azure tablestorage: can't delete %s: %s", id, err)
+	}
+
+	if err := latest.Delete(false, nil); err != nil {
+		return fmt.Errorf("azure tablestorage: can't delete %s: %s", id, err)
+	}
+
+	result, err := s.table.QueryEntities(timeout, storage.MinimalMetadata, &storage.QueryOptions{
+		Filter: fmt.Sprintf("PartitionKey eq '%s'", quote(id)),
+		Select: []string{"PartitionKey", "RowKey"},
+	})
+
+	for {
+		if err != nil {
+			return fmt.Errorf("azure tablestorage: can't delete versions of %s: %s", id, err)
+		}
+
+		for start := 0; start < len(result.Entities); start += maxBatchSize {
+			end := start + maxBatchSize
+			if end > len(result.Entities) {
+				end = len(result.Entities)
+			}
+
+			batch := s.table.NewBatch()
+			for _, e := range result.Entities[start:end] {
+				// with the ETag, versions of an entity that was added again aren't deleted
+				batch.DeleteEntity(e, false)
+			}
+
+			if err := batch.ExecuteBatch(); err != nil {
+				return fmt.Errorf("azure tablestorage: can't delete versions of %s: %s", id, err)
+			}
+		}
+
+		if result.NextLink == nil {
+			return nil
+		}
+
+		result, err = result.NextResults(nil)
+	}
+}
+
+// listFilter selects the latest version rows of the entities starting with prefix
+func listFilter(prefix string) string {
+	filter := fmt.Sprintf("RowKey eq '%s'", latestVersionRowKey)
+
+	if prefix != "" {
+		filter += fmt.Sprintf(" and PartitionKey ge '%s'", quote(prefix))
+	}
+
+	return filter
+}
+
+// quote escapes a string literal of a filter
+func quote(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+func isNotFound(err error) bool {
+	serr, ok := err.(storage.AzureStorageServiceError)
+	return ok && serr.StatusCode == http.StatusNotFound
+}
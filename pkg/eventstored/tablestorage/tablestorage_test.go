@@ -0,0 +1,61 @@
+package tablestorage
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListFilter(t *testing.T) {
+	assert.Equal(t, "RowKey eq 'latestVersion'", listFilter(""))
+	assert.Equal(t, "RowKey eq 'latestVersion' and PartitionKey ge '$idem-'", listFilter("$idem-"))
+	assert.Equal(t, "RowKey eq 'latestVersion' and PartitionKey ge 'o''brien'", listFilter("o'brien"))
+}
+
+// TestListIDsAndDelete runs against Azurite or a storage account like the conformance suite
+func TestListIDsAndDelete(t *testing.T) {
+	account := os.Getenv("EVENTSTORE_TABLESTORAGE_ACCOUNT")
+	if account == "" {
+		t.Skip("EVENTSTORE_TABLESTORAGE_ACCOUNT is not set")
+	}
+
+	s := NewStore()
+	assert.Nil(t, s.Init(store.Metadata{
+		Properties: map[string]string{
+			storageAccountName: account,
+			storageAccountKey:  os.Getenv("EVENTSTORE_TABLESTORAGE_KEY"),
+			tableNameSuffix:    "conformance",
+		},
+	}))
+
+	prefix := fmt.Sprintf("list-%d-", time.Now().UnixNano())
+
+	for _, id := range []string{prefix + "2", prefix + "1"} {
+		_, err := s.Add(&store.Entity{ID: id, Data: 1})
+		assert.Nil(t, err)
+		_, err = s.Append(&store.Entity{ID: id, Data: 2}, store.None)
+		assert.Nil(t, err)
+	}
+
+	ids := []string{}
+	assert.Nil(t, s.ListIDs(prefix, func(id string) error {
+		ids = append(ids, id)
+		return nil
+	}))
+	assert.Equal(t, []string{prefix + "1", prefix + "2"}, ids)
+
+	assert.Nil(t, s.Delete(prefix+"1"))
+	assert.Nil(t, s.Delete(prefix+"1"))
+
+	_, err := s.GetLatestVersionNumber(prefix + "1")
+	assert.NotNil(t, err)
+
+	// the id can be used again
+	ety, err := s.Add(&store.Entity{ID: prefix + "1", Data: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), ety.Version)
+}
@@ -0,0 +1,305 @@
+package timestamps
+
+//---------------------------------------------------------------------------------------------
+// Write timestamps are recorded per entity in the system stream '$ts-<id>'. Each entry holds
+// an entity version and the server time it was committed at. Entries are appended after the
+// write with optimistic concurrency and kept sorted by version and time: an entry is stamped no
+// earlier than its predecessor, and a version whose entry comes after the one of a later version
+// is covered by it, as it was written before. So lookups are binary searches over single entries.
+// A version whose entry couldn't be appended is unindexed, until the entry of a later version
+// covers it; lookups that depend on it fail.
+//---------------------------------------------------------------------------------------------
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+)
+
+const (
+	// IndexKey is the store metadata key that enables write timestamps
+	IndexKey = "timestampIndex"
+	// StreamPrefix is the prefix of timestamp streams, followed by the entity id
+	StreamPrefix = streams.SystemPrefix + "ts-"
+
+	maxRecordAttempts = 10
+)
+
+// ErrUnindexed is returned by lookups that depend on versions whose write timestamps weren't recorded
+var ErrUnindexed = errors.New("timestamps: the latest versions of the entity have no write timestamp")
+
+type indexEntry struct {
+	Version   int64     `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Store is an EventStore that records the write timestamps of all entity versions written through it
+type Store struct {
+	store.EventStore
+	now func() time.Time
+}
+
+// NewStore creates a new timestamp Store wrapping s
+func NewStore(s store.EventStore) *Store {
+	return &Store{
+		EventStore: s,
+		now:        time.Now,
+	}
+}
+
+// From returns the timestamp Store in the chain of decorators of s
+func From(s store.EventStore) (*Store, bool) {
+	ts, ok := wrapper.Find(s, func(s store.EventStore) bool {
+		_, ok := s.(*Store)
+		return ok
+	}).(*Store)
+
+	return ts, ok
+}
+
+// Unwrap returns the decorated EventStore
+func (s *Store) Unwrap() store.EventStore {
+	return s.EventStore
+}
+
+// Add adds a new entity and records the write timestamp of its first version
+func (s *Store) Add(entity *store.Entity) (*store.Entity, error) {
+	res, err := s.EventStore.Add(entity)
+	if err != nil {
+		return res, err
+	}
+
+	if err := s.record(res); err != nil {
+		// the entity is written, its version stays unindexed until a later one is recorded
+		log.Printf("timestamps: failed to record write timestamp of version %v of %s: %s\n", res.Version, res.ID, err)
+	}
+
+	return res, nil
+}
+
+// Append appends a new version of an entity and records its write timestamp
+func (s *Store) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	res, err := s.EventStore.Append(entity, concurrency)
+	if err != nil {
+		return res, err
+	}
+
+	if err := s.record(res); err != nil {
+		// the entity is written, its version stays unindexed until a later one is recorded
+		log.Printf("timestamps: failed to record write timestamp of version %v of %s: %s\n", res.Version, res.ID, err)
+	}
+
+	return res, nil
+}
+
+// VersionAt returns the highest version of entity id that was written at or before t.
+// An error is returned if the entity didn't exist at that time.
+func (s *Store) VersionAt(id string, t time.Time) (int64, error) {
+	n, err := streams.LatestVersion(s.EventStore, StreamPrefix+id)
+	if err != nil {
+		return 0, err
+	}
+
+	// highest position with timestamp <= t
+	position, err := s.search(id, n, func(e indexEntry) bool {
+		return !e.Timestamp.After(t)
+	}, true)
+	if err != nil {
+		return 0, err
+	}
+
+	e := indexEntry{}
+	if position > 0 {
+		if e, err = s.entry(id, position); err != nil {
+			return 0, err
+		}
+	}
+
+	if position == n {
+		// versions written after the last entry may be written before t, too
+		if err := s.checkIndexed(id, e.Version); err != nil {
+			return 0, err
+		}
+	}
+
+	if position == 0 {
+		return 0, fmt.Errorf("entity %s did not exist at %s", id, t.Format(time.RFC3339))
+	}
+
+	return e.Version, nil
+}
+
+// VersionRange returns the first and last version of entity id that were written between from and to,
+// both inclusive. False is returned if no version was written in that period.
+func (s *Store) VersionRange(id string, from, to time.Time) (int64, int64, bool, error) {
+	n, err := streams.LatestVersion(s.EventStore, StreamPrefix+id)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	// lowest position with timestamp >= from
+	first, err := s.search(id, n, func(e indexEntry) bool {
+		return !e.Timestamp.Before(from)
+	}, false)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	// highest position with timestamp <= to
+	last, err := s.search(id, n, func(e indexEntry) bool {
+		return !e.Timestamp.After(to)
+	}, true)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	if last == n {
+		// versions written after the last entry may be written before to, too
+		indexed := int64(0)
+		if n > 0 {
+			e, err := s.entry(id, n)
+			if err != nil {
+				return 0, 0, false, err
+			}
+
+			indexed = e.Version
+		}
+
+		if err := s.checkIndexed(id, indexed); err != nil {
+			return 0, 0, false, err
+		}
+	}
+
+	if first == 0 || last == 0 || first > last {
+		return 0, 0, false, nil
+	}
+
+	start, err := s.entry(id, first)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	end, err := s.entry(id, last)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	return start.Version, end.Version, true, nil
+}
+
+// checkIndexed returns ErrUnindexed if entity id has versions after the indexed one
+func (s *Store) checkIndexed(id string, indexed int64) error {
+	latest, err := streams.LatestVersion(s.EventStore, id)
+	if err != nil {
+		return err
+	}
+
+	if latest > indexed {
+		return fmt.Errorf("%w: %s is indexed up to version %v of %v", ErrUnindexed, id, indexed, latest)
+	}
+
+	return nil
+}
+
+// search does a binary search over the positions 1..n of the timestamp stream of id. With highest
+// set, the highest position that satisfies match is returned, otherwise the lowest one. match must
+// be monotonic. 0 is returned if no position matches.
+func (s *Store) search(id string, n int64, match func(indexEntry) bool, highest bool) (int64, error) {
+	lo, hi := int64(1), n
+	result := int64(0)
+
+	for lo <= hi {
+		mid := lo + (hi-lo)/2
+
+		e, err := s.entry(id, mid)
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case match(e) && highest:
+			result = mid
+			lo = mid + 1
+		case match(e):
+			result = mid
+			hi = mid - 1
+		case highest:
+			hi = mid - 1
+		default:
+			lo = mid + 1
+		}
+	}
+
+	return result, nil
+}
+
+func (s *Store) entry(id string, position int64) (indexEntry, error) {
+	e := indexEntry{}
+
+	ety, err := s.EventStore.GetByVersion(StreamPrefix+id, position)
+	if err != nil {
+		return e, err
+	}
+
+	if err := streams.Decode(ety, &e); err != nil {
+		return e, fmt.Errorf("timestamps: can't decode entry %v of %s: %s", position, id, err)
+	}
+
+	return e, nil
+}
+
+// record appends the entry of the version of entity to its timestamp stream, stamped no earlier
+// than the last entry. If a later version was recorded first, its entry covers this version.
+func (s *Store) record(entity *store.Entity) error {
+	if streams.IsSystemID(entity.ID) {
+		return nil
+	}
+
+	var lasterr error
+
+	for i := 0; i < maxRecordAttempts; i++ {
+		entry := indexEntry{
+			Version:   entity.Version,
+			Timestamp: s.now().UTC(),
+		}
+
+		n, err := streams.LatestVersion(s.EventStore, StreamPrefix+entity.ID)
+		if err != nil {
+			return err
+		}
+
+		if n > 0 {
+			last, err := s.entry(entity.ID, n)
+			if err != nil {
+				return err
+			}
+
+			if last.Version >= entity.Version {
+				return nil
+			}
+
+			if entry.Timestamp.Before(last.Timestamp) {
+				entry.Timestamp = last.Timestamp
+			}
+		}
+
+		err = streams.AppendVersion(s.EventStore, &store.Entity{ID: StreamPrefix + entity.ID, Version: n + 1, Data: entry})
+		if err == nil {
+			return nil
+		}
+
+		// the first entry is added, not all backends report a conflict for an existing entity
+		if n > 0 && !streams.IsVersionConflict(err) {
+			return err
+		}
+
+		lasterr = err
+	}
+
+	return fmt.Errorf("timestamps: giving up recording version %v of %s after %d attempts: %s", entity.Version, entity.ID, maxRecordAttempts, lasterr)
+}
@@ -0,0 +1,180 @@
+package timestamps
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/fakestore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/streams"
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// createTestStore writes version n of entity 'order-1' at start + n days
+func createTestStore(t *testing.T, versions int) *Store {
+	s := NewStore(fakestore.NewStore())
+	now := start
+
+	s.now = func() time.Time {
+		return now
+	}
+
+	for i := 1; i <= versions; i++ {
+		now = start.AddDate(0, 0, i)
+
+		var err error
+		if i == 1 {
+			_, err = s.Add(&store.Entity{ID: "order-1", Data: i})
+		} else {
+			_, err = s.Append(&store.Entity{ID: "order-1", Data: i}, store.None)
+		}
+
+		assert.Nil(t, err)
+	}
+
+	return s
+}
+
+func TestVersionAt(t *testing.T) {
+	s := createTestStore(t, 10)
+
+	v, err := s.VersionAt("order-1", start.AddDate(0, 0, 1))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), v)
+
+	v, err = s.VersionAt("order-1", start.AddDate(0, 0, 5).Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), v)
+
+	v, err = s.VersionAt("order-1", start.AddDate(1, 0, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), v)
+}
+
+func TestVersionAtBeforeCreation(t *testing.T) {
+	s := createTestStore(t, 3)
+
+	_, err := s.VersionAt("order-1", start)
+	assert.NotNil(t, err)
+
+	_, err = s.VersionAt("order-2", start)
+	assert.NotNil(t, err)
+}
+
+func TestVersionRange(t *testing.T) {
+	s := createTestStore(t, 10)
+
+	first, last, found, err := s.VersionRange("order-1", start.AddDate(0, 0, 3), start.AddDate(0, 0, 6))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(3), first)
+	assert.Equal(t, int64(6), last)
+
+	first, last, found, err = s.VersionRange("order-1", start.AddDate(0, 0, 3).Add(time.Hour), start.AddDate(0, 0, 4).Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	first, last, found, err = s.VersionRange("order-1", start, start.AddDate(1, 0, 0))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(1), first)
+	assert.Equal(t, int64(10), last)
+}
+
+func TestEntriesAreRecordedInOrder(t *testing.T) {
+	s := NewStore(fakestore.NewStore())
+
+	for i := 1; i <= 5; i++ {
+		assert.Nil(t, streams.AppendVersion(s.EventStore, &store.Entity{ID: "order-1", Version: int64(i), Data: i}))
+	}
+
+	// concurrent writers record version 3 before version 2, the clock of the writer of version 4 is behind
+	for _, e := range []indexEntry{
+		{Version: 1, Timestamp: start.AddDate(0, 0, 1)},
+		{Version: 3, Timestamp: start.AddDate(0, 0, 3)},
+		{Version: 2, Timestamp: start.AddDate(0, 0, 2)},
+		{Version: 4, Timestamp: start.AddDate(0, 0, 2).Add(time.Hour)},
+		{Version: 5, Timestamp: start.AddDate(0, 0, 5)},
+	} {
+		now := e.Timestamp
+		s.now = func() time.Time { return now }
+		assert.Nil(t, s.record(&store.Entity{ID: "order-1", Version: e.Version}))
+	}
+
+	etys, err := streams.ReadRange(s.EventStore, StreamPrefix+"order-1", 1, 4)
+	assert.Nil(t, err)
+
+	recorded := []indexEntry{}
+	for i := range etys {
+		e := indexEntry{}
+		assert.Nil(t, streams.Decode(&etys[i], &e))
+		recorded = append(recorded, e)
+	}
+
+	// version 2 is covered by version 3, version 4 isn't stamped before version 3
+	assert.Equal(t, []indexEntry{
+		{Version: 1, Timestamp: start.AddDate(0, 0, 1)},
+		{Version: 3, Timestamp: start.AddDate(0, 0, 3)},
+		{Version: 4, Timestamp: start.AddDate(0, 0, 3)},
+		{Version: 5, Timestamp: start.AddDate(0, 0, 5)},
+	}, recorded)
+
+	v, err := s.VersionAt("order-1", start.AddDate(0, 0, 3))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), v)
+
+	first, last, found, err := s.VersionRange("order-1", start.AddDate(0, 0, 2), start.AddDate(0, 0, 4))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(3), first)
+	assert.Equal(t, int64(4), last)
+}
+
+// failingIndex fails the appends to timestamp streams while failing is set
+type failingIndex struct {
+	store.EventStore
+	failing bool
+}
+
+func (s *failingIndex) Append(entity *store.Entity, concurrency store.ConcurrencyControl) (*store.Entity, error) {
+	if s.failing && streams.IsSystemID(entity.ID) {
+		return nil, errors.New("backend unavailable")
+	}
+
+	return s.EventStore.Append(entity, concurrency)
+}
+
+func TestUnindexedVersionsFailLookups(t *testing.T) {
+	backend := &failingIndex{EventStore: fakestore.NewStore()}
+	s := NewStore(backend)
+	now := start.AddDate(0, 0, 1)
+	s.now = func() time.Time { return now }
+
+	_, err := s.Add(&store.Entity{ID: "order-1", Data: 1})
+	assert.Nil(t, err)
+
+	backend.failing = true
+	now = start.AddDate(0, 0, 2)
+	_, err = s.Append(&store.Entity{ID: "order-1", Data: 2}, store.None)
+	assert.Nil(t, err, "the entity is written")
+
+	// version 2 may be written at any time after version 1
+	_, err = s.VersionAt("order-1", start.AddDate(0, 0, 3))
+	assert.True(t, errors.Is(err, ErrUnindexed))
+
+	_, _, _, err = s.VersionRange("order-1", start, start.AddDate(0, 0, 3))
+	assert.True(t, errors.Is(err, ErrUnindexed))
+
+	// the entry of version 3 covers version 2
+	backend.failing = false
+	now = start.AddDate(0, 0, 4)
+	_, err = s.Append(&store.Entity{ID: "order-1", Data: 3}, store.None)
+	assert.Nil(t, err)
+
+	v, err := s.VersionAt("order-1", start.AddDate(0, 0, 4))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), v)
+}
@@ -0,0 +1,49 @@
+package wrapper
+
+import (
+	"io"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+)
+
+// Wrapper is implemented by EventStores that decorate another EventStore
+type Wrapper interface {
+	Unwrap() store.EventStore
+}
+
+// Find walks the chain of decorated EventStores, starting with s, and returns
+// the first one for which match returns true. Nil is returned if none matches.
+func Find(s store.EventStore, match func(store.EventStore) bool) store.EventStore {
+	for s != nil {
+		if match(s) {
+			return s
+		}
+
+		w, ok := s.(Wrapper)
+		if !ok {
+			return nil
+		}
+
+		s = w.Unwrap()
+	}
+
+	return nil
+}
+
+// Close closes all EventStores in the chain of decorated EventStores that implement io.Closer,
+// starting with s. The first error is returned.
+func Close(s store.EventStore) error {
+	var result error
+
+	Find(s, func(s store.EventStore) bool {
+		if c, ok := s.(io.Closer); ok {
+			if err := c.Close(); err != nil && result == nil {
+				result = err
+			}
+		}
+
+		return false
+	})
+
+	return result
+}
@@ -0,0 +1,131 @@
+package injector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	"github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1beta1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// handleConversion converts Eventstores between the versions of the API for the api server. The
+// review is answered in the version of apiextensions it was sent with, v1 and v1beta1 share their
+// format.
+func (i *injector) handleConversion(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil || len(data) == 0 {
+		log.Println("injector: empty conversion request body received")
+		http.Error(w, "Empty request body", http.StatusBadRequest)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		log.Printf("injector: request Content-Type=%s, expect application/json\n", contentType)
+		http.Error(w, "invalid Content-Type, expect `application/json`", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	review := apiextensionsv1.ConversionReview{}
+	if err := json.Unmarshal(data, &review); err != nil || review.Request == nil {
+		log.Printf("injector: Can't decode ConversionReview: %v\n", err)
+		http.Error(w, "invalid ConversionReview", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = convertEventstores(review.Request)
+	review.Request = nil
+
+	response, err := json.Marshal(review)
+	if err != nil {
+		log.Printf("injector: can't serialize response ConversionReview: %s", err)
+		http.Error(w, fmt.Sprintf("can't serialize response: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(response); err != nil {
+		log.Printf("injector: cant write ConversionReview response: %s", err)
+	}
+}
+
+// convertEventstores converts all objects of req to its desired version, or none of them
+func convertEventstores(req *apiextensionsv1.ConversionRequest) *apiextensionsv1.ConversionResponse {
+	response := &apiextensionsv1.ConversionResponse{UID: req.UID}
+
+	for _, obj := range req.Objects {
+		converted, err := convertEventstore(obj.Raw, req.DesiredAPIVersion)
+		if err != nil {
+			log.Printf("injector: can't convert Eventstore to %s: %s\n", req.DesiredAPIVersion, err)
+
+			response.ConvertedObjects = nil
+			response.Result = metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: err.Error(),
+			}
+
+			return response
+		}
+
+		response.ConvertedObjects = append(response.ConvertedObjects, runtime.RawExtension{Raw: converted})
+	}
+
+	response.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return response
+}
+
+// convertEventstore converts the serialized Eventstore raw to apiVersion
+func convertEventstore(raw []byte, apiVersion string) ([]byte, error) {
+	typeMeta := metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, fmt.Errorf("can't deserialize object: %s", err)
+	}
+
+	if typeMeta.Kind != eventstoreKind.Kind {
+		return nil, fmt.Errorf("can't convert kind %s", typeMeta.Kind)
+	}
+
+	if typeMeta.APIVersion == apiVersion {
+		return raw, nil
+	}
+
+	alpha := v1alpha1.SchemeGroupVersion.String()
+	beta := v1beta1.SchemeGroupVersion.String()
+
+	switch {
+	case typeMeta.APIVersion == alpha && apiVersion == beta:
+		src := &v1alpha1.Eventstore{}
+		if err := json.Unmarshal(raw, src); err != nil {
+			return nil, fmt.Errorf("can't deserialize Eventstore from json: %s", err)
+		}
+
+		dst := &v1beta1.Eventstore{}
+		if err := src.ConvertTo(dst); err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(dst)
+	case typeMeta.APIVersion == beta && apiVersion == alpha:
+		src := &v1beta1.Eventstore{}
+		if err := json.Unmarshal(raw, src); err != nil {
+			return nil, fmt.Errorf("can't deserialize Eventstore from json: %s", err)
+		}
+
+		dst := &v1alpha1.Eventstore{}
+		if err := dst.ConvertFrom(src); err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(dst)
+	default:
+		return nil, fmt.Errorf("can't convert %s to %s", typeMeta.APIVersion, apiVersion)
+	}
+}
@@ -0,0 +1,94 @@
+package injector
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	"github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1beta1"
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func convert(t *testing.T, desiredAPIVersion string, objects ...interface{}) *apiextensionsv1.ConversionResponse {
+	req := apiextensionsv1.ConversionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "ConversionReview"},
+		Request: &apiextensionsv1.ConversionRequest{
+			UID:               "conversion-uid",
+			DesiredAPIVersion: desiredAPIVersion,
+		},
+	}
+
+	for _, obj := range objects {
+		raw, _ := json.Marshal(obj)
+		req.Request.Objects = append(req.Request.Objects, runtime.RawExtension{Raw: raw})
+	}
+
+	body, _ := json.Marshal(req)
+	request := httptest.NewRequest(http.MethodPost, "/convert", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	NewInjector().(*injector).server.Handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	response := apiextensionsv1.ConversionReview{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "apiextensions.k8s.io/v1", response.APIVersion)
+	assert.Nil(t, response.Request)
+	assert.Equal(t, "conversion-uid", string(response.Response.UID))
+
+	return response.Response
+}
+
+func TestHandleConversionConvertsBothWays(t *testing.T) {
+	es := testEventstore("eventstore.azure.tablestorage",
+		v1alpha1.MetadataItem{Name: "storageAccountName", Value: "account"},
+		v1alpha1.MetadataItem{Name: "storageAccountKey", SecretKeyRef: v1alpha1.SecretKeyRef{Name: "storage", Key: "key"}},
+	)
+
+	response := convert(t, "eventstore.io/v1beta1", es)
+	assert.Equal(t, metav1.StatusSuccess, response.Result.Status)
+	assert.Len(t, response.ConvertedObjects, 1)
+
+	beta := &v1beta1.Eventstore{}
+	assert.Nil(t, json.Unmarshal(response.ConvertedObjects[0].Raw, beta))
+	assert.Equal(t, "eventstore.io/v1beta1", beta.APIVersion)
+	assert.Equal(t, "account", beta.Spec.AzureTableStorage.AccountName.Value)
+	assert.Equal(t, "storage", beta.Spec.AzureTableStorage.AccountKey.SecretKeyRef.Name)
+
+	response = convert(t, "eventstore.io/v1alpha1", beta)
+	assert.Equal(t, metav1.StatusSuccess, response.Result.Status)
+
+	alpha := &v1alpha1.Eventstore{}
+	assert.Nil(t, json.Unmarshal(response.ConvertedObjects[0].Raw, alpha))
+	assert.Equal(t, es.Spec, alpha.Spec)
+}
+
+func TestHandleConversionFailsForAllObjects(t *testing.T) {
+	invalid := &v1beta1.Eventstore{
+		TypeMeta: metav1.TypeMeta{APIVersion: "eventstore.io/v1beta1", Kind: "Eventstore"},
+		Spec: v1beta1.EventstoreSpec{Backend: v1beta1.Backend{
+			InMemory: &v1beta1.InMemoryBackend{},
+			Chaos:    &v1beta1.ChaosBackend{},
+		}},
+	}
+
+	response := convert(t, "eventstore.io/v1alpha1", testEventstore("eventstore.inmemory"), invalid)
+
+	assert.Equal(t, metav1.StatusFailure, response.Result.Status)
+	assert.Contains(t, response.Result.Message, "inMemory, chaos")
+	assert.Empty(t, response.ConvertedObjects)
+}
+
+func TestHandleConversionRejectsOtherKinds(t *testing.T) {
+	pod := metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"}
+
+	response := convert(t, "eventstore.io/v1beta1", pod)
+	assert.Equal(t, metav1.StatusFailure, response.Result.Status)
+}
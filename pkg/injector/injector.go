@@ -3,12 +3,14 @@ package injector
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"time"
 
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
 	"k8s.io/api/admission/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -43,6 +45,8 @@ func NewInjector() Injector {
 	}
 
 	mux.HandleFunc("/mutate", i.handleRequest)
+	mux.HandleFunc("/validate", i.handleValidation)
+	mux.HandleFunc(v1alpha1.ConversionPath, i.handleConversion)
 	return i
 }
 
@@ -81,51 +85,23 @@ func (i *injector) Run(ctx context.Context) error {
 }
 
 func (i *injector) handleRequest(w http.ResponseWriter, r *http.Request) {
-	defer r.Body.Close()
-
-	var data []byte
 	var admissionResponse *v1beta1.AdmissionResponse
 	var patchOps []PatchOperation
-	var err error
-	admissionReview := v1beta1.AdmissionReview{}
-
-	// read and check body
-	if r.Body != nil {
-		if d, err := ioutil.ReadAll(r.Body); err == nil {
-			data = d
-		}
-	}
 
-	if len(data) == 0 {
-		log.Println("injector: empty request body received")
-		http.Error(w, "Empty request body", http.StatusBadRequest)
-		return
-	}
-
-	// check Content-Type
-	contentType := r.Header.Get("Content-Type")
-	if contentType != "application/json" {
-		log.Printf("injector: request Content-Type=%s, expect application/json\n", contentType)
-		http.Error(w, "invalid Content-Type, expect `application/json`", http.StatusUnsupportedMediaType)
-		return
-	}
-
-	// deserialize review
-	if _, _, err = i.deserializer.Decode(data, nil, &admissionReview); err != nil {
-		log.Printf("injector: Can't decode body: %v\n", err)
-		respondWithError(nil, w, err)
+	admissionReview, ok := i.readAdmissionReview(w, r)
+	if !ok {
 		return
 	}
 
 	if admissionReview.Request.Kind.Kind != "Pod" {
 		log.Printf("injector: invalid kind for review: %s", admissionReview.Kind)
-		respondWithError(&admissionReview, w, fmt.Errorf("invalid kind of review: %s", admissionReview.Kind))
+		respondWithError(admissionReview, w, fmt.Errorf("invalid kind of review: %s", admissionReview.Kind))
 		return
 	}
 
 	pod, err := deserializePod(admissionReview.Request)
 	if err != nil {
-		respondWithError(&admissionReview, w, err)
+		respondWithError(admissionReview, w, err)
 		return
 	}
 
@@ -142,7 +118,7 @@ func (i *injector) handleRequest(w http.ResponseWriter, r *http.Request) {
 		jsonPatch, err := json.Marshal(patchOps)
 
 		if err != nil {
-			respondWithError(&admissionReview, w, err)
+			respondWithError(admissionReview, w, err)
 			return
 		}
 
@@ -166,6 +142,50 @@ func (i *injector) handleRequest(w http.ResponseWriter, r *http.Request) {
 	writeAdmissionResponse(&arResponse, w, http.StatusOK)
 }
 
+// readAdmissionReview reads the AdmissionReview of a request, ok is false if an error was
+// responded
+func (i *injector) readAdmissionReview(w http.ResponseWriter, r *http.Request) (*v1beta1.AdmissionReview, bool) {
+	defer r.Body.Close()
+
+	var data []byte
+	admissionReview := v1beta1.AdmissionReview{}
+
+	// read and check body
+	if r.Body != nil {
+		if d, err := ioutil.ReadAll(r.Body); err == nil {
+			data = d
+		}
+	}
+
+	if len(data) == 0 {
+		log.Println("injector: empty request body received")
+		http.Error(w, "Empty request body", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// check Content-Type
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		log.Printf("injector: request Content-Type=%s, expect application/json\n", contentType)
+		http.Error(w, "invalid Content-Type, expect `application/json`", http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+
+	// deserialize review
+	if _, _, err := i.deserializer.Decode(data, nil, &admissionReview); err != nil {
+		log.Printf("injector: Can't decode body: %v\n", err)
+		respondWithError(nil, w, err)
+		return nil, false
+	}
+
+	if admissionReview.Request == nil {
+		respondWithError(nil, w, errors.New("review without request"))
+		return nil, false
+	}
+
+	return &admissionReview, true
+}
+
 func toAdmissionResponse(err error) *v1beta1.AdmissionResponse {
 	return &v1beta1.AdmissionResponse{
 		Result: &metav1.Status{
@@ -12,6 +12,8 @@ const (
 	argPort             = "-port"
 	argEventstores      = "-eventstores"
 	argOperatorEndpoint = "-operatorendpoint"
+	// namespaceEnv holds the namespace of the pod, the sidecar only uses the Eventstores in it
+	namespaceEnv        = "POD_NAMESPACE"
 	sidecarName         = "eventstored"
 	sidecarImage        = "m009/eventstored:latest"
 	httpPortName        = "http"
@@ -68,6 +70,12 @@ func createSidecarContainer(port int, evtsNames, controlPlaneNamespace string) c
 			fmt.Sprintf("%s='%s'", argEventstores, evtsNames),
 			fmt.Sprintf("%s=http://%s.%s.svc.cluster.local:%d", argOperatorEndpoint, operatorService, controlPlaneNamespace, operatorServicePort),
 		},
+		Env: []corev1.EnvVar{
+			{
+				Name:      namespaceEnv,
+				ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"}},
+			},
+		},
 	}
 
 	return cntr
@@ -0,0 +1,202 @@
+package injector
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/chaos"
+	registry "github.com/AndreasM009/eventstore/pkg/eventstored/eventstore"
+	"k8s.io/api/admission/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// eventstoreKind is the kind of the Eventstores validated on create and update
+var eventstoreKind = schema.GroupKind{Group: "eventstore.io", Kind: "Eventstore"}
+
+// handleValidation admits Eventstores that the registry of the sidecars can create
+func (i *injector) handleValidation(w http.ResponseWriter, r *http.Request) {
+	admissionReview, ok := i.readAdmissionReview(w, r)
+	if !ok {
+		return
+	}
+
+	if admissionReview.Request.Kind.Kind != eventstoreKind.Kind {
+		log.Printf("injector: invalid kind for validation: %s", admissionReview.Request.Kind.Kind)
+		respondWithError(admissionReview, w, fmt.Errorf("invalid kind of review: %s", admissionReview.Request.Kind.Kind))
+		return
+	}
+
+	admissionResponse, err := reviewEventstore(admissionReview.Request)
+	if err != nil {
+		respondWithError(admissionReview, w, err)
+		return
+	}
+
+	arResponse := v1beta1.AdmissionReview{}
+	arResponse.Response = admissionResponse
+	arResponse.Response.UID = admissionReview.Request.UID
+
+	writeAdmissionResponse(&arResponse, w, http.StatusOK)
+}
+
+// reviewEventstore denies an Eventstore with all its problems. Updates that keep the spec, like the
+// ones of the finalizers of the operator, are admitted, so Eventstores created before the webhook
+// can be deleted.
+func reviewEventstore(req *v1beta1.AdmissionRequest) (*v1beta1.AdmissionResponse, error) {
+	es := &v1alpha1.Eventstore{}
+	if err := json.Unmarshal(req.Object.Raw, es); err != nil {
+		return nil, fmt.Errorf("can't deserialize Eventstore from json: %s", err)
+	}
+
+	if req.Operation == v1beta1.Update && len(req.OldObject.Raw) != 0 {
+		old := &v1alpha1.Eventstore{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return nil, fmt.Errorf("can't deserialize Eventstore from json: %s", err)
+		}
+
+		if es.GetDeletionTimestamp() != nil || reflect.DeepEqual(old.Spec, es.Spec) {
+			return allowed(), nil
+		}
+	}
+
+	errs := validateEventstore(es)
+	if len(errs) == 0 {
+		return allowed(), nil
+	}
+
+	status := apierrors.NewInvalid(eventstoreKind, es.GetName(), errs).ErrStatus
+	log.Printf("injector: denied Eventstore %s/%s: %s\n", req.Namespace, es.GetName(), status.Message)
+
+	return &v1beta1.AdmissionResponse{
+		Allowed: false,
+		Result:  &status,
+	}, nil
+}
+
+func allowed() *v1beta1.AdmissionResponse {
+	return &v1beta1.AdmissionResponse{
+		Allowed: true,
+		Result: &metav1.Status{
+			Status: "Success",
+		},
+	}
+}
+
+// validateEventstore checks that the registry knows the types of the store and its migration
+// target, that their required metadata is present and that all metadata is well formed
+func validateEventstore(es *v1alpha1.Eventstore) field.ErrorList {
+	spec := field.NewPath("spec")
+
+	errs := validateStore(spec, es.Spec.Type, es.Spec.Metadata)
+
+	if es.Spec.Sink != nil {
+		errs = append(errs, validateMetadata(spec.Child("sink", "metadata"), es.Spec.Sink.Metadata)...)
+	}
+
+	for i, p := range es.Spec.Projections {
+		errs = append(errs, validateMetadata(spec.Child("projections").Index(i).Child("metadata"), p.Metadata)...)
+	}
+
+	if es.Spec.Migration != nil {
+		errs = append(errs, validateStore(spec.Child("migration"), es.Spec.Migration.Type, es.Spec.Migration.Metadata)...)
+	}
+
+	return errs
+}
+
+// validateStore checks a backend of type typ with metadata, path is the parent of both
+func validateStore(path *field.Path, typ string, metadata []v1alpha1.MetadataItem) field.ErrorList {
+	errs := validateMetadata(path.Child("metadata"), metadata)
+
+	if typ == "" {
+		return append(errs, field.Required(path.Child("type"), ""))
+	}
+
+	required, ok := registry.RequiredMetadata(typ)
+	if !ok {
+		return append(errs, field.NotSupported(path.Child("type"), typ, registry.Types()))
+	}
+
+	values := map[string]v1alpha1.MetadataItem{}
+	for _, m := range metadata {
+		values[m.Name] = m
+	}
+
+	if registry.IsChaosType(typ) {
+		if target, ok := values[chaos.TargetKey]; ok && target.Value != "" {
+			targetRequired, known := registry.RequiredMetadata(target.Value)
+
+			switch {
+			case !known || registry.IsChaosType(target.Value):
+				targets := []string{}
+				for _, t := range registry.Types() {
+					if !registry.IsChaosType(t) {
+						targets = append(targets, t)
+					}
+				}
+
+				errs = append(errs, field.NotSupported(path.Child("metadata").Key(chaos.TargetKey), target.Value, targets))
+			default:
+				required = append(required, targetRequired...)
+			}
+		}
+	}
+
+	missing := []string{}
+	for _, key := range required {
+		if m, ok := values[key]; !ok || (m.Value == "" && m.SecretKeyRef.Name == "") {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) != 0 {
+		errs = append(errs, field.Required(path.Child("metadata"), fmt.Sprintf("type %s requires %s", typ, strings.Join(missing, ", "))))
+	}
+
+	return errs
+}
+
+// validateMetadata checks that names are unique and secretKeyRefs are complete
+func validateMetadata(path *field.Path, metadata []v1alpha1.MetadataItem) field.ErrorList {
+	errs := field.ErrorList{}
+	names := map[string]bool{}
+
+	for i, m := range metadata {
+		item := path.Index(i)
+
+		if m.Name == "" {
+			errs = append(errs, field.Required(item.Child("name"), ""))
+		} else if names[m.Name] {
+			errs = append(errs, field.Duplicate(item.Child("name"), m.Name))
+		}
+
+		names[m.Name] = true
+
+		ref := m.SecretKeyRef
+		if ref.Name == "" && ref.Key == "" {
+			continue
+		}
+
+		if ref.Name == "" {
+			errs = append(errs, field.Required(item.Child("secretKeyRef", "name"), "secretKeyRef needs the name of a secret"))
+		}
+
+		if ref.Key == "" {
+			errs = append(errs, field.Required(item.Child("secretKeyRef", "key"), "secretKeyRef needs the key in the secret"))
+		}
+
+		if m.Value != "" {
+			errs = append(errs, field.Forbidden(item.Child("value"), "value and secretKeyRef are exclusive"))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,160 @@
+package injector
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func testEventstore(typ string, metadata ...v1alpha1.MetadataItem) *v1alpha1.Eventstore {
+	return &v1alpha1.Eventstore{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "eventstore.io/v1alpha1", Kind: "Eventstore"},
+		ObjectMeta: metav1.ObjectMeta{Name: "teststore", Namespace: "default"},
+		Spec:       v1alpha1.EventstoreSpec{Type: typ, Metadata: metadata},
+	}
+}
+
+func messages(t *testing.T, es *v1alpha1.Eventstore) []string {
+	result := []string{}
+	for _, err := range validateEventstore(es) {
+		result = append(result, err.Error())
+	}
+
+	return result
+}
+
+func TestValidateEventstoreAcceptsValidStores(t *testing.T) {
+	assert.Empty(t, messages(t, testEventstore("eventstore.inmemory")))
+
+	assert.Empty(t, messages(t, testEventstore("eventstore.azure.tablestorage",
+		v1alpha1.MetadataItem{Name: "storageAccountName", Value: "account"},
+		v1alpha1.MetadataItem{Name: "storageAccountKey", SecretKeyRef: v1alpha1.SecretKeyRef{Name: "storage", Key: "key"}},
+	)))
+
+	assert.Empty(t, messages(t, testEventstore("eventstore.chaos",
+		v1alpha1.MetadataItem{Name: "chaosTarget", Value: "eventstore.inmemory"},
+	)))
+}
+
+func TestValidateEventstoreRejectsUnknownType(t *testing.T) {
+	result := messages(t, testEventstore("eventstore.azure.cosmos"))
+
+	assert.Len(t, result, 1)
+	assert.Contains(t, result[0], "spec.type")
+	assert.Contains(t, result[0], `"eventstore.azure.cosmos"`)
+	assert.Contains(t, result[0], "eventstore.azure.cosmosdb")
+}
+
+func TestValidateEventstoreRequiresMetadataOfType(t *testing.T) {
+	result := messages(t, testEventstore("eventstore.azure.cosmosdb",
+		v1alpha1.MetadataItem{Name: "url", Value: "https://account.documents.azure.com"},
+		v1alpha1.MetadataItem{Name: "database", Value: ""},
+	))
+
+	assert.Len(t, result, 1)
+	assert.Contains(t, result[0], "spec.metadata")
+	assert.Contains(t, result[0], "masterKey, database, container")
+}
+
+func TestValidateEventstoreChecksChaosTarget(t *testing.T) {
+	result := messages(t, testEventstore("eventstore.chaos",
+		v1alpha1.MetadataItem{Name: "chaosTarget", Value: "eventstore.chaos"},
+	))
+
+	assert.Len(t, result, 1)
+	assert.Contains(t, result[0], "spec.metadata[chaosTarget]")
+
+	result = messages(t, testEventstore("eventstore.chaos",
+		v1alpha1.MetadataItem{Name: "chaosTarget", Value: "eventstore.azure.tablestorage"},
+	))
+
+	assert.Len(t, result, 1)
+	assert.Contains(t, result[0], "storageAccountName, storageAccountKey")
+}
+
+func TestValidateEventstoreChecksMetadataItems(t *testing.T) {
+	es := testEventstore("eventstore.inmemory",
+		v1alpha1.MetadataItem{Name: "journal", Value: "true"},
+		v1alpha1.MetadataItem{Name: "journal", Value: "false"},
+		v1alpha1.MetadataItem{Name: "token", SecretKeyRef: v1alpha1.SecretKeyRef{Name: "tokens"}},
+		v1alpha1.MetadataItem{Name: "password", Value: "secret", SecretKeyRef: v1alpha1.SecretKeyRef{Name: "passwords", Key: "password"}},
+	)
+
+	es.Spec.Migration = &v1alpha1.MigrationSpec{Type: "eventstore.azure.table", Stage: "dualwrite"}
+
+	result := messages(t, es)
+
+	assert.Len(t, result, 4)
+	assert.Contains(t, result[0], "spec.metadata[1].name: Duplicate value")
+	assert.Contains(t, result[1], "spec.metadata[2].secretKeyRef.key: Required value")
+	assert.Contains(t, result[2], "spec.metadata[3].value: Forbidden")
+	assert.NotContains(t, result[2], "secret\"")
+	assert.Contains(t, result[3], "spec.migration.type: Unsupported value")
+}
+
+func review(t *testing.T, operation v1beta1.Operation, es, old *v1alpha1.Eventstore) *v1beta1.AdmissionResponse {
+	raw, _ := json.Marshal(es)
+	req := v1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1beta1", Kind: "AdmissionReview"},
+		Request: &v1beta1.AdmissionRequest{
+			UID:       "review-uid",
+			Kind:      metav1.GroupVersionKind{Group: "eventstore.io", Version: "v1alpha1", Kind: "Eventstore"},
+			Operation: operation,
+			Namespace: "default",
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	}
+
+	if old != nil {
+		oldRaw, _ := json.Marshal(old)
+		req.Request.OldObject = runtime.RawExtension{Raw: oldRaw}
+	}
+
+	body, _ := json.Marshal(req)
+	request := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	NewInjector().(*injector).server.Handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	response := v1beta1.AdmissionReview{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "review-uid", string(response.Response.UID))
+
+	return response.Response
+}
+
+func TestHandleValidationDeniesInvalidEventstore(t *testing.T) {
+	response := review(t, v1beta1.Create, testEventstore("eventstore.azure.cosmos"), nil)
+
+	assert.False(t, response.Allowed)
+	assert.Equal(t, metav1.StatusReasonInvalid, response.Result.Reason)
+	assert.True(t, strings.HasPrefix(response.Result.Message, `Eventstore.eventstore.io "teststore" is invalid`))
+	assert.Len(t, response.Result.Details.Causes, 1)
+}
+
+func TestHandleValidationAllowsValidEventstore(t *testing.T) {
+	response := review(t, v1beta1.Create, testEventstore("eventstore.inmemory"), nil)
+	assert.True(t, response.Allowed)
+}
+
+func TestHandleValidationAllowsUpdatesKeepingTheSpec(t *testing.T) {
+	old := testEventstore("eventstore.azure.cosmos")
+	es := old.DeepCopy()
+	es.SetFinalizers([]string{"eventstore.io/sidecars"})
+
+	assert.True(t, review(t, v1beta1.Update, es, old).Allowed)
+
+	es.Spec.Type = "eventstore.azure.cosmosdb2"
+	assert.False(t, review(t, v1beta1.Update, es, old).Allowed)
+}
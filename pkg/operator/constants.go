@@ -7,4 +7,6 @@ const (
 	eventstoreAppID      = "eventstore/appid"
 	evenstoreDefaultPort = 5600
 	httpPortName         = "http"
+	// eventstoreFinalizer keeps deleted Eventstores until they are removed from the sidecars
+	eventstoreFinalizer = "eventstore.io/sidecars"
 )
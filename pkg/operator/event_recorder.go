@@ -0,0 +1,70 @@
+package operator
+
+import (
+	"log"
+
+	eventstorescheme "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned/scheme"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// eventComponent is the source of the Events of the operator
+const eventComponent = "eventstore-operator"
+
+// Reasons of the Events the operator records on Eventstores, EventstoreSubscriptions and the
+// workloads of the sidecars
+const (
+	// ReasonServiceCreated is recorded on a workload when the service of its sidecars was created
+	ReasonServiceCreated = "ServiceCreated"
+	// ReasonServiceUpdated is recorded on a workload when the service of its sidecars was updated
+	ReasonServiceUpdated = "ServiceUpdated"
+	// ReasonServiceDeleted is recorded on a workload when the service of its sidecars was deleted
+	ReasonServiceDeleted = "ServiceDeleted"
+	// ReasonServiceFailed is recorded on a workload when its service can't be created or updated
+	ReasonServiceFailed = "ServiceFailed"
+	// ReasonServiceConflict is recorded on a workload whose service is controlled by another object
+	ReasonServiceConflict = "ServiceConflict"
+	// ReasonAppIDMissing is recorded on a workload that enables eventstore without an appid
+	ReasonAppIDMissing = "AppIDMissing"
+	// ReasonConfigPushed is recorded on an Eventstore when the sidecars received its generation
+	ReasonConfigPushed = "ConfigPushed"
+	// ReasonConfigPushFailed is recorded on an Eventstore for each pod that missed its generation
+	ReasonConfigPushFailed = "ConfigPushFailed"
+	// ReasonConfigRemoved is recorded on a deleted Eventstore when the sidecars removed it
+	ReasonConfigRemoved = "ConfigRemoved"
+	// ReasonConfigRemoveFailed is recorded on a deleted Eventstore for each pod that still has it
+	ReasonConfigRemoveFailed = "ConfigRemoveFailed"
+	// ReasonFinalizerTimedOut is recorded on a deleted Eventstore released after finalizerTimeout
+	ReasonFinalizerTimedOut = "FinalizerTimedOut"
+	// ReasonSecretNotFound is recorded on an object that references a missing secret or key
+	ReasonSecretNotFound = "SecretNotFound"
+	// ReasonInvalidSubscription is recorded on a subscription that can't be delivered
+	ReasonInvalidSubscription = "InvalidSubscription"
+	// ReasonDeliveryStarted is recorded on a subscription when its delivery (re)started
+	ReasonDeliveryStarted = "DeliveryStarted"
+)
+
+// newEventRecorder creates a recorder of Kubernetes Events on the objects of the operator, so
+// `kubectl describe` shows what it did. Events are logged, too. The returned function stops the
+// recording.
+func newEventRecorder(kubeClient kubernetes.Interface) (record.EventRecorder, func()) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(eventstorescheme.AddToScheme(scheme))
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(func(format string, args ...interface{}) {
+		log.Printf(format+"\n", args...)
+	})
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: kubeClient.CoreV1().Events(metav1.NamespaceAll),
+	})
+
+	return broadcaster.NewRecorder(scheme, corev1.EventSource{Component: eventComponent}), broadcaster.Shutdown
+}
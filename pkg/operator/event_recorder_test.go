@@ -0,0 +1,177 @@
+package operator
+
+import (
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	eventstorefake "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+// recordedEvents returns the Events recorded so far as '<type> <reason> <message>'
+func recordedEvents(recorder *record.FakeRecorder) []string {
+	result := []string{}
+
+	for {
+		select {
+		case e := <-recorder.Events:
+			result = append(result, e)
+		default:
+			return result
+		}
+	}
+}
+
+func TestWorkloadEvents(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	recorder := record.NewFakeRecorder(100)
+	p := newWorkloadProcessor(client, recorder)
+
+	assert.Nil(t, p.ProcessChanged(testDeployment(enabledAnnotations())))
+
+	annotations := enabledAnnotations()
+	annotations[eventstorePortKey] = "7000"
+	assert.Nil(t, p.ProcessChanged(testDeployment(annotations)))
+
+	annotations[eventstoreEnabledKey] = "false"
+	assert.Nil(t, p.ProcessChanged(testDeployment(annotations)))
+
+	annotations = enabledAnnotations()
+	annotations[eventstoreAppID] = ""
+	assert.Nil(t, p.ProcessChanged(testDeployment(annotations)))
+
+	assert.Equal(t, []string{
+		"Normal ServiceCreated Created service myapp-eventstore",
+		"Normal ServiceUpdated Updated service myapp-eventstore",
+		"Normal ServiceDeleted Deleted service myapp-eventstore",
+		"Warning AppIDMissing Skipping creation of the sidecar service, annotation eventstore/appid is empty",
+	}, recordedEvents(recorder))
+}
+
+func TestWorkloadEventOfServiceControlledByAnotherObject(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myapp-eventstore",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "StatefulSet", Name: "other", UID: "other-uid", Controller: &[]bool{true}[0]},
+			},
+		},
+	})
+	recorder := record.NewFakeRecorder(100)
+
+	assert.Nil(t, newWorkloadProcessor(client, recorder).ProcessChanged(testDeployment(enabledAnnotations())))
+	assert.Equal(t, []string{
+		"Warning ServiceConflict Service myapp-eventstore is controlled by StatefulSet other, it's left unchanged",
+	}, recordedEvents(recorder))
+}
+
+func TestEventstoreEventsOfPushes(t *testing.T) {
+	healthy := newTestSidecar(0)
+	defer healthy.server.Close()
+
+	restarting := newTestSidecar(1)
+	defer restarting.server.Close()
+
+	es := newTestEventstore("1")
+	recorder := record.NewFakeRecorder(100)
+	p := newEventStoreProcessor(newTestKubeClient(healthy.subset("app-1"), restarting.subset("app-2")), eventstorefake.NewSimpleClientset(es), recorder)
+
+	assert.NotNil(t, p.ProcessChanged(es))
+	assert.Equal(t, []string{
+		"Warning ConfigPushFailed Pushing generation 1 to the sidecar of pod default/app-2 failed: update sidecar config returned 503",
+	}, recordedEvents(recorder))
+
+	assert.Nil(t, p.ProcessChanged(es))
+	assert.Equal(t, []string{"Normal ConfigPushed Pushed generation 1 to 1 sidecars"}, recordedEvents(recorder))
+
+	// nothing is pending, nothing is recorded
+	assert.Nil(t, p.ProcessChanged(es))
+	assert.Empty(t, recordedEvents(recorder))
+}
+
+func TestEventstoreEventsOfMissingSecrets(t *testing.T) {
+	sidecar := newTestSidecar(0)
+	defer sidecar.server.Close()
+
+	client := newTestKubeClient(sidecar.subset("app-1"))
+	_ = client.Tracker().Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "storage", Namespace: "default"},
+		Data:       map[string][]byte{"accountName": []byte("account")},
+	})
+
+	es := newTestEventstore("1")
+	es.Spec = v1alpha1.EventstoreSpec{
+		Type: "eventstore.azure.tablestorage",
+		Metadata: []v1alpha1.MetadataItem{
+			{Name: "storageAccountName", SecretKeyRef: v1alpha1.SecretKeyRef{Name: "storage", Key: "accountName"}},
+			{Name: "storageAccountKey", SecretKeyRef: v1alpha1.SecretKeyRef{Name: "storage", Key: "accountKey"}},
+		},
+		Sink: &v1alpha1.SinkSpec{
+			Type:     "kafka",
+			Metadata: []v1alpha1.MetadataItem{{Name: "brokers", SecretKeyRef: v1alpha1.SecretKeyRef{Name: "kafka", Key: "brokers"}}},
+		},
+	}
+
+	recorder := record.NewFakeRecorder(100)
+	p := newEventStoreProcessor(client, eventstorefake.NewSimpleClientset(es), recorder)
+
+	// the Eventstore is pushed anyway
+	assert.Nil(t, p.ProcessChanged(es))
+	assert.Len(t, sidecar.pushed(), 1)
+
+	assert.Equal(t, []string{
+		"Warning SecretNotFound spec.metadata[storageAccountKey]: secret not found: default/storage has no key accountKey",
+		"Warning SecretNotFound spec.sink.metadata[brokers]: secret not found: default/kafka",
+		"Normal ConfigPushed Pushed generation 1 to 1 sidecars",
+	}, recordedEvents(recorder))
+
+	// secrets of an observed generation aren't checked again
+	es.Status.ObservedGeneration = 1
+	assert.Nil(t, p.ProcessChanged(es))
+	assert.Empty(t, recordedEvents(recorder))
+}
+
+func TestEventstoreEventsOfDeletion(t *testing.T) {
+	unreachable := newTestSidecar(-1)
+	unreachable.server.Close()
+
+	es := newTestEventstore("2")
+	es.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(-2 * finalizerTimeout)}
+
+	recorder := record.NewFakeRecorder(100)
+	p := newEventStoreProcessor(newTestKubeClient(unreachable.subset("app-1")), eventstorefake.NewSimpleClientset(es), recorder)
+	assert.Nil(t, p.ProcessChanged(es))
+
+	events := recordedEvents(recorder)
+	assert.Len(t, events, 3)
+	assert.Contains(t, events[0], "Warning ConfigRemoveFailed Removing the Eventstore from the sidecar of pod default/app-1 failed")
+	assert.Contains(t, events[1], "Warning FinalizerTimedOut Releasing the Eventstore after 5m0s")
+	assert.Equal(t, "Normal ConfigRemoved Removed from 1 sidecars", events[2])
+}
+
+func TestSubscriptionEvents(t *testing.T) {
+	es := newTestEventstore("1")
+	subscription := newTestSubscription("", v1alpha1.SubscriptionFilter{})
+	subscription.Namespace = "default"
+
+	recorder := record.NewFakeRecorder(100)
+	p := newSubscriptionProcessor(fake.NewSimpleClientset(), eventstorefake.NewSimpleClientset(es), recorder)
+
+	assert.Nil(t, p.ProcessChanged(subscription))
+	assert.Equal(t, []string{"Warning InvalidSubscription invalid subscription: the subscription has no url"}, recordedEvents(recorder))
+
+	subscription.Spec.URL = "http://localhost/hook"
+	subscription.Spec.SigningSecret = &v1alpha1.SecretKeyRef{Name: "hooks", Key: "signing"}
+	subscription.ResourceVersion = "2"
+
+	assert.NotNil(t, p.ProcessChanged(subscription))
+	assert.Equal(t, []string{
+		"Warning SecretNotFound spec.signingSecret: secret not found: default/hooks",
+	}, recordedEvents(recorder))
+}
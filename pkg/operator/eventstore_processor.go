@@ -3,56 +3,390 @@ package operator
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"k8s.io/apimachinery/pkg/labels"
 
 	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	scheme "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 )
 
+const (
+	// pushTimeout bounds a configuration push to a single sidecar
+	pushTimeout = 5 * time.Second
+	// finalizerTimeout is the time after which a deleted Eventstore is released, even if some
+	// sidecars couldn't be notified
+	finalizerTimeout = 5 * time.Minute
+)
+
+// eventstoreProcessor pushes the configuration of changed Eventstores to the sidecars. Pushes are
+// tracked per pod, so a requeued Eventstore is only pushed to the pods that missed it. The result
+// is written to the status of the Eventstore. Deleted Eventstores are held by a finalizer until
+// the sidecars removed them.
 type eventstoreProcessor struct {
-	kubeClient kubernetes.Interface
+	kubeClient       kubernetes.Interface
+	eventstoreClient scheme.Interface
+	recorder         record.EventRecorder
+	client           *http.Client
+	mutex            sync.Mutex
+	// pushed holds the configuration each pod received by Eventstore, or its deletion
+	pushed map[string]map[string]string
+}
+
+// sidecar is the address of an eventstored sidecar and the pod it's running in. id identifies
+// the pod, a recreated pod with the same name is another one.
+type sidecar struct {
+	id      string
+	pod     string
+	address string
 }
 
-func newEventStoreProcessor(kubeClient kubernetes.Interface) Processor {
+func newEventStoreProcessor(kubeClient kubernetes.Interface, eventstoreClient scheme.Interface, recorder record.EventRecorder) Processor {
 	return &eventstoreProcessor{
-		kubeClient: kubeClient,
+		kubeClient:       kubeClient,
+		eventstoreClient: eventstoreClient,
+		recorder:         recorder,
+		client:           &http.Client{Timeout: pushTimeout},
+		pushed:           map[string]map[string]string{},
 	}
 }
 
+// ProcessChanged pushes the Eventstore to all sidecars that don't have its configuration yet. The
+// configuration is its generation and its spec with the secret values, so updates of the status
+// or the metadata aren't pushed, but rotated secrets are. If some pushes fail, an error is returned
+// to requeue the Eventstore.
 func (p *eventstoreProcessor) ProcessChanged(obj interface{}) error {
-	log.Println("Eventstore Object changed")
-
 	eventstore := obj.(*v1alpha1.Eventstore)
+	key := eventstore.GetNamespace() + "/" + eventstore.GetName()
+	resourceVersion := eventstore.GetResourceVersion()
+
+	if eventstore.GetDeletionTimestamp() != nil {
+		return p.processDeletion(eventstore)
+	}
+
+	if !hasFinalizer(eventstore) {
+		// the update with the finalizer is processed again
+		return p.updateFinalizers(eventstore, append(eventstore.GetFinalizers(), eventstoreFinalizer))
+	}
+
+	log.Printf("Eventstore %s changed, resourceVersion %s\n", key, resourceVersion)
+
+	if eventstore.Status.ObservedGeneration != eventstore.GetGeneration() {
+		p.checkSecrets(eventstore)
+	}
+
+	resolved, err := withSecretValues(p.kubeClient, eventstore)
+	if err != nil {
+		return fmt.Errorf("can't resolve secrets of Eventstore %s: %s", key, err)
+	}
+
+	payload, err := json.Marshal(resolved)
+	if err != nil {
+		return fmt.Errorf("can't serialize Eventstore to json: %s", err)
+	}
+
+	spec, err := json.Marshal(resolved.Spec)
+	if err != nil {
+		return fmt.Errorf("can't serialize Eventstore to json: %s", err)
+	}
+
+	version := "generation/" + strconv.FormatInt(eventstore.GetGeneration(), 10) + "/" + configurationVersion(spec)
+
+	result, err := p.pushAll(eventstore.GetNamespace(), key, version, func(s sidecar) error {
+		return p.updateSidecar(s, eventstore.GetName(), payload)
+	})
 
-	services, err := p.getEventstoreServices()
 	if err != nil {
-		log.Println("can't get evenstore services")
+		return err
+	}
+
+	status := v1alpha1.EventstoreStatus{
+		ObservedGeneration: eventstore.GetGeneration(),
+		Ready:              len(result.failed) == 0,
+		Sidecars:           int32(result.sidecars),
+	}
+
+	if len(result.failed) != 0 {
+		err = fmt.Errorf("failed to push Eventstore %s to %d of %d sidecars: %s", key, len(result.failed), result.pending, result.failures())
+		status.Message = fmt.Sprintf("%d of %d sidecars didn't receive generation %d", len(result.failed), result.sidecars, eventstore.GetGeneration())
+
+		for _, f := range result.failed {
+			p.recorder.Eventf(eventstore, corev1.EventTypeWarning, ReasonConfigPushFailed,
+				"Pushing generation %d to the sidecar of pod %s failed: %s", eventstore.GetGeneration(), f.pod, f.err)
+		}
+	} else if result.pending != 0 {
+		p.recorder.Eventf(eventstore, corev1.EventTypeNormal, ReasonConfigPushed,
+			"Pushed generation %d to %d sidecars", eventstore.GetGeneration(), result.pending)
+	}
+
+	if statusErr := p.updateStatus(eventstore, status); statusErr != nil && err == nil {
+		return statusErr
+	}
+
+	return err
+}
+
+// configurationVersion returns the version of the resolved spec of an Eventstore
+func configurationVersion(spec []byte) string {
+	h := sha256.Sum256(spec)
+	return hex.EncodeToString(h[:])
+}
+
+// updateStatus writes status to the Eventstore, if it changed
+func (p *eventstoreProcessor) updateStatus(eventstore *v1alpha1.Eventstore, status v1alpha1.EventstoreStatus) error {
+	if eventstore.Status == status {
 		return nil
 	}
 
-	endpoints := p.getEndpoints(services)
+	es := eventstore.DeepCopy()
+	es.Status = status
 
-	for _, e := range endpoints {
-		p.updateSidecar(e, eventstore)
+	_, err := p.eventstoreClient.EventstoreV1alpha1().Eventstores(es.GetNamespace()).UpdateStatus(context.TODO(), es, metav1.UpdateOptions{})
+	if apierrors.IsNotFound(err) {
+		// deleted meanwhile
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("can't update status of Eventstore %s/%s: %s", es.GetNamespace(), es.GetName(), err)
+	}
+
+	return nil
+}
+
+// processDeletion removes the Eventstore from all sidecars and releases it by removing the
+// finalizer. It's released after finalizerTimeout, if some sidecars can't be reached.
+func (p *eventstoreProcessor) processDeletion(eventstore *v1alpha1.Eventstore) error {
+	if !hasFinalizer(eventstore) {
+		return nil
+	}
+
+	key := eventstore.GetNamespace() + "/" + eventstore.GetName()
+	resourceVersion := eventstore.GetResourceVersion()
+
+	// deletions are tracked like pushes, but don't match resourceVersions of pushes
+	result, err := p.pushAll(eventstore.GetNamespace(), key, "deleted/"+resourceVersion, func(s sidecar) error {
+		return p.deleteFromSidecar(s, eventstore.GetName(), resourceVersion)
+	})
+
+	if err == nil && len(result.failed) != 0 {
+		err = fmt.Errorf("failed to remove Eventstore %s from %d of %d sidecars: %s", key, len(result.failed), result.pending, result.failures())
+
+		for _, f := range result.failed {
+			p.recorder.Eventf(eventstore, corev1.EventTypeWarning, ReasonConfigRemoveFailed,
+				"Removing the Eventstore from the sidecar of pod %s failed: %s", f.pod, f.err)
+		}
+	}
+
+	if err != nil {
+		if time.Since(eventstore.GetDeletionTimestamp().Time) < finalizerTimeout {
+			return err
+		}
+
+		p.recorder.Eventf(eventstore, corev1.EventTypeWarning, ReasonFinalizerTimedOut,
+			"Releasing the Eventstore after %s: %s", finalizerTimeout, err)
+	}
+
+	finalizers := []string{}
+	for _, f := range eventstore.GetFinalizers() {
+		if f != eventstoreFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+
+	if err := p.updateFinalizers(eventstore, finalizers); err != nil {
+		return err
+	}
+
+	if result.pending != 0 {
+		p.recorder.Eventf(eventstore, corev1.EventTypeNormal, ReasonConfigRemoved, "Removed from %d sidecars", result.pending)
 	}
 
 	return nil
 }
 
+// checkSecrets records an Event for each secret reference of the Eventstore to a missing secret
+// or key. The Eventstore is pushed anyway, the sidecars fail to create the store until the secret
+// is created.
+func (p *eventstoreProcessor) checkSecrets(eventstore *v1alpha1.Eventstore) {
+	refs := secretKeyRefs(eventstore.Spec)
+
+	paths := make([]string, 0, len(refs))
+	for path := range refs {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		_, err := secretValue(p.kubeClient, eventstore.GetNamespace(), refs[path])
+		if errors.Is(err, errSecretNotFound) {
+			p.recorder.Eventf(eventstore, corev1.EventTypeWarning, ReasonSecretNotFound, "%s: %s", path, err)
+		}
+	}
+}
+
+// pushResult counts the sidecars of a push and the ones it was pending for, failed holds the
+// failures of the latter
+type pushResult struct {
+	sidecars int
+	pending  int
+	failed   []pushFailure
+}
+
+// pushFailure is a push that failed for the sidecar of pod
+type pushFailure struct {
+	pod string
+	err error
+}
+
+// failures joins the failed pushes of r
+func (r pushResult) failures() string {
+	failed := make([]string, 0, len(r.failed))
+	for _, f := range r.failed {
+		failed = append(failed, fmt.Sprintf("%s: %s", f.pod, f.err))
+	}
+
+	return strings.Join(failed, "; ")
+}
+
+// pushAll calls push for every sidecar in namespace that didn't receive version of the Eventstore
+// yet. Sidecars only use the Eventstores of their namespace, they don't get the secrets of others.
+func (p *eventstoreProcessor) pushAll(namespace, key, version string, push func(s sidecar) error) (pushResult, error) {
+	services, err := p.getEventstoreServices(namespace)
+	if err != nil {
+		return pushResult{}, fmt.Errorf("can't get eventstore services: %s", err)
+	}
+
+	endpoints, err := p.getEndpoints(services)
+	if err != nil {
+		return pushResult{}, err
+	}
+
+	sidecars := sidecarsOf(endpoints)
+	pending := p.pending(key, version, sidecars)
+
+	failures := make(chan pushFailure, len(pending))
+	wg := sync.WaitGroup{}
+
+	for _, s := range pending {
+		wg.Add(1)
+
+		go func(s sidecar) {
+			defer wg.Done()
+
+			if err := push(s); err != nil {
+				failures <- pushFailure{pod: s.pod, err: err}
+				return
+			}
+
+			p.delivered(key, s.id, version)
+		}(s)
+	}
+
+	wg.Wait()
+	close(failures)
+
+	failed := []pushFailure{}
+	for f := range failures {
+		failed = append(failed, f)
+	}
+
+	return pushResult{sidecars: len(sidecars), pending: len(pending), failed: failed}, nil
+}
+
+func (p *eventstoreProcessor) updateFinalizers(eventstore *v1alpha1.Eventstore, finalizers []string) error {
+	es := eventstore.DeepCopy()
+	es.SetFinalizers(finalizers)
+
+	_, err := p.eventstoreClient.EventstoreV1alpha1().Eventstores(es.GetNamespace()).Update(context.TODO(), es, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("can't update finalizers of Eventstore %s/%s: %s", es.GetNamespace(), es.GetName(), err)
+	}
+
+	return nil
+}
+
+func hasFinalizer(eventstore *v1alpha1.Eventstore) bool {
+	for _, f := range eventstore.GetFinalizers() {
+		if f == eventstoreFinalizer {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (p *eventstoreProcessor) ProcessDeleted(obj interface{}) error {
-	log.Println("Eventstore Object deleted")
+	// obj may be the final state of an Eventstore deleted while the informer was disconnected
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	delete(p.pushed, key)
+	p.mutex.Unlock()
+
+	log.Printf("Eventstore %s deleted\n", key)
 	return nil
 }
 
-func (p *eventstoreProcessor) getEventstoreServices() (*corev1.ServiceList, error) {
-	services, err := p.kubeClient.CoreV1().Services(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+// pending returns the sidecars that didn't receive version of the Eventstore. Pods that are gone
+// are forgotten.
+func (p *eventstoreProcessor) pending(key, version string, sidecars []sidecar) []sidecar {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	pushed := p.pushed[key]
+	current := map[string]string{}
+	result := []sidecar{}
+
+	for _, s := range sidecars {
+		if v, ok := pushed[s.id]; ok {
+			current[s.id] = v
+		}
+
+		if pushed[s.id] != version {
+			result = append(result, s)
+		}
+	}
+
+	p.pushed[key] = current
+	return result
+}
+
+// delivered records that the pod with id received version of the Eventstore
+func (p *eventstoreProcessor) delivered(key, id, version string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, ok := p.pushed[key]; !ok {
+		p.pushed[key] = map[string]string{}
+	}
+
+	p.pushed[key][id] = version
+}
+
+func (p *eventstoreProcessor) getEventstoreServices(namespace string) (*corev1.ServiceList, error) {
+	services, err := p.kubeClient.CoreV1().Services(namespace).List(context.TODO(), metav1.ListOptions{
 		LabelSelector: labels.SelectorFromSet(map[string]string{eventstoreEnabledKey: "true"}).String(),
 	})
 
@@ -63,49 +397,101 @@ func (p *eventstoreProcessor) getEventstoreServices() (*corev1.ServiceList, erro
 	return services, nil
 }
 
-func (p *eventstoreProcessor) getEndpoints(services *corev1.ServiceList) []*corev1.Endpoints {
+func (p *eventstoreProcessor) getEndpoints(services *corev1.ServiceList) ([]*corev1.Endpoints, error) {
 	result := []*corev1.Endpoints{}
 	for _, v := range services.Items {
 		endpoint, err := p.kubeClient.CoreV1().Endpoints(v.GetNamespace()).Get(context.TODO(), v.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			// no pods yet, they load the configuration when they start
+			continue
+		}
+
 		if err != nil {
-			log.Printf("failed to get endpint for service %s: %s", v.GetName(), err)
-		} else {
-			result = append(result, endpoint)
+			return nil, fmt.Errorf("failed to get endpoint for service %s: %s", v.GetName(), err)
 		}
+
+		result = append(result, endpoint)
 	}
 
-	return result
+	return result, nil
 }
 
-func (p *eventstoreProcessor) updateSidecar(endpoint *corev1.Endpoints, settings *v1alpha1.Eventstore) {
-	if endpoint == nil || len(endpoint.Subsets) <= 0 {
-		return
+// sidecarsOf returns the ready sidecars of all subsets of endpoints
+func sidecarsOf(endpoints []*corev1.Endpoints) []sidecar {
+	result := []sidecar{}
+
+	for _, e := range endpoints {
+		for _, subset := range e.Subsets {
+			port := int32(evenstoreDefaultPort)
+
+			for _, p := range subset.Ports {
+				if p.Name == httpPortName {
+					port = p.Port
+				}
+			}
+
+			for _, a := range subset.Addresses {
+				id, pod := a.IP, a.IP
+				if a.TargetRef != nil && a.TargetRef.Kind == "Pod" {
+					id = string(a.TargetRef.UID)
+					pod = a.TargetRef.Namespace + "/" + a.TargetRef.Name
+				}
+
+				result = append(result, sidecar{
+					id:      id,
+					pod:     pod,
+					address: fmt.Sprintf("%s:%d", a.IP, port),
+				})
+			}
+		}
 	}
 
-	payload, err := json.Marshal(settings)
+	return result
+}
+
+// deleteFromSidecar removes the Eventstore from a sidecar. A sidecar that already has a newer
+// resourceVersion answers with 409, the Eventstore was created again.
+func (p *eventstoreProcessor) deleteFromSidecar(s sidecar, name, resourceVersion string) error {
+	url := fmt.Sprintf("http://%s/configurations/%s?resourceVersion=%s", s.address, name, resourceVersion)
 
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
-		log.Printf("can't serialize Eventstore to json: %s\n", err)
-		return
+		return err
 	}
 
-	for _, a := range endpoint.Subsets[0].Addresses {
-		address := fmt.Sprintf("%s:%d", a.IP, evenstoreDefaultPort)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
 
-		go func(address string) {
-			url := fmt.Sprintf("http://%s/configurations/%s", address, settings.ObjectMeta.Name)
-			resp, err := http.Post(url, "application/json", bytes.NewBuffer(payload))
-			if err != nil {
-				log.Printf("failed to send update to sidecar: %s\n", err)
-				return
-			}
+	defer resp.Body.Close()
 
-			if resp.StatusCode != http.StatusOK {
-				log.Printf("update sidecar config returned %d\n", resp.StatusCode)
-				return
-			}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
+		return fmt.Errorf("remove sidecar config returned %d", resp.StatusCode)
+	}
 
-			log.Println("sidecar updated with new config")
-		}(address)
+	return nil
+}
+
+// updateSidecar posts the configuration to a sidecar. A sidecar that already has a newer
+// resourceVersion answers with 409, the push is done anyway.
+func (p *eventstoreProcessor) updateSidecar(s sidecar, name string, payload []byte) error {
+	url := fmt.Sprintf("http://%s/configurations/%s", s.address, name)
+
+	resp, err := p.client.Post(url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		log.Printf("sidecar of pod %s has a newer configuration of Eventstore %s\n", s.pod, name)
+		return nil
+	default:
+		return fmt.Errorf("update sidecar config returned %d", resp.StatusCode)
 	}
 }
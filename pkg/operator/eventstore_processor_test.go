@@ -0,0 +1,369 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	eventstorefake "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+// testSidecar records the configurations pushed to it and the resourceVersions of deletions, it
+// fails the first failures requests
+type testSidecar struct {
+	mutex     sync.Mutex
+	failures  int
+	pushes    []v1alpha1.Eventstore
+	deletions []string
+	server    *httptest.Server
+}
+
+func newTestSidecar(failures int) *testSidecar {
+	s := &testSidecar{failures: failures}
+
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		if s.failures > 0 {
+			s.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			s.deletions = append(s.deletions, r.URL.Query().Get("resourceVersion"))
+			return
+		}
+
+		es := v1alpha1.Eventstore{}
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &es)
+		s.pushes = append(s.pushes, es)
+	}))
+
+	return s
+}
+
+func (s *testSidecar) pushed() []v1alpha1.Eventstore {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.pushes
+}
+
+func (s *testSidecar) deleted() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.deletions
+}
+
+func (s *testSidecar) subset(pod string) corev1.EndpointSubset {
+	u, _ := url.Parse(s.server.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	return corev1.EndpointSubset{
+		Addresses: []corev1.EndpointAddress{
+			{
+				IP:        u.Hostname(),
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: pod, UID: types.UID(pod)},
+			},
+		},
+		Ports: []corev1.EndpointPort{{Name: httpPortName, Port: int32(port)}},
+	}
+}
+
+// newTestEventstore creates an Eventstore whose generation is its resourceVersion
+func newTestEventstore(resourceVersion string) *v1alpha1.Eventstore {
+	generation, _ := strconv.ParseInt(resourceVersion, 10, 64)
+
+	return &v1alpha1.Eventstore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "teststore",
+			Namespace:       "default",
+			ResourceVersion: resourceVersion,
+			Generation:      generation,
+			Finalizers:      []string{eventstoreFinalizer},
+		},
+		Spec: v1alpha1.EventstoreSpec{Type: "eventstore.inmemory"},
+	}
+}
+
+// addTestService adds an eventstore service with the sidecars of subsets in namespace to client
+func addTestService(t *testing.T, client *fake.Clientset, namespace string, subsets ...corev1.EndpointSubset) {
+	_, err := client.CoreV1().Services(namespace).Create(context.TODO(), &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-eventstore", Namespace: namespace, Labels: map[string]string{eventstoreEnabledKey: "true"}},
+	}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	_, err = client.CoreV1().Endpoints(namespace).Create(context.TODO(), &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-eventstore", Namespace: namespace},
+		Subsets:    subsets,
+	}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+}
+
+func newTestKubeClient(subsets ...corev1.EndpointSubset) *fake.Clientset {
+	return fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-eventstore", Namespace: "default", Labels: map[string]string{eventstoreEnabledKey: "true"}},
+		},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-eventstore", Namespace: "default"},
+			Subsets:    subsets,
+		},
+	)
+}
+
+func TestPushesAreRetriedPerPod(t *testing.T) {
+	healthy := newTestSidecar(0)
+	defer healthy.server.Close()
+
+	restarting := newTestSidecar(1)
+	defer restarting.server.Close()
+
+	client := newTestKubeClient(healthy.subset("app-1"), restarting.subset("app-2"))
+	p := newEventStoreProcessor(client, eventstorefake.NewSimpleClientset(), record.NewFakeRecorder(100))
+
+	err := p.ProcessChanged(newTestEventstore("1"))
+	assert.NotNil(t, err, "a failed push requeues the Eventstore")
+	assert.Contains(t, err.Error(), "default/app-2")
+	assert.Len(t, healthy.pushed(), 1)
+	assert.Len(t, restarting.pushed(), 0)
+
+	// the retry only pushes to the pod that missed the update
+	assert.Nil(t, p.ProcessChanged(newTestEventstore("1")))
+	assert.Len(t, healthy.pushed(), 1)
+	assert.Len(t, restarting.pushed(), 1)
+	assert.Equal(t, "1", restarting.pushed()[0].GetResourceVersion())
+
+	// a new generation is pushed to all pods
+	assert.Nil(t, p.ProcessChanged(newTestEventstore("2")))
+	assert.Len(t, healthy.pushed(), 2)
+	assert.Len(t, restarting.pushed(), 2)
+	assert.Equal(t, "2", healthy.pushed()[1].GetResourceVersion())
+}
+
+func TestPushesCarrySecretValues(t *testing.T) {
+	sidecar := newTestSidecar(0)
+	defer sidecar.server.Close()
+
+	client := newTestKubeClient(sidecar.subset("app-1"))
+	_, err := client.CoreV1().Secrets("default").Create(context.TODO(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "storage", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("testaccountkey")},
+	}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	es := newTestEventstore("1")
+	es.Spec.Metadata = []v1alpha1.MetadataItem{
+		{Name: "storageAccountKey", SecretKeyRef: v1alpha1.SecretKeyRef{Name: "storage", Key: "key"}},
+		{Name: "tableNameSuffix", SecretKeyRef: v1alpha1.SecretKeyRef{Name: "missing", Key: "suffix"}},
+	}
+
+	p := newEventStoreProcessor(client, eventstorefake.NewSimpleClientset(), record.NewFakeRecorder(100))
+	assert.Nil(t, p.ProcessChanged(es))
+
+	assert.Len(t, sidecar.pushed(), 1)
+	metadata := sidecar.pushed()[0].Spec.Metadata
+	assert.Equal(t, "testaccountkey", metadata[0].Value)
+	assert.Empty(t, metadata[1].Value, "missing secrets are left empty")
+
+	// the informer's object isn't changed
+	assert.Empty(t, es.Spec.Metadata[0].Value)
+}
+
+func TestPushesAreScopedToTheNamespace(t *testing.T) {
+	sidecar := newTestSidecar(0)
+	defer sidecar.server.Close()
+
+	other := newTestSidecar(0)
+	defer other.server.Close()
+
+	client := newTestKubeClient(sidecar.subset("app-1"))
+	addTestService(t, client, "other", other.subset("app-2"))
+
+	p := newEventStoreProcessor(client, eventstorefake.NewSimpleClientset(), record.NewFakeRecorder(100))
+	assert.Nil(t, p.ProcessChanged(newTestEventstore("1")))
+
+	assert.Len(t, sidecar.pushed(), 1)
+	assert.Empty(t, other.pushed(), "sidecars don't get the Eventstores of other namespaces")
+}
+
+func TestRotatedSecretsArePushed(t *testing.T) {
+	sidecar := newTestSidecar(0)
+	defer sidecar.server.Close()
+
+	client := newTestKubeClient(sidecar.subset("app-1"))
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "storage", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("testaccountkey")},
+	}
+	_, err := client.CoreV1().Secrets("default").Create(context.TODO(), secret, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	es := newTestEventstore("1")
+	es.Spec.Metadata = []v1alpha1.MetadataItem{
+		{Name: "storageAccountKey", SecretKeyRef: v1alpha1.SecretKeyRef{Name: "storage", Key: "key"}},
+	}
+
+	p := newEventStoreProcessor(client, eventstorefake.NewSimpleClientset(), record.NewFakeRecorder(100))
+	assert.Nil(t, p.ProcessChanged(es))
+	assert.Nil(t, p.ProcessChanged(es))
+	assert.Len(t, sidecar.pushed(), 1)
+
+	// the resync processes the Eventstore again with the same generation
+	secret.Data["key"] = []byte("rotatedaccountkey")
+	_, err = client.CoreV1().Secrets("default").Update(context.TODO(), secret, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+
+	assert.Nil(t, p.ProcessChanged(es))
+	assert.Len(t, sidecar.pushed(), 2)
+	assert.Equal(t, "rotatedaccountkey", sidecar.pushed()[1].Spec.Metadata[0].Value)
+}
+
+func TestStatusIsWrittenAndNotPushed(t *testing.T) {
+	healthy := newTestSidecar(0)
+	defer healthy.server.Close()
+
+	restarting := newTestSidecar(1)
+	defer restarting.server.Close()
+
+	es := newTestEventstore("1")
+	eventstoreClient := eventstorefake.NewSimpleClientset(es)
+	p := newEventStoreProcessor(newTestKubeClient(healthy.subset("app-1"), restarting.subset("app-2")), eventstoreClient, record.NewFakeRecorder(100))
+
+	assert.NotNil(t, p.ProcessChanged(es))
+
+	current, err := eventstoreClient.EventstoreV1alpha1().Eventstores("default").Get(context.TODO(), "teststore", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.False(t, current.Status.Ready)
+	assert.Equal(t, int32(2), current.Status.Sidecars)
+	assert.Equal(t, int64(1), current.Status.ObservedGeneration)
+	assert.Equal(t, "1 of 2 sidecars didn't receive generation 1", current.Status.Message)
+
+	assert.Nil(t, p.ProcessChanged(current))
+
+	current, err = eventstoreClient.EventstoreV1alpha1().Eventstores("default").Get(context.TODO(), "teststore", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.True(t, current.Status.Ready)
+	assert.Empty(t, current.Status.Message)
+
+	// the update of the status has a new resourceVersion, but the same generation
+	current.ResourceVersion = "3"
+	assert.Nil(t, p.ProcessChanged(current))
+	assert.Len(t, healthy.pushed(), 1)
+	assert.Len(t, restarting.pushed(), 1)
+}
+
+func TestPushToSidecarWithNewerConfiguration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer server.Close()
+
+	p := newEventStoreProcessor(fake.NewSimpleClientset(), eventstorefake.NewSimpleClientset(), record.NewFakeRecorder(100)).(*eventstoreProcessor)
+	u, _ := url.Parse(server.URL)
+
+	assert.Nil(t, p.updateSidecar(sidecar{id: "app-1", pod: "default/app-1", address: u.Host}, "teststore", []byte("{}")))
+}
+
+func TestFinalizerIsAdded(t *testing.T) {
+	sidecar := newTestSidecar(0)
+	defer sidecar.server.Close()
+
+	es := newTestEventstore("1")
+	es.Finalizers = nil
+	eventstoreClient := eventstorefake.NewSimpleClientset(es)
+
+	p := newEventStoreProcessor(newTestKubeClient(sidecar.subset("app-1")), eventstoreClient, record.NewFakeRecorder(100))
+	assert.Nil(t, p.ProcessChanged(es))
+
+	// the Eventstore is pushed when the update with the finalizer is processed
+	assert.Empty(t, sidecar.pushed())
+
+	updated, err := eventstoreClient.EventstoreV1alpha1().Eventstores("default").Get(context.TODO(), "teststore", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{eventstoreFinalizer}, updated.GetFinalizers())
+}
+
+func TestDeletionIsPropagatedToSidecars(t *testing.T) {
+	healthy := newTestSidecar(0)
+	defer healthy.server.Close()
+
+	restarting := newTestSidecar(1)
+	defer restarting.server.Close()
+
+	es := newTestEventstore("2")
+	es.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	eventstoreClient := eventstorefake.NewSimpleClientset(es)
+
+	p := newEventStoreProcessor(newTestKubeClient(healthy.subset("app-1"), restarting.subset("app-2")), eventstoreClient, record.NewFakeRecorder(100))
+
+	// the finalizer stays until all sidecars removed the Eventstore
+	assert.NotNil(t, p.ProcessChanged(es))
+	assert.Equal(t, []string{"2"}, healthy.deleted())
+
+	current, err := eventstoreClient.EventstoreV1alpha1().Eventstores("default").Get(context.TODO(), "teststore", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{eventstoreFinalizer}, current.GetFinalizers())
+
+	assert.Nil(t, p.ProcessChanged(es))
+	assert.Equal(t, []string{"2"}, healthy.deleted())
+	assert.Equal(t, []string{"2"}, restarting.deleted())
+
+	current, err = eventstoreClient.EventstoreV1alpha1().Eventstores("default").Get(context.TODO(), "teststore", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, current.GetFinalizers())
+}
+
+func TestDeletionIsScopedToTheNamespace(t *testing.T) {
+	sidecar := newTestSidecar(0)
+	defer sidecar.server.Close()
+
+	other := newTestSidecar(0)
+	defer other.server.Close()
+
+	client := newTestKubeClient(sidecar.subset("app-1"))
+	addTestService(t, client, "other", other.subset("app-2"))
+
+	es := newTestEventstore("2")
+	es.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	p := newEventStoreProcessor(client, eventstorefake.NewSimpleClientset(es), record.NewFakeRecorder(100))
+	assert.Nil(t, p.ProcessChanged(es))
+
+	assert.Equal(t, []string{"2"}, sidecar.deleted())
+	assert.Empty(t, other.deleted(), "an Eventstore with the same name in another namespace is kept")
+}
+
+func TestDeletionIsReleasedAfterTimeout(t *testing.T) {
+	unreachable := newTestSidecar(-1)
+	unreachable.server.Close()
+
+	es := newTestEventstore("2")
+	es.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(-2 * finalizerTimeout)}
+	eventstoreClient := eventstorefake.NewSimpleClientset(es)
+
+	p := newEventStoreProcessor(newTestKubeClient(unreachable.subset("app-1")), eventstoreClient, record.NewFakeRecorder(100))
+	assert.Nil(t, p.ProcessChanged(es))
+
+	current, err := eventstoreClient.EventstoreV1alpha1().Eventstores("default").Get(context.TODO(), "teststore", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, current.GetFinalizers())
+}
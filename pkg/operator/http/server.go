@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	eventstoreclient "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned"
 	routing "github.com/qiangxue/fasthttp-routing"
@@ -20,18 +23,23 @@ type Server interface {
 type server struct {
 	port             int
 	eventstoreClient *eventstoreclient.Clientset
+	watches          *Watches
 	router           *routing.Router
 }
 
 // NewServer creates a new server
-func NewServer(port int, eventstoreClient *eventstoreclient.Clientset) Server {
+func NewServer(port int, eventstoreClient *eventstoreclient.Clientset, watches *Watches) Server {
 	s := &server{
 		port:             port,
 		eventstoreClient: eventstoreClient,
+		watches:          watches,
 		router:           routing.New(),
 	}
 
+	// /eventstores?namespace=default, all namespaces without namespace
 	s.router.Get("/eventstores", s.onGetComponents)
+	// /eventstores/watch?names=a,b&namespace=default&resourceVersion=42&timeoutSeconds=30
+	s.router.Get("/eventstores/watch", s.onWatchComponents)
 	return s
 }
 
@@ -46,7 +54,7 @@ func (s *server) StartNonBlocking() {
 
 func (s *server) onGetComponents(c *routing.Context) error {
 	stores, err := s.eventstoreClient.EventstoreV1alpha1().
-		Eventstores(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+		Eventstores(string(c.QueryArgs().Peek("namespace"))).List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
 		msg := NewErrorResponse("ERR_GETTING_EVENTSTORES", fmt.Sprintf("can't get EventStores %s", err))
 		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
@@ -63,3 +71,66 @@ func (s *server) onGetComponents(c *routing.Context) error {
 	respondWithJSON(c.RequestCtx, fasthttp.StatusOK, data)
 	return nil
 }
+
+func (s *server) onWatchComponents(c *routing.Context) error {
+	args := c.QueryArgs()
+
+	filter := WatchFilter{
+		Namespace: string(args.Peek("namespace")),
+	}
+
+	for _, n := range strings.Split(string(args.Peek("names")), ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			filter.Names = append(filter.Names, n)
+		}
+	}
+
+	if len(filter.Names) == 0 {
+		msg := NewErrorResponse("ERR_WATCH_EVENTSTORES", "names of the Eventstores to watch are missing")
+		respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+		return nil
+	}
+
+	timeout := defaultWatchTimeout
+	if value := args.Peek("timeoutSeconds"); len(value) != 0 {
+		seconds, err := strconv.Atoi(string(value))
+		if err != nil || seconds < 0 {
+			msg := NewErrorResponse("ERR_WATCH_EVENTSTORES", fmt.Sprintf("invalid timeoutSeconds %s", value))
+			respondWithError(c.RequestCtx, fasthttp.StatusBadRequest, msg)
+			return nil
+		}
+
+		timeout = time.Duration(seconds) * time.Second
+		if timeout > maxWatchTimeout {
+			timeout = maxWatchTimeout
+		}
+	}
+
+	response, err := s.watches.Watch(filter, string(args.Peek("resourceVersion")), timeout)
+
+	switch err.(type) {
+	case nil:
+	case errExpired:
+		msg := NewErrorResponse("ERR_WATCH_EXPIRED", err.Error())
+		respondWithError(c.RequestCtx, fasthttp.StatusGone, msg)
+		return nil
+	case errNotSynced:
+		msg := NewErrorResponse("ERR_WATCH_NOT_SYNCED", err.Error())
+		respondWithError(c.RequestCtx, fasthttp.StatusServiceUnavailable, msg)
+		return nil
+	default:
+		msg := NewErrorResponse("ERR_WATCH_EVENTSTORES", err.Error())
+		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+		return nil
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		msg := NewErrorResponse("ERR_SERIALIZE_EVENTSTORES", fmt.Sprintf("can't serialize watch events %s", err))
+		respondWithError(c.RequestCtx, fasthttp.StatusInternalServerError, msg)
+		return nil
+	}
+
+	respondWithJSON(c.RequestCtx, fasthttp.StatusOK, data)
+	return nil
+}
@@ -0,0 +1,283 @@
+package http
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Types of watch events
+const (
+	EventAdded    = "ADDED"
+	EventModified = "MODIFIED"
+	EventDeleted  = "DELETED"
+)
+
+const (
+	// defaultWatchEvents is the number of recent events kept to resume watches
+	defaultWatchEvents = 1000
+	// defaultWatchTimeout is the time a watch waits for changes, if the client has no own timeout
+	defaultWatchTimeout = 30 * time.Second
+	maxWatchTimeout     = 5 * time.Minute
+)
+
+// WatchEvent is a change of an Eventstore
+type WatchEvent struct {
+	Type   string               `json:"type"`
+	Object *v1alpha1.Eventstore `json:"object"`
+}
+
+// WatchResponse holds the events of a watch. ResourceVersion resumes the watch with the events
+// that follow these ones.
+type WatchResponse struct {
+	ResourceVersion string       `json:"resourceVersion"`
+	Events          []WatchEvent `json:"events"`
+}
+
+// WatchFilter selects the Eventstores of a watch, an empty namespace selects all namespaces
+type WatchFilter struct {
+	Names     []string
+	Namespace string
+}
+
+func (f WatchFilter) matches(es *v1alpha1.Eventstore) bool {
+	if f.Namespace != "" && f.Namespace != es.GetNamespace() {
+		return false
+	}
+
+	for _, n := range f.Names {
+		if n == es.GetName() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Watches keeps the recent changes of Eventstores, so watches of sidecars can be resumed. It's fed
+// by the Eventstore informer of the operator, snapshots are taken from its cache.
+type Watches struct {
+	mutex     sync.Mutex
+	informer  cache.SharedIndexInformer
+	started   bool
+	events    []watchEvent
+	maxEvents int
+	// resourceVersion is the latest resourceVersion seen
+	resourceVersion uint64
+	// compacted is the resourceVersion up to which events are gone, older watches can't be resumed
+	compacted uint64
+	// changed is closed and replaced on every change to wake up waiting watches
+	changed chan struct{}
+}
+
+type watchEvent struct {
+	WatchEvent
+	resourceVersion uint64
+}
+
+// NewWatches creates new Watches that follow the Eventstores of informer
+func NewWatches(informer cache.SharedIndexInformer) *Watches {
+	w := &Watches{
+		informer:  informer,
+		maxEvents: defaultWatchEvents,
+		changed:   make(chan struct{}),
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.record(EventAdded, obj)
+		},
+		UpdateFunc: func(old, obj interface{}) {
+			// resyncs of the informer aren't changes
+			if old.(*v1alpha1.Eventstore).GetResourceVersion() != obj.(*v1alpha1.Eventstore).GetResourceVersion() {
+				w.record(EventModified, obj)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			w.record(EventDeleted, obj)
+		},
+	})
+
+	return w
+}
+
+func (w *Watches) record(typ string, obj interface{}) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		// the resourceVersion of the deletion is unknown, all watches start again
+		w.compact()
+		w.notify()
+		return
+	}
+
+	es, ok := obj.(*v1alpha1.Eventstore)
+	if !ok {
+		return
+	}
+
+	rv, err := strconv.ParseUint(es.GetResourceVersion(), 10, 64)
+	if err != nil {
+		return
+	}
+
+	if es.GetDeletionTimestamp() != nil {
+		// sidecars remove Eventstores as soon as they are being deleted
+		typ = EventDeleted
+	}
+
+	if rv > w.resourceVersion {
+		w.resourceVersion = rv
+	}
+
+	w.events = append(w.events, watchEvent{WatchEvent: WatchEvent{Type: typ, Object: es}, resourceVersion: rv})
+
+	if len(w.events) > w.maxEvents {
+		dropped := w.events[0]
+		w.events = w.events[1:]
+
+		if dropped.resourceVersion > w.compacted {
+			w.compacted = dropped.resourceVersion
+		}
+	}
+
+	w.notify()
+}
+
+func (w *Watches) notify() {
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// errNotSynced is returned by Watch until the informer has synced
+type errNotSynced struct{}
+
+func (errNotSynced) Error() string {
+	return "the Eventstores aren't synced yet"
+}
+
+// errExpired is returned by Watch for a resourceVersion that can't be resumed
+type errExpired struct {
+	resourceVersion string
+}
+
+func (e errExpired) Error() string {
+	return "resourceVersion " + e.resourceVersion + " is too old, start again without resourceVersion"
+}
+
+// Watch returns the changes of the Eventstores selected by filter after resourceVersion. It waits
+// up to timeout for changes. Without resourceVersion all selected Eventstores are returned as
+// added.
+func (w *Watches) Watch(filter WatchFilter, resourceVersion string, timeout time.Duration) (WatchResponse, error) {
+	if !w.informer.HasSynced() {
+		return WatchResponse{}, errNotSynced{}
+	}
+
+	if resourceVersion == "" || resourceVersion == "0" {
+		return w.snapshot(filter), nil
+	}
+
+	rv, err := strconv.ParseUint(resourceVersion, 10, 64)
+	if err != nil {
+		return WatchResponse{}, errExpired{resourceVersion: resourceVersion}
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		response, changed, err := w.since(filter, rv, resourceVersion)
+		if err != nil || len(response.Events) != 0 {
+			return response, err
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return response, nil
+		}
+	}
+}
+
+// snapshot returns the Eventstores in the cache of the informer. The cache is updated before the
+// events are recorded, so its resourceVersion is read first and the snapshot is at least as new.
+func (w *Watches) snapshot(filter WatchFilter) WatchResponse {
+	w.mutex.Lock()
+	w.start()
+	rv := w.resourceVersion
+	w.mutex.Unlock()
+
+	if synced, err := strconv.ParseUint(w.informer.LastSyncResourceVersion(), 10, 64); err == nil && synced > rv {
+		rv = synced
+	}
+
+	response := WatchResponse{
+		ResourceVersion: strconv.FormatUint(rv, 10),
+		Events:          []WatchEvent{},
+	}
+
+	for _, obj := range w.informer.GetStore().List() {
+		if es, ok := obj.(*v1alpha1.Eventstore); ok && es.GetDeletionTimestamp() == nil && filter.matches(es) {
+			response.Events = append(response.Events, WatchEvent{Type: EventAdded, Object: es})
+		}
+	}
+
+	return response
+}
+
+// since returns the events after rv and the channel that is closed on the next change
+func (w *Watches) since(filter WatchFilter, rv uint64, resourceVersion string) (WatchResponse, <-chan struct{}, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.start()
+
+	if rv < w.compacted {
+		return WatchResponse{}, nil, errExpired{resourceVersion: resourceVersion}
+	}
+
+	// a snapshot may be newer than the recorded events
+	latest := w.resourceVersion
+	if rv > latest {
+		latest = rv
+	}
+
+	response := WatchResponse{
+		ResourceVersion: strconv.FormatUint(latest, 10),
+		Events:          []WatchEvent{},
+	}
+
+	for _, e := range w.events {
+		if e.resourceVersion > rv && filter.matches(e.Object) {
+			response.Events = append(response.Events, e.WatchEvent)
+		}
+	}
+
+	return response, w.changed, nil
+}
+
+// start compacts the events of the initial sync. Objects deleted before the operator started
+// aren't among them, so watches of a previous operator can't be resumed.
+func (w *Watches) start() {
+	if w.started {
+		return
+	}
+
+	w.started = true
+	w.compact()
+}
+
+// compact drops all events, watches with an older resourceVersion than the one the informer
+// synced at start again
+func (w *Watches) compact() {
+	w.events = nil
+	w.compacted = w.resourceVersion
+
+	if rv, err := strconv.ParseUint(w.informer.LastSyncResourceVersion(), 10, 64); err == nil && rv > w.compacted {
+		w.compacted = rv
+	}
+}
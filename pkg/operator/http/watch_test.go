@@ -0,0 +1,136 @@
+package http
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	"github.com/AndreasM009/eventstore/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestEventstore(name, resourceVersion string) *v1alpha1.Eventstore {
+	return &v1alpha1.Eventstore{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", ResourceVersion: resourceVersion},
+		Spec:       v1alpha1.EventstoreSpec{Type: "eventstore.inmemory"},
+	}
+}
+
+func newTestWatches(t *testing.T, objects ...runtime.Object) (*Watches, *fake.Clientset, chan struct{}) {
+	client := fake.NewSimpleClientset(objects...)
+	eventstores := client.EventstoreV1alpha1().Eventstores(metav1.NamespaceAll)
+
+	informer := cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return eventstores.List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return eventstores.Watch(context.TODO(), options)
+			},
+		},
+		&v1alpha1.Eventstore{}, 0, cache.Indexers{})
+
+	w := NewWatches(informer)
+	stop := make(chan struct{})
+
+	go informer.Run(stop)
+	assert.True(t, cache.WaitForCacheSync(stop, informer.HasSynced))
+
+	return w, client, stop
+}
+
+func waitForEvents(t *testing.T, w *Watches, filter WatchFilter, resourceVersion string) WatchResponse {
+	response, err := w.Watch(filter, resourceVersion, 5*time.Second)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, response.Events)
+	return response
+}
+
+func TestWatchFollowsChanges(t *testing.T) {
+	w, client, stop := newTestWatches(t, newTestEventstore("orders", "10"), newTestEventstore("customers", "11"))
+	defer close(stop)
+
+	filter := WatchFilter{Names: []string{"orders"}}
+
+	response, err := w.Watch(filter, "", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, "11", response.ResourceVersion)
+	assert.Len(t, response.Events, 1)
+	assert.Equal(t, EventAdded, response.Events[0].Type)
+	assert.Equal(t, "orders", response.Events[0].Object.GetName())
+
+	// without changes the watch times out empty
+	response, err = w.Watch(filter, "11", 10*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, "11", response.ResourceVersion)
+	assert.Empty(t, response.Events)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		client.EventstoreV1alpha1().Eventstores("default").Update(context.TODO(), newTestEventstore("customers", "12"), metav1.UpdateOptions{})
+		client.EventstoreV1alpha1().Eventstores("default").Update(context.TODO(), newTestEventstore("orders", "13"), metav1.UpdateOptions{})
+	}()
+
+	// changes of other Eventstores don't end the watch
+	response = waitForEvents(t, w, filter, "11")
+	assert.Equal(t, "13", response.ResourceVersion)
+	assert.Len(t, response.Events, 1)
+	assert.Equal(t, EventModified, response.Events[0].Type)
+	assert.Equal(t, "13", response.Events[0].Object.GetResourceVersion())
+
+	// the fake clientset keeps the resourceVersion of deleted objects, the API server increments it
+	client.EventstoreV1alpha1().Eventstores("default").Update(context.TODO(), newTestEventstore("orders", "14"), metav1.UpdateOptions{})
+	client.EventstoreV1alpha1().Eventstores("default").Delete(context.TODO(), "orders", metav1.DeleteOptions{})
+
+	assert.Eventually(t, func() bool {
+		response, err := w.Watch(filter, "13", time.Second)
+		return err == nil && len(response.Events) == 2 && response.Events[1].Type == EventDeleted
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWatchExpires(t *testing.T) {
+	w, client, stop := newTestWatches(t, newTestEventstore("orders", "10"))
+	defer close(stop)
+
+	filter := WatchFilter{Names: []string{"orders"}}
+
+	response, err := w.Watch(filter, "", time.Second)
+	assert.Nil(t, err)
+
+	w.mutex.Lock()
+	w.maxEvents = 1
+	w.mutex.Unlock()
+
+	client.EventstoreV1alpha1().Eventstores("default").Update(context.TODO(), newTestEventstore("orders", "11"), metav1.UpdateOptions{})
+	waitForEvents(t, w, filter, response.ResourceVersion)
+	client.EventstoreV1alpha1().Eventstores("default").Update(context.TODO(), newTestEventstore("orders", "12"), metav1.UpdateOptions{})
+	waitForEvents(t, w, filter, "11")
+
+	_, err = w.Watch(filter, "10", time.Second)
+	assert.IsType(t, errExpired{}, err)
+
+	// the client starts again with a snapshot
+	response, err = w.Watch(filter, "", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, "12", response.ResourceVersion)
+	assert.Equal(t, "12", response.Events[0].Object.GetResourceVersion())
+}
+
+func TestTerminatingEventstoresAreDeleted(t *testing.T) {
+	terminating := newTestEventstore("orders", "10")
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	terminating.Finalizers = []string{"eventstore.io/sidecars"}
+
+	w, _, stop := newTestWatches(t, terminating)
+	defer close(stop)
+
+	response, err := w.Watch(WatchFilter{Names: []string{"orders"}}, "", time.Second)
+	assert.Nil(t, err)
+	assert.Empty(t, response.Events)
+}
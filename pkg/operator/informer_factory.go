@@ -2,10 +2,12 @@ package operator
 
 import (
 	"context"
+	"time"
 
 	eventstorev1alphav1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
 	scheme "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
@@ -15,11 +17,13 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
-// createEventstoreIndexInformer creates a new SharedIndexInformer
+// createEventstoreIndexInformer creates a new SharedIndexInformer, that delivers all Eventstores
+// again after resync, if it isn't 0
 func createEventstoreIndexInformer(
 	ctx context.Context,
 	eventstoreClient scheme.Interface,
 	namespace string,
+	resync time.Duration,
 	fieldSelector fields.Selector,
 	labelSelector labels.Selector) cache.SharedIndexInformer {
 	evtClient := eventstoreClient.EventstoreV1alpha1().Eventstores(namespace)
@@ -45,19 +49,59 @@ func createEventstoreIndexInformer(
 			},
 		},
 		&eventstorev1alphav1.Eventstore{},
-		0,
+		resync,
 		cache.Indexers{},
 	)
 }
 
-func createDeploymentIndexInformer(
+// createSubscriptionIndexInformer creates a new SharedIndexInformer for EventstoreSubscriptions
+func createSubscriptionIndexInformer(
 	ctx context.Context,
-	kubernetesClient kubernetes.Interface,
+	eventstoreClient scheme.Interface,
 	namespace string,
 	fieldSelector fields.Selector,
 	labelSelector labels.Selector) cache.SharedIndexInformer {
-	deploymentsClient := kubernetesClient.AppsV1().Deployments(namespace)
+	subscriptionClient := eventstoreClient.EventstoreV1alpha1().EventstoreSubscriptions(namespace)
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if fieldSelector != nil {
+					options.FieldSelector = fieldSelector.String()
+				}
+				if labelSelector != nil {
+					options.LabelSelector = labelSelector.String()
+				}
+				return subscriptionClient.List(ctx, options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				if fieldSelector != nil {
+					options.FieldSelector = fieldSelector.String()
+				}
+				if labelSelector != nil {
+					options.LabelSelector = labelSelector.String()
+				}
+				return subscriptionClient.Watch(ctx, options)
+			},
+		},
+		&eventstorev1alphav1.EventstoreSubscription{},
+		0,
+		cache.Indexers{},
+	)
+}
 
+// listFunc and watchFunc list and watch the objects of a workload informer
+type listFunc = func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error)
+type watchFunc = func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error)
+
+// createWorkloadIndexInformer creates a new SharedIndexInformer for objType listed and watched by
+// list and watchObjects
+func createWorkloadIndexInformer(
+	ctx context.Context,
+	list listFunc,
+	watchObjects watchFunc,
+	objType runtime.Object,
+	fieldSelector fields.Selector,
+	labelSelector labels.Selector) cache.SharedIndexInformer {
 	return cache.NewSharedIndexInformer(
 		&cache.ListWatch{
 			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
@@ -67,7 +111,7 @@ func createDeploymentIndexInformer(
 				if labelSelector != nil {
 					options.LabelSelector = labelSelector.String()
 				}
-				return deploymentsClient.List(ctx, options)
+				return list(ctx, options)
 			},
 			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
 				if fieldSelector != nil {
@@ -76,11 +120,116 @@ func createDeploymentIndexInformer(
 				if labelSelector != nil {
 					options.LabelSelector = labelSelector.String()
 				}
-				return deploymentsClient.Watch(ctx, options)
+				return watchObjects(ctx, options)
 			},
 		},
-		&appsv1.Deployment{},
+		objType,
 		0,
 		cache.Indexers{},
 	)
 }
+
+// createEndpointsIndexInformer creates a new SharedIndexInformer for Endpoints
+func createEndpointsIndexInformer(
+	ctx context.Context,
+	kubernetesClient kubernetes.Interface,
+	namespace string,
+	fieldSelector fields.Selector,
+	labelSelector labels.Selector) cache.SharedIndexInformer {
+	endpointsClient := kubernetesClient.CoreV1().Endpoints(namespace)
+
+	return createWorkloadIndexInformer(
+		ctx,
+		func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return endpointsClient.List(ctx, options)
+		},
+		endpointsClient.Watch,
+		&corev1.Endpoints{},
+		fieldSelector,
+		labelSelector,
+	)
+}
+
+// createDeploymentIndexInformer creates a new SharedIndexInformer for Deployments
+func createDeploymentIndexInformer(
+	ctx context.Context,
+	kubernetesClient kubernetes.Interface,
+	namespace string,
+	fieldSelector fields.Selector,
+	labelSelector labels.Selector) cache.SharedIndexInformer {
+	deploymentsClient := kubernetesClient.AppsV1().Deployments(namespace)
+
+	return createWorkloadIndexInformer(
+		ctx,
+		func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return deploymentsClient.List(ctx, options)
+		},
+		deploymentsClient.Watch,
+		&appsv1.Deployment{},
+		fieldSelector,
+		labelSelector,
+	)
+}
+
+// createStatefulSetIndexInformer creates a new SharedIndexInformer for StatefulSets
+func createStatefulSetIndexInformer(
+	ctx context.Context,
+	kubernetesClient kubernetes.Interface,
+	namespace string,
+	fieldSelector fields.Selector,
+	labelSelector labels.Selector) cache.SharedIndexInformer {
+	statefulSetsClient := kubernetesClient.AppsV1().StatefulSets(namespace)
+
+	return createWorkloadIndexInformer(
+		ctx,
+		func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return statefulSetsClient.List(ctx, options)
+		},
+		statefulSetsClient.Watch,
+		&appsv1.StatefulSet{},
+		fieldSelector,
+		labelSelector,
+	)
+}
+
+// createDaemonSetIndexInformer creates a new SharedIndexInformer for DaemonSets
+func createDaemonSetIndexInformer(
+	ctx context.Context,
+	kubernetesClient kubernetes.Interface,
+	namespace string,
+	fieldSelector fields.Selector,
+	labelSelector labels.Selector) cache.SharedIndexInformer {
+	daemonSetsClient := kubernetesClient.AppsV1().DaemonSets(namespace)
+
+	return createWorkloadIndexInformer(
+		ctx,
+		func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return daemonSetsClient.List(ctx, options)
+		},
+		daemonSetsClient.Watch,
+		&appsv1.DaemonSet{},
+		fieldSelector,
+		labelSelector,
+	)
+}
+
+// createReplicaSetIndexInformer creates a new SharedIndexInformer for ReplicaSets
+func createReplicaSetIndexInformer(
+	ctx context.Context,
+	kubernetesClient kubernetes.Interface,
+	namespace string,
+	fieldSelector fields.Selector,
+	labelSelector labels.Selector) cache.SharedIndexInformer {
+	replicaSetsClient := kubernetesClient.AppsV1().ReplicaSets(namespace)
+
+	return createWorkloadIndexInformer(
+		ctx,
+		func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return replicaSetsClient.List(ctx, options)
+		},
+		replicaSetsClient.Watch,
+		&appsv1.ReplicaSet{},
+		fieldSelector,
+		labelSelector,
+	)
+}
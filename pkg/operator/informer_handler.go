@@ -1,20 +1,84 @@
 package operator
 
 import (
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
 
-func newInformerHandler(queue workqueue.RateLimitingInterface) cache.ResourceEventHandlerFuncs {
+// newInformerHandler enqueues the keys of changed objects, deleted is called with the final state
+// of deleted objects before their key is enqueued
+func newInformerHandler(queue workqueue.RateLimitingInterface, deleted func(key string, obj interface{})) cache.ResourceEventHandlerFuncs {
+	enqueue := func(obj interface{}) {
+		if key, err := cache.MetaNamespaceKeyFunc(obj); err == nil {
+			queue.Add(key)
+		}
+	}
+
 	return cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			queue.Add(obj)
-		},
+		AddFunc: enqueue,
 		UpdateFunc: func(_, obj interface{}) {
-			queue.Add(obj)
+			enqueue(obj)
 		},
 		DeleteFunc: func(obj interface{}) {
-			queue.Add(obj)
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				return
+			}
+
+			deleted(key, obj)
+			queue.Add(key)
+		},
+	}
+}
+
+// newSubscriptionEnqueueHandler enqueues the subscriptions of an Eventstore whenever it changes,
+// so their deliveries are restarted with the new configuration of the store
+func newSubscriptionEnqueueHandler(subscriptions cache.SharedIndexInformer, queue workqueue.RateLimitingInterface) cache.ResourceEventHandlerFuncs {
+	enqueue := func(obj interface{}) {
+		es, ok := obj.(*v1alpha1.Eventstore)
+		if !ok {
+			return
+		}
+
+		for _, o := range subscriptions.GetIndexer().List() {
+			s := o.(*v1alpha1.EventstoreSubscription)
+			if s.GetNamespace() == es.GetNamespace() && s.Spec.Eventstore == es.GetName() {
+				queue.Add(s.GetNamespace() + "/" + s.GetName())
+			}
+		}
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(_, obj interface{}) {
+			enqueue(obj)
+		},
+	}
+}
+
+// newSidecarEnqueueHandler enqueues the Eventstores in the namespace of changed Endpoints of the
+// eventstore services, so sidecars of new pods get the Eventstores with their secret values
+func newSidecarEnqueueHandler(eventstores cache.SharedIndexInformer, queue workqueue.RateLimitingInterface) cache.ResourceEventHandlerFuncs {
+	enqueue := func(obj interface{}) {
+		endpoints, ok := obj.(*corev1.Endpoints)
+		if !ok {
+			return
+		}
+
+		for _, o := range eventstores.GetIndexer().List() {
+			es := o.(*v1alpha1.Eventstore)
+			if es.GetNamespace() == endpoints.GetNamespace() {
+				queue.Add(es.GetNamespace() + "/" + es.GetName())
+			}
+		}
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(_, obj interface{}) {
+			enqueue(obj)
 		},
 	}
 }
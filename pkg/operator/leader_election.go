@@ -0,0 +1,164 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Defaults of the leader election
+const (
+	DefaultLeaseName     = "eventstore-operator"
+	DefaultLeaseDuration = 15 * time.Second
+	DefaultRenewDeadline = 10 * time.Second
+	DefaultRetryPeriod   = 2 * time.Second
+)
+
+// ErrLeadershipLost is returned by RunLeaderElected, if the replica stopped leading before its
+// context was done. The informers of the operator can't be started again, so the replica has to be
+// restarted.
+var ErrLeadershipLost = errors.New("operator: lost leadership")
+
+// LeaderElection configures the election of the replica that runs the informers and workers of the
+// operator. Replicas compete for the Lease Name in Namespace.
+type LeaderElection struct {
+	Enabled       bool
+	Namespace     string
+	Name          string
+	Identity      string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// NewLeaderElection creates a LeaderElection with the default durations, the identity is the
+// hostname, which is the pod name in Kubernetes
+func NewLeaderElection(namespace string) LeaderElection {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "eventstore-operator"
+	}
+
+	return LeaderElection{
+		Enabled:       true,
+		Namespace:     namespace,
+		Name:          DefaultLeaseName,
+		Identity:      fmt.Sprintf("%s_%s", hostname, uuid.NewUUID()),
+		LeaseDuration: DefaultLeaseDuration,
+		RenewDeadline: DefaultRenewDeadline,
+		RetryPeriod:   DefaultRetryPeriod,
+	}
+}
+
+// RunLeaderElected runs op while this replica is the leader. It returns when ctx is done, the lease
+// is released then, so another replica takes over without waiting for it to expire. Without
+// leader election op runs until ctx is done. Only the leader initializes the custom resource
+// definitions, replicas with different conversion configurations don't overwrite each other.
+func RunLeaderElected(ctx context.Context, kubeClient kubernetes.Interface, election LeaderElection, op Operator, conversion *apiextensionsv1.WebhookClientConfig) error {
+	if !election.Enabled {
+		done, err := start(ctx, op, conversion)
+		if err != nil {
+			return err
+		}
+
+		<-done
+		return nil
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      election.Name,
+			Namespace: election.Namespace,
+		},
+		Client: kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: election.Identity,
+		},
+	}
+
+	electionContext, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// started is closed when this replica becomes the leader, stopped receives the result of the
+	// operator when it stopped
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            election.Name,
+		LeaseDuration:   election.LeaseDuration,
+		RenewDeadline:   election.RenewDeadline,
+		RetryPeriod:     election.RetryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leading context.Context) {
+				close(started)
+				log.Printf("Operator %s is the leader\n", election.Identity)
+
+				done, err := start(leading, op, conversion)
+				if err != nil {
+					// give up the lease, another replica may succeed
+					cancel()
+					stopped <- err
+					return
+				}
+
+				<-done
+				// an operator that stopped on its own doesn't lead anymore
+				cancel()
+				stopped <- nil
+			},
+			OnStoppedLeading: func() {
+				log.Printf("Operator %s stopped leading\n", election.Identity)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != election.Identity {
+					log.Printf("Operator %s is the leader\n", identity)
+				}
+			},
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("operator: invalid leader election: %s", err)
+	}
+
+	elector.Run(electionContext)
+
+	select {
+	case <-started:
+	default:
+		return nil
+	}
+
+	// the informers and workers are stopped with the leading context
+	if err := <-stopped; err != nil {
+		return err
+	}
+
+	if ctx.Err() == nil {
+		return ErrLeadershipLost
+	}
+
+	return nil
+}
+
+// start initializes the custom resource definitions and runs op
+func start(ctx context.Context, op Operator, conversion *apiextensionsv1.WebhookClientConfig) (<-chan struct{}, error) {
+	if err := op.InitCustomResourceDefinitions(conversion); err != nil {
+		return nil, fmt.Errorf("error creating CustomResourceDefinitions: %s", err)
+	}
+
+	return op.Run(ctx)
+}
@@ -0,0 +1,151 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testOperator counts the replicas running it and the initializations of the custom resource definitions
+type testOperator struct {
+	running     *int32
+	initialized *int32
+	initErr     error
+	started     chan string
+	name        string
+}
+
+func (o *testOperator) Run(ctx context.Context) (<-chan struct{}, error) {
+	atomic.AddInt32(o.running, 1)
+	o.started <- o.name
+
+	done := make(chan struct{})
+
+	go func() {
+		<-ctx.Done()
+		atomic.AddInt32(o.running, -1)
+		close(done)
+	}()
+
+	return done, nil
+}
+
+func (o *testOperator) InitCustomResourceDefinitions(*apiextensionsv1.WebhookClientConfig) error {
+	if o.initErr != nil {
+		return o.initErr
+	}
+
+	atomic.AddInt32(o.initialized, 1)
+	return nil
+}
+
+func testLeaderElection(identity string) LeaderElection {
+	return LeaderElection{
+		Enabled:       true,
+		Namespace:     "default",
+		Name:          DefaultLeaseName,
+		Identity:      identity,
+		LeaseDuration: time.Minute,
+		RenewDeadline: 500 * time.Millisecond,
+		RetryPeriod:   50 * time.Millisecond,
+	}
+}
+
+func TestRunLeaderElectedRunsOneReplica(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	running := int32(0)
+	initialized := int32(0)
+	started := make(chan string, 2)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	result1 := make(chan error, 1)
+	result2 := make(chan error, 1)
+
+	go func() {
+		result1 <- RunLeaderElected(ctx1, client, testLeaderElection("first"), &testOperator{running: &running, initialized: &initialized, started: started, name: "first"}, nil)
+	}()
+
+	assert.Equal(t, "first", <-started)
+
+	go func() {
+		result2 <- RunLeaderElected(ctx2, client, testLeaderElection("second"), &testOperator{running: &running, initialized: &initialized, started: started, name: "second"}, nil)
+	}()
+
+	// the second replica waits for the lease
+	select {
+	case name := <-started:
+		t.Fatalf("%s started while the first replica leads", name)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&running))
+	// only the leader initializes the custom resource definitions
+	assert.Equal(t, int32(1), atomic.LoadInt32(&initialized))
+
+	// the released lease is taken over long before it expires
+	cancel1()
+	assert.Nil(t, <-result1)
+
+	select {
+	case name := <-started:
+		assert.Equal(t, "second", name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second replica didn't take over the lease")
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&running))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&initialized))
+
+	cancel2()
+	assert.Nil(t, <-result2)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&running))
+}
+
+func TestRunLeaderElectedWithoutElection(t *testing.T) {
+	running := int32(0)
+	initialized := int32(0)
+	started := make(chan string, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	election := testLeaderElection("only")
+	election.Enabled = false
+
+	result := make(chan error, 1)
+	go func() {
+		result <- RunLeaderElected(ctx, nil, election, &testOperator{running: &running, initialized: &initialized, started: started, name: "only"}, nil)
+	}()
+
+	assert.Equal(t, "only", <-started)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&initialized))
+
+	cancel()
+	assert.Nil(t, <-result)
+}
+
+func TestRunLeaderElectedRejectsInvalidDurations(t *testing.T) {
+	election := testLeaderElection("invalid")
+	election.RenewDeadline = 2 * election.LeaseDuration
+
+	err := RunLeaderElected(context.Background(), fake.NewSimpleClientset(), election, &testOperator{}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestRunLeaderElectedFailsIfTheDefinitionsCantBeInitialized(t *testing.T) {
+	running := int32(0)
+	started := make(chan string, 1)
+
+	op := &testOperator{running: &running, initErr: errors.New("forbidden"), started: started, name: "first"}
+
+	err := RunLeaderElected(context.Background(), fake.NewSimpleClientset(), testLeaderElection("first"), op, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&running))
+}
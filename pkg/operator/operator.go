@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"log"
+	"time"
 
 	eventstorev1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
 	eventstore "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
@@ -16,57 +19,117 @@ import (
 )
 
 const (
-	eventStoreWorker = "EventStore"
-	deploymentWorker = "Deployment"
+	eventStoreWorker   = "EventStore"
+	deploymentWorker   = "Deployment"
+	statefulSetWorker  = "StatefulSet"
+	daemonSetWorker    = "DaemonSet"
+	replicaSetWorker   = "ReplicaSet"
+	subscriptionWorker = "Subscription"
+
+	// eventstoreResync is the interval in which all Eventstores are processed again, so rotated
+	// secrets are pushed to the sidecars
+	eventstoreResync = 5 * time.Minute
+	// maxRetryDelay caps the backoff of failed items, they are retried until they succeed
+	maxRetryDelay = time.Minute
 )
 
 // Operator interface
 type Operator interface {
 	Run(context.Context) (<-chan struct{}, error)
-	InitCustomResourceDefinitions() error
+	InitCustomResourceDefinitions(conversion *apiextensionsv1.WebhookClientConfig) error
 }
 
 type operator struct {
-	eventstoreClient    *eventstore.Clientset
-	kubernetesClient    *kubernetes.Clientset
-	extensionClient     *apiextensionsclient.Clientset
-	eventstoreInformer  cache.SharedIndexInformer
-	deploymentInformer  cache.SharedIndexInformer
-	eventstoreQueue     workqueue.RateLimitingInterface
-	deploymentQueue     workqueue.RateLimitingInterface
-	eventstoreWorker    QueueWorker
-	deploymentWorker    QueueWorker
-	eventstoreProcessor Processor
-	deploymentProcessor Processor
+	eventstoreClient      *eventstore.Clientset
+	kubernetesClient      *kubernetes.Clientset
+	extensionClient       *apiextensionsclient.Clientset
+	eventstoreInformer    cache.SharedIndexInformer
+	subscriptionInformer  cache.SharedIndexInformer
+	eventstoreQueue       workqueue.RateLimitingInterface
+	subscriptionQueue     workqueue.RateLimitingInterface
+	eventstoreWorker      QueueWorker
+	subscriptionWorker    QueueWorker
+	eventstoreProcessor   Processor
+	workloadProcessor     Processor
+	subscriptionProcessor Processor
+	// stopRecording stops the recorder of the Events of the processors
+	stopRecording func()
+	// workloads are the controllers of the kinds of workloads whose sidecars get a service
+	workloads []*workloadController
+	// endpointsInformer follows the Endpoints of the eventstore services, i.e. the sidecars
+	endpointsInformer cache.SharedIndexInformer
+}
+
+// workloadController processes the workloads of a kind with the workload processor
+type workloadController struct {
+	name     string
+	informer cache.SharedIndexInformer
+	queue    workqueue.RateLimitingInterface
+	worker   QueueWorker
+}
+
+func newWorkloadController(name string, informer cache.SharedIndexInformer, processor Processor) *workloadController {
+	queue := newRateLimitingQueue()
+
+	return &workloadController{
+		name:     name,
+		informer: informer,
+		queue:    queue,
+		worker:   newQueueWorker(name, informer, queue, processor.ProcessChanged, processor.ProcessDeleted),
+	}
+}
+
+// newRateLimitingQueue creates a queue that delays failed items exponentially up to maxRetryDelay
+func newRateLimitingQueue() workqueue.RateLimitingInterface {
+	return workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(5*time.Millisecond, maxRetryDelay))
 }
 
 // NewOperator creates a new Eventstore Operator
 func NewOperator(eventstoreClient *eventstore.Clientset, kubernetesClient *kubernetes.Clientset, extensionClient *apiextensionsclient.Clientset) Operator {
+	recorder, stopRecording := newEventRecorder(kubernetesClient)
+
 	op := &operator{
 		kubernetesClient: kubernetesClient,
 		eventstoreClient: eventstoreClient,
 		extensionClient:  extensionClient,
 		eventstoreInformer: createEventstoreIndexInformer(
+			context.TODO(), eventstoreClient, metav1.NamespaceAll, eventstoreResync, nil, nil),
+		eventstoreQueue:     newRateLimitingQueue(),
+		eventstoreProcessor: newEventStoreProcessor(kubernetesClient, eventstoreClient, recorder),
+		workloadProcessor:   newWorkloadProcessor(kubernetesClient, recorder),
+		subscriptionInformer: createSubscriptionIndexInformer(
 			context.TODO(), eventstoreClient, metav1.NamespaceAll, nil, nil),
-		eventstoreQueue:     workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
-		eventstoreProcessor: newEventStoreProcessor(kubernetesClient),
-		deploymentInformer: createDeploymentIndexInformer(
-			context.TODO(), kubernetesClient, metav1.NamespaceAll, nil, nil),
-		deploymentQueue:     workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
-		deploymentProcessor: newDeploymentProcessor(kubernetesClient),
+		subscriptionQueue: newRateLimitingQueue(),
+		endpointsInformer: createEndpointsIndexInformer(
+			context.TODO(), kubernetesClient, metav1.NamespaceAll, nil,
+			labels.SelectorFromSet(map[string]string{eventstoreEnabledKey: "true"})),
+		subscriptionProcessor: newSubscriptionProcessor(kubernetesClient, eventstoreClient, recorder),
+		stopRecording:         stopRecording,
 	}
 
 	op.eventstoreWorker = newQueueWorker(
 		eventStoreWorker, op.eventstoreInformer,
 		op.eventstoreQueue, op.eventstoreProcessor.ProcessChanged, op.eventstoreProcessor.ProcessDeleted)
 
-	op.eventstoreInformer.AddEventHandler(newInformerHandler(op.eventstoreQueue))
+	// the Endpoints inherit the labels of their service
+	op.endpointsInformer.AddEventHandler(newSidecarEnqueueHandler(op.eventstoreInformer, op.eventstoreQueue))
+
+	op.workloads = []*workloadController{
+		newWorkloadController(deploymentWorker, createDeploymentIndexInformer(
+			context.TODO(), kubernetesClient, metav1.NamespaceAll, nil, nil), op.workloadProcessor),
+		newWorkloadController(statefulSetWorker, createStatefulSetIndexInformer(
+			context.TODO(), kubernetesClient, metav1.NamespaceAll, nil, nil), op.workloadProcessor),
+		newWorkloadController(daemonSetWorker, createDaemonSetIndexInformer(
+			context.TODO(), kubernetesClient, metav1.NamespaceAll, nil, nil), op.workloadProcessor),
+		newWorkloadController(replicaSetWorker, createReplicaSetIndexInformer(
+			context.TODO(), kubernetesClient, metav1.NamespaceAll, nil, nil), op.workloadProcessor),
+	}
 
-	op.deploymentWorker = newQueueWorker(
-		deploymentWorker, op.deploymentInformer,
-		op.deploymentQueue, op.deploymentProcessor.ProcessChanged, op.deploymentProcessor.ProcessDeleted)
+	op.subscriptionWorker = newQueueWorker(
+		subscriptionWorker, op.subscriptionInformer,
+		op.subscriptionQueue, op.subscriptionProcessor.ProcessChanged, op.subscriptionProcessor.ProcessDeleted)
 
-	op.deploymentInformer.AddEventHandler(newInformerHandler(op.deploymentQueue))
+	op.eventstoreInformer.AddEventHandler(newSubscriptionEnqueueHandler(op.subscriptionInformer, op.subscriptionQueue))
 
 	return op
 }
@@ -74,6 +137,11 @@ func NewOperator(eventstoreClient *eventstore.Clientset, kubernetesClient *kuber
 func (op *operator) Run(ctx context.Context) (<-chan struct{}, error) {
 	stopContext, cancel := context.WithCancel(context.Background())
 
+	go func() {
+		<-stopContext.Done()
+		op.stopRecording()
+	}()
+
 	go func() {
 		// stop worker
 		defer op.eventstoreQueue.ShutDown()
@@ -82,11 +150,27 @@ func (op *operator) Run(ctx context.Context) (<-chan struct{}, error) {
 		cancel()
 	}()
 
+	for _, w := range op.workloads {
+		go func(w *workloadController) {
+			// stop worker
+			defer w.queue.ShutDown()
+			w.informer.Run(stopContext.Done())
+			log.Printf("%s SharedIndexInformer stopped\n", w.name)
+			cancel()
+		}(w)
+	}
+
+	go func() {
+		op.endpointsInformer.Run(stopContext.Done())
+		log.Println("Endpoints SharedIndexInformer stopped")
+		cancel()
+	}()
+
 	go func() {
 		// stop worker
-		defer op.deploymentQueue.ShutDown()
-		op.deploymentInformer.Run(stopContext.Done())
-		log.Println("Deployment SharedIndexInformer stopped")
+		defer op.subscriptionQueue.ShutDown()
+		op.subscriptionInformer.Run(stopContext.Done())
+		log.Println("Subscription SharedIndexInformer stopped")
 		cancel()
 	}()
 
@@ -110,12 +194,27 @@ func (op *operator) Run(ctx context.Context) (<-chan struct{}, error) {
 	}()
 
 	op.eventstoreWorker.Run(stopContext.Done())
-	op.deploymentWorker.Run(stopContext.Done())
+	for _, w := range op.workloads {
+		w.worker.Run(stopContext.Done())
+	}
+
+	op.subscriptionWorker.Run(stopContext.Done())
 
 	return stopContext.Done(), nil
 }
 
-// InitCustomResourceDefinitions create custom resources
-func (op *operator) InitCustomResourceDefinitions() error {
-	return eventstorev1alpha1.CreateCustomResourceDefinition("", op.extensionClient)
+// NewEventstoreInformer creates an informer of the Eventstores in all namespaces. It's independent
+// of the operator, so it runs on replicas that aren't the leader, too.
+func NewEventstoreInformer(eventstoreClient eventstore.Interface) cache.SharedIndexInformer {
+	return createEventstoreIndexInformer(context.TODO(), eventstoreClient, metav1.NamespaceAll, 0, nil, nil)
+}
+
+// InitCustomResourceDefinitions create custom resources. Without the conversion webhook only the
+// v1alpha1 version of Eventstores is served.
+func (op *operator) InitCustomResourceDefinitions(conversion *apiextensionsv1.WebhookClientConfig) error {
+	if err := eventstorev1alpha1.CreateCustomResourceDefinition("", conversion, op.extensionClient); err != nil {
+		return err
+	}
+
+	return eventstorev1alpha1.CreateSubscriptionCustomResourceDefinition("", op.extensionClient)
 }
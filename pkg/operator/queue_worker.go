@@ -3,6 +3,7 @@ package operator
 import (
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -20,27 +21,37 @@ type QueueWorker interface {
 type changedEvent = func(obj interface{}) error
 type deletedEvent = func(obj interface{}) error
 
+// queueworker processes the objects of an informer. The queue holds their keys, so an object that
+// changes while it's queued or retried is processed once in its latest state.
 type queueworker struct {
 	name         string
 	informer     cache.SharedIndexInformer
 	queue        workqueue.RateLimitingInterface
 	changedEvent changedEvent
 	deletedEvent deletedEvent
+	mutex        sync.Mutex
+	// deleted holds the final state of deleted objects by key, until their deletion is processed
+	deleted map[string]interface{}
 }
 
+// newQueueWorker creates a worker that enqueues the changes of informer in queue
 func newQueueWorker(
 	name string,
 	informer cache.SharedIndexInformer,
 	queue workqueue.RateLimitingInterface,
 	changed changedEvent,
 	deleted deletedEvent) QueueWorker {
-	return &queueworker{
+	qw := &queueworker{
 		name:         name,
 		informer:     informer,
 		queue:        queue,
 		changedEvent: changed,
 		deletedEvent: deleted,
+		deleted:      map[string]interface{}{},
 	}
+
+	informer.AddEventHandler(newInformerHandler(queue, qw.recordDeletion))
+	return qw
 }
 
 func (qw *queueworker) Run(stop <-chan struct{}) {
@@ -69,28 +80,60 @@ func (qw *queueworker) processItems() bool {
 
 	if err == nil {
 		qw.queue.Forget(obj)
-	} else if qw.queue.NumRequeues(obj) < 20 {
-		qw.queue.AddRateLimited(obj)
 	} else {
-		qw.queue.Forget(obj)
-		err := fmt.Errorf("Worker %s, error processing (giving up): %v", qw.name, err)
+		// failed items are retried until they succeed, the queue caps their backoff
+		err := fmt.Errorf("Worker %s, error processing (retry %d): %v", qw.name, qw.queue.NumRequeues(obj)+1, err)
 		log.Println(err)
 		utilruntime.HandleError(err)
+		qw.queue.AddRateLimited(obj)
 	}
 
 	return true
 }
 
-func (qw *queueworker) processItem(obj interface{}) error {
-	qobj, exists, err := qw.informer.GetIndexer().Get(obj)
+func (qw *queueworker) processItem(key interface{}) error {
+	qobj, exists, err := qw.informer.GetIndexer().GetByKey(key.(string))
 
 	if err != nil {
 		return fmt.Errorf("worker %s Error fetching object", qw.name)
 	}
 
-	if !exists {
-		return qw.deletedEvent(obj)
+	if exists {
+		// the object was created again before its deletion was processed
+		qw.mutex.Lock()
+		delete(qw.deleted, key.(string))
+		qw.mutex.Unlock()
+
+		return qw.changedEvent(qobj)
+	}
+
+	if err := qw.deletedEvent(qw.finalState(key.(string))); err != nil {
+		return err
+	}
+
+	qw.mutex.Lock()
+	delete(qw.deleted, key.(string))
+	qw.mutex.Unlock()
+	return nil
+}
+
+// recordDeletion remembers the final state of a deleted object with key
+func (qw *queueworker) recordDeletion(key string, obj interface{}) {
+	qw.mutex.Lock()
+	defer qw.mutex.Unlock()
+
+	qw.deleted[key] = obj
+}
+
+// finalState returns the final state of the deleted object with key. It's unknown, if the worker
+// didn't see the deletion, e.g. for keys enqueued by other informers.
+func (qw *queueworker) finalState(key string) interface{} {
+	qw.mutex.Lock()
+	defer qw.mutex.Unlock()
+
+	if obj, ok := qw.deleted[key]; ok {
+		return obj
 	}
 
-	return qw.changedEvent(qobj)
+	return cache.DeletedFinalStateUnknown{Key: key}
 }
@@ -0,0 +1,107 @@
+package operator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func newTestQueue() workqueue.RateLimitingInterface {
+	return workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Microsecond, time.Millisecond))
+}
+
+func newTestInformer() cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(&cache.ListWatch{}, &v1alpha1.Eventstore{}, 0, cache.Indexers{})
+}
+
+func TestFailedItemsAreRetriedUntilTheySucceed(t *testing.T) {
+	informer := newTestInformer()
+	es := newTestEventstore("1")
+	assert.Nil(t, informer.GetIndexer().Add(es))
+
+	queue := newTestQueue()
+	defer queue.ShutDown()
+
+	failures := 30
+	changed := func(obj interface{}) error {
+		if failures == 0 {
+			return nil
+		}
+
+		failures--
+		return errors.New("sidecar unreachable")
+	}
+
+	qw := newQueueWorker("test", informer, queue, changed, changed).(*queueworker)
+	queue.Add("default/teststore")
+
+	// more retries than the workqueue's default controllers give up after
+	for i := 0; i < 30; i++ {
+		assert.True(t, qw.processItems())
+		assert.Equal(t, i+1, queue.NumRequeues("default/teststore"))
+	}
+
+	assert.True(t, qw.processItems())
+	assert.Equal(t, 0, failures)
+	assert.Equal(t, 0, queue.NumRequeues("default/teststore"))
+}
+
+func TestChangesOfAQueuedObjectAreProcessedOnce(t *testing.T) {
+	informer := newTestInformer()
+	queue := newTestQueue()
+	defer queue.ShutDown()
+
+	processed := []string{}
+	changed := func(obj interface{}) error {
+		processed = append(processed, obj.(*v1alpha1.Eventstore).GetResourceVersion())
+		return nil
+	}
+
+	qw := newQueueWorker("test", informer, queue, changed, changed).(*queueworker)
+	handler := newInformerHandler(queue, qw.recordDeletion)
+
+	for _, rv := range []string{"1", "2", "3"} {
+		es := newTestEventstore(rv)
+		assert.Nil(t, informer.GetIndexer().Update(es))
+		handler.OnUpdate(nil, es)
+	}
+
+	assert.Equal(t, 1, queue.Len())
+	assert.True(t, qw.processItems())
+	assert.Equal(t, []string{"3"}, processed)
+}
+
+func TestDeletionsAreProcessedWithTheFinalState(t *testing.T) {
+	informer := newTestInformer()
+	queue := newTestQueue()
+	defer queue.ShutDown()
+
+	failures := 1
+	deleted := []interface{}{}
+	remove := func(obj interface{}) error {
+		if failures > 0 {
+			failures--
+			return errors.New("sidecar unreachable")
+		}
+
+		deleted = append(deleted, obj)
+		return nil
+	}
+
+	qw := newQueueWorker("test", informer, queue, nil, remove).(*queueworker)
+	handler := newInformerHandler(queue, qw.recordDeletion)
+
+	es := newTestEventstore("1")
+	handler.OnDelete(es)
+
+	// the final state is kept for the retry
+	assert.True(t, qw.processItems())
+	assert.True(t, qw.processItems())
+	assert.Equal(t, []interface{}{es}, deleted)
+	assert.Empty(t, qw.deleted)
+}
@@ -0,0 +1,105 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// errSecretNotFound is wrapped by the errors of secret references to missing secrets or keys
+var errSecretNotFound = errors.New("secret not found")
+
+// secretValue returns the value of the key ref references in a secret in namespace
+func secretValue(kubeClient kubernetes.Interface, namespace string, ref v1alpha1.SecretKeyRef) (string, error) {
+	secret, err := kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("%w: %s/%s", errSecretNotFound, namespace, ref.Name)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("can't get secret %s/%s: %s", namespace, ref.Name, err)
+	}
+
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s/%s has no key %s", errSecretNotFound, namespace, ref.Name, ref.Key)
+	}
+
+	return string(value), nil
+}
+
+// withSecretValues returns a copy of an Eventstore with the values of its secret references, as
+// the sidecars can't read secrets. Values of references to missing secrets or keys are left
+// empty, the sidecars fail to create the store until the secret exists.
+func withSecretValues(kubeClient kubernetes.Interface, eventstore *v1alpha1.Eventstore) (*v1alpha1.Eventstore, error) {
+	es := eventstore.DeepCopy()
+	metadata := [][]v1alpha1.MetadataItem{es.Spec.Metadata}
+
+	if es.Spec.Sink != nil {
+		metadata = append(metadata, es.Spec.Sink.Metadata)
+	}
+
+	for _, p := range es.Spec.Projections {
+		metadata = append(metadata, p.Metadata)
+	}
+
+	if es.Spec.Migration != nil {
+		metadata = append(metadata, es.Spec.Migration.Metadata)
+	}
+
+	for _, items := range metadata {
+		for i := range items {
+			if items[i].SecretKeyRef.Name == "" {
+				continue
+			}
+
+			value, err := secretValue(kubeClient, es.GetNamespace(), items[i].SecretKeyRef)
+			if errors.Is(err, errSecretNotFound) {
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			items[i].Value = value
+		}
+	}
+
+	return es, nil
+}
+
+// secretKeyRefs returns the secret references of the metadata in the spec of an Eventstore by the
+// path of their metadata item
+func secretKeyRefs(spec v1alpha1.EventstoreSpec) map[string]v1alpha1.SecretKeyRef {
+	result := map[string]v1alpha1.SecretKeyRef{}
+
+	add := func(path string, metadata []v1alpha1.MetadataItem) {
+		for _, m := range metadata {
+			if m.SecretKeyRef.Name != "" {
+				result[fmt.Sprintf("%s[%s]", path, m.Name)] = m.SecretKeyRef
+			}
+		}
+	}
+
+	add("spec.metadata", spec.Metadata)
+
+	if spec.Sink != nil {
+		add("spec.sink.metadata", spec.Sink.Metadata)
+	}
+
+	for i, p := range spec.Projections {
+		add(fmt.Sprintf("spec.projections[%d].metadata", i), p.Metadata)
+	}
+
+	if spec.Migration != nil {
+		add("spec.migration.metadata", spec.Migration.Metadata)
+	}
+
+	return result
+}
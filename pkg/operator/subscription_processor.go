@@ -0,0 +1,342 @@
+package operator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	scheme "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/config"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/eventstore"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/wrapper"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+const defaultMaxAttempts = 10
+
+// subscriptionProcessor runs a delivery for each EventstoreSubscription. The delivery opens
+// the backend of the Eventstore and follows its journal with a consumer per subscription, so
+// deliveries continue from the checkpoint after a restart of the operator. Only Eventstores with
+// a journal and a backend shared by the sidecars can be delivered, the in memory backend lives in
+// each sidecar. Whether the delivery runs is written to the status of the subscription.
+type subscriptionProcessor struct {
+	kubeClient       kubernetes.Interface
+	eventstoreClient scheme.Interface
+	recorder         record.EventRecorder
+	registry         eventstore.Registry
+	mutex            sync.Mutex
+	deliveries       map[string]*delivery
+}
+
+// delivery delivers the journal of a store to the webhook of a subscription
+type delivery struct {
+	// version of the subscription and the configuration the delivery was started with
+	version  string
+	store    store.EventStore
+	consumer *journal.Consumer
+}
+
+// errInvalidSubscription is wrapped by the errors of subscriptions that can't be delivered until
+// they or their Eventstore change, they aren't retried
+var errInvalidSubscription = errors.New("invalid subscription")
+
+func newSubscriptionProcessor(kubeClient kubernetes.Interface, eventstoreClient scheme.Interface, recorder record.EventRecorder) Processor {
+	return &subscriptionProcessor{
+		kubeClient:       kubeClient,
+		eventstoreClient: eventstoreClient,
+		recorder:         recorder,
+		registry:         eventstore.NewRegistry(),
+		deliveries:       map[string]*delivery{},
+	}
+}
+
+func (p *subscriptionProcessor) ProcessChanged(obj interface{}) error {
+	subscription := obj.(*v1alpha1.EventstoreSubscription)
+
+	status := v1alpha1.EventstoreSubscriptionStatus{
+		ObservedGeneration: subscription.GetGeneration(),
+		Ready:              true,
+	}
+
+	err := p.deliver(subscription)
+	if err != nil {
+		status.Ready = false
+		status.Message = err.Error()
+	}
+
+	if errors.Is(err, errInvalidSubscription) {
+		if subscription.Status.Message != status.Message {
+			p.recorder.Event(subscription, corev1.EventTypeWarning, ReasonInvalidSubscription, err.Error())
+		}
+
+		err = nil
+	}
+
+	if statusErr := p.updateStatus(subscription, status); statusErr != nil && err == nil {
+		return statusErr
+	}
+
+	return err
+}
+
+// deliver starts the delivery of a subscription, a running delivery is restarted if the
+// subscription or the configuration of its Eventstore changed
+func (p *subscriptionProcessor) deliver(subscription *v1alpha1.EventstoreSubscription) error {
+	key := subscription.GetNamespace() + "/" + subscription.GetName()
+
+	es, err := p.eventstoreClient.EventstoreV1alpha1().Eventstores(subscription.GetNamespace()).Get(
+		context.TODO(), subscription.Spec.Eventstore, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		p.stop(key)
+		return fmt.Errorf("%w: Eventstore %s not found", errInvalidSubscription, subscription.Spec.Eventstore)
+	}
+
+	if err != nil {
+		return fmt.Errorf("can't get Eventstore %s of subscription %s: %s", subscription.Spec.Eventstore, key, err)
+	}
+
+	backoff, maxAttempts, err := retryPolicyOf(subscription.Spec.RetryPolicy)
+	if err != nil {
+		p.stop(key)
+		return fmt.Errorf("%w: invalid retry policy: %s", errInvalidSubscription, err)
+	}
+
+	if subscription.Spec.URL == "" {
+		p.stop(key)
+		return fmt.Errorf("%w: the subscription has no url", errInvalidSubscription)
+	}
+
+	var secret []byte
+	if ref := subscription.Spec.SigningSecret; ref != nil {
+		value, err := secretValue(p.kubeClient, subscription.GetNamespace(), *ref)
+		if err != nil {
+			p.recordSecretError(subscription, "spec.signingSecret", err)
+			return err
+		}
+
+		secret = []byte(value)
+	}
+
+	cfg, err := p.configurationOf(es)
+	if err != nil {
+		p.recordSecretError(subscription, "Eventstore "+es.GetName(), err)
+		return err
+	}
+
+	if !eventstore.IsShared(cfg) {
+		p.stop(key)
+		return fmt.Errorf("%w: the backend %s of Eventstore %s isn't shared by the sidecars", errInvalidSubscription, cfg.Spec.Type, es.GetName())
+	}
+
+	if !eventstore.HasJournal(cfg) {
+		p.stop(key)
+		return fmt.Errorf("%w: the journal of Eventstore %s isn't enabled", errInvalidSubscription, es.GetName())
+	}
+
+	// secrets are part of the version, so rotated secrets restart the delivery
+	version, err := versionOf(subscription, cfg, secret)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if d, ok := p.deliveries[key]; ok {
+		if d.version == version {
+			return nil
+		}
+
+		d.close()
+		delete(p.deliveries, key)
+	}
+
+	s, err := p.registry.CreateBackend(cfg)
+	if err != nil {
+		if s != nil {
+			wrapper.Close(s)
+		}
+
+		return fmt.Errorf("can't open the backend of Eventstore %s: %s", es.GetName(), err)
+	}
+
+	hook := newWebhook(subscription, secret)
+	consumer := journal.NewConsumer(
+		journal.NewStore(s), "subscription-"+string(subscription.GetUID()), backoff, hook.deliver).WithMaxAttempts(maxAttempts)
+
+	consumer.Start()
+
+	p.deliveries[key] = &delivery{
+		version:  version,
+		store:    s,
+		consumer: consumer,
+	}
+
+	p.recorder.Eventf(subscription, corev1.EventTypeNormal, ReasonDeliveryStarted, "Delivering Eventstore %s to %s", es.GetName(), subscription.Spec.URL)
+	return nil
+}
+
+// stop stops the delivery of the subscription key, if it runs
+func (p *subscriptionProcessor) stop(key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if d, ok := p.deliveries[key]; ok {
+		d.close()
+		delete(p.deliveries, key)
+		log.Printf("Subscription %s stopped\n", key)
+	}
+}
+
+// updateStatus writes status to the subscription, if it changed
+func (p *subscriptionProcessor) updateStatus(subscription *v1alpha1.EventstoreSubscription, status v1alpha1.EventstoreSubscriptionStatus) error {
+	if subscription.Status == status {
+		return nil
+	}
+
+	s := subscription.DeepCopy()
+	s.Status = status
+
+	_, err := p.eventstoreClient.EventstoreV1alpha1().EventstoreSubscriptions(s.GetNamespace()).UpdateStatus(context.TODO(), s, metav1.UpdateOptions{})
+	if apierrors.IsNotFound(err) {
+		// deleted meanwhile
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("can't update status of subscription %s/%s: %s", s.GetNamespace(), s.GetName(), err)
+	}
+
+	return nil
+}
+
+// recordSecretError records an Event on the subscription, if err is caused by a missing secret
+// referenced by what
+func (p *subscriptionProcessor) recordSecretError(subscription *v1alpha1.EventstoreSubscription, what string, err error) {
+	if errors.Is(err, errSecretNotFound) {
+		p.recorder.Eventf(subscription, corev1.EventTypeWarning, ReasonSecretNotFound, "%s: %s", what, err)
+	}
+}
+
+func (p *subscriptionProcessor) ProcessDeleted(obj interface{}) error {
+	// obj may be the final state of a subscription deleted while the informer was disconnected
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return err
+	}
+
+	p.stop(key)
+	return nil
+}
+
+// configurationOf returns the configuration of the backend of an Eventstore with the values of
+// secret references resolved. Sinks, projections and migrations are taken over without their
+// metadata, they only tell that the store has a journal.
+func (p *subscriptionProcessor) configurationOf(es *v1alpha1.Eventstore) (config.Configuration, error) {
+	cfg := config.Configuration{
+		Kind:     es.Kind,
+		Metadata: config.ConfigurationMetadata{Name: es.GetName()},
+		Spec:     config.Spec{Type: es.Spec.Type},
+	}
+
+	if es.Spec.Sink != nil {
+		cfg.Spec.Sink = &config.SinkSpec{Type: es.Spec.Sink.Type}
+	}
+
+	for _, projection := range es.Spec.Projections {
+		cfg.Spec.Projections = append(cfg.Spec.Projections, config.ProjectionSpec{Name: projection.Name, Type: projection.Type})
+	}
+
+	if m := es.Spec.Migration; m != nil {
+		cfg.Spec.Migration = &config.MigrationSpec{Type: m.Type, Stage: m.Stage}
+	}
+
+	for _, m := range es.Spec.Metadata {
+		value := m.Value
+
+		if m.SecretKeyRef.Name != "" {
+			v, err := secretValue(p.kubeClient, es.GetNamespace(), m.SecretKeyRef)
+			if err != nil {
+				return cfg, err
+			}
+
+			value = v
+		}
+
+		cfg.Spec.Metadata = append(cfg.Spec.Metadata, config.SpecMetadata{Name: m.Name, Value: value})
+	}
+
+	return cfg, nil
+}
+
+// versionOf returns the version of a delivery of the subscription from the configuration cfg
+func versionOf(subscription *v1alpha1.EventstoreSubscription, cfg config.Configuration, secret []byte) (string, error) {
+	spec, err := json.Marshal(subscription.Spec)
+	if err != nil {
+		return "", err
+	}
+
+	backend, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write(spec)
+	h.Write(backend)
+	h.Write(secret)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (d *delivery) close() {
+	d.consumer.Close()
+	wrapper.Close(d.store)
+}
+
+func retryPolicyOf(policy v1alpha1.RetryPolicy) (journal.Backoff, int, error) {
+	backoff := journal.DefaultBackoff
+	maxAttempts := defaultMaxAttempts
+
+	if policy.MaxAttempts > 0 {
+		maxAttempts = policy.MaxAttempts
+	}
+
+	if policy.MinBackoff != "" {
+		d, err := time.ParseDuration(policy.MinBackoff)
+		if err != nil || d <= 0 {
+			return backoff, 0, fmt.Errorf("invalid minBackoff %s", policy.MinBackoff)
+		}
+
+		backoff.Min = d
+	}
+
+	if policy.MaxBackoff != "" {
+		d, err := time.ParseDuration(policy.MaxBackoff)
+		if err != nil || d <= 0 {
+			return backoff, 0, fmt.Errorf("invalid maxBackoff %s", policy.MaxBackoff)
+		}
+
+		backoff.Max = d
+	}
+
+	if backoff.Max < backoff.Min {
+		return backoff, 0, fmt.Errorf("maxBackoff %s is less than minBackoff %s", backoff.Max, backoff.Min)
+	}
+
+	return backoff, maxAttempts, nil
+}
@@ -0,0 +1,77 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	eventstorefake "github.com/AndreasM009/eventstore/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestSubscriptionOf(url string) *v1alpha1.EventstoreSubscription {
+	subscription := newTestSubscription(url, v1alpha1.SubscriptionFilter{})
+	subscription.Namespace = "default"
+	subscription.Generation = 1
+	return subscription
+}
+
+// processSubscription processes a subscription and returns it with the status written by the processor
+func processSubscription(t *testing.T, p Processor, eventstoreClient *eventstorefake.Clientset, subscription *v1alpha1.EventstoreSubscription) *v1alpha1.EventstoreSubscription {
+	assert.Nil(t, p.ProcessChanged(subscription), "invalid subscriptions aren't retried")
+
+	current, err := eventstoreClient.EventstoreV1alpha1().EventstoreSubscriptions("default").Get(context.TODO(), subscription.GetName(), metav1.GetOptions{})
+	assert.Nil(t, err)
+	return current
+}
+
+func TestInvalidSubscriptionsHaveAStatus(t *testing.T) {
+	subscription := newTestSubscriptionOf("")
+	eventstoreClient := eventstorefake.NewSimpleClientset(subscription)
+	p := newSubscriptionProcessor(fake.NewSimpleClientset(), eventstoreClient, record.NewFakeRecorder(100))
+
+	current := processSubscription(t, p, eventstoreClient, subscription)
+	assert.False(t, current.Status.Ready)
+	assert.Equal(t, int64(1), current.Status.ObservedGeneration)
+	assert.Equal(t, "invalid subscription: Eventstore teststore not found", current.Status.Message)
+
+	es := newTestEventstore("1")
+	es.Spec.Type = "eventstore.azure.tablestorage"
+	es.Spec.Metadata = []v1alpha1.MetadataItem{{Name: "journal", Value: "true"}}
+	_, err := eventstoreClient.EventstoreV1alpha1().Eventstores("default").Create(context.TODO(), es, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	current = processSubscription(t, p, eventstoreClient, current)
+	assert.False(t, current.Status.Ready)
+	assert.Equal(t, "invalid subscription: the subscription has no url", current.Status.Message)
+
+	current.Spec.URL = "http://localhost:8080"
+	current.Spec.RetryPolicy.MinBackoff = "soon"
+
+	current = processSubscription(t, p, eventstoreClient, current)
+	assert.False(t, current.Status.Ready)
+	assert.Equal(t, "invalid subscription: invalid retry policy: invalid minBackoff soon", current.Status.Message)
+}
+
+func TestUnsupportedEventstoresAreRejected(t *testing.T) {
+	subscription := newTestSubscriptionOf("http://localhost:8080")
+	es := newTestEventstore("1")
+	eventstoreClient := eventstorefake.NewSimpleClientset(subscription, es)
+	p := newSubscriptionProcessor(fake.NewSimpleClientset(), eventstoreClient, record.NewFakeRecorder(100))
+
+	// the in memory backend lives in each sidecar
+	current := processSubscription(t, p, eventstoreClient, subscription)
+	assert.False(t, current.Status.Ready)
+	assert.Equal(t, "invalid subscription: the backend eventstore.inmemory of Eventstore teststore isn't shared by the sidecars", current.Status.Message)
+
+	es.Spec.Type = "eventstore.azure.tablestorage"
+	_, err := eventstoreClient.EventstoreV1alpha1().Eventstores("default").Update(context.TODO(), es, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+
+	current = processSubscription(t, p, eventstoreClient, current)
+	assert.False(t, current.Status.Ready)
+	assert.Equal(t, "invalid subscription: the journal of Eventstore teststore isn't enabled", current.Status.Message)
+}
@@ -0,0 +1,116 @@
+package operator
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+)
+
+const (
+	signatureHeader = "Eventstore-Signature"
+	deliveryHeader  = "Eventstore-Delivery"
+	webhookTimeout  = 10 * time.Second
+)
+
+// webhookPayload is posted to the url of a subscription
+type webhookPayload struct {
+	Subscription string        `json:"subscription"`
+	Eventstore   string        `json:"eventstore"`
+	Position     int64         `json:"position"`
+	Entity       *store.Entity `json:"entity"`
+}
+
+// webhook delivers the entity versions that match the filter of a subscription to its url.
+// If a secret is set, the payload is signed with HMAC-SHA256 in the header 'Eventstore-Signature'.
+type webhook struct {
+	subscription string
+	eventstore   string
+	url          string
+	filter       v1alpha1.SubscriptionFilter
+	secret       []byte
+	client       *http.Client
+}
+
+func newWebhook(subscription *v1alpha1.EventstoreSubscription, secret []byte) *webhook {
+	return &webhook{
+		subscription: subscription.GetName(),
+		eventstore:   subscription.Spec.Eventstore,
+		url:          subscription.Spec.URL,
+		filter:       subscription.Spec.Filter,
+		secret:       secret,
+		client:       &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+func (w *webhook) matches(entity *store.Entity) bool {
+	if w.filter.IDPrefix != "" && !strings.HasPrefix(entity.ID, w.filter.IDPrefix) {
+		return false
+	}
+
+	if w.filter.EventType != "" && entity.Metadata != w.filter.EventType {
+		return false
+	}
+
+	return true
+}
+
+// deliver is a journal.Handler, entity versions that don't match the filter are skipped
+func (w *webhook) deliver(record journal.Record, entity *store.Entity) error {
+	if !w.matches(entity) {
+		return nil
+	}
+
+	payload, err := json.Marshal(webhookPayload{
+		Subscription: w.subscription,
+		Eventstore:   w.eventstore,
+		Position:     record.Position,
+		Entity:       entity,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(deliveryHeader, strconv.FormatInt(record.Position, 10))
+
+	if len(w.secret) > 0 {
+		req.Header.Set(signatureHeader, sign(w.secret, payload))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned %d", w.url, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// sign returns the signature of payload in the form 'sha256=<hex encoded HMAC>'
+func sign(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
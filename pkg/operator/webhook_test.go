@@ -0,0 +1,94 @@
+package operator
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AndreasM009/eventstore-impl/store"
+	v1alpha1 "github.com/AndreasM009/eventstore/pkg/apis/eventstore/v1alpha1"
+	"github.com/AndreasM009/eventstore/pkg/eventstored/journal"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestSubscription(url string, filter v1alpha1.SubscriptionFilter) *v1alpha1.EventstoreSubscription {
+	return &v1alpha1.EventstoreSubscription{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders"},
+		Spec: v1alpha1.EventstoreSubscriptionSpec{
+			Eventstore: "teststore",
+			URL:        url,
+			Filter:     filter,
+		},
+	}
+}
+
+func TestWebhookDeliversSignedPayload(t *testing.T) {
+	var body []byte
+	var header http.Header
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer server.Close()
+
+	hook := newWebhook(newTestSubscription(server.URL, v1alpha1.SubscriptionFilter{}), []byte("secret"))
+
+	ety := &store.Entity{ID: "order-1", Version: 2, Data: "data"}
+	assert.Nil(t, hook.deliver(journal.Record{Position: 7, ID: "order-1", Version: 2}, ety))
+
+	payload := webhookPayload{}
+	assert.Nil(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, "orders", payload.Subscription)
+	assert.Equal(t, "teststore", payload.Eventstore)
+	assert.Equal(t, int64(7), payload.Position)
+	assert.Equal(t, "order-1", payload.Entity.ID)
+
+	assert.Equal(t, "7", header.Get(deliveryHeader))
+	assert.Equal(t, sign([]byte("secret"), body), header.Get(signatureHeader))
+}
+
+func TestWebhookFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	hook := newWebhook(newTestSubscription(server.URL, v1alpha1.SubscriptionFilter{}), nil)
+
+	err := hook.deliver(journal.Record{Position: 1}, &store.Entity{ID: "order-1", Version: 1})
+	assert.NotNil(t, err)
+}
+
+func TestWebhookFilter(t *testing.T) {
+	hook := newWebhook(newTestSubscription("", v1alpha1.SubscriptionFilter{IDPrefix: "order-", EventType: "created"}), nil)
+
+	assert.True(t, hook.matches(&store.Entity{ID: "order-1", Metadata: "created"}))
+	assert.False(t, hook.matches(&store.Entity{ID: "customer-1", Metadata: "created"}))
+	assert.False(t, hook.matches(&store.Entity{ID: "order-1", Metadata: "shipped"}))
+
+	// filtered entity versions are skipped without delivery
+	assert.Nil(t, hook.deliver(journal.Record{Position: 1}, &store.Entity{ID: "customer-1"}))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	backoff, maxAttempts, err := retryPolicyOf(v1alpha1.RetryPolicy{})
+	assert.Nil(t, err)
+	assert.Equal(t, journal.DefaultBackoff, backoff)
+	assert.Equal(t, defaultMaxAttempts, maxAttempts)
+
+	backoff, maxAttempts, err = retryPolicyOf(v1alpha1.RetryPolicy{MaxAttempts: 3, MinBackoff: "1s", MaxBackoff: "1m"})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, maxAttempts)
+	assert.Equal(t, "1s", backoff.Min.String())
+	assert.Equal(t, "1m0s", backoff.Max.String())
+
+	_, _, err = retryPolicyOf(v1alpha1.RetryPolicy{MinBackoff: "1m", MaxBackoff: "1s"})
+	assert.NotNil(t, err)
+
+	_, _, err = retryPolicyOf(v1alpha1.RetryPolicy{MinBackoff: "soon"})
+	assert.NotNil(t, err)
+}
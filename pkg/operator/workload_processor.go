@@ -0,0 +1,305 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// workload is a Deployment, StatefulSet, DaemonSet or ReplicaSet. Its pods get a service for their
+// sidecars, the annotations of its pod template configure it.
+type workload struct {
+	metav1.Object
+	kind        string
+	selector    *metav1.LabelSelector
+	annotations map[string]string
+}
+
+// workloadOf returns the workload of obj. ok is false for other objects and for ReplicaSets of
+// Deployments, their Deployment has the service.
+func workloadOf(obj interface{}) (w *workload, ok bool) {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return &workload{Object: o, kind: "Deployment", selector: o.Spec.Selector, annotations: o.Spec.Template.Annotations}, true
+	case *appsv1.StatefulSet:
+		return &workload{Object: o, kind: "StatefulSet", selector: o.Spec.Selector, annotations: o.Spec.Template.Annotations}, true
+	case *appsv1.DaemonSet:
+		return &workload{Object: o, kind: "DaemonSet", selector: o.Spec.Selector, annotations: o.Spec.Template.Annotations}, true
+	case *appsv1.ReplicaSet:
+		if owner := metav1.GetControllerOf(o); owner != nil && owner.Kind == "Deployment" {
+			return nil, false
+		}
+
+		return &workload{Object: o, kind: "ReplicaSet", selector: o.Spec.Selector, annotations: o.Spec.Template.Annotations}, true
+	case cache.DeletedFinalStateUnknown:
+		return workloadOf(o.Obj)
+	default:
+		return nil, false
+	}
+}
+
+func (w *workload) String() string {
+	return fmt.Sprintf("%s '%s' in namespace '%s'", w.kind, w.GetName(), w.GetNamespace())
+}
+
+// object returns the Deployment, StatefulSet, DaemonSet or ReplicaSet of w, the Events of its
+// service are recorded on it
+func (w *workload) object() runtime.Object {
+	return w.Object.(runtime.Object)
+}
+
+type workloadProcessor struct {
+	kubeClient kubernetes.Interface
+	recorder   record.EventRecorder
+}
+
+// newWorkloadProcessor creates a Processor for Deployments, StatefulSets, DaemonSets and ReplicaSets
+func newWorkloadProcessor(kubeClient kubernetes.Interface, recorder record.EventRecorder) Processor {
+	return &workloadProcessor{
+		kubeClient: kubeClient,
+		recorder:   recorder,
+	}
+}
+
+// ProcessChanged reconciles the service of the sidecars of a workload: it's created or updated
+// while the workload has eventstore enabled and deleted when it's disabled. Services of a former
+// appid are deleted, too.
+func (p *workloadProcessor) ProcessChanged(obj interface{}) error {
+	w, ok := workloadOf(obj)
+	if !ok {
+		return nil
+	}
+
+	desired := p.desiredService(w)
+
+	owned, err := p.ownedServices(w)
+	if err != nil {
+		return err
+	}
+
+	for i := range owned {
+		service := &owned[i]
+		if desired == nil || service.GetName() != desired.GetName() {
+			if err := p.deleteService(service, w); err != nil {
+				return err
+			}
+		}
+	}
+
+	if desired == nil {
+		if isEventstoreEnabled(w.annotations) {
+			p.recorder.Eventf(w.object(), corev1.EventTypeWarning, ReasonAppIDMissing,
+				"Skipping creation of the sidecar service, annotation %s is empty", eventstoreAppID)
+		}
+
+		return nil
+	}
+
+	services := p.kubeClient.CoreV1().Services(w.GetNamespace())
+
+	current, err := services.Get(context.TODO(), desired.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if _, err := services.Create(context.TODO(), desired, metav1.CreateOptions{}); err != nil {
+			p.recorder.Eventf(w.object(), corev1.EventTypeWarning, ReasonServiceFailed, "Creating service %s failed: %s", desired.GetName(), err)
+			return fmt.Errorf("creating service for %s failed: %s", w, err)
+		}
+
+		p.recorder.Eventf(w.object(), corev1.EventTypeNormal, ReasonServiceCreated, "Created service %s", desired.GetName())
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("getting service %s failed: %s", desired.GetName(), err)
+	}
+
+	if owner := metav1.GetControllerOf(current); owner != nil && owner.UID != w.GetUID() {
+		p.recorder.Eventf(w.object(), corev1.EventTypeWarning, ReasonServiceConflict,
+			"Service %s is controlled by %s %s, it's left unchanged", current.GetName(), owner.Kind, owner.Name)
+		return nil
+	}
+
+	if !serviceDrifted(current, desired) {
+		return nil
+	}
+
+	// services created before they were owned by their workload are adopted
+	updated := current.DeepCopy()
+	updated.Spec.Selector = desired.Spec.Selector
+	updated.Spec.Ports = desired.Spec.Ports
+	updated.SetOwnerReferences(desired.GetOwnerReferences())
+
+	if updated.Labels == nil {
+		updated.Labels = map[string]string{}
+	}
+
+	updated.Labels[eventstoreEnabledKey] = "true"
+
+	if _, err := services.Update(context.TODO(), updated, metav1.UpdateOptions{}); err != nil {
+		p.recorder.Eventf(w.object(), corev1.EventTypeWarning, ReasonServiceFailed, "Updating service %s failed: %s", updated.GetName(), err)
+		return fmt.Errorf("updating service %s for %s failed: %s", updated.GetName(), w, err)
+	}
+
+	p.recorder.Eventf(w.object(), corev1.EventTypeNormal, ReasonServiceUpdated, "Updated service %s", desired.GetName())
+	return nil
+}
+
+// ProcessDeleted relies on the garbage collection of the owned services. Services created before
+// they were owned are deleted.
+func (p *workloadProcessor) ProcessDeleted(obj interface{}) error {
+	w, ok := workloadOf(obj)
+	if !ok {
+		return nil
+	}
+
+	appID := getAppID(w.annotations)
+	if appID == "" {
+		return nil
+	}
+
+	servicename := fmt.Sprintf("%s-eventstore", appID)
+
+	service, err := p.kubeClient.CoreV1().Services(w.GetNamespace()).Get(context.TODO(), servicename, metav1.GetOptions{})
+	if err != nil || len(service.GetOwnerReferences()) != 0 || service.Labels[eventstoreEnabledKey] != "true" {
+		return nil
+	}
+
+	return p.deleteService(service, w)
+}
+
+// desiredService returns the service of the sidecars of w, or nil if it shouldn't have one
+func (p *workloadProcessor) desiredService(w *workload) *corev1.Service {
+	if !isEventstoreEnabled(w.annotations) {
+		return nil
+	}
+
+	appID := getAppID(w.annotations)
+	if appID == "" || w.selector == nil {
+		return nil
+	}
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-eventstore", appID),
+			Namespace: w.GetNamespace(),
+			Labels:    map[string]string{eventstoreEnabledKey: "true"},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(w, appsv1.SchemeGroupVersion.WithKind(w.kind)),
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: w.selector.MatchLabels,
+			Ports: []corev1.ServicePort{
+				{
+					Protocol:   corev1.ProtocolTCP,
+					Port:       80,
+					TargetPort: intstr.FromInt(getSidecarPort(w.annotations)),
+					Name:       httpPortName,
+				},
+			},
+		},
+	}
+}
+
+// ownedServices returns the eventstore services controlled by w
+func (p *workloadProcessor) ownedServices(w *workload) ([]corev1.Service, error) {
+	services, err := p.kubeClient.CoreV1().Services(w.GetNamespace()).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{eventstoreEnabledKey: "true"}).String(),
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("listing services of %s failed: %s", w, err)
+	}
+
+	result := []corev1.Service{}
+	for _, s := range services.Items {
+		if owner := metav1.GetControllerOf(&s); owner != nil && owner.UID == w.GetUID() {
+			result = append(result, s)
+		}
+	}
+
+	return result, nil
+}
+
+func (p *workloadProcessor) deleteService(service *corev1.Service, w *workload) error {
+	err := p.kubeClient.CoreV1().Services(service.GetNamespace()).Delete(context.TODO(), service.GetName(), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed deleting service %s of %s: %s", service.GetName(), w, err)
+	}
+
+	p.recorder.Eventf(w.object(), corev1.EventTypeNormal, ReasonServiceDeleted, "Deleted service %s", service.GetName())
+	return nil
+}
+
+// serviceDrifted checks if the fields of current that are reconciled differ from desired
+func serviceDrifted(current, desired *corev1.Service) bool {
+	if current.Labels[eventstoreEnabledKey] != "true" {
+		return true
+	}
+
+	if !reflect.DeepEqual(current.GetOwnerReferences(), desired.GetOwnerReferences()) {
+		return true
+	}
+
+	if !reflect.DeepEqual(current.Spec.Selector, desired.Spec.Selector) || len(current.Spec.Ports) != len(desired.Spec.Ports) {
+		return true
+	}
+
+	for i, port := range desired.Spec.Ports {
+		c := current.Spec.Ports[i]
+		if c.Name != port.Name || c.Protocol != port.Protocol || c.Port != port.Port || c.TargetPort != port.TargetPort {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isEventstoreEnabled(annotations map[string]string) bool {
+	enabled, ok := annotations[eventstoreEnabledKey]
+
+	if !ok {
+		return false
+	}
+
+	switch strings.ToLower(enabled) {
+	case "y", "yes", "true", "on", "1":
+		return true
+	default:
+		return false
+	}
+}
+
+func getAppID(annotations map[string]string) string {
+	if id, ok := annotations[eventstoreAppID]; ok {
+		return id
+	}
+
+	return ""
+}
+
+func getSidecarPort(annotations map[string]string) int {
+	if port, ok := annotations[eventstorePortKey]; ok {
+		portnumber, err := strconv.Atoi(port)
+		if err != nil {
+			return evenstoreDefaultPort
+		}
+
+		return portnumber
+	}
+
+	return evenstoreDefaultPort
+}
@@ -0,0 +1,265 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func testDeployment(annotations map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+			UID:       "deployment-uid",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "app"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Annotations: annotations},
+			},
+		},
+	}
+}
+
+func enabledAnnotations() map[string]string {
+	return map[string]string{
+		eventstoreEnabledKey: "true",
+		eventstoreAppID:      "myapp",
+	}
+}
+
+func getService(t *testing.T, client *fake.Clientset, name string) *corev1.Service {
+	service, err := client.CoreV1().Services("default").Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+
+	return service
+}
+
+func TestWorkloadProcessorCreatesOwnedService(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	p := newWorkloadProcessor(client, record.NewFakeRecorder(100))
+
+	assert.Nil(t, p.ProcessChanged(testDeployment(enabledAnnotations())))
+
+	service := getService(t, client, "myapp-eventstore")
+	assert.NotNil(t, service)
+	assert.Equal(t, "true", service.Labels[eventstoreEnabledKey])
+	assert.Equal(t, map[string]string{"app": "app"}, service.Spec.Selector)
+	assert.Equal(t, intstr.FromInt(evenstoreDefaultPort), service.Spec.Ports[0].TargetPort)
+
+	owner := metav1.GetControllerOf(service)
+	assert.NotNil(t, owner)
+	assert.Equal(t, "Deployment", owner.Kind)
+	assert.Equal(t, "app", owner.Name)
+	assert.Equal(t, "deployment-uid", string(owner.UID))
+}
+
+func TestWorkloadProcessorUpdatesDriftedService(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	p := newWorkloadProcessor(client, record.NewFakeRecorder(100))
+
+	assert.Nil(t, p.ProcessChanged(testDeployment(enabledAnnotations())))
+
+	annotations := enabledAnnotations()
+	annotations[eventstorePortKey] = "7000"
+	deployment := testDeployment(annotations)
+	deployment.Spec.Selector.MatchLabels = map[string]string{"app": "app", "tier": "backend"}
+
+	assert.Nil(t, p.ProcessChanged(deployment))
+
+	service := getService(t, client, "myapp-eventstore")
+	assert.Equal(t, intstr.FromInt(7000), service.Spec.Ports[0].TargetPort)
+	assert.Equal(t, map[string]string{"app": "app", "tier": "backend"}, service.Spec.Selector)
+}
+
+func TestWorkloadProcessorKeepsUnchangedService(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	p := newWorkloadProcessor(client, record.NewFakeRecorder(100))
+
+	assert.Nil(t, p.ProcessChanged(testDeployment(enabledAnnotations())))
+	client.ClearActions()
+
+	assert.Nil(t, p.ProcessChanged(testDeployment(enabledAnnotations())))
+
+	for _, a := range client.Actions() {
+		assert.NotContains(t, []string{"create", "update", "delete"}, a.GetVerb())
+	}
+}
+
+func TestWorkloadProcessorDeletesServiceWhenDisabled(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	p := newWorkloadProcessor(client, record.NewFakeRecorder(100))
+
+	assert.Nil(t, p.ProcessChanged(testDeployment(enabledAnnotations())))
+
+	annotations := enabledAnnotations()
+	annotations[eventstoreEnabledKey] = "false"
+	assert.Nil(t, p.ProcessChanged(testDeployment(annotations)))
+
+	assert.Nil(t, getService(t, client, "myapp-eventstore"))
+}
+
+func TestWorkloadProcessorReplacesServiceOfFormerAppID(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	p := newWorkloadProcessor(client, record.NewFakeRecorder(100))
+
+	assert.Nil(t, p.ProcessChanged(testDeployment(enabledAnnotations())))
+
+	annotations := enabledAnnotations()
+	annotations[eventstoreAppID] = "otherapp"
+	assert.Nil(t, p.ProcessChanged(testDeployment(annotations)))
+
+	assert.Nil(t, getService(t, client, "myapp-eventstore"))
+	assert.NotNil(t, getService(t, client, "otherapp-eventstore"))
+}
+
+func TestWorkloadProcessorAdoptsExistingService(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myapp-eventstore",
+			Namespace: "default",
+			Labels:    map[string]string{eventstoreEnabledKey: "true"},
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: "10.0.0.1",
+			Selector:  map[string]string{"app": "app"},
+			Ports: []corev1.ServicePort{
+				{Protocol: corev1.ProtocolTCP, Port: 80, TargetPort: intstr.FromInt(evenstoreDefaultPort), Name: httpPortName},
+			},
+		},
+	})
+
+	p := newWorkloadProcessor(client, record.NewFakeRecorder(100))
+	assert.Nil(t, p.ProcessChanged(testDeployment(enabledAnnotations())))
+
+	service := getService(t, client, "myapp-eventstore")
+	assert.NotNil(t, metav1.GetControllerOf(service))
+	assert.Equal(t, "10.0.0.1", service.Spec.ClusterIP)
+}
+
+func TestWorkloadProcessorSkipsServiceOfOtherController(t *testing.T) {
+	other := testDeployment(enabledAnnotations())
+	other.Name = "other"
+	other.UID = "other-uid"
+
+	client := fake.NewSimpleClientset()
+	p := newWorkloadProcessor(client, record.NewFakeRecorder(100))
+
+	assert.Nil(t, p.ProcessChanged(other))
+	assert.Nil(t, p.ProcessChanged(testDeployment(enabledAnnotations())))
+
+	service := getService(t, client, "myapp-eventstore")
+	assert.Equal(t, "other", metav1.GetControllerOf(service).Name)
+}
+
+func TestWorkloadProcessorDeletesUnownedServiceOnDeletion(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myapp-eventstore",
+			Namespace: "default",
+			Labels:    map[string]string{eventstoreEnabledKey: "true"},
+		},
+	})
+
+	p := newWorkloadProcessor(client, record.NewFakeRecorder(100))
+	assert.Nil(t, p.ProcessDeleted(testDeployment(enabledAnnotations())))
+
+	assert.Nil(t, getService(t, client, "myapp-eventstore"))
+}
+
+func TestWorkloadProcessorCreatesServiceOfStatefulSet(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	p := newWorkloadProcessor(client, record.NewFakeRecorder(100))
+
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", UID: "statefulset-uid"},
+		Spec: appsv1.StatefulSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Annotations: enabledAnnotations()},
+			},
+		},
+	}
+
+	assert.Nil(t, p.ProcessChanged(statefulSet))
+
+	service := getService(t, client, "myapp-eventstore")
+	assert.Equal(t, map[string]string{"app": "db"}, service.Spec.Selector)
+
+	owner := metav1.GetControllerOf(service)
+	assert.Equal(t, "StatefulSet", owner.Kind)
+	assert.Equal(t, "apps/v1", owner.APIVersion)
+	assert.Equal(t, "statefulset-uid", string(owner.UID))
+}
+
+func TestWorkloadProcessorCreatesServiceOfDaemonSet(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	p := newWorkloadProcessor(client, record.NewFakeRecorder(100))
+
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "default", UID: "daemonset-uid"},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "agent"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Annotations: enabledAnnotations()},
+			},
+		},
+	}
+
+	assert.Nil(t, p.ProcessChanged(daemonSet))
+	assert.Equal(t, "DaemonSet", metav1.GetControllerOf(getService(t, client, "myapp-eventstore")).Kind)
+}
+
+func TestWorkloadProcessorSkipsReplicaSetOfDeployment(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	p := newWorkloadProcessor(client, record.NewFakeRecorder(100))
+
+	deployment := testDeployment(enabledAnnotations())
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app-5d4f",
+			Namespace: "default",
+			UID:       "replicaset-uid",
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(deployment, appsv1.SchemeGroupVersion.WithKind("Deployment")),
+			},
+		},
+		Spec: appsv1.ReplicaSetSpec{
+			Selector: deployment.Spec.Selector,
+			Template: deployment.Spec.Template,
+		},
+	}
+
+	assert.Nil(t, p.ProcessChanged(replicaSet))
+	assert.Nil(t, getService(t, client, "myapp-eventstore"))
+
+	replicaSet.OwnerReferences = nil
+	assert.Nil(t, p.ProcessChanged(replicaSet))
+	assert.Equal(t, "ReplicaSet", metav1.GetControllerOf(getService(t, client, "myapp-eventstore")).Kind)
+}
+
+func TestWorkloadProcessorHandlesTombstones(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myapp-eventstore",
+			Namespace: "default",
+			Labels:    map[string]string{eventstoreEnabledKey: "true"},
+		},
+	})
+
+	p := newWorkloadProcessor(client, record.NewFakeRecorder(100))
+	assert.Nil(t, p.ProcessDeleted(cache.DeletedFinalStateUnknown{Key: "default/app", Obj: testDeployment(enabledAnnotations())}))
+
+	assert.Nil(t, getService(t, client, "myapp-eventstore"))
+}
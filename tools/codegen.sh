@@ -13,5 +13,5 @@ CODEGEN_PKG=${CODEGEN_PKG:-$(cd "${SCRIPT_ROOT}"; ls -d -1 ./vendor/k8s.io/code-
 
 bash ".${CODEGEN_PKG}"/generate-groups.sh "deepcopy,client,informer,lister" \
   "github.com/AndreasM009/eventstore/pkg/client" "github.com/AndreasM009/eventstore/pkg/apis" \
-  "eventstore:v1alpha1" \
+  "eventstore:v1alpha1,v1beta1" \
   --go-header-file ./boilerplate.go.txt
\ No newline at end of file